@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
@@ -12,6 +13,30 @@ import (
 )
 
 func main() {
+	// Load configurations
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Commands run once instead of serving
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gc":
+			err = api.RunGC(cfg, os.Args[2:])
+		case "storage":
+			err = api.RunStorage(cfg, os.Args[2:])
+		case "session":
+			err = api.RunSession(cfg, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	env := strings.ToLower(os.Getenv("ENVIRONMENT"))
 
 	// pprof web server. See: https://golang.org/pkg/net/http/pprof/
@@ -23,13 +48,8 @@ func main() {
 		}()
 	}
 
-	// Load configurations and initialize the API server
-	_, err := config.Load()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = api.Run()
+	// Initialize the API server
+	err = api.Run(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
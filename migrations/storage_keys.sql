@@ -0,0 +1,71 @@
+-- Replaces the URLs of the objects of raw photos, photos and photo variants
+-- with their keys in the storage backend, so the base URL of the storage can
+-- change without rewriting rows. Keys are backfilled from the URLs, and rows
+-- are assigned to the backend with the given ID, matching the storage
+-- backend_id setting:
+--
+--   psql -v ON_ERROR_STOP=1 -v backend=default -f migrations/storage_keys.sql
+--
+-- The migration runs in a single transaction, and fails without changing
+-- anything if a URL doesn't end in a key of the storage layout.
+
+\if :{?backend}
+\else
+\set backend default
+\endif
+
+begin;
+
+alter table raw_photos
+    add column storage_backend varchar(50),
+    add column storage_key     varchar(500);
+
+-- Raw photos are stored under raw/<user>/<hash><ext>, or under
+-- quarantine/<user>/<hash> while they're scanned
+update raw_photos
+set storage_backend = :'backend',
+    storage_key     = substring(storage_url from '((?:raw|quarantine)/[^/]+/[^/]+)$');
+
+alter table raw_photos
+    alter column storage_backend set not null,
+    alter column storage_key set not null,
+    drop column storage_url;
+
+alter table photo_variants
+    add column storage_backend varchar(50);
+
+update photo_variants
+set storage_backend = :'backend';
+
+alter table photos
+    add column storage_backend varchar(50),
+    add column original_key    varchar(500),
+    add column thumbnail_key   varchar(500);
+
+-- Originals are the objects of raw photos, and thumbnails the objects of
+-- variants, stored under photos/<id>/<name>.<version><ext>. Photos without
+-- variants have no thumbnail.
+update photos p
+set storage_backend = :'backend',
+    original_key    = substring(p.original_url from '((?:raw|quarantine)/[^/]+/[^/]+)$'),
+    thumbnail_key   = coalesce(
+            (select v.storage_key
+             from photo_variants v
+             where v.photo_id = p.id
+               and v.storage_url = p.thumbnail_url
+             limit 1),
+            substring(p.thumbnail_url from '(photos/[^/]+/[^/]+)$'),
+            case when p.thumbnail_url = '' then '' end);
+
+alter table photos
+    alter column storage_backend set not null,
+    alter column original_key set not null,
+    alter column thumbnail_key set not null,
+    drop column original_url,
+    drop column thumbnail_url;
+
+alter table photo_variants
+    alter column storage_backend set not null,
+    drop column storage_url;
+
+commit;
@@ -5,6 +5,11 @@ create table users
     email VARCHAR(255) UNIQUE,
     profile_image_url TEXT,
     bio TEXT,
+    -- How the location in the EXIF data of uploaded photos is kept: strip,
+    -- city (rounded) or keep
+    location_privacy varchar(10) default 'strip' not null
+        constraint users_location_privacy_check
+            check (location_privacy in ('strip', 'city', 'keep')),
     created_at TIMESTAMP,
     updated_at TIMESTAMP,
     constraint users_pk
@@ -16,22 +21,82 @@ create table raw_photos
     id                uuid default gen_random_uuid()         not null,
     user_id           uuid                                   not null,
     original_filename varchar(255)                           not null,
-    storage_url       varchar(500)                           not null,
+    -- Objects are stored under their key in the storage backend with the ID,
+    -- and their URLs are resolved when responding
+    storage_backend   varchar(50)                            not null,
+    storage_key       varchar(500)                           not null,
     file_size         bigint                                 not null,
     mime_type         varchar(100)                           not null,
-    md5_hash          varchar(32)                            not null,
+    md5_hash          varchar(32)                            not null, -- compared against S3 ETags
+    sha256_hash       varchar(64)                            not null,
     width             integer,
     height            integer,
     exif_data         jsonb,
+    -- Difference hash of the decoded image, compared by Hamming distance to
+    -- find re-saved or resized copies. Hamming distances can't use an index,
+    -- so queries scan the hashed raw photos, of a single user where possible.
+    perceptual_hash   bigint,
     uploaded_at       timestamp with time zone default now() not null,
     processed_at      timestamp with time zone,
     schedule_deletion timestamp with time zone,
+    -- Files rejected when they were decoded are kept for review, stored apart
+    -- from raw photos, and never processed into photos
+    quarantined_at    timestamp with time zone,
+    quarantine_reason varchar(255),
     constraint raw_photos_pk
         primary key (id),
     constraint raw_photos_user_fk
         foreign key (user_id) references users (id),
-    constraint raw_photos_md5_unique
-        unique (md5_hash)
+    constraint raw_photos_user_sha256_unique
+        unique (user_id, sha256_hash),
+    constraint raw_photos_quarantine_check
+        check ((quarantined_at is null) = (quarantine_reason is null))
+);
+
+-- Verdicts of scanning uploaded files for admins to review. Files allowed
+-- without labels aren't recorded.
+create table scan_results
+(
+    id          uuid default gen_random_uuid()         not null,
+    user_id     uuid                                   not null,
+    filename    varchar(255)                           not null,
+    sha256_hash varchar(64)                            not null,
+    scanner     varchar(50)                            not null,
+    verdict     varchar(10)                            not null
+        constraint scan_results_verdict_check
+            check (verdict in ('allow', 'quarantine', 'reject')),
+    labels      text[]                   default '{}'  not null,
+    scanned_at  timestamp with time zone default now() not null,
+    constraint scan_results_pk
+        primary key (id),
+    constraint scan_results_user_fk
+        foreign key (user_id) references users (id)
+);
+
+create index scan_results_scanned_at_idx
+    on scan_results (scanned_at desc);
+
+create table photo_uploads
+(
+    id            uuid default gen_random_uuid()         not null,
+    user_id       uuid                                   not null,
+    filename      varchar(255)                           not null,
+    upload_length bigint                                 not null,
+    upload_offset bigint                   default 0     not null,
+    metadata      jsonb,
+    direct        boolean                  default false not null,
+    mime_type     varchar(100),
+    sha256_hash   varchar(64),
+    raw_photo_id  uuid,
+    created_at    timestamp with time zone default now() not null,
+    expires_at    timestamp with time zone               not null,
+    completed_at  timestamp with time zone,
+    constraint photo_uploads_pk
+        primary key (id),
+    constraint photo_uploads_user_fk
+        foreign key (user_id) references users (id),
+    constraint photo_uploads_raw_photo_fk
+        foreign key (raw_photo_id) references raw_photos (id)
 );
 
 create table photos
@@ -40,25 +105,64 @@ create table photos
     raw_photo_id      uuid                                   not null,
     user_id           uuid                                   not null,
     filename          varchar(255)                           not null,
-    original_url      varchar(500)                           not null,
-    thumbnail_url     varchar(500)                           not null,
+    -- Keys of objects in the storage backend. The original is the "original"
+    -- variant, with the EXIF data stripped, and the thumbnail the first
+    -- variant, empty if there is none. The raw photo keeps the uploaded file.
+    storage_backend   varchar(50)                            not null,
+    original_key      varchar(500)                           not null,
+    thumbnail_key     varchar(500)                           not null,
     caption           text,
     tags              text[],
     file_size         bigint                                 not null,
     mime_type         varchar(100)                           not null,
     width             integer,
     height            integer,
+    -- Placeholder shown while the photo loads, as a BlurHash and #rrggbb color
+    blurhash          varchar(64),
+    dominant_color    varchar(7),
+    -- Location from the EXIF data as kept by the owner's privacy settings,
+    -- in decimal degrees
+    latitude          double precision,
+    longitude         double precision,
+    place_name        varchar(255),
     uploaded_at       timestamp with time zone default now() not null,
     updated_at        timestamp with time zone default now() not null,
     schedule_deletion timestamp with time zone,
     constraint photos_pk
         primary key (id),
+    constraint photos_location_check
+        check ((latitude is null) = (longitude is null)
+            and latitude between -90 and 90 and longitude between -180 and 180),
     constraint photos_raw_photo_fk
         foreign key (raw_photo_id) references raw_photos (id),
     constraint photos_user_fk
         foreign key (user_id) references users (id)
 );
 
+-- Nearby queries narrow photos down to a bounding box before computing
+-- distances
+create index photos_location_idx
+    on photos (latitude, longitude)
+    where latitude is not null and schedule_deletion is null;
+
+-- Resized renditions of photos, defined by the photo variants configuration
+create table photo_variants
+(
+    photo_id        uuid                                   not null,
+    name            varchar(50)                            not null,
+    width           integer                                not null,
+    height          integer                                not null,
+    format          varchar(20)                            not null,
+    storage_backend varchar(50)                            not null,
+    storage_key     varchar(500)                           not null,
+    file_size       bigint                                 not null,
+    created_at      timestamp with time zone default now() not null,
+    constraint photo_variants_pk
+        primary key (photo_id, name, format),
+    constraint photo_variants_photo_fk
+        foreign key (photo_id) references photos (id) on delete cascade
+);
+
 create table photo_likes
 (
     photo_id    UUID REFERENCES photos(id),
@@ -74,4 +178,34 @@ create table photo_comments
     user_id UUID REFERENCES users(id),
     content TEXT,
     created_at TIMESTAMP
-);
\ No newline at end of file
+);
+
+-- Responses of requests made with an Idempotency-Key header, replayed to
+-- retries of the request until the key expires
+create table idempotency_keys
+(
+    user_id          varchar(255)                           not null,
+    key              varchar(255)                           not null,
+    fingerprint      varchar(64)                            not null,
+    status_code      integer,
+    response_headers jsonb,
+    response_body    bytea,
+    created_at       timestamp with time zone default now() not null,
+    expires_at       timestamp with time zone               not null,
+    constraint idempotency_keys_pk
+        primary key (user_id, key)
+);
+
+create index idempotency_keys_expires_at_idx
+    on idempotency_keys (expires_at);
+
+-- Keys of objects the secondary storage of a mirror failed to be written,
+-- copied from the primary storage or deleted from the secondary storage once
+-- repaired
+create table storage_repairs
+(
+    key       varchar(1024)                          not null,
+    queued_at timestamp with time zone default now() not null,
+    constraint storage_repairs_pk
+        primary key (key)
+);
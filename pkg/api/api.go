@@ -1,12 +1,15 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -15,12 +18,22 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 
+	"jelly/pkg/api/v1/admin"
 	"jelly/pkg/api/v1/gen"
 	"jelly/pkg/api/v1/healthcheck"
 	"jelly/pkg/api/v1/photo"
+	"jelly/pkg/api/v1/user"
 	"jelly/pkg/api/v1/util"
+	"jelly/pkg/cache"
+	"jelly/pkg/cdn"
+	"jelly/pkg/config"
+	"jelly/pkg/kms"
 	"jelly/pkg/pgdb"
+	"jelly/pkg/ratelimit"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
 )
 
 // Check that the Handler implements the generated API interface
@@ -31,20 +44,351 @@ var _ gen.ServerInterface = (*Handler)(nil)
 type Handler struct {
 	healthcheck.HealthHandler
 	photo.PhotoHandler
+	user.UserHandler
+	admin.AdminHandler
 }
 
 // NewHandler creates a new Handler instance, initializing the database
-// connection.
-func NewHandler(db *pgdb.Client) Handler {
+// connection. Objects are stored in the storage backend with the ID.
+func NewHandler(db photo.Database, userDB user.Database, adminDB admin.Database, storage store.Storage,
+	backend string, urls *store.URLResolver, c cdn.CDN, scanner scan.Scanner) Handler {
+	// Storage behind a circuit breaker is reported by the health check
+	var health healthcheck.HealthHandler
+	if resilient, ok := store.As[*store.ResilientStorage](storage); ok {
+		health.Storage = resilient
+	}
+
 	return Handler{
-		HealthHandler: healthcheck.HealthHandler{},
-		PhotoHandler:  photo.PhotoHandler{DB: db},
+		HealthHandler: health,
+		PhotoHandler:  photo.PhotoHandler{DB: db, Storage: storage, Backend: backend, URLs: urls, CDN: c, Scanner: scanner},
+		UserHandler:   user.UserHandler{DB: userDB},
+		AdminHandler:  admin.AdminHandler{DB: adminDB},
+	}
+}
+
+// NewStorage creates the storage backend selected by the configuration. S3
+// operations are retried and go through a circuit breaker, objects are
+// mirrored to a secondary backend if a mirror is configured, queueing the
+// objects to repair in the queue or in memory if it's nil, and objects are
+// encrypted if encryption is configured.
+func NewStorage(cfg *config.Config, repairs store.RepairQueue) (store.Storage, error) {
+	private, err := storageAccess(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := NewKMS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		// Encrypted objects are only readable through presigned URLs
+		if !private {
+			return nil, errors.New("storage encryption requires private access")
+		}
+		if cfg.Storage.EncryptionBaseURL == "" {
+			return nil, errors.New("storage encryption requires an encryption base url")
+		}
+	}
+
+	storage, err := newStorage(cfg, private)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects are mirrored as stored, encrypted or not
+	if cfg.Storage.Mirror != "" {
+		secondary, err := NewBackend(cfg, cfg.Storage.Mirror)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage mirror: %w", err)
+		}
+		if repairs == nil {
+			repairs = store.NewMemoryRepairQueue()
+		}
+		// Repairs wait for presigned uploads to complete or expire
+		storage = store.NewMirroredStorage(storage, secondary, repairs, config.GetUploadURLExpiration())
+	}
+
+	if keys == nil {
+		return storage, nil
+	}
+	return store.NewEncryptedStorage(storage, keys, config.GetStorageEncryptionPrefixes(),
+		cfg.Storage.EncryptionBaseURL, []byte(cfg.Storage.EncryptionURLSecret)), nil
+}
+
+// NewBackend creates the storage backend at a URL, with the access of the
+// configuration and without encryption:
+//
+//	local:<path>?base_url=<url>
+//	s3://<bucket>?region=<region>&endpoint=<url>&base_url=<url>
+//
+// Either can have an id parameter, the ID rows refer to the backend by.
+func NewBackend(cfg *config.Config, backend string) (store.Storage, error) {
+	private, err := storageAccess(cfg)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage backend %s: %w", backend, err)
+	}
+
+	// The backend is created like the configured one
+	backendCfg := *cfg
+	backendCfg.Storage.Type = u.Scheme
+	backendCfg.Storage.BaseURL = u.Query().Get("base_url")
+	switch strings.ToLower(u.Scheme) {
+	case "local":
+		backendCfg.Storage.LocalPath = cmp.Or(u.Opaque, u.Path)
+		if backendCfg.Storage.LocalPath == "" {
+			return nil, fmt.Errorf("local storage backend %s has no path", backend)
+		}
+		if backendCfg.Storage.BaseURL == "" {
+			return nil, fmt.Errorf("local storage backend %s has no base_url", backend)
+		}
+	case "s3":
+		backendCfg.Storage.S3Bucket = u.Host
+		backendCfg.Storage.S3Region = cmp.Or(u.Query().Get("region"), cfg.Storage.S3Region)
+		backendCfg.Storage.S3Endpoint = u.Query().Get("endpoint")
+		if backendCfg.Storage.S3Bucket == "" {
+			return nil, fmt.Errorf("s3 storage backend %s has no bucket", backend)
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", u.Scheme)
+	}
+
+	return newStorage(&backendCfg, private)
+}
+
+// backendID returns the ID of the storage backend at a URL, the configured ID
+// unless it has an id parameter.
+func backendID(backend string) string {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return config.GetStorageBackendID()
+	}
+	return cmp.Or(u.Query().Get("id"), config.GetStorageBackendID())
+}
+
+// storageAccess reports whether the configured storage is private.
+func storageAccess(cfg *config.Config) (bool, error) {
+	switch strings.ToLower(cfg.Storage.Access) {
+	case "", "public":
+		return false, nil
+	case "private":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown storage access: %s", cfg.Storage.Access)
+	}
+}
+
+// newStorage creates the backend of the storage.
+func newStorage(cfg *config.Config, private bool) (store.Storage, error) {
+	switch strings.ToLower(cfg.Storage.Type) {
+	case "", "local":
+		local := store.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.BaseURL)
+		local.SetPrivate(private)
+		return local, nil
+	case "s3":
+		client, err := store.NewS3Client(context.Background(), cfg.Storage.S3Region, cfg.Storage.S3Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		remote := store.NewS3Storage(client, cfg.Storage.S3Bucket, cfg.Storage.S3Region)
+		if cfg.Storage.BaseURL != "" {
+			remote = store.NewS3StorageWithCustomURL(client, cfg.Storage.S3Bucket,
+				cfg.Storage.S3Region, cfg.Storage.BaseURL)
+		}
+		remote.SetPrivate(private)
+		return store.NewResilientStorage(remote, store.ResilienceOptions{
+			Timeout:          config.GetStorageTimeout(),
+			Retries:          config.GetStorageRetries(),
+			RetryDelay:       config.GetStorageRetryDelay(),
+			BreakerThreshold: config.GetStorageBreakerThreshold(),
+			BreakerCooldown:  config.GetStorageBreakerCooldown(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+}
+
+// NewKMS creates the KMS holding the master keys of storage encryption, or
+// returns nil if storage isn't encrypted.
+func NewKMS(cfg *config.Config) (kms.KMS, error) {
+	switch strings.ToLower(cfg.Storage.Encryption) {
+	case "", "none":
+		return nil, nil
+	case "key":
+		current, err := base64.StdEncoding.DecodeString(cfg.Storage.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage encryption key: %w", err)
+		}
+		var previous [][]byte
+		for _, encoded := range strings.Split(cfg.Storage.EncryptionPreviousKeys, ",") {
+			if encoded = strings.TrimSpace(encoded); encoded == "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid previous storage encryption key: %w", err)
+			}
+			previous = append(previous, key)
+		}
+		return kms.NewStatic(current, previous...)
+	case "file":
+		if cfg.Storage.EncryptionKeyFile == "" {
+			return nil, errors.New("file storage encryption requires a key file")
+		}
+		return kms.NewFile(cfg.Storage.EncryptionKeyFile)
+	default:
+		return nil, fmt.Errorf("unknown storage encryption: %s", cfg.Storage.Encryption)
+	}
+}
+
+// NewCDN creates the CDN selected by the configuration, or returns nil if
+// there is none. The local CDN serves the storage itself.
+func NewCDN(cfg *config.Config, storage store.Storage) (cdn.CDN, error) {
+	typ := strings.ToLower(cfg.CDN.Type)
+	if typ != "" && typ != "none" && cfg.CDN.BaseURL == "" {
+		return nil, fmt.Errorf("%s cdn requires a base url", typ)
+	}
+
+	switch typ {
+	case "", "none":
+		return nil, nil
+	case "cloudfront":
+		if cfg.CDN.DistributionID == "" || cfg.CDN.KeyPairID == "" {
+			return nil, errors.New("cloudfront cdn requires a distribution id and key pair id")
+		}
+		data, err := os.ReadFile(cfg.CDN.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cdn private key: %w", err)
+		}
+		key, err := cdn.ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		credentials, err := store.NewDefaultCredentials(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return cdn.NewCloudFront(cfg.CDN.BaseURL, cfg.CDN.DistributionID, cfg.CDN.KeyPairID, key, credentials), nil
+	case "hmac":
+		if cfg.CDN.Secret == "" {
+			return nil, errors.New("hmac cdn requires a secret")
+		}
+		return cdn.NewHMAC(cfg.CDN.BaseURL, []byte(cfg.CDN.Secret), cfg.CDN.PurgeURL), nil
+	case "local":
+		return cdn.NewFake(cfg.CDN.BaseURL, storage), nil
+	default:
+		return nil, fmt.Errorf("unknown cdn type: %s", cfg.CDN.Type)
+	}
+}
+
+// NewURLResolver creates the resolver of the URLs of the objects of the
+// storage, under the configured backend ID. Objects of public storage are
+// fetched from their URL in storage, objects of private storage from presigned
+// URLs, and objects behind a CDN from URLs signed for it.
+func NewURLResolver(cfg *config.Config, storage store.Storage, c cdn.CDN) *store.URLResolver {
+	backends := map[string]store.Storage{config.GetStorageBackendID(): storage}
+	expirations := map[store.Class]time.Duration{
+		store.ClassRaw:       config.GetStorageRawURLExpiration(),
+		store.ClassOriginal:  config.GetStorageOriginalURLExpiration(),
+		store.ClassThumbnail: config.GetStorageThumbnailURLExpiration(),
+	}
+
+	switch {
+	case c != nil:
+		return store.NewCDNURLResolver(backends, c, expirations)
+	case strings.EqualFold(cfg.Storage.Access, "private"):
+		return store.NewPresignedURLResolver(backends, expirations)
+	default:
+		return store.NewURLResolver(backends)
+	}
+}
+
+// NewRedisClient creates a client of the configured Redis server, or returns
+// nil if no backend uses Redis.
+func NewRedisClient(cfg *config.Config) (*redis.Client, error) {
+	if !strings.EqualFold(cfg.RateLimit.Backend, "redis") && !strings.EqualFold(cfg.Cache.Backend, "redis") {
+		return nil, nil
+	}
+
+	opts, err := redis.ParseURL(cfg.Redis.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+	return redis.NewClient(opts), nil
+}
+
+// NewLimiter creates the rate limiter backend selected by the configuration.
+func NewLimiter(cfg *config.Config, rdb *redis.Client) (ratelimit.Limiter, error) {
+	switch strings.ToLower(cfg.RateLimit.Backend) {
+	case "", "memory":
+		return ratelimit.NewMemory(), nil
+	case "redis":
+		return ratelimit.NewRedis(rdb), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend: %s", cfg.RateLimit.Backend)
+	}
+}
+
+// NewCache creates the cache backend selected by the configuration.
+func NewCache(cfg *config.Config, rdb *redis.Client) (cache.Cache, error) {
+	switch strings.ToLower(cfg.Cache.Backend) {
+	case "none":
+		return cache.Noop{}, nil
+	case "", "memory":
+		return cache.NewLRU(config.GetCacheSize()), nil
+	case "redis":
+		return cache.NewRedis(rdb), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Cache.Backend)
+	}
+}
+
+// NewScanner creates the scanner of uploaded files selected by the
+// configuration.
+func NewScanner(cfg *config.Config) (scan.Scanner, error) {
+	switch strings.ToLower(cfg.Scan.Backend) {
+	case "", "none":
+		return scan.Noop{}, nil
+	case "clamav":
+		if cfg.Scan.ClamAVAddress == "" {
+			return nil, errors.New("clamav scanner requires an address")
+		}
+		return scan.NewClamAV(cfg.Scan.ClamAVAddress, config.GetScanTimeout()), nil
+	default:
+		return nil, fmt.Errorf("unknown scan backend: %s", cfg.Scan.Backend)
+	}
+}
+
+// idempotentOperations are the routes honoring the Idempotency-Key header.
+var idempotentOperations = []string{
+	"POST /photo",
+	"POST /photo/{id}/like",
+	"POST /photo/{id}/comments",
+}
+
+// deleteExpiredIdempotencyKeys periodically removes expired idempotency keys.
+// Expired keys are reclaimed when reused, this only bounds the table size.
+func deleteExpiredIdempotencyKeys(db *pgdb.Client) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := db.DeleteExpiredIdempotencyKeys(context.Background())
+		if err != nil {
+			slog.Error("Failed to delete expired idempotency keys", "error", err)
+			continue
+		}
+		slog.Debug("Deleted expired idempotency keys", "count", n)
 	}
 }
 
 // Run starts the server, initializing the logger and a handler instance that will be
 // used by the code-generated router.
-func Run() error {
+func Run(cfg *config.Config) error {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	env := strings.ToLower(os.Getenv("ENVIRONMENT"))
 	if env == "local" || env == "dev" {
@@ -57,7 +401,7 @@ func Run() error {
 	var db *pgdb.Client
 	if env != "local" {
 		var err error
-		db, err = pgdb.NewClient()
+		db, err = pgdb.NewClient(cfg.DatabaseURL())
 		if err != nil {
 			return fmt.Errorf("failed to open a db connection: %w", err)
 		}
@@ -66,6 +410,51 @@ func Run() error {
 		}(db)
 	}
 
+	// Without a database, objects to repair are queued in memory
+	var repairs store.RepairQueue
+	if db != nil {
+		repairs = db
+	}
+	storage, err := NewStorage(cfg, repairs)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	c, err := NewCDN(cfg, storage)
+	if err != nil {
+		return fmt.Errorf("failed to create cdn: %w", err)
+	}
+
+	rdb, err := NewRedisClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create redis client: %w", err)
+	}
+	if rdb != nil {
+		defer func(rdb *redis.Client) {
+			_ = rdb.Close()
+		}(rdb)
+	}
+
+	limiter, err := NewLimiter(cfg, rdb)
+	if err != nil {
+		return fmt.Errorf("failed to create rate limiter: %w", err)
+	}
+
+	scanner, err := NewScanner(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scanner: %w", err)
+	}
+
+	// Photos and their counts are read through the cache
+	var photoDB photo.Database = db
+	if db != nil {
+		photoCache, err := NewCache(cfg, rdb)
+		if err != nil {
+			return fmt.Errorf("failed to create cache: %w", err)
+		}
+		photoDB = photo.NewCachedDatabase(db, photoCache, config.GetCacheTTL(), config.GetCacheNegativeTTL())
+	}
+
 	// Base router for page serving
 	baseRouter := http.NewServeMux()
 	baseRouter.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -74,17 +463,60 @@ func Run() error {
 		http.ServeFile(w, r, "templates/index.html")
 	})
 
+	// Local storage has no object server of its own, so serve it and receive
+	// presigned uploads from here
+	if local, ok := store.As[*store.LocalStorage](storage); ok {
+		baseRouter.Handle("/files/", http.StripPrefix("/files/", local))
+	}
+	if encrypted, ok := store.As[*store.EncryptedStorage](storage); ok {
+		baseRouter.Handle("/encrypted/", http.StripPrefix("/encrypted/", encrypted))
+	}
+	if fake, ok := c.(*cdn.Fake); ok {
+		baseRouter.Handle("/cdn/", http.StripPrefix("/cdn/", fake))
+	}
+	if mirrored, ok := store.As[*store.MirroredStorage](storage); ok {
+		go repairMirror(mirrored, config.GetStorageMirrorRepairInterval())
+	}
+
+	// Middlewares are applied in reverse order, so the last one runs first
+	middlewares := []gen.MiddlewareFunc{util.Recovery}
+	if db != nil {
+		middlewares = append(middlewares, util.Idempotency(util.IdempotencyConfig{
+			Store:       db,
+			TTL:         config.GetIdempotencyTTL(),
+			Lease:       config.GetIdempotencyLease(),
+			MaxBodySize: config.GetPhotoMaxFileSizeBytes() + 1<<20, // Room for the other form fields
+			Patterns:    idempotentOperations,
+		}))
+		go deleteExpiredIdempotencyKeys(db)
+		if interval := config.GetGCInterval(); interval > 0 {
+			go collectGarbage(db, storage, c, interval)
+		}
+	}
+	middlewares = append(middlewares,
+		util.RateLimit(util.RateLimitConfig{
+			Limiter:    limiter,
+			Default:    config.GetRateLimitDefault(),
+			Limits:     config.GetRateLimitOperations(),
+			Operations: operations,
+		}),
+		util.Authenticate(util.AuthConfig{
+			SessionSecret: config.GetAuthSessionSecret(),
+			APIKeys:       config.GetAuthAPIKeys(),
+		}),
+		util.LogRequest,
+		middleware.AllowContentEncoding("utf-8"),
+	)
+
+	urls := NewURLResolver(cfg, storage, c)
+
 	// Create a sub-router with the generated OpenAPI spec. Register the API
 	// routes, and strip the `/api` prefix since we don't specify it in the API
 	// spec.
 	h1 := gen.HandlerWithOptions(
-		NewHandler(db), gen.StdHTTPServerOptions{
-			BaseRouter: http.NewServeMux(),
-			Middlewares: []gen.MiddlewareFunc{
-				util.Recovery,
-				util.LogRequest,
-				middleware.AllowContentEncoding("utf-8"),
-			},
+		NewHandler(photoDB, db, db, storage, config.GetStorageBackendID(), urls, c, scanner), gen.StdHTTPServerOptions{
+			BaseRouter:  http.NewServeMux(),
+			Middlewares: middlewares,
 		},
 	)
 	baseRouter.Handle("/api/", http.StripPrefix("/api", h1))
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"jelly/pkg/cdn"
+	"jelly/pkg/config"
+	"jelly/pkg/gc"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// collectGarbage periodically deletes expired photos, raw photos and uploads,
+// and storage objects without a row, purging them from the CDN.
+func collectGarbage(db *pgdb.Client, storage store.Storage, c cdn.CDN, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	collector := &gc.Collector{
+		DB:          db,
+		Storage:     storage,
+		Backend:     config.GetStorageBackendID(),
+		CDN:         c,
+		OrphanAge:   config.GetGCOrphanAge(),
+		ScratchPath: config.GetUploadScratchPath(),
+	}
+	for range ticker.C {
+		report, err := collector.Run(context.Background())
+		if err != nil {
+			slog.Error("Failed to collect garbage", "error", err, "report", report)
+			continue
+		}
+		slog.Info("Collected garbage", "report", report)
+	}
+}
+
+// RunGC runs the `jelly gc` command, collecting garbage once and printing a
+// summary.
+func RunGC(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be deleted without deleting anything")
+	orphanAge := flags.Duration("orphan-age", config.GetGCOrphanAge(),
+		"how old storage objects without a row must be to be deleted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := pgdb.NewClient(cfg.DatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to open a db connection: %w", err)
+	}
+	defer func(db *pgdb.Client) {
+		_ = db.Close()
+	}(db)
+
+	storage, err := NewStorage(cfg, db)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	c, err := NewCDN(cfg, storage)
+	if err != nil {
+		return fmt.Errorf("failed to create cdn: %w", err)
+	}
+
+	collector := &gc.Collector{
+		DB:          db,
+		Storage:     storage,
+		Backend:     config.GetStorageBackendID(),
+		CDN:         c,
+		OrphanAge:   *orphanAge,
+		ScratchPath: config.GetUploadScratchPath(),
+		DryRun:      *dryRun,
+	}
+	report, err := collector.Run(context.Background())
+	fmt.Fprint(os.Stdout, report)
+	if err != nil {
+		return fmt.Errorf("failed to collect garbage: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package api
+
+// operations maps the route patterns of the generated router to the
+// operationIds of the API spec, which rate limits are configured by.
+var operations = map[string]string{
+	"GET /health":                 "healthCheck",
+	"POST /photo":                 "uploadPhoto",
+	"GET /photo/{id}":             "getPhoto",
+	"DELETE /photo/{id}":          "deletePhoto",
+	"PUT /photo/{id}/place":       "updatePhotoPlace",
+	"GET /photo/{id}/similar":     "getSimilarPhotos",
+	"GET /photos/nearby":          "getNearbyPhotos",
+	"POST /photo/{id}/like":       "likePhoto",
+	"POST /photo/{id}/comments":   "createComment",
+	"GET /photo/raw/{id}":         "getRawPhoto",
+	"POST /photo/upload-url":      "createPhotoUploadUrl",
+	"POST /photo/upload-complete": "completePhotoUpload",
+	"GET /user/privacy":           "getPrivacySettings",
+	"PUT /user/privacy":           "updatePrivacySettings",
+	"GET /admin/scans":            "listScanResults",
+	"GET /img/{photo_id}":         "getImage",
+	"OPTIONS /uploads":            "getUploadCapabilities",
+	"POST /uploads":               "createUpload",
+	"HEAD /uploads/{id}":          "getUploadOffset",
+	"PATCH /uploads/{id}":         "patchUpload",
+	"DELETE /uploads/{id}":        "terminateUpload",
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestOperations checks that the operations map matches the API spec, so rate
+// limits configured by operationId apply to the right routes.
+func TestOperations(t *testing.T) {
+	data, err := os.ReadFile("../../config/api.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read API spec: %v", err)
+	}
+
+	// Path items hold parameters besides the operations, so decode lazily
+	var spec struct {
+		Paths map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("Failed to parse API spec: %v", err)
+	}
+
+	count := 0
+	for path, methods := range spec.Paths {
+		for method, node := range methods {
+			var op struct {
+				OperationID string `yaml:"operationId"`
+			}
+			if node.Kind != yaml.MappingNode || node.Decode(&op) != nil || op.OperationID == "" {
+				continue
+			}
+			count++
+
+			pattern := strings.ToUpper(method) + " " + path
+			if got := operations[pattern]; got != op.OperationID {
+				t.Errorf("Expected %s to map to %s, got %q", pattern, op.OperationID, got)
+			}
+		}
+	}
+
+	if count != len(operations) {
+		t.Errorf("Expected %d operations, got %d", count, len(operations))
+	}
+}
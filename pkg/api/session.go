@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+)
+
+// RunSession runs the `jelly session` command, printing the value of a
+// session cookie of a user, for signing in users from scripts and tests.
+func RunSession(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session", flag.ExitOnError)
+	ttl := flags.Duration("ttl", config.GetAuthSessionTTL(), "how long the session is valid")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: jelly session [flags] <user-id>")
+	}
+
+	userID := flags.Arg(0)
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	secret := config.GetAuthSessionSecret()
+	if len(secret) == 0 {
+		return errors.New("sessions require a session secret")
+	}
+
+	fmt.Fprintln(os.Stdout, util.SignSession(secret, userID, time.Now().Add(*ttl)))
+	return nil
+}
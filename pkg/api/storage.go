@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"jelly/pkg/config"
+	"jelly/pkg/kms"
+	"jelly/pkg/migrate"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// repairMirror periodically copies the objects the mirror of storage failed
+// to be written from the primary storage.
+func repairMirror(storage *store.MirroredStorage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := storage.Repair(context.Background())
+		if err != nil {
+			slog.Error("Failed to repair storage mirror", "error", err, "report", report)
+			continue
+		}
+		if report != (store.RepairReport{}) {
+			slog.Info("Repaired storage mirror", "report", report)
+		}
+	}
+}
+
+// RunStorage runs the `jelly storage` commands managing stored objects.
+func RunStorage(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: jelly storage rotate-keys|migrate [flags]")
+	}
+
+	switch args[0] {
+	case "rotate-keys":
+		return runRotateKeys(cfg, args[1:])
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown storage command: %s", args[0])
+	}
+}
+
+// runRotateKeys runs the `jelly storage rotate-keys` command, wrapping the
+// data keys of encrypted objects with the current master key and printing a
+// summary. Previous master keys can be removed once no object needs them.
+func runRotateKeys(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	generate := flags.Bool("generate", false,
+		"generate a new current master key in the key file before rotating")
+	dryRun := flags.Bool("dry-run", false, "report what would be rotated without rotating anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *generate && *dryRun {
+		return errors.New("a key can't be generated in a dry run")
+	}
+
+	if *generate {
+		keys, err := NewKMS(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create kms: %w", err)
+		}
+		file, ok := keys.(*kms.File)
+		if !ok {
+			return errors.New("keys can only be generated with file storage encryption")
+		}
+		id, err := file.Generate()
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Generated master key %s\n", id)
+	}
+
+	// Objects the mirror fails to be written are queued for the servers to
+	// repair
+	var repairs store.RepairQueue
+	if cfg.Storage.Mirror != "" && !*dryRun {
+		db, err := pgdb.NewClient(cfg.DatabaseURL())
+		if err != nil {
+			return fmt.Errorf("failed to open a db connection: %w", err)
+		}
+		defer func(db *pgdb.Client) {
+			_ = db.Close()
+		}(db)
+		repairs = db
+	}
+
+	storage, err := NewStorage(cfg, repairs)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	encrypted, ok := store.As[*store.EncryptedStorage](storage)
+	if !ok {
+		return errors.New("storage isn't encrypted")
+	}
+
+	report, err := encrypted.RotateKeys(context.Background(), *dryRun)
+	fmt.Fprint(os.Stdout, report)
+	if err != nil {
+		return fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	if report.Errors > 0 {
+		return fmt.Errorf("failed to rotate %d objects", report.Errors)
+	}
+
+	return nil
+}
+
+// runMigrate runs the `jelly storage migrate` command, copying all objects
+// from a storage backend to another and rewriting the backend IDs of the rows
+// to the ID of the other backend, then printing a summary. An interrupted migration resumes
+// from the state file when run again.
+func runMigrate(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", "", "URL of the backend to copy objects from, e.g. local:./uploads?base_url=...")
+	to := flags.String("to", "", "URL of the backend to copy objects to, e.g. s3://bucket?region=...")
+	statePath := flags.String("state", "storage-migration.json", "file the progress is saved to")
+	dryRun := flags.Bool("dry-run", false, "report what would be copied without copying anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return errors.New("usage: jelly storage migrate --from <backend> --to <backend> [flags]")
+	}
+
+	// Objects are copied as stored, so encrypted objects stay encrypted
+	src, err := NewBackend(cfg, *from)
+	if err != nil {
+		return fmt.Errorf("failed to create source storage: %w", err)
+	}
+	dst, err := NewBackend(cfg, *to)
+	if err != nil {
+		return fmt.Errorf("failed to create destination storage: %w", err)
+	}
+
+	db, err := pgdb.NewClient(cfg.DatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to open a db connection: %w", err)
+	}
+	defer func(db *pgdb.Client) {
+		_ = db.Close()
+	}(db)
+
+	// Interrupting saves the progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	migrator := &migrate.Migrator{
+		From:        src,
+		To:          dst,
+		FromBackend: backendID(*from),
+		ToBackend:   backendID(*to),
+		DB:          db,
+		State:       *statePath,
+		DryRun:      *dryRun,
+	}
+	report, err := migrator.Run(ctx)
+	fmt.Fprint(os.Stdout, report)
+	if err != nil {
+		return fmt.Errorf("failed to migrate storage: %w", err)
+	}
+	if report.Errors > 0 {
+		return fmt.Errorf("failed to migrate %d objects, run again to resume", report.Errors)
+	}
+
+	return nil
+}
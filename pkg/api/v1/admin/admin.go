@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"context"
+	"crypto/hmac"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+)
+
+// Limits of scan result queries
+const (
+	defaultScanLimit = 20
+	maxScanLimit     = 100
+)
+
+// Database defines the persistence operations used by the admin handlers.
+type Database interface {
+	ListScanResults(ctx context.Context, verdict scan.Verdict, limit, offset int) ([]model.ScanResult, error)
+}
+
+// AdminHandler implements the endpoints of the configured admin users.
+type AdminHandler struct {
+	DB Database
+}
+
+// IsAdmin reports whether the request is made by an admin: a user
+// authenticated by their session among the admin users, or a client with the
+// admin API key.
+func IsAdmin(r *http.Request) bool {
+	if key := config.GetAdminAPIKey(); key != "" {
+		if hmac.Equal([]byte(key), []byte(r.Header.Get(util2.HeaderAPIKey))) {
+			return true
+		}
+	}
+
+	userID := util2.GetUserID(r.Context())
+	return userID != "" && slices.Contains(config.GetAdminUserIDs(), userID)
+}
+
+// ListScanResults lists the verdicts of scanning uploaded files, most recent
+// first.
+// GET /admin/scans
+func (h AdminHandler) ListScanResults(w http.ResponseWriter, r *http.Request, params gen.ListScanResultsParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if !IsAdmin(r) {
+		logger.Info("Scan results without an admin user", "user_id", util2.GetUserID(r.Context()))
+		http.Error(w, util2.ErrMsgAdminRequired, http.StatusForbidden)
+		return
+	}
+
+	var verdict scan.Verdict
+	if params.Verdict != nil {
+		verdict = scan.Verdict(*params.Verdict)
+	}
+	limit, offset := defaultScanLimit, 0
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if params.Offset != nil {
+		offset = *params.Offset
+	}
+	if (verdict != "" && !verdict.Valid()) || limit < 1 || limit > maxScanLimit || offset < 0 {
+		logger.Info("Invalid scan results query", "verdict", verdict, "limit", limit, "offset", offset)
+		http.Error(w, util2.ErrMsgInvalidScanQuery, http.StatusBadRequest)
+		return
+	}
+
+	// One more result than requested tells whether there are more
+	results, err := h.DB.ListScanResults(r.Context(), verdict, limit+1, offset)
+	if err != nil {
+		logger.Error("Failed to list scan results", "error", err, "verdict", verdict)
+		http.Error(w, util2.ErrMsgFailedToListScanResults, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gen.ScanResultsResponse{
+		Results: make([]gen.ScanResult, 0, min(len(results), limit)),
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: len(results) > limit,
+	}
+	for i := range results[:min(len(results), limit)] {
+		resp.Results = append(resp.Results, results[i].ToScanResult())
+	}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
@@ -0,0 +1,120 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package admin
+
+import (
+	"context"
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockDatabase creates a new instance of MockDatabase. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDatabase(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDatabase {
+	mock := &MockDatabase{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDatabase is an autogenerated mock type for the Database type
+type MockDatabase struct {
+	mock.Mock
+}
+
+type MockDatabase_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDatabase) EXPECT() *MockDatabase_Expecter {
+	return &MockDatabase_Expecter{mock: &_m.Mock}
+}
+
+// ListScanResults provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) ListScanResults(ctx context.Context, verdict scan.Verdict, limit int, offset int) ([]model.ScanResult, error) {
+	ret := _mock.Called(ctx, verdict, limit, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListScanResults")
+	}
+
+	var r0 []model.ScanResult
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, scan.Verdict, int, int) ([]model.ScanResult, error)); ok {
+		return returnFunc(ctx, verdict, limit, offset)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, scan.Verdict, int, int) []model.ScanResult); ok {
+		r0 = returnFunc(ctx, verdict, limit, offset)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ScanResult)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, scan.Verdict, int, int) error); ok {
+		r1 = returnFunc(ctx, verdict, limit, offset)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_ListScanResults_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListScanResults'
+type MockDatabase_ListScanResults_Call struct {
+	*mock.Call
+}
+
+// ListScanResults is a helper method to define mock.On call
+//   - ctx context.Context
+//   - verdict scan.Verdict
+//   - limit int
+//   - offset int
+func (_e *MockDatabase_Expecter) ListScanResults(ctx interface{}, verdict interface{}, limit interface{}, offset interface{}) *MockDatabase_ListScanResults_Call {
+	return &MockDatabase_ListScanResults_Call{Call: _e.mock.On("ListScanResults", ctx, verdict, limit, offset)}
+}
+
+func (_c *MockDatabase_ListScanResults_Call) Run(run func(ctx context.Context, verdict scan.Verdict, limit int, offset int)) *MockDatabase_ListScanResults_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 scan.Verdict
+		if args[1] != nil {
+			arg1 = args[1].(scan.Verdict)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		var arg3 int
+		if args[3] != nil {
+			arg3 = args[3].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_ListScanResults_Call) Return(scanResults []model.ScanResult, err error) *MockDatabase_ListScanResults_Call {
+	_c.Call.Return(scanResults, err)
+	return _c
+}
+
+func (_c *MockDatabase_ListScanResults_Call) RunAndReturn(run func(ctx context.Context, verdict scan.Verdict, limit int, offset int) ([]model.ScanResult, error)) *MockDatabase_ListScanResults_Call {
+	_c.Call.Return(run)
+	return _c
+}
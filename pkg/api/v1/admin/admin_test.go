@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+)
+
+const (
+	testAdminID = "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f"
+	testUserID  = "3b8e7d2a-1c4f-4e6a-9b5d-8f7a6c5e4d3b"
+)
+
+func newRequest(userID string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/admin/scans", nil)
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+func verdictPtr(v gen.ListScanResultsParamsVerdict) *gen.ListScanResultsParamsVerdict {
+	return &v
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func testScanResult(verdict scan.Verdict, labels ...string) model.ScanResult {
+	return model.ScanResult{
+		ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		UserID:     testUserID,
+		Filename:   "photo.jpg",
+		SHA256Hash: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Scanner:    "clamav",
+		Verdict:    verdict,
+		Labels:     pq.StringArray(labels),
+		ScannedAt:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	t.Setenv("ADMIN_USER_IDS", testAdminID)
+	t.Setenv("ADMIN_API_KEY", "admin-key")
+
+	tests := []struct {
+		name     string
+		userID   string
+		apiKey   string
+		expected bool
+	}{
+		{name: "admin user", userID: testAdminID, expected: true},
+		{name: "other user", userID: testUserID},
+		{name: "admin api key", apiKey: "admin-key", expected: true},
+		{name: "other api key", userID: testUserID, apiKey: "other-key"},
+		{name: "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(tt.userID)
+			if tt.apiKey != "" {
+				req.Header.Set(util2.HeaderAPIKey, tt.apiKey)
+			}
+			if got := IsAdmin(req); got != tt.expected {
+				t.Errorf("Expected admin %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ListScanResults(t *testing.T) {
+	t.Setenv("ADMIN_USER_IDS", "not-a-user, "+testAdminID)
+
+	tests := []struct {
+		name           string
+		userID         string
+		params         gen.ListScanResultsParams
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+		expectedCount  int
+		expectedMore   bool
+	}{
+		{
+			name:           "no user",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not an admin",
+			userID:         testUserID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid verdict",
+			userID:         testAdminID,
+			params:         gen.ListScanResultsParams{Verdict: verdictPtr("maybe")},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid limit",
+			userID:         testAdminID,
+			params:         gen.ListScanResultsParams{Limit: intPtr(101)},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "database error",
+			userID: testAdminID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().ListScanResults(mock.Anything, scan.Verdict(""), 21, 0).
+					Return(nil, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "all verdicts",
+			userID: testAdminID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().ListScanResults(mock.Anything, scan.Verdict(""), 21, 0).Return([]model.ScanResult{
+					testScanResult(scan.Reject, "Eicar-Test-Signature"),
+					testScanResult(scan.Quarantine),
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:   "by verdict with more",
+			userID: testAdminID,
+			params: gen.ListScanResultsParams{Verdict: verdictPtr(gen.ListScanResultsParamsVerdictReject),
+				Limit: intPtr(1), Offset: intPtr(3)},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().ListScanResults(mock.Anything, scan.Reject, 2, 3).Return([]model.ScanResult{
+					testScanResult(scan.Reject, "Eicar-Test-Signature"),
+					testScanResult(scan.Reject, "Win.Trojan.Agent"),
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+			expectedMore:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := AdminHandler{DB: db}
+			w := httptest.NewRecorder()
+
+			handler.ListScanResults(w, newRequest(tt.userID), tt.params)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.ScanResultsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(resp.Results) != tt.expectedCount || resp.HasMore != tt.expectedMore {
+				t.Errorf("Expected %d results and hasMore %v, got %d and %v",
+					tt.expectedCount, tt.expectedMore, len(resp.Results), resp.HasMore)
+			}
+			if first := resp.Results[0]; first.Verdict != gen.ScanResultVerdictReject ||
+				len(first.Labels) != 1 || first.Labels[0] != "Eicar-Test-Signature" || first.UserId != testUserID {
+				t.Errorf("Unexpected first result %+v", first)
+			}
+			// Results without labels have an empty list
+			if len(resp.Results) > 1 && resp.Results[1].Labels == nil {
+				t.Errorf("Expected empty labels, got nil")
+			}
+		})
+	}
+}
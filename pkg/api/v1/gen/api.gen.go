@@ -14,19 +14,121 @@ import (
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
+// Defines values for PhotoUploadUrlRequestMethod.
+const (
+	PhotoUploadUrlRequestMethodPOST PhotoUploadUrlRequestMethod = "POST"
+	PhotoUploadUrlRequestMethodPUT  PhotoUploadUrlRequestMethod = "PUT"
+)
+
+// Defines values for PhotoUploadUrlResponseMethod.
+const (
+	PhotoUploadUrlResponseMethodPOST PhotoUploadUrlResponseMethod = "POST"
+	PhotoUploadUrlResponseMethodPUT  PhotoUploadUrlResponseMethod = "PUT"
+)
+
+// Defines values for PrivacySettingsLocation.
+const (
+	City  PrivacySettingsLocation = "city"
+	Keep  PrivacySettingsLocation = "keep"
+	Strip PrivacySettingsLocation = "strip"
+)
+
+// Defines values for ScanResultVerdict.
+const (
+	ScanResultVerdictAllow      ScanResultVerdict = "allow"
+	ScanResultVerdictQuarantine ScanResultVerdict = "quarantine"
+	ScanResultVerdictReject     ScanResultVerdict = "reject"
+)
+
+// Defines values for ListScanResultsParamsVerdict.
+const (
+	ListScanResultsParamsVerdictAllow      ListScanResultsParamsVerdict = "allow"
+	ListScanResultsParamsVerdictQuarantine ListScanResultsParamsVerdict = "quarantine"
+	ListScanResultsParamsVerdictReject     ListScanResultsParamsVerdict = "reject"
+)
+
+// Defines values for GetImageParamsFit.
+const (
+	Contain GetImageParamsFit = "contain"
+	Cover   GetImageParamsFit = "cover"
+)
+
+// Defines values for GetImageParamsFmt.
+const (
+	Avif GetImageParamsFmt = "avif"
+	Jpeg GetImageParamsFmt = "jpeg"
+	Png  GetImageParamsFmt = "png"
+	Webp GetImageParamsFmt = "webp"
+)
+
 // BadRequest defines model for BadRequest.
 type BadRequest struct {
 	Message string `json:"message"`
 }
 
+// BlurHash BlurHash (https://blurha.sh) of the photo, decoded into a blurred placeholder shown at the photo's aspect ratio while it loads. Unset for photos processed before placeholders were introduced.
+type BlurHash = string
+
+// Comment defines model for Comment.
+type Comment struct {
+	// Content Text of the comment
+	Content string `json:"content"`
+
+	// CreatedAt Timestamp when the comment was created
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Id Unique identifier for the comment
+	Id string `json:"id"`
+
+	// PhotoId ID of the commented photo
+	PhotoId string `json:"photoId"`
+
+	// UserId ID of the commenting user
+	UserId string `json:"userId"`
+}
+
+// CommentRequest defines model for CommentRequest.
+type CommentRequest struct {
+	// Content Text of the comment
+	Content string `json:"content"`
+}
+
+// CommentResponse defines model for CommentResponse.
+type CommentResponse struct {
+	Comment Comment `json:"comment"`
+	Message *string `json:"message,omitempty"`
+}
+
+// Conflict defines model for Conflict.
+type Conflict struct {
+	Message string `json:"message"`
+}
+
+// ContentTooLarge defines model for ContentTooLarge.
+type ContentTooLarge struct {
+	Message string `json:"message"`
+}
+
+// DominantColor Most common color of the photo, as a flat placeholder background
+type DominantColor = string
+
 // Forbidden defines model for Forbidden.
 type Forbidden struct {
 	Message string `json:"message"`
 }
 
+// Gone defines model for Gone.
+type Gone struct {
+	Message string `json:"message"`
+}
+
 // HealthCheck defines model for HealthCheck.
 type HealthCheck struct {
+	// Status ok, or unavailable if storage is
 	Status string `json:"status"`
+
+	// Storage State of the circuit breaker of storage, closed, open or half-open, if storage is behind one
+	Storage *string `json:"storage,omitempty"`
 }
 
 // InternalServerError defines model for InternalServerError.
@@ -34,6 +136,63 @@ type InternalServerError struct {
 	Message string `json:"message"`
 }
 
+// LikeResponse defines model for LikeResponse.
+type LikeResponse struct {
+	// LikeCount Number of likes of the photo
+	LikeCount int `json:"likeCount"`
+
+	// Liked True if the user likes the photo
+	Liked bool `json:"liked"`
+
+	// PhotoId ID of the liked photo
+	PhotoId string `json:"photoId"`
+}
+
+// NearbyPhoto defines model for NearbyPhoto.
+type NearbyPhoto struct {
+	// Caption Photo caption
+	Caption *string `json:"caption,omitempty"`
+
+	// DistanceKm Distance from the searched location in kilometers
+	DistanceKm float64 `json:"distanceKm"`
+
+	// Id Unique identifier for the photo
+	Id string `json:"id"`
+
+	// Latitude Latitude in decimal degrees
+	Latitude float64 `json:"latitude"`
+
+	// Longitude Longitude in decimal degrees
+	Longitude float64 `json:"longitude"`
+
+	// PlaceName Name of the place the photo was taken at
+	PlaceName *string `json:"placeName,omitempty"`
+
+	// ThumbnailUrl URL to the thumbnail version
+	ThumbnailUrl string `json:"thumbnailUrl"`
+
+	// UploadedAt Timestamp when photo was uploaded
+	UploadedAt time.Time `json:"uploadedAt"`
+
+	// UserId User who uploaded the photo
+	UserId string `json:"userId"`
+}
+
+// NearbyPhotosResponse defines model for NearbyPhotosResponse.
+type NearbyPhotosResponse struct {
+	// HasMore True if more photos are within the radius
+	HasMore bool `json:"hasMore"`
+
+	// Limit Maximum number of photos returned
+	Limit int `json:"limit"`
+
+	// Offset Number of photos skipped
+	Offset int `json:"offset"`
+
+	// Photos Photos nearest first
+	Photos []NearbyPhoto `json:"photos"`
+}
+
 // NotFound defines model for NotFound.
 type NotFound struct {
 	Message string `json:"message"`
@@ -41,9 +200,18 @@ type NotFound struct {
 
 // Photo defines model for Photo.
 type Photo struct {
+	// BlurHash BlurHash (https://blurha.sh) of the photo, decoded into a blurred placeholder shown at the photo's aspect ratio while it loads. Unset for photos processed before placeholders were introduced.
+	BlurHash *BlurHash `json:"blurHash,omitempty"`
+
 	// Caption Photo caption
 	Caption *string `json:"caption,omitempty"`
 
+	// DominantColor Most common color of the photo, as a flat placeholder background
+	DominantColor *DominantColor `json:"dominantColor,omitempty"`
+
+	// Height Photo height in pixels
+	Height *int `json:"height,omitempty"`
+
 	// Id Unique identifier for the photo
 	Id string `json:"id"`
 
@@ -55,13 +223,25 @@ type Photo struct {
 
 	// Url URL to access the uploaded photo
 	Url string `json:"url"`
+
+	// Width Photo width in pixels
+	Width *int `json:"width,omitempty"`
 }
 
 // PhotoDetails defines model for PhotoDetails.
 type PhotoDetails struct {
+	// BlurHash BlurHash (https://blurha.sh) of the photo, decoded into a blurred placeholder shown at the photo's aspect ratio while it loads. Unset for photos processed before placeholders were introduced.
+	BlurHash *BlurHash `json:"blurHash,omitempty"`
+
 	// Caption Photo caption
 	Caption *string `json:"caption,omitempty"`
 
+	// CommentCount Number of comments on the photo
+	CommentCount int `json:"commentCount"`
+
+	// DominantColor Most common color of the photo, as a flat placeholder background
+	DominantColor *DominantColor `json:"dominantColor,omitempty"`
+
 	// FileSize File size in bytes
 	FileSize int64 `json:"fileSize"`
 
@@ -74,12 +254,24 @@ type PhotoDetails struct {
 	// Id Unique identifier for the photo
 	Id string `json:"id"`
 
+	// Latitude Latitude the photo was taken at in decimal degrees, rounded if the owner shares it at city level
+	Latitude *float64 `json:"latitude,omitempty"`
+
+	// LikeCount Number of likes of the photo
+	LikeCount int `json:"likeCount"`
+
+	// Longitude Longitude the photo was taken at in decimal degrees, rounded if the owner shares it at city level
+	Longitude *float64 `json:"longitude,omitempty"`
+
 	// MimeType MIME type of the photo
 	MimeType string `json:"mimeType"`
 
 	// OriginalUrl URL to the original processed photo
 	OriginalUrl string `json:"originalUrl"`
 
+	// PlaceName Name of the place the photo was taken at, set by the owner
+	PlaceName *string `json:"placeName,omitempty"`
+
 	// RawPhotoId Reference to the raw photo
 	RawPhotoId string `json:"rawPhotoId"`
 
@@ -101,6 +293,9 @@ type PhotoDetails struct {
 	// UserId User who uploaded the photo
 	UserId string `json:"userId"`
 
+	// Variants Resized renditions of the photo, e.g. to build a srcset
+	Variants []PhotoVariant `json:"variants"`
+
 	// Width Photo width in pixels
 	Width *int `json:"width,omitempty"`
 }
@@ -111,15 +306,112 @@ type PhotoDetailsResponse struct {
 	Photo   PhotoDetails `json:"photo"`
 }
 
+// PhotoPlace defines model for PhotoPlace.
+type PhotoPlace struct {
+	// PlaceName Name of the place the photo was taken at, cleared if unset or empty
+	PlaceName *string `json:"placeName,omitempty"`
+}
+
+// PhotoUploadCompleteRequest defines model for PhotoUploadCompleteRequest.
+type PhotoUploadCompleteRequest struct {
+	// UploadId ID returned when the upload was created
+	UploadId string `json:"uploadId"`
+}
+
 // PhotoUploadResponse defines model for PhotoUploadResponse.
 type PhotoUploadResponse struct {
-	Message *string `json:"message,omitempty"`
-	Photo   Photo   `json:"photo"`
+	// Duplicate True if the user had already uploaded this photo
+	Duplicate *bool   `json:"duplicate,omitempty"`
+	Message   *string `json:"message,omitempty"`
+	Photo     Photo   `json:"photo"`
+
+	// SimilarPhotos Photos the user already uploaded that look like this one, such as re-saved or resized copies, most similar first
+	SimilarPhotos *[]SimilarPhoto `json:"similarPhotos,omitempty"`
+}
+
+// PhotoUploadUrlRequest defines model for PhotoUploadUrlRequest.
+type PhotoUploadUrlRequest struct {
+	// ContentLength Size of the photo in bytes
+	ContentLength int64 `json:"contentLength"`
+
+	// ContentType MIME type of the photo
+	ContentType string `json:"contentType"`
+
+	// Filename Original filename of the photo
+	Filename string `json:"filename"`
+
+	// Method PUT sends the photo as the request body, POST sends it as the last field of a multipart form, e.g. from an HTML form
+	Method *PhotoUploadUrlRequestMethod `json:"method,omitempty"`
+
+	// Sha256 Hex encoded SHA-256 checksum of the photo
+	Sha256 string `json:"sha256"`
+}
+
+// PhotoUploadUrlRequestMethod PUT sends the photo as the request body, POST sends it as the last field of a multipart form, e.g. from an HTML form
+type PhotoUploadUrlRequestMethod string
+
+// PhotoUploadUrlResponse defines model for PhotoUploadUrlResponse.
+type PhotoUploadUrlResponse struct {
+	// ExpiresAt Time after which the upload request is rejected
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Fields Form fields that must be sent before the file with a POST request
+	Fields *map[string]string `json:"fields,omitempty"`
+
+	// Headers Headers that must be sent with a PUT request
+	Headers *map[string]string `json:"headers,omitempty"`
+
+	// Method HTTP method of the upload request
+	Method PhotoUploadUrlResponseMethod `json:"method"`
+
+	// UploadId ID to complete the upload with
+	UploadId string `json:"uploadId"`
+
+	// Url URL to send the upload request to
+	Url string `json:"url"`
+}
+
+// PhotoUploadUrlResponseMethod HTTP method of the upload request
+type PhotoUploadUrlResponseMethod string
+
+// PhotoVariant defines model for PhotoVariant.
+type PhotoVariant struct {
+	// FileSize File size in bytes
+	FileSize int64 `json:"fileSize"`
+
+	// Format Image format of the variant. Each variant is rendered in the format of the photo and in the configured formats, e.g. webp.
+	Format string `json:"format"`
+
+	// Height Variant height in pixels
+	Height int `json:"height"`
+
+	// Name Name of the variant, `animated` for the animation of animated GIFs
+	Name string `json:"name"`
+
+	// Url URL of the variant
+	Url string `json:"url"`
+
+	// Width Variant width in pixels
+	Width int `json:"width"`
+}
+
+// PreconditionFailed defines model for PreconditionFailed.
+type PreconditionFailed struct {
+	Message string `json:"message"`
 }
 
+// PrivacySettings defines model for PrivacySettings.
+type PrivacySettings struct {
+	// Location How the GPS location in the EXIF data of uploaded photos is kept: `strip` removes it, `city` rounds it to city level and removes the other GPS tags, `keep` keeps it as is
+	Location PrivacySettingsLocation `json:"location"`
+}
+
+// PrivacySettingsLocation How the GPS location in the EXIF data of uploaded photos is kept: `strip` removes it, `city` rounds it to city level and removes the other GPS tags, `keep` keeps it as is
+type PrivacySettingsLocation string
+
 // RawPhotoDetails defines model for RawPhotoDetails.
 type RawPhotoDetails struct {
-	// ExifData EXIF metadata from the photo
+	// ExifData EXIF metadata from the photo, with the location kept as set by the owner's privacy settings. Tags locating or identifying the owner, such as GPS tags and serial numbers, are only returned to the owner.
 	ExifData *map[string]interface{} `json:"exifData,omitempty"`
 
 	// FileSize File size in bytes
@@ -131,7 +423,7 @@ type RawPhotoDetails struct {
 	// Id Unique identifier for the raw photo
 	Id string `json:"id"`
 
-	// Md5Hash MD5 hash of the file
+	// Md5Hash MD5 hash of the file, comparable to the storage ETag
 	Md5Hash string `json:"md5Hash"`
 
 	// MimeType MIME type of the photo
@@ -146,8 +438,11 @@ type RawPhotoDetails struct {
 	// ScheduleDeletion Scheduled deletion timestamp
 	ScheduleDeletion *time.Time `json:"scheduleDeletion,omitempty"`
 
-	// StorageUrl URL to the raw photo in storage
-	StorageUrl string `json:"storageUrl"`
+	// Sha256Hash SHA-256 hash of the file, used to deduplicate uploads per user
+	Sha256Hash string `json:"sha256Hash"`
+
+	// StorageUrl URL to the raw photo in storage, as uploaded with all of its metadata. Only returned to the owner.
+	StorageUrl *string `json:"storageUrl,omitempty"`
 
 	// UploadedAt Timestamp when photo was uploaded
 	UploadedAt time.Time `json:"uploadedAt"`
@@ -165,9 +460,147 @@ type RawPhotoDetailsResponse struct {
 	RawPhoto RawPhotoDetails `json:"rawPhoto"`
 }
 
+// ScanResult defines model for ScanResult.
+type ScanResult struct {
+	Filename string `json:"filename"`
+	Id       string `json:"id"`
+
+	// Labels Labels explaining the verdict, such as the name of the malware found
+	Labels    []string  `json:"labels"`
+	ScannedAt time.Time `json:"scannedAt"`
+
+	// Scanner Name of the scanner
+	Scanner string `json:"scanner"`
+
+	// Sha256 SHA-256 checksum of the file, identifying the raw photo of a quarantined file with the user ID
+	Sha256 string `json:"sha256"`
+
+	// UserId ID of the user who uploaded the file
+	UserId string `json:"userId"`
+
+	// Verdict What was done with the file: `allow` processed it, `quarantine` kept it for review without processing it and `reject` discarded it
+	Verdict ScanResultVerdict `json:"verdict"`
+}
+
+// ScanResultVerdict What was done with the file: `allow` processed it, `quarantine` kept it for review without processing it and `reject` discarded it
+type ScanResultVerdict string
+
+// ScanResultsResponse defines model for ScanResultsResponse.
+type ScanResultsResponse struct {
+	// HasMore True if more results are recorded
+	HasMore bool `json:"hasMore"`
+
+	// Limit Maximum number of results returned
+	Limit int `json:"limit"`
+
+	// Offset Number of results skipped
+	Offset int `json:"offset"`
+
+	// Results Scan results, most recent first
+	Results []ScanResult `json:"results"`
+}
+
+// ServiceUnavailable defines model for ServiceUnavailable.
+type ServiceUnavailable struct {
+	Message string `json:"message"`
+}
+
+// SimilarPhoto defines model for SimilarPhoto.
+type SimilarPhoto struct {
+	// Caption Photo caption
+	Caption *string `json:"caption,omitempty"`
+
+	// Distance Number of bits differing between the perceptual hashes of the photos, 0 for photos that look identical
+	Distance int `json:"distance"`
+
+	// Id Unique identifier for the photo
+	Id string `json:"id"`
+
+	// ThumbnailUrl URL to the thumbnail version
+	ThumbnailUrl string `json:"thumbnailUrl"`
+
+	// UploadedAt Timestamp when photo was uploaded
+	UploadedAt time.Time `json:"uploadedAt"`
+
+	// UserId User who uploaded the photo
+	UserId string `json:"userId"`
+}
+
+// SimilarPhotosResponse defines model for SimilarPhotosResponse.
+type SimilarPhotosResponse struct {
+	// Photos Similar photos, most similar first
+	Photos []SimilarPhoto `json:"photos"`
+}
+
+// TooManyRequests defines model for TooManyRequests.
+type TooManyRequests struct {
+	Message string `json:"message"`
+}
+
+// UnprocessableEntity defines model for UnprocessableEntity.
+type UnprocessableEntity struct {
+	Message string `json:"message"`
+}
+
+// UnsupportedMediaType defines model for UnsupportedMediaType.
+type UnsupportedMediaType struct {
+	Message string `json:"message"`
+}
+
+// IdempotencyKey defines model for Idempotency-Key.
+type IdempotencyKey = string
+
+// TusResumable defines model for Tus-Resumable.
+type TusResumable = string
+
 // InternalError defines model for internal-error.
 type InternalError = InternalServerError
 
+// ListScanResultsParams defines parameters for ListScanResults.
+type ListScanResultsParams struct {
+	// Verdict Only list results with this verdict
+	Verdict *ListScanResultsParamsVerdict `form:"verdict,omitempty" json:"verdict,omitempty"`
+
+	// Limit Maximum number of results to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Offset Number of results to skip
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// ListScanResultsParamsVerdict defines parameters for ListScanResults.
+type ListScanResultsParamsVerdict string
+
+// GetImageParams defines parameters for GetImage.
+type GetImageParams struct {
+	// W Width to fit the image to
+	W *int `form:"w,omitempty" json:"w,omitempty"`
+
+	// H Height to fit the image to
+	H *int `form:"h,omitempty" json:"h,omitempty"`
+
+	// Fit `contain` scales the image to fit within the width and height, `cover` scales and crops it to cover both
+	Fit *GetImageParamsFit `form:"fit,omitempty" json:"fit,omitempty"`
+
+	// Fmt Format to encode the image in, negotiated from the Accept header by default
+	Fmt *GetImageParamsFmt `form:"fmt,omitempty" json:"fmt,omitempty"`
+
+	// Q Quality of lossy formats (jpeg, webp, avif)
+	Q *int `form:"q,omitempty" json:"q,omitempty"`
+
+	// Sig URL-safe base64 HMAC-SHA256 of the photo ID and the other query parameters, formatted as `{photo_id}?{query}` with the parameters sorted by name
+	Sig string `form:"sig" json:"sig"`
+
+	// IfNoneMatch ETag of a previously served image
+	IfNoneMatch *string `json:"If-None-Match,omitempty"`
+}
+
+// GetImageParamsFit defines parameters for GetImage.
+type GetImageParamsFit string
+
+// GetImageParamsFmt defines parameters for GetImage.
+type GetImageParamsFmt string
+
 // UploadPhotoMultipartBody defines parameters for UploadPhoto.
 type UploadPhotoMultipartBody struct {
 	// Caption Optional caption for the photo
@@ -180,23 +613,167 @@ type UploadPhotoMultipartBody struct {
 	Tags *[]string `json:"tags,omitempty"`
 }
 
+// UploadPhotoParams defines parameters for UploadPhoto.
+type UploadPhotoParams struct {
+	// IdempotencyKey Unique key making retries of the request safe. A retry with the same key and body returns the stored response with `Idempotent-Replayed: true`, while the first request is still in progress it is rejected with 409, and a retry with a different body is rejected with 422.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// CreateCommentParams defines parameters for CreateComment.
+type CreateCommentParams struct {
+	// IdempotencyKey Unique key making retries of the request safe. A retry with the same key and body returns the stored response with `Idempotent-Replayed: true`, while the first request is still in progress it is rejected with 409, and a retry with a different body is rejected with 422.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// LikePhotoParams defines parameters for LikePhoto.
+type LikePhotoParams struct {
+	// IdempotencyKey Unique key making retries of the request safe. A retry with the same key and body returns the stored response with `Idempotent-Replayed: true`, while the first request is still in progress it is rejected with 409, and a retry with a different body is rejected with 422.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// GetSimilarPhotosParams defines parameters for GetSimilarPhotos.
+type GetSimilarPhotosParams struct {
+	// MaxDistance Maximum number of bits differing between the perceptual hashes, the configured similarity threshold by default
+	MaxDistance *int `form:"max_distance,omitempty" json:"max_distance,omitempty"`
+
+	// Limit Maximum number of photos to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// GetNearbyPhotosParams defines parameters for GetNearbyPhotos.
+type GetNearbyPhotosParams struct {
+	// Lat Latitude in decimal degrees
+	Lat float64 `form:"lat" json:"lat"`
+
+	// Lon Longitude in decimal degrees
+	Lon float64 `form:"lon" json:"lon"`
+
+	// RadiusKm Radius to search within, in kilometers
+	RadiusKm float64 `form:"radius_km" json:"radius_km"`
+
+	// Limit Maximum number of photos to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Offset Number of photos to skip
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// CreateUploadParams defines parameters for CreateUpload.
+type CreateUploadParams struct {
+	// TusResumable Version of the tus protocol used by the client
+	TusResumable *TusResumable `json:"Tus-Resumable,omitempty"`
+
+	// UploadLength Size of the photo in bytes
+	UploadLength *int64 `json:"Upload-Length,omitempty"`
+
+	// UploadMetadata Comma separated key and base64 value pairs, such as the filename
+	UploadMetadata *string `json:"Upload-Metadata,omitempty"`
+}
+
+// TerminateUploadParams defines parameters for TerminateUpload.
+type TerminateUploadParams struct {
+	// TusResumable Version of the tus protocol used by the client
+	TusResumable *TusResumable `json:"Tus-Resumable,omitempty"`
+}
+
+// GetUploadOffsetParams defines parameters for GetUploadOffset.
+type GetUploadOffsetParams struct {
+	// TusResumable Version of the tus protocol used by the client
+	TusResumable *TusResumable `json:"Tus-Resumable,omitempty"`
+}
+
+// PatchUploadParams defines parameters for PatchUpload.
+type PatchUploadParams struct {
+	// TusResumable Version of the tus protocol used by the client
+	TusResumable *TusResumable `json:"Tus-Resumable,omitempty"`
+
+	// UploadOffset Offset the chunk starts at, which must match the current upload offset
+	UploadOffset *int64 `json:"Upload-Offset,omitempty"`
+}
+
 // UploadPhotoMultipartRequestBody defines body for UploadPhoto for multipart/form-data ContentType.
 type UploadPhotoMultipartRequestBody UploadPhotoMultipartBody
 
+// CompletePhotoUploadJSONRequestBody defines body for CompletePhotoUpload for application/json ContentType.
+type CompletePhotoUploadJSONRequestBody = PhotoUploadCompleteRequest
+
+// CreatePhotoUploadUrlJSONRequestBody defines body for CreatePhotoUploadUrl for application/json ContentType.
+type CreatePhotoUploadUrlJSONRequestBody = PhotoUploadUrlRequest
+
+// CreateCommentJSONRequestBody defines body for CreateComment for application/json ContentType.
+type CreateCommentJSONRequestBody = CommentRequest
+
+// UpdatePhotoPlaceJSONRequestBody defines body for UpdatePhotoPlace for application/json ContentType.
+type UpdatePhotoPlaceJSONRequestBody = PhotoPlace
+
+// UpdatePrivacySettingsJSONRequestBody defines body for UpdatePrivacySettings for application/json ContentType.
+type UpdatePrivacySettingsJSONRequestBody = PrivacySettings
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 
+	// (GET /admin/scans)
+	ListScanResults(w http.ResponseWriter, r *http.Request, params ListScanResultsParams)
+
 	// (GET /health)
 	HealthCheck(w http.ResponseWriter, r *http.Request)
 
+	// (GET /img/{photo_id})
+	GetImage(w http.ResponseWriter, r *http.Request, photoId string, params GetImageParams)
+
 	// (POST /photo)
-	UploadPhoto(w http.ResponseWriter, r *http.Request)
+	UploadPhoto(w http.ResponseWriter, r *http.Request, params UploadPhotoParams)
 
 	// (GET /photo/raw/{id})
 	GetRawPhoto(w http.ResponseWriter, r *http.Request, id string)
 
+	// (POST /photo/upload-complete)
+	CompletePhotoUpload(w http.ResponseWriter, r *http.Request)
+
+	// (POST /photo/upload-url)
+	CreatePhotoUploadUrl(w http.ResponseWriter, r *http.Request)
+
+	// (DELETE /photo/{id})
+	DeletePhoto(w http.ResponseWriter, r *http.Request, id string)
+
 	// (GET /photo/{id})
 	GetPhoto(w http.ResponseWriter, r *http.Request, id string)
+
+	// (POST /photo/{id}/comments)
+	CreateComment(w http.ResponseWriter, r *http.Request, id string, params CreateCommentParams)
+
+	// (POST /photo/{id}/like)
+	LikePhoto(w http.ResponseWriter, r *http.Request, id string, params LikePhotoParams)
+
+	// (PUT /photo/{id}/place)
+	UpdatePhotoPlace(w http.ResponseWriter, r *http.Request, id string)
+
+	// (GET /photo/{id}/similar)
+	GetSimilarPhotos(w http.ResponseWriter, r *http.Request, id string, params GetSimilarPhotosParams)
+
+	// (GET /photos/nearby)
+	GetNearbyPhotos(w http.ResponseWriter, r *http.Request, params GetNearbyPhotosParams)
+
+	// (OPTIONS /uploads)
+	GetUploadCapabilities(w http.ResponseWriter, r *http.Request)
+
+	// (POST /uploads)
+	CreateUpload(w http.ResponseWriter, r *http.Request, params CreateUploadParams)
+
+	// (DELETE /uploads/{id})
+	TerminateUpload(w http.ResponseWriter, r *http.Request, id string, params TerminateUploadParams)
+
+	// (HEAD /uploads/{id})
+	GetUploadOffset(w http.ResponseWriter, r *http.Request, id string, params GetUploadOffsetParams)
+
+	// (PATCH /uploads/{id})
+	PatchUpload(w http.ResponseWriter, r *http.Request, id string, params PatchUploadParams)
+
+	// (GET /user/privacy)
+	GetPrivacySettings(w http.ResponseWriter, r *http.Request)
+
+	// (PUT /user/privacy)
+	UpdatePrivacySettings(w http.ResponseWriter, r *http.Request)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -208,12 +785,161 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
-// HealthCheck operation middleware
-func (siw *ServerInterfaceWrapper) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// ListScanResults operation middleware
+func (siw *ServerInterfaceWrapper) ListScanResults(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListScanResultsParams
+
+	// ------------- Optional query parameter "verdict" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "verdict", r.URL.Query(), &params.Verdict)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "verdict", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.HealthCheck(w, r)
+		siw.Handler.ListScanResults(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// HealthCheck operation middleware
+func (siw *ServerInterfaceWrapper) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.HealthCheck(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetImage operation middleware
+func (siw *ServerInterfaceWrapper) GetImage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "photo_id" -------------
+	var photoId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "photo_id", r.PathValue("photo_id"), &photoId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "photo_id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetImageParams
+
+	// ------------- Optional query parameter "w" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "w", r.URL.Query(), &params.W)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "w", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "h" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "h", r.URL.Query(), &params.H)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "h", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "fit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fit", r.URL.Query(), &params.Fit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "fit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "fmt" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fmt", r.URL.Query(), &params.Fmt)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "fmt", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "q" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "q", r.URL.Query(), &params.Q)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "q", Err: err})
+		return
+	}
+
+	// ------------- Required query parameter "sig" -------------
+
+	if paramValue := r.URL.Query().Get("sig"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "sig"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "sig", r.URL.Query(), &params.Sig)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sig", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "If-None-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-None-Match")]; found {
+		var IfNoneMatch string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-None-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-None-Match", valueList[0], &IfNoneMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-None-Match", Err: err})
+			return
+		}
+
+		params.IfNoneMatch = &IfNoneMatch
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetImage(w, r, photoId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -227,8 +953,34 @@ func (siw *ServerInterfaceWrapper) HealthCheck(w http.ResponseWriter, r *http.Re
 func (siw *ServerInterfaceWrapper) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params UploadPhotoParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.UploadPhoto(w, r)
+		siw.Handler.UploadPhoto(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -264,6 +1016,62 @@ func (siw *ServerInterfaceWrapper) GetRawPhoto(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// CompletePhotoUpload operation middleware
+func (siw *ServerInterfaceWrapper) CompletePhotoUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CompletePhotoUpload(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// CreatePhotoUploadUrl operation middleware
+func (siw *ServerInterfaceWrapper) CreatePhotoUploadUrl(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreatePhotoUploadUrl(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// DeletePhoto operation middleware
+func (siw *ServerInterfaceWrapper) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeletePhoto(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // GetPhoto operation middleware
 func (siw *ServerInterfaceWrapper) GetPhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -290,6 +1098,551 @@ func (siw *ServerInterfaceWrapper) GetPhoto(w http.ResponseWriter, r *http.Reque
 	handler.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// CreateComment operation middleware
+func (siw *ServerInterfaceWrapper) CreateComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateCommentParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateComment(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// LikePhoto operation middleware
+func (siw *ServerInterfaceWrapper) LikePhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params LikePhotoParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.LikePhoto(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// UpdatePhotoPlace operation middleware
+func (siw *ServerInterfaceWrapper) UpdatePhotoPlace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.UpdatePhotoPlace(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetSimilarPhotos operation middleware
+func (siw *ServerInterfaceWrapper) GetSimilarPhotos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetSimilarPhotosParams
+
+	// ------------- Optional query parameter "max_distance" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "max_distance", r.URL.Query(), &params.MaxDistance)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "max_distance", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetSimilarPhotos(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetNearbyPhotos operation middleware
+func (siw *ServerInterfaceWrapper) GetNearbyPhotos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetNearbyPhotosParams
+
+	// ------------- Required query parameter "lat" -------------
+
+	if paramValue := r.URL.Query().Get("lat"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "lat"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "lat", r.URL.Query(), &params.Lat)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lat", Err: err})
+		return
+	}
+
+	// ------------- Required query parameter "lon" -------------
+
+	if paramValue := r.URL.Query().Get("lon"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "lon"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "lon", r.URL.Query(), &params.Lon)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lon", Err: err})
+		return
+	}
+
+	// ------------- Required query parameter "radius_km" -------------
+
+	if paramValue := r.URL.Query().Get("radius_km"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "radius_km"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "radius_km", r.URL.Query(), &params.RadiusKm)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "radius_km", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetNearbyPhotos(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetUploadCapabilities operation middleware
+func (siw *ServerInterfaceWrapper) GetUploadCapabilities(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUploadCapabilities(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// CreateUpload operation middleware
+func (siw *ServerInterfaceWrapper) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateUploadParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Tus-Resumable" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Tus-Resumable")]; found {
+		var TusResumable TusResumable
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Tus-Resumable", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Tus-Resumable", valueList[0], &TusResumable, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Tus-Resumable", Err: err})
+			return
+		}
+
+		params.TusResumable = &TusResumable
+
+	}
+
+	// ------------- Optional header parameter "Upload-Length" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Upload-Length")]; found {
+		var UploadLength int64
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Upload-Length", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Upload-Length", valueList[0], &UploadLength, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Upload-Length", Err: err})
+			return
+		}
+
+		params.UploadLength = &UploadLength
+
+	}
+
+	// ------------- Optional header parameter "Upload-Metadata" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Upload-Metadata")]; found {
+		var UploadMetadata string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Upload-Metadata", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Upload-Metadata", valueList[0], &UploadMetadata, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Upload-Metadata", Err: err})
+			return
+		}
+
+		params.UploadMetadata = &UploadMetadata
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateUpload(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// TerminateUpload operation middleware
+func (siw *ServerInterfaceWrapper) TerminateUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params TerminateUploadParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Tus-Resumable" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Tus-Resumable")]; found {
+		var TusResumable TusResumable
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Tus-Resumable", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Tus-Resumable", valueList[0], &TusResumable, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Tus-Resumable", Err: err})
+			return
+		}
+
+		params.TusResumable = &TusResumable
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.TerminateUpload(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetUploadOffset operation middleware
+func (siw *ServerInterfaceWrapper) GetUploadOffset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetUploadOffsetParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Tus-Resumable" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Tus-Resumable")]; found {
+		var TusResumable TusResumable
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Tus-Resumable", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Tus-Resumable", valueList[0], &TusResumable, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Tus-Resumable", Err: err})
+			return
+		}
+
+		params.TusResumable = &TusResumable
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUploadOffset(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// PatchUpload operation middleware
+func (siw *ServerInterfaceWrapper) PatchUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PatchUploadParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Tus-Resumable" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Tus-Resumable")]; found {
+		var TusResumable TusResumable
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Tus-Resumable", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Tus-Resumable", valueList[0], &TusResumable, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Tus-Resumable", Err: err})
+			return
+		}
+
+		params.TusResumable = &TusResumable
+
+	}
+
+	// ------------- Optional header parameter "Upload-Offset" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Upload-Offset")]; found {
+		var UploadOffset int64
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Upload-Offset", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Upload-Offset", valueList[0], &UploadOffset, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Upload-Offset", Err: err})
+			return
+		}
+
+		params.UploadOffset = &UploadOffset
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PatchUpload(w, r, id, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetPrivacySettings operation middleware
+func (siw *ServerInterfaceWrapper) GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetPrivacySettings(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// UpdatePrivacySettings operation middleware
+func (siw *ServerInterfaceWrapper) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.UpdatePrivacySettings(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -404,10 +1757,27 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	m.HandleFunc("GET "+options.BaseURL+"/admin/scans", wrapper.ListScanResults)
 	m.HandleFunc("GET "+options.BaseURL+"/health", wrapper.HealthCheck)
+	m.HandleFunc("GET "+options.BaseURL+"/img/{photo_id}", wrapper.GetImage)
 	m.HandleFunc("POST "+options.BaseURL+"/photo", wrapper.UploadPhoto)
 	m.HandleFunc("GET "+options.BaseURL+"/photo/raw/{id}", wrapper.GetRawPhoto)
+	m.HandleFunc("POST "+options.BaseURL+"/photo/upload-complete", wrapper.CompletePhotoUpload)
+	m.HandleFunc("POST "+options.BaseURL+"/photo/upload-url", wrapper.CreatePhotoUploadUrl)
+	m.HandleFunc("DELETE "+options.BaseURL+"/photo/{id}", wrapper.DeletePhoto)
 	m.HandleFunc("GET "+options.BaseURL+"/photo/{id}", wrapper.GetPhoto)
+	m.HandleFunc("POST "+options.BaseURL+"/photo/{id}/comments", wrapper.CreateComment)
+	m.HandleFunc("POST "+options.BaseURL+"/photo/{id}/like", wrapper.LikePhoto)
+	m.HandleFunc("PUT "+options.BaseURL+"/photo/{id}/place", wrapper.UpdatePhotoPlace)
+	m.HandleFunc("GET "+options.BaseURL+"/photo/{id}/similar", wrapper.GetSimilarPhotos)
+	m.HandleFunc("GET "+options.BaseURL+"/photos/nearby", wrapper.GetNearbyPhotos)
+	m.HandleFunc("OPTIONS "+options.BaseURL+"/uploads", wrapper.GetUploadCapabilities)
+	m.HandleFunc("POST "+options.BaseURL+"/uploads", wrapper.CreateUpload)
+	m.HandleFunc("DELETE "+options.BaseURL+"/uploads/{id}", wrapper.TerminateUpload)
+	m.HandleFunc("HEAD "+options.BaseURL+"/uploads/{id}", wrapper.GetUploadOffset)
+	m.HandleFunc("PATCH "+options.BaseURL+"/uploads/{id}", wrapper.PatchUpload)
+	m.HandleFunc("GET "+options.BaseURL+"/user/privacy", wrapper.GetPrivacySettings)
+	m.HandleFunc("PUT "+options.BaseURL+"/user/privacy", wrapper.UpdatePrivacySettings)
 
 	return m
 }
@@ -6,12 +6,23 @@ import (
 
 	"jelly/pkg/api/v1/gen"
 	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/store"
 )
 
+// Breaker is storage behind a circuit breaker
+type Breaker interface {
+	// State returns the state of the circuit breaker
+	State() store.BreakerState
+}
+
 // HealthHandler implements health check endpoints.
-type HealthHandler struct{}
+type HealthHandler struct {
+	Storage Breaker // Circuit breaker of storage, nil if there is none
+}
 
-// HealthCheck returns {"status": "ok"} with HTTP 200.
+// HealthCheck returns {"status": "ok"} with HTTP 200, and the state of the
+// circuit breaker of storage. While the breaker is open, it returns
+// {"status": "unavailable"} with HTTP 503.
 // GET /health
 func (h HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
@@ -20,6 +31,17 @@ func (h HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	resp := gen.HealthCheck{
 		Status: "ok",
 	}
+	status := http.StatusOK
+
+	if h.Storage != nil {
+		state := string(h.Storage.State())
+		resp.Storage = &state
+		if state == string(store.BreakerOpen) {
+			logger.Warn("Storage circuit breaker is open")
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
 
-	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+	util2.WriteJSONResponse(w, logger, status, resp)
 }
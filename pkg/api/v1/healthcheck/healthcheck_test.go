@@ -10,6 +10,7 @@ import (
 
 	"jelly/pkg/api/v1/gen"
 	"jelly/pkg/api/v1/util"
+	"jelly/pkg/store"
 )
 
 func TestHealthHandler_HealthCheck(t *testing.T) {
@@ -95,3 +96,53 @@ func TestHealthHandler_HealthCheck_JSONMarshalError(t *testing.T) {
 		t.Errorf("Expected status 'ok', got %s", resp.Status)
 	}
 }
+
+// stubBreaker is a circuit breaker in a fixed state
+type stubBreaker store.BreakerState
+
+func (b stubBreaker) State() store.BreakerState {
+	return store.BreakerState(b)
+}
+
+func TestHealthHandler_HealthCheck_Storage(t *testing.T) {
+	tests := []struct {
+		name           string
+		state          store.BreakerState
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"closed", store.BreakerClosed, http.StatusOK, "ok"},
+		{"half-open", store.BreakerHalfOpen, http.StatusOK, "ok"},
+		{"open", store.BreakerOpen, http.StatusServiceUnavailable, "unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HealthHandler{Storage: stubBreaker(tt.state)}
+
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			ctx := context.WithValue(req.Context(), util.ContextLogger, slog.Default())
+			req = req.WithContext(ctx)
+
+			w := httptest.NewRecorder()
+
+			handler.HealthCheck(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+
+			var resp gen.HealthCheck
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if resp.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, resp.Status)
+			}
+			if resp.Storage == nil || *resp.Storage != string(tt.state) {
+				t.Errorf("Expected storage %s, got %v", tt.state, resp.Storage)
+			}
+		})
+	}
+}
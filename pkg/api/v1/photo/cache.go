@@ -0,0 +1,113 @@
+package photo
+
+import (
+	"context"
+	"time"
+
+	"jelly/pkg/cache"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+// CachedDatabase is a Database reading photos and their like and comment
+// counts through a cache. Writes go to the database and then invalidate the
+// cached values they change. Photos that don't exist are cached too, so
+// requests for them don't reach the database either.
+type CachedDatabase struct {
+	Database
+	loader *cache.Loader
+}
+
+// NewCachedDatabase wraps a Database with a cache. Values are cached for ttl,
+// and missing photos for negativeTTL.
+func NewCachedDatabase(db Database, c cache.Cache, ttl, negativeTTL time.Duration) *CachedDatabase {
+	return &CachedDatabase{
+		Database: db,
+		loader: &cache.Loader{
+			Cache:       c,
+			TTL:         ttl,
+			NegativeTTL: negativeTTL,
+			NotFound:    pgdb.ErrNotFound,
+		},
+	}
+}
+
+func photoKey(photoID string) string {
+	return "photo:" + photoID
+}
+
+func photoLikesKey(photoID string) string {
+	return "photo:" + photoID + ":likes"
+}
+
+func photoCommentsKey(photoID string) string {
+	return "photo:" + photoID + ":comments"
+}
+
+// GetPhotoByID returns the photo with the given ID, or ErrNotFound.
+func (c *CachedDatabase) GetPhotoByID(ctx context.Context, photoID string) (model.Photo, error) {
+	return cache.Load(ctx, c.loader, photoKey(photoID), func(ctx context.Context) (model.Photo, error) {
+		return c.Database.GetPhotoByID(ctx, photoID)
+	})
+}
+
+// CreatePhoto creates a photo and invalidates it, in case it was cached as
+// missing.
+func (c *CachedDatabase) CreatePhoto(ctx context.Context, photo model.Photo) error {
+	if err := c.Database.CreatePhoto(ctx, photo); err != nil {
+		return err
+	}
+	c.loader.Invalidate(ctx, photoKey(photo.ID))
+	return nil
+}
+
+// UpdatePhotoPlace sets the place name of a photo and invalidates it.
+func (c *CachedDatabase) UpdatePhotoPlace(ctx context.Context, photoID string, placeName *string) error {
+	if err := c.Database.UpdatePhotoPlace(ctx, photoID, placeName); err != nil {
+		return err
+	}
+	c.loader.Invalidate(ctx, photoKey(photoID))
+	return nil
+}
+
+// DeletePhoto schedules a photo for deletion and invalidates it.
+func (c *CachedDatabase) DeletePhoto(ctx context.Context, photoID string, deletionDuration time.Duration) error {
+	if err := c.Database.DeletePhoto(ctx, photoID, deletionDuration); err != nil {
+		return err
+	}
+	c.loader.Invalidate(ctx, photoKey(photoID), photoLikesKey(photoID), photoCommentsKey(photoID))
+	return nil
+}
+
+// LikePhoto records a like and invalidates the like count of the photo.
+func (c *CachedDatabase) LikePhoto(ctx context.Context, userID, photoID string) error {
+	if err := c.Database.LikePhoto(ctx, userID, photoID); err != nil {
+		return err
+	}
+	c.loader.Invalidate(ctx, photoLikesKey(photoID))
+	return nil
+}
+
+// CountPhotoLikes returns the number of likes of the photo.
+func (c *CachedDatabase) CountPhotoLikes(ctx context.Context, photoID string) (int, error) {
+	return cache.Load(ctx, c.loader, photoLikesKey(photoID), func(ctx context.Context) (int, error) {
+		return c.Database.CountPhotoLikes(ctx, photoID)
+	})
+}
+
+// CreateComment creates a comment and invalidates the comment count of the
+// photo.
+func (c *CachedDatabase) CreateComment(ctx context.Context, comment model.Comment) error {
+	if err := c.Database.CreateComment(ctx, comment); err != nil {
+		return err
+	}
+	c.loader.Invalidate(ctx, photoCommentsKey(comment.PhotoID))
+	return nil
+}
+
+// CountPhotoComments returns the number of comments on the photo.
+func (c *CachedDatabase) CountPhotoComments(ctx context.Context, photoID string) (int, error) {
+	return cache.Load(ctx, c.loader, photoCommentsKey(photoID), func(ctx context.Context) (int, error) {
+		return c.Database.CountPhotoComments(ctx, photoID)
+	})
+}
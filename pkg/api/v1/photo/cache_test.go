@@ -0,0 +1,191 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/cache"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+func newTestCachedDatabase(t *testing.T) (*CachedDatabase, *MockDatabase) {
+	db := NewMockDatabase(t)
+	return NewCachedDatabase(db, cache.NewLRU(100), time.Minute, time.Second), db
+}
+
+func TestCachedDatabase_GetPhotoByID(t *testing.T) {
+	ctx := context.Background()
+	cached, db := newTestCachedDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil).Once()
+
+	for range 3 {
+		photo, err := cached.GetPhotoByID(ctx, testPhotoID)
+		require.NoError(t, err)
+		assert.Equal(t, testPhotoID, photo.ID)
+	}
+}
+
+func TestCachedDatabase_GetPhotoByID_NotFound(t *testing.T) {
+	ctx := context.Background()
+	cached, db := newTestCachedDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).
+		Return(model.Photo{}, fmt.Errorf("failed to get photo: %w", pgdb.ErrNotFound)).Once()
+
+	for range 3 {
+		_, err := cached.GetPhotoByID(ctx, testPhotoID)
+		assert.ErrorIs(t, err, pgdb.ErrNotFound)
+	}
+}
+
+func TestCachedDatabase_GetPhotoByID_ErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	cached, db := newTestCachedDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, errors.New("db down")).Twice()
+
+	for range 2 {
+		_, err := cached.GetPhotoByID(ctx, testPhotoID)
+		assert.Error(t, err)
+	}
+}
+
+func TestCachedDatabase_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	caption := "Beautiful sunset"
+
+	tests := []struct {
+		name  string
+		setup func(*MockDatabase)
+		write func(*CachedDatabase) error
+		read  func(*CachedDatabase) (any, error)
+		want  []any
+	}{
+		{
+			name: "place update invalidates the photo",
+			setup: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil).Once()
+				m.EXPECT().UpdatePhotoPlace(mock.Anything, testPhotoID, &caption).Return(nil)
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).
+					Return(model.Photo{ID: testPhotoID, PlaceName: &caption}, nil).Once()
+			},
+			write: func(c *CachedDatabase) error {
+				return c.UpdatePhotoPlace(ctx, testPhotoID, &caption)
+			},
+			read: func(c *CachedDatabase) (any, error) {
+				photo, err := c.GetPhotoByID(ctx, testPhotoID)
+				return photo.PlaceName, err
+			},
+			want: []any{(*string)(nil), &caption},
+		},
+		{
+			name: "create invalidates a missing photo",
+			setup: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, pgdb.ErrNotFound).Once()
+				m.EXPECT().CreatePhoto(mock.Anything, mock.Anything).Return(nil)
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil).Once()
+			},
+			write: func(c *CachedDatabase) error {
+				return c.CreatePhoto(ctx, model.Photo{ID: testPhotoID})
+			},
+			read: func(c *CachedDatabase) (any, error) {
+				photo, err := c.GetPhotoByID(ctx, testPhotoID)
+				if errors.Is(err, pgdb.ErrNotFound) {
+					return "not found", nil
+				}
+				return photo.ID, err
+			},
+			want: []any{"not found", testPhotoID},
+		},
+		{
+			name: "delete invalidates the photo",
+			setup: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil).Once()
+				m.EXPECT().DeletePhoto(mock.Anything, testPhotoID, time.Hour).Return(nil)
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, pgdb.ErrNotFound).Once()
+			},
+			write: func(c *CachedDatabase) error {
+				return c.DeletePhoto(ctx, testPhotoID, time.Hour)
+			},
+			read: func(c *CachedDatabase) (any, error) {
+				photo, err := c.GetPhotoByID(ctx, testPhotoID)
+				if errors.Is(err, pgdb.ErrNotFound) {
+					return "not found", nil
+				}
+				return photo.ID, err
+			},
+			want: []any{testPhotoID, "not found"},
+		},
+		{
+			name: "like invalidates the like count",
+			setup: func(m *MockDatabase) {
+				m.EXPECT().CountPhotoLikes(mock.Anything, testPhotoID).Return(1, nil).Once()
+				m.EXPECT().LikePhoto(mock.Anything, testUserID, testPhotoID).Return(nil)
+				m.EXPECT().CountPhotoLikes(mock.Anything, testPhotoID).Return(2, nil).Once()
+			},
+			write: func(c *CachedDatabase) error {
+				return c.LikePhoto(ctx, testUserID, testPhotoID)
+			},
+			read: func(c *CachedDatabase) (any, error) {
+				return c.CountPhotoLikes(ctx, testPhotoID)
+			},
+			want: []any{1, 2},
+		},
+		{
+			name: "comment invalidates the comment count",
+			setup: func(m *MockDatabase) {
+				m.EXPECT().CountPhotoComments(mock.Anything, testPhotoID).Return(1, nil).Once()
+				m.EXPECT().CreateComment(mock.Anything, mock.Anything).Return(nil)
+				m.EXPECT().CountPhotoComments(mock.Anything, testPhotoID).Return(2, nil).Once()
+			},
+			write: func(c *CachedDatabase) error {
+				return c.CreateComment(ctx, model.Comment{PhotoID: testPhotoID})
+			},
+			read: func(c *CachedDatabase) (any, error) {
+				return c.CountPhotoComments(ctx, testPhotoID)
+			},
+			want: []any{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached, db := newTestCachedDatabase(t)
+			tt.setup(db)
+
+			// The second read is served from the cache
+			for range 2 {
+				got, err := tt.read(cached)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want[0], got)
+			}
+
+			require.NoError(t, tt.write(cached))
+
+			got, err := tt.read(cached)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want[1], got)
+		})
+	}
+}
+
+func TestCachedDatabase_FailedWriteKeepsCache(t *testing.T) {
+	ctx := context.Background()
+	cached, db := newTestCachedDatabase(t)
+	db.EXPECT().CountPhotoLikes(mock.Anything, testPhotoID).Return(1, nil).Once()
+	db.EXPECT().LikePhoto(mock.Anything, testUserID, testPhotoID).Return(errors.New("db down"))
+
+	_, err := cached.CountPhotoLikes(ctx, testPhotoID)
+	require.NoError(t, err)
+	require.Error(t, cached.LikePhoto(ctx, testUserID, testPhotoID))
+
+	count, err := cached.CountPhotoLikes(ctx, testPhotoID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
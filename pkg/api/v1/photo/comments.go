@@ -0,0 +1,77 @@
+package photo
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+)
+
+// maxCommentLength is the maximum number of characters in a comment.
+const maxCommentLength = 2000
+
+// CreateComment adds a comment to a photo.
+// POST /photo/{id}/comments
+func (h PhotoHandler) CreateComment(w http.ResponseWriter, r *http.Request, id string,
+	params gen.CreateCommentParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Comment without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	var req gen.CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Info("Invalid comment request", "error", err)
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" || utf8.RuneCountInString(content) > maxCommentLength {
+		http.Error(w, util2.ErrMsgInvalidComment, http.StatusBadRequest)
+		return
+	}
+
+	if status, message := h.checkPhotoExists(r, logger, id); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	comment := model.Comment{
+		ID:        uuid.New().String(),
+		PhotoID:   id,
+		UserID:    userID,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	if err := h.DB.CreateComment(r.Context(), comment); err != nil {
+		logger.Error("Failed to create comment", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToCreateComment, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Comment created", "id", id, "comment_id", comment.ID)
+
+	util2.WriteJSONResponse(w, logger, http.StatusCreated, gen.CommentResponse{
+		Comment: comment.ToComment(),
+		Message: util2.StringPtr("Comment created successfully"),
+	})
+}
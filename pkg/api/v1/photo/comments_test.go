@@ -0,0 +1,94 @@
+package photo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+func TestPhotoHandler_CreateComment(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		userID         string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:    "created",
+			content: "  Great shot!  ",
+			userID:  testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil)
+				m.EXPECT().CreateComment(mock.Anything, mock.MatchedBy(func(c model.Comment) bool {
+					return c.PhotoID == testPhotoID && c.UserID == testUserID && c.Content == "Great shot!"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "without user",
+			content:        "Great shot!",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "empty",
+			content:        "   ",
+			userID:         testUserID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too long",
+			content:        strings.Repeat("é", maxCommentLength+1),
+			userID:         testUserID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "photo not found",
+			content: "Great shot!",
+			userID:  testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+			w := httptest.NewRecorder()
+
+			handler.CreateComment(w, newJSONRequest(t, "/photo/"+testPhotoID+"/comments",
+				gen.CommentRequest{Content: tt.content}, tt.userID), testPhotoID, gen.CreateCommentParams{})
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var resp gen.CommentResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Comment.Content != "Great shot!" || resp.Comment.Id == "" {
+				t.Errorf("Unexpected comment: %+v", resp.Comment)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package photo
+
+import (
+	"context"
+
+	"jelly/pkg/api/v1/gen"
+	"jelly/pkg/model"
+	"jelly/pkg/store"
+)
+
+// backend returns the storage backend with the ID, so objects are read from
+// the backend their row refers to.
+func (h PhotoHandler) backend(id string) (store.Storage, error) {
+	if id == h.Backend {
+		return h.Storage, nil
+	}
+	return h.URLs.Backend(id)
+}
+
+// deliverURL sets the URL clients fetch an object of the class from, given the
+// ID of its storage backend and its key. The URL expires if storage is
+// private.
+func (h PhotoHandler) deliverURL(ctx context.Context, class store.Class, backend, key string, url *string) error {
+	delivered, err := h.URLs.URL(ctx, class, backend, key)
+	if err != nil {
+		return err
+	}
+	*url = delivered
+	return nil
+}
+
+// deliverPhotoDetails sets the URLs of the original, thumbnail and variants of
+// a photo.
+func (h PhotoHandler) deliverPhotoDetails(ctx context.Context, photo model.Photo, details *gen.PhotoDetails) error {
+	err := h.deliverURL(ctx, store.ClassOriginal, photo.StorageBackend, photo.OriginalKey, &details.OriginalUrl)
+	if err != nil {
+		return err
+	}
+	err = h.deliverURL(ctx, store.ClassThumbnail, photo.StorageBackend, photo.ThumbnailKey, &details.ThumbnailUrl)
+	if err != nil {
+		return err
+	}
+	for i, variant := range photo.Variants {
+		err := h.deliverURL(ctx, store.ClassThumbnail, variant.StorageBackend, variant.StorageKey,
+			&details.Variants[i].Url)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
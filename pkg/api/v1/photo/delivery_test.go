@@ -0,0 +1,187 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/store"
+)
+
+// testBackend is the ID of the storage backend objects of the tests are in
+const testBackend = "default"
+
+// newTestURLResolver creates a resolver of the URLs of objects in public
+// storage under https://example.com.
+func newTestURLResolver(t *testing.T) *store.URLResolver {
+	storage := store.NewLocalStorage(t.TempDir(), "https://example.com")
+	return store.NewURLResolver(map[string]store.Storage{testBackend: storage})
+}
+
+// newTestPresignedURLResolver creates a resolver presigning the URLs of
+// objects in the storage with the test expirations.
+func newTestPresignedURLResolver(storage store.Storage) *store.URLResolver {
+	return store.NewPresignedURLResolver(map[string]store.Storage{testBackend: storage}, testExpirations)
+}
+
+// testExpirations are the expirations of URLs delivered by the tests
+var testExpirations = map[store.Class]time.Duration{
+	store.ClassRaw:       5 * time.Minute,
+	store.ClassOriginal:  15 * time.Minute,
+	store.ClassThumbnail: time.Hour,
+}
+
+// expectPresigned expects a storage object to be presigned with the
+// expiration of its class.
+func expectPresigned(storage *store.MockStorage, key string, class store.Class) {
+	storage.EXPECT().GenerateURL(mock.Anything, key, testExpirations[class]).
+		Return("https://bucket.example.com/"+key+"?signed", nil)
+}
+
+func TestPhotoHandler_GetPhoto_Private(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	photo := model.Photo{
+		ID:             photoID,
+		StorageBackend: testBackend,
+		OriginalKey:    "raw/u/abc.jpg",
+		ThumbnailKey:   "photos/" + photoID + "/thumb.jpg",
+		Variants: []model.PhotoVariant{
+			{Name: "thumb", StorageBackend: testBackend, StorageKey: "photos/" + photoID + "/thumb.jpg"},
+			{Name: "large", StorageBackend: testBackend, StorageKey: "photos/" + photoID + "/large.jpg"},
+		},
+	}
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+	db.EXPECT().CountPhotoLikes(mock.Anything, photoID).Return(0, nil)
+	db.EXPECT().CountPhotoComments(mock.Anything, photoID).Return(0, nil)
+
+	storage := store.NewMockStorage(t)
+	expectPresigned(storage, "raw/u/abc.jpg", store.ClassOriginal)
+	expectPresigned(storage, "photos/"+photoID+"/thumb.jpg", store.ClassThumbnail)
+	expectPresigned(storage, "photos/"+photoID+"/large.jpg", store.ClassThumbnail)
+
+	handler := PhotoHandler{DB: db, Storage: storage, URLs: newTestPresignedURLResolver(storage)}
+	req := httptest.NewRequest(http.MethodGet, "/photo/"+photoID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), util2.ContextLogger, slog.Default()))
+	w := httptest.NewRecorder()
+
+	handler.GetPhoto(w, req, photoID)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp gen.PhotoDetailsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.Photo.OriginalUrl != "https://bucket.example.com/raw/u/abc.jpg?signed" {
+		t.Errorf("Expected a presigned original URL, got %s", resp.Photo.OriginalUrl)
+	}
+	if resp.Photo.ThumbnailUrl != "https://bucket.example.com/photos/"+photoID+"/thumb.jpg?signed" {
+		t.Errorf("Expected a presigned thumbnail URL, got %s", resp.Photo.ThumbnailUrl)
+	}
+	for _, variant := range resp.Photo.Variants {
+		if variant.Url != "https://bucket.example.com/photos/"+photoID+"/"+variant.Name+".jpg?signed" {
+			t.Errorf("Expected a presigned URL of variant %s, got %s", variant.Name, variant.Url)
+		}
+	}
+}
+
+func TestPhotoHandler_GetRawPhoto_Private(t *testing.T) {
+	rawID := "3f2e1d0c-9b8a-4c7d-8e6f-5a4b3c2d1e0f"
+
+	tests := []struct {
+		name           string
+		userID         string
+		presignErr     error
+		expectedStatus int
+		expectedURL    string
+	}{
+		{
+			name:           "presigned",
+			userID:         testUserID,
+			expectedStatus: http.StatusOK,
+			expectedURL:    "https://bucket.example.com/raw/u/abc.jpg?signed",
+		},
+		{
+			name:           "presign failure",
+			userID:         testUserID,
+			presignErr:     errors.New("no credentials"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			// The raw photo is only fetched by its owner
+			name:           "other user",
+			userID:         "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			db.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{ID: rawID, UserID: testUserID,
+				StorageBackend: testBackend, StorageKey: "raw/u/abc.jpg"}, nil)
+
+			storage := store.NewMockStorage(t)
+			if tt.userID == testUserID {
+				storage.EXPECT().GenerateURL(mock.Anything, "raw/u/abc.jpg", 5*time.Minute).
+					Return(tt.expectedURL, tt.presignErr)
+			}
+
+			handler := PhotoHandler{DB: db, Storage: storage, URLs: newTestPresignedURLResolver(storage)}
+			req := httptest.NewRequest(http.MethodGet, "/photo/raw/"+rawID, nil)
+			ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+			ctx = context.WithValue(ctx, util2.ContextUserID, tt.userID)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			handler.GetRawPhoto(w, req, rawID)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp gen.RawPhotoDetailsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if tt.expectedURL == "" && resp.RawPhoto.StorageUrl != nil {
+				t.Errorf("Expected no URL, got %s", *resp.RawPhoto.StorageUrl)
+			} else if tt.expectedURL != "" && (resp.RawPhoto.StorageUrl == nil || *resp.RawPhoto.StorageUrl != tt.expectedURL) {
+				t.Errorf("Expected URL %s, got %v", tt.expectedURL, resp.RawPhoto.StorageUrl)
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_GetPhoto_UnknownBackend(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{ID: photoID, StorageBackend: "old",
+		OriginalKey: "raw/u/abc.jpg"}, nil)
+
+	handler := PhotoHandler{DB: db, URLs: newTestURLResolver(t)}
+	req := httptest.NewRequest(http.MethodGet, "/photo/"+photoID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), util2.ContextLogger, slog.Default()))
+	w := httptest.NewRecorder()
+
+	handler.GetPhoto(w, req, photoID)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
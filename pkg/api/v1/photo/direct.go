@@ -0,0 +1,283 @@
+package photo
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/model"
+	"jelly/pkg/store"
+)
+
+// CreatePhotoUploadUrl creates a presigned request that uploads a photo
+// directly to storage. The photo is staged under a key of its own upload, and
+// the request is restricted to the declared size, type and checksum. Only
+// verified photos are stored under their content-addressed raw photo key, so
+// an upload can never replace a raw photo.
+// POST /photo/upload-url
+func (h PhotoHandler) CreatePhotoUploadUrl(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Upload without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	var req gen.PhotoUploadUrlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Filename) > 255 {
+		logger.Info("Invalid upload URL request", "error", err)
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	if !isSupportedType(req.ContentType) {
+		logger.Info("Unsupported file type", "mime_type", req.ContentType)
+		http.Error(w, unsupportedTypeMessage(), http.StatusBadRequest)
+		return
+	}
+
+	if !isSHA256(req.Sha256) {
+		http.Error(w, util2.ErrMsgInvalidChecksum, http.StatusBadRequest)
+		return
+	}
+
+	maxFileSize := config.GetPhotoMaxFileSizeBytes()
+	if req.ContentLength <= 0 {
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	} else if req.ContentLength > maxFileSize {
+		logger.Info("File size too large",
+			"max_size_mb", maxFileSize/(1024*1024), "file_size_mb", req.ContentLength/(1024*1024))
+		http.Error(w, util2.ErrMsgFileTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	now := time.Now()
+	upload := model.Upload{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Filename:     req.Filename,
+		UploadLength: req.ContentLength,
+		Direct:       true,
+		MimeType:     &req.ContentType,
+		SHA256Hash:   &req.Sha256,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(config.GetUploadExpiration()),
+	}
+
+	conditions := store.UploadConditions{
+		ContentType:   req.ContentType,
+		ContentLength: req.ContentLength,
+		SHA256:        req.Sha256,
+	}
+	expiration := config.GetUploadURLExpiration()
+
+	var presigned store.PresignedUpload
+	var err error
+	if req.Method != nil && *req.Method == gen.PhotoUploadUrlRequestMethodPOST {
+		presigned, err = h.Storage.GenerateUploadForm(r.Context(), directUploadKey(upload), conditions, expiration)
+	} else {
+		presigned, err = h.Storage.GenerateUploadURL(r.Context(), directUploadKey(upload), conditions, expiration)
+	}
+	if errors.Is(err, store.ErrEncrypted) {
+		logger.Info("Direct upload of an encrypted photo", "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgDirectUploadDisabled, http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.Error("Failed to presign upload", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCreateUpload, http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.DB.CreateUpload(r.Context(), upload); err != nil {
+		logger.Error("Failed to create upload", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCreateUpload, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gen.PhotoUploadUrlResponse{
+		UploadId:  upload.ID,
+		Method:    gen.PhotoUploadUrlResponseMethod(presigned.Method),
+		Url:       presigned.URL,
+		ExpiresAt: presigned.ExpiresAt,
+	}
+	if len(presigned.Headers) > 0 {
+		resp.Headers = &presigned.Headers
+	}
+	if len(presigned.Fields) > 0 {
+		resp.Fields = &presigned.Fields
+	}
+
+	logger.Info("Direct upload created", "upload_id", upload.ID, "method", presigned.Method,
+		"upload_length", upload.UploadLength)
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// CompletePhotoUpload verifies a photo uploaded directly to storage and hands
+// it to the upload pipeline, which stores it under its raw photo key. The
+// staged photo is deleted once it's processed, or if it doesn't match the
+// upload or is rejected.
+// POST /photo/upload-complete
+func (h PhotoHandler) CompletePhotoUpload(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Upload without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	var req gen.PhotoUploadCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Info("Invalid upload complete request", "error", err)
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	unlock := uploadLocks.Lock(req.UploadId)
+	defer unlock()
+
+	upload, status := h.getUpload(r, logger, req.UploadId, true)
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		http.Error(w, util2.ErrMsgUploadNotFound, status)
+		return
+	case http.StatusGone:
+		http.Error(w, util2.ErrMsgUploadExpired, status)
+		return
+	default:
+		http.Error(w, util2.ErrMsgFailedToCompleteUpload, status)
+		return
+	}
+
+	// Completing again returns the photo processed the first time
+	if upload.CompletedAt != nil {
+		photo, err := h.DB.GetPhotoByRawPhotoID(r.Context(), *upload.RawPhotoID)
+		if err != nil {
+			logger.Error("Failed to get photo", "error", err, "upload_id", upload.ID)
+			http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := h.newUploadResponse(r.Context(), processedUpload{photo: photo})
+		if err != nil {
+			logger.Error("Failed to deliver photo", "error", err, "upload_id", upload.ID)
+			http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+			return
+		}
+
+		util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+		return
+	}
+
+	info, err := h.Storage.Stat(r.Context(), directUploadKey(upload))
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Info("Direct upload not received", "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgUploadNotReceived, http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.Error("Failed to stat uploaded photo", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+		return
+	}
+
+	// Storage enforces the presigned conditions, but verify them in case the
+	// object was written some other way
+	if info.Size != upload.UploadLength || info.ContentType != *upload.MimeType ||
+		(info.SHA256 != "" && info.SHA256 != *upload.SHA256Hash) {
+		logger.Warn("Uploaded photo does not match the upload", "upload_id", upload.ID,
+			"size", info.Size, "mime_type", info.ContentType, "sha256", info.SHA256)
+		h.discardDirectUpload(r.Context(), logger, upload)
+		http.Error(w, util2.ErrMsgUploadMismatch, http.StatusBadRequest)
+		return
+	}
+
+	// The photo is scanned and processed like any other upload, so it's read
+	// back from storage
+	data, _, err := h.Storage.Download(r.Context(), directUploadKey(upload))
+	if err != nil {
+		logger.Error("Failed to download uploaded photo", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+		return
+	}
+
+	// Not every storage reports checksums, so the content is always verified
+	// against the declared checksum
+	raw := newRawPhoto(upload.UserID, upload.Filename, data)
+	if raw.FileSize != upload.UploadLength || raw.MimeType != *upload.MimeType ||
+		raw.SHA256Hash != *upload.SHA256Hash {
+		logger.Warn("Uploaded photo does not match the upload", "upload_id", upload.ID,
+			"size", raw.FileSize, "mime_type", raw.MimeType, "sha256", raw.SHA256Hash)
+		h.discardDirectUpload(r.Context(), logger, upload)
+		http.Error(w, util2.ErrMsgUploadMismatch, http.StatusBadRequest)
+		return
+	}
+
+	processed, status, message := h.processUpload(r.Context(), logger.With("upload_id", upload.ID),
+		raw, data, nil, []string{})
+	if status != http.StatusOK {
+		// Rejected photos aren't kept, but photos that couldn't be processed
+		// for now are kept so completing the upload can be retried
+		if status < http.StatusInternalServerError {
+			h.discardDirectUpload(r.Context(), logger, upload)
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := h.DB.CompleteUpload(r.Context(), upload.ID, processed.raw.ID); err != nil {
+		logger.Error("Failed to complete upload", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+		return
+	}
+	h.discardDirectUpload(r.Context(), logger, upload)
+
+	logger.Info("Direct upload completed", "upload_id", upload.ID, "raw_photo_id", processed.raw.ID,
+		"photo_id", processed.photo.ID, "duplicate", processed.duplicate)
+
+	resp, err := h.newUploadResponse(r.Context(), processed)
+	if err != nil {
+		logger.Error("Failed to deliver photo", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCompleteUpload, http.StatusInternalServerError)
+		return
+	}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// discardDirectUpload deletes the staged object of a direct upload. Objects
+// left behind are deleted by the garbage collector.
+func (h PhotoHandler) discardDirectUpload(ctx context.Context, logger *slog.Logger, upload model.Upload) {
+	key := directUploadKey(upload)
+	if err := h.Storage.Delete(context.WithoutCancel(ctx), key); err != nil {
+		logger.Warn("Failed to delete staged upload", "error", err, "upload_id", upload.ID, "key", key)
+	}
+}
+
+// directUploadKey returns the key a direct upload is staged under. It's
+// addressed by the upload rather than the content, next to the raw photos of
+// the user so it's stored like them.
+func directUploadKey(upload model.Upload) string {
+	return fmt.Sprintf("raw/%s/%s%s", upload.UserID, upload.ID, fileExtensions[*upload.MimeType])
+}
+
+// isSHA256 reports whether s is a lowercase hex encoded SHA-256 checksum.
+func isSHA256(s string) bool {
+	sum, err := hex.DecodeString(s)
+	return err == nil && len(sum) == 32 && hex.EncodeToString(sum) == s
+}
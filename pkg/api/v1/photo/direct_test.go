@@ -0,0 +1,493 @@
+package photo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
+)
+
+const testSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// newJSONRequest creates a request with a JSON body and the logger and user
+// set in the context.
+func newJSONRequest(t *testing.T, target string, body any, userID string) *http.Request {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+func newDirectUpload(completed bool) *model.Upload {
+	upload := &model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		Filename:     "beach.jpg",
+		UploadLength: 1024,
+		Direct:       true,
+		MimeType:     util2.StringPtr("image/jpeg"),
+		SHA256Hash:   util2.StringPtr(testSHA256),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	if completed {
+		now := time.Now()
+		upload.RawPhotoID = util2.StringPtr("5b6c7d8e-9f0a-4b1c-8d2e-3f4a5b6c7d8e")
+		upload.CompletedAt = &now
+	}
+	return upload
+}
+
+func TestPhotoHandler_CreatePhotoUploadUrl(t *testing.T) {
+	conditions := store.UploadConditions{ContentType: "image/jpeg", ContentLength: 1024, SHA256: testSHA256}
+
+	tests := []struct {
+		name   string
+		method *gen.PhotoUploadUrlRequestMethod
+		setup  func(m *store.MockStorage, key any)
+		check  func(*testing.T, gen.PhotoUploadUrlResponse)
+	}{
+		{
+			name: "PUT by default",
+			setup: func(m *store.MockStorage, key any) {
+				m.EXPECT().GenerateUploadURL(mock.Anything, key, conditions, 15*time.Minute).
+					Return(store.PresignedUpload{
+						Method:  http.MethodPut,
+						URL:     "https://example.com/staged.jpg?signature=abc",
+						Headers: map[string]string{"Content-Type": "image/jpeg"},
+					}, nil)
+			},
+			check: func(t *testing.T, resp gen.PhotoUploadUrlResponse) {
+				assert.Equal(t, gen.PhotoUploadUrlResponseMethodPUT, resp.Method)
+				require.NotNil(t, resp.Headers)
+				assert.Equal(t, "image/jpeg", (*resp.Headers)["Content-Type"])
+				assert.Nil(t, resp.Fields)
+			},
+		},
+		{
+			name:   "POST form",
+			method: func() *gen.PhotoUploadUrlRequestMethod { m := gen.PhotoUploadUrlRequestMethodPOST; return &m }(),
+			setup: func(m *store.MockStorage, key any) {
+				m.EXPECT().GenerateUploadForm(mock.Anything, key, conditions, 15*time.Minute).
+					Return(store.PresignedUpload{
+						Method: http.MethodPost,
+						URL:    "https://example.com/",
+						Fields: map[string]string{"key": "staged.jpg"},
+					}, nil)
+			},
+			check: func(t *testing.T, resp gen.PhotoUploadUrlResponse) {
+				assert.Equal(t, gen.PhotoUploadUrlResponseMethodPOST, resp.Method)
+				require.NotNil(t, resp.Fields)
+				assert.Equal(t, "staged.jpg", (*resp.Fields)["key"])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			db.EXPECT().CreateUpload(mock.Anything, mock.MatchedBy(func(upload model.Upload) bool {
+				return upload.Direct && upload.UserID == testUserID && upload.Filename == "beach.jpg" &&
+					upload.UploadLength == 1024 && *upload.SHA256Hash == testSHA256
+			})).Return(nil)
+
+			// The photo is staged under a key of the upload, never the key of
+			// the raw photo it may replace
+			var staged string
+			storage := store.NewMockStorage(t)
+			tt.setup(storage, mock.MatchedBy(func(key string) bool {
+				staged = key
+				return true
+			}))
+
+			handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
+			w := httptest.NewRecorder()
+
+			handler.CreatePhotoUploadUrl(w, newJSONRequest(t, "/photo/upload-url", gen.PhotoUploadUrlRequest{
+				Filename:      "beach.jpg",
+				ContentType:   "image/jpeg",
+				ContentLength: 1024,
+				Sha256:        testSHA256,
+				Method:        tt.method,
+			}, testUserID))
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp gen.PhotoUploadUrlResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "raw/"+testUserID+"/"+resp.UploadId+".jpg", staged)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestPhotoHandler_CreatePhotoUploadUrl_Validation(t *testing.T) {
+	t.Setenv("PHOTO_MAX_FILE_SIZE_MB", "1")
+
+	valid := gen.PhotoUploadUrlRequest{
+		Filename:      "beach.jpg",
+		ContentType:   "image/jpeg",
+		ContentLength: 1024,
+		Sha256:        testSHA256,
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(*gen.PhotoUploadUrlRequest)
+		userID         string
+		expectedStatus int
+	}{
+		{"missing user", func(*gen.PhotoUploadUrlRequest) {}, "", http.StatusForbidden},
+		{"unsupported type", func(r *gen.PhotoUploadUrlRequest) { r.ContentType = "application/pdf" }, testUserID, http.StatusBadRequest},
+		{"invalid checksum", func(r *gen.PhotoUploadUrlRequest) { r.Sha256 = "abc" }, testUserID, http.StatusBadRequest},
+		{"uppercase checksum", func(r *gen.PhotoUploadUrlRequest) {
+			r.Sha256 = "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"
+		}, testUserID, http.StatusBadRequest},
+		{"empty photo", func(r *gen.PhotoUploadUrlRequest) { r.ContentLength = 0 }, testUserID, http.StatusBadRequest},
+		{"too large", func(r *gen.PhotoUploadUrlRequest) { r.ContentLength = 2 << 20 }, testUserID, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+
+			handler := PhotoHandler{DB: NewMockDatabase(t), Storage: store.NewMockStorage(t)}
+			w := httptest.NewRecorder()
+
+			handler.CreatePhotoUploadUrl(w, newJSONRequest(t, "/photo/upload-url", req, tt.userID))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestPhotoHandler_CreatePhotoUploadUrl_Encrypted(t *testing.T) {
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().GenerateUploadURL(mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(store.PresignedUpload{}, store.ErrEncrypted)
+
+	// No upload is created for a photo that can't be uploaded
+	handler := PhotoHandler{DB: NewMockDatabase(t), Storage: storage}
+	w := httptest.NewRecorder()
+
+	handler.CreatePhotoUploadUrl(w, newJSONRequest(t, "/photo/upload-url", gen.PhotoUploadUrlRequest{
+		Filename:      "beach.jpg",
+		ContentType:   "image/jpeg",
+		ContentLength: 1024,
+		Sha256:        testSHA256,
+	}, testUserID))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), util2.ErrMsgDirectUploadDisabled)
+}
+
+// newReceivedDirectUpload returns a direct upload of the data.
+func newReceivedDirectUpload(data []byte, completed bool) *model.Upload {
+	upload := newDirectUpload(completed)
+	upload.UploadLength = int64(len(data))
+	upload.SHA256Hash = util2.StringPtr(util2.CalculateSHA256(data))
+	return upload
+}
+
+func TestPhotoHandler_CompletePhotoUpload(t *testing.T) {
+	image := testJPEG(t)
+	sha256Hash := util2.CalculateSHA256(image)
+	key := "raw/" + testUserID + "/" + testUploadID + ".jpg"
+	rawKey := "raw/" + testUserID + "/" + sha256Hash + ".jpg"
+	stored := store.ObjectInfo{
+		Key:         key,
+		URL:         "https://example.com/" + key,
+		Size:        int64(len(image)),
+		ContentType: "image/jpeg",
+		SHA256:      sha256Hash,
+	}
+	existing := model.RawPhoto{ID: "5b6c7d8e-9f0a-4b1c-8d2e-3f4a5b6c7d8e", UserID: testUserID, SHA256Hash: sha256Hash}
+
+	// tampered has the size and type of the image, but not its content
+	tampered := bytes.Clone(image)
+	tampered[len(tampered)-3] ^= 0xff
+	photo := model.Photo{ID: "5d2c8e1f-7a3b-4c6d-8e9f-0a1b2c3d4e5f", RawPhotoID: existing.ID, UserID: testUserID,
+		StorageBackend: testBackend, OriginalKey: rawKey}
+
+	tests := []struct {
+		name              string
+		upload            *model.Upload
+		setup             func(*MockDatabase, *store.MockStorage)
+		expectedStatus    int
+		expectedDuplicate bool
+	}{
+		{
+			name:   "processes the photo",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				s.EXPECT().Stat(mock.Anything, key).Return(stored, nil)
+				s.EXPECT().Download(mock.Anything, key).Return(image, "image/jpeg", nil)
+				db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, sha256Hash).
+					Return(model.RawPhoto{}, pgdb.ErrNotFound)
+				s.EXPECT().Upload(mock.Anything, rawKey, image, "image/jpeg", mock.Anything).
+					Return("https://example.com/"+rawKey, nil)
+				db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+					return raw.StorageBackend == testBackend && raw.StorageKey == rawKey &&
+						raw.MD5Hash == util2.CalculateMD5(image) && raw.SHA256Hash == sha256Hash &&
+						raw.OriginalFilename == "beach.jpg" && raw.PerceptualHash != nil
+				})).Return(nil)
+				db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+					Return([]model.SimilarPhoto{}, nil)
+				expectVariantUploads(s)
+				db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+					return photo.Filename == "beach.jpg" && len(photo.Variants) == 9
+				})).Return(nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "photo already uploaded",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				s.EXPECT().Stat(mock.Anything, key).Return(stored, nil)
+				s.EXPECT().Download(mock.Anything, key).Return(image, "image/jpeg", nil)
+				db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, sha256Hash).Return(existing, nil)
+				db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, existing.ID).Return(photo, nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus:    http.StatusOK,
+			expectedDuplicate: true,
+		},
+		{
+			name:   "completed again",
+			upload: newReceivedDirectUpload(image, true),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, existing.ID).Return(photo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "not uploaded yet",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				s.EXPECT().Stat(mock.Anything, key).Return(store.ObjectInfo{}, store.ErrNotFound)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "size mismatch",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				info := stored
+				info.Size++
+				s.EXPECT().Stat(mock.Anything, key).Return(info, nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "checksum not reported by storage",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				info := stored
+				info.SHA256 = ""
+				s.EXPECT().Stat(mock.Anything, key).Return(info, nil)
+				s.EXPECT().Download(mock.Anything, key).Return(tampered, "image/jpeg", nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "content mismatch",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				s.EXPECT().Stat(mock.Anything, key).Return(stored, nil)
+				s.EXPECT().Download(mock.Anything, key).Return(tampered, "image/jpeg", nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			// The object of a raw photo uploaded before is left as it is, the
+			// mocks fail on unexpected calls
+			name:   "content mismatch of an uploaded photo",
+			upload: newReceivedDirectUpload(image, false),
+			setup: func(db *MockDatabase, s *store.MockStorage) {
+				s.EXPECT().Stat(mock.Anything, key).Return(stored, nil)
+				s.EXPECT().Download(mock.Anything, key).Return(tampered, "image/jpeg", nil)
+				s.EXPECT().Delete(mock.Anything, key).Return(nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "resumable upload",
+			upload: func() *model.Upload {
+				upload := newReceivedDirectUpload(image, false)
+				upload.Direct = false
+				return upload
+			}(),
+			setup:          func(db *MockDatabase, s *store.MockStorage) {},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "expired",
+			upload: func() *model.Upload {
+				upload := newReceivedDirectUpload(image, false)
+				upload.ExpiresAt = time.Now().Add(-time.Minute)
+				return upload
+			}(),
+			setup:          func(db *MockDatabase, s *store.MockStorage) {},
+			expectedStatus: http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			uploads := fakeUploads(db)
+			uploads[testUploadID] = tt.upload
+
+			storage := store.NewMockStorage(t)
+			tt.setup(db, storage)
+
+			handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
+			w := httptest.NewRecorder()
+
+			handler.CompletePhotoUpload(w, newJSONRequest(t, "/photo/upload-complete",
+				gen.PhotoUploadCompleteRequest{UploadId: testUploadID}, testUserID))
+
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.PhotoUploadResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.expectedDuplicate, *resp.Duplicate)
+			assert.NotEmpty(t, resp.Photo.Id)
+			assert.NotNil(t, uploads[testUploadID].CompletedAt)
+		})
+	}
+}
+
+func TestPhotoHandler_CompletePhotoUpload_Scan(t *testing.T) {
+	image := testJPEG(t)
+	upload := newReceivedDirectUpload(image, false)
+	key := directUploadKey(*upload)
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	uploads[testUploadID] = upload
+	db.EXPECT().CreateScanResult(mock.Anything, mock.MatchedBy(func(result model.ScanResult) bool {
+		return result.SHA256Hash == *upload.SHA256Hash && result.Verdict == scan.Reject
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Stat(mock.Anything, key).Return(store.ObjectInfo{Key: key, Size: upload.UploadLength,
+		ContentType: "image/jpeg", SHA256: *upload.SHA256Hash}, nil)
+	storage.EXPECT().Download(mock.Anything, key).Return(image, "image/jpeg", nil)
+
+	// Rejected photos are deleted
+	storage.EXPECT().Delete(mock.Anything, key).Return(nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend,
+		Scanner: stubScanner{result: scan.Result{Scanner: "moderation", Verdict: scan.Reject}}}
+	w := httptest.NewRecorder()
+
+	handler.CompletePhotoUpload(w, newJSONRequest(t, "/photo/upload-complete",
+		gen.PhotoUploadCompleteRequest{UploadId: testUploadID}, testUserID))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), util2.ErrMsgFileRejected)
+	assert.Nil(t, uploads[testUploadID].CompletedAt)
+}
+
+// TestPhotoHandler_DirectUpload_LocalStorage runs a direct upload end to end
+// against local storage served over HTTP.
+func TestPhotoHandler_DirectUpload_LocalStorage(t *testing.T) {
+	image := testJPEG(t)
+	sha256Hash := util2.CalculateSHA256(image)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	storage := store.NewLocalStorage(t.TempDir(), server.URL+"/files")
+	mux.Handle("/files/", http.StripPrefix("/files/", storage))
+
+	db := NewMockDatabase(t)
+	fakeUploads(db)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, sha256Hash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.SHA256Hash == sha256Hash && raw.MD5Hash == util2.CalculateMD5(image)
+	})).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return([]model.SimilarPhoto{}, nil)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.Anything).Return(nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend,
+		URLs: store.NewURLResolver(map[string]store.Storage{testBackend: storage})}
+
+	w := httptest.NewRecorder()
+	handler.CreatePhotoUploadUrl(w, newJSONRequest(t, "/photo/upload-url", gen.PhotoUploadUrlRequest{
+		Filename:      "beach.jpg",
+		ContentType:   "image/jpeg",
+		ContentLength: int64(len(image)),
+		Sha256:        sha256Hash,
+	}, testUserID))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var presigned gen.PhotoUploadUrlResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&presigned))
+
+	// Completing before the photo has been uploaded fails
+	w = httptest.NewRecorder()
+	handler.CompletePhotoUpload(w, newJSONRequest(t, "/photo/upload-complete",
+		gen.PhotoUploadCompleteRequest{UploadId: presigned.UploadId}, testUserID))
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	req, err := http.NewRequest(string(presigned.Method), presigned.Url, bytes.NewReader(image))
+	require.NoError(t, err)
+	for name, value := range *presigned.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	w = httptest.NewRecorder()
+	handler.CompletePhotoUpload(w, newJSONRequest(t, "/photo/upload-complete",
+		gen.PhotoUploadCompleteRequest{UploadId: presigned.UploadId}, testUserID))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var uploaded gen.PhotoUploadResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&uploaded))
+	assert.True(t, strings.HasPrefix(uploaded.Photo.Url, server.URL+"/files/photos/"+uploaded.Photo.Id+"/original."),
+		uploaded.Photo.Url)
+
+	// The photo is stored under its raw photo key, and the staged photo is
+	// deleted
+	stored, _, err := storage.Download(context.Background(), "raw/"+testUserID+"/"+sha256Hash+".jpg")
+	require.NoError(t, err)
+	assert.Equal(t, image, stored)
+	exists, err := storage.Exists(context.Background(), "raw/"+testUserID+"/"+presigned.UploadId+".jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
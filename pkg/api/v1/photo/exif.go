@@ -0,0 +1,89 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+// cityDecimals are the decimal places locations are rounded to at city level,
+// about 10 km
+const cityDecimals = 1
+
+// readExif reads the EXIF data of an uploaded photo to store, with the
+// location kept as set by the user's privacy settings. Photos without readable
+// EXIF data have none stored.
+func (h PhotoHandler) readExif(ctx context.Context, userID string, data []byte) (*string, error) {
+	exif, err := imaging.ReadExif(data)
+	if err != nil {
+		util2.GetLogger(ctx).Info("Ignoring invalid EXIF data", "error", err, "user_id", userID)
+		return nil, nil
+	} else if len(exif) == 0 {
+		return nil, nil
+	}
+
+	if hasLocation(exif) {
+		privacy, err := h.DB.GetLocationPrivacy(ctx, userID)
+		if errors.Is(err, pgdb.ErrNotFound) {
+			privacy = model.LocationStrip
+		} else if err != nil {
+			return nil, err
+		}
+		applyLocationPrivacy(exif, privacy)
+	}
+
+	encoded, err := json.Marshal(exif)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode EXIF data: %w", err)
+	}
+	s := string(encoded)
+	return &s, nil
+}
+
+// hasLocation reports whether EXIF data has any GPS tags.
+func hasLocation(exif imaging.Exif) bool {
+	for name := range exif {
+		if strings.HasPrefix(name, "GPS") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLocationPrivacy strips or rounds the location of EXIF data unless the
+// user keeps it. Unknown settings strip it.
+func applyLocationPrivacy(exif imaging.Exif, privacy model.LocationPrivacy) {
+	switch privacy {
+	case model.LocationKeep:
+	case model.LocationCity:
+		exif.RoundLocation(cityDecimals)
+	default:
+		exif.StripLocation()
+	}
+}
+
+// photoLocation returns the location in the stored EXIF data of a raw photo,
+// which the owner's privacy settings were already applied to, or nil if it
+// has none.
+func photoLocation(raw model.RawPhoto) (lat, lon *float64) {
+	if raw.ExifData == nil {
+		return nil, nil
+	}
+
+	var exif imaging.Exif
+	if err := json.Unmarshal([]byte(*raw.ExifData), &exif); err != nil {
+		return nil, nil
+	}
+
+	if la, lo, ok := exif.Location(); ok {
+		return &la, &lo
+	}
+	return nil, nil
+}
@@ -0,0 +1,300 @@
+package photo
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// testGPSJPEG returns a JPEG taken by a Sony camera at 49°15'36"N 123°6'W.
+func testGPSJPEG(t *testing.T) []byte {
+	be := binary.BigEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = be.AppendUint16(b, tag)
+		b = be.AppendUint16(b, typ)
+		b = be.AppendUint32(b, count)
+		return be.AppendUint32(b, value)
+	}
+	ascii := func(s string) uint32 { return be.Uint32(append([]byte(s), 0, 0, 0, 0)) }
+
+	// IFD0 at 8 points to the GPS IFD at 38, whose coordinates follow at 92
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 2}
+	tiff = entry(tiff, 0x010f, 2, 4, ascii("Sony"))
+	tiff = entry(tiff, 0x8825, 4, 1, 38)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 4)
+	tiff = entry(tiff, 0x0001, 2, 2, ascii("N"))
+	tiff = entry(tiff, 0x0002, 5, 3, 92)
+	tiff = entry(tiff, 0x0003, 2, 2, ascii("W"))
+	tiff = entry(tiff, 0x0004, 5, 3, 116)
+	tiff = append(tiff, 0, 0, 0, 0)
+	for _, v := range []uint32{49, 1, 15, 1, 36, 1, 123, 1, 6, 1, 0, 1} {
+		tiff = be.AppendUint32(tiff, v)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xff, 0xe1}, be.AppendUint16(nil, uint16(len(payload)+2))...)
+	segment = append(segment, payload...)
+
+	img := testJPEG(t)
+	return append(append(append([]byte{}, img[:2]...), segment...), img[2:]...)
+}
+
+func TestPhotoHandler_ReadExif(t *testing.T) {
+	tests := []struct {
+		name     string
+		privacy  model.LocationPrivacy
+		err      error
+		expected imaging.Exif
+	}{
+		{
+			name:     "strip",
+			privacy:  model.LocationStrip,
+			expected: imaging.Exif{"Make": "Sony"},
+		},
+		{
+			name:     "city",
+			privacy:  model.LocationCity,
+			expected: imaging.Exif{"Make": "Sony", "GPSLatitude": 49.3, "GPSLongitude": -123.1},
+		},
+		{
+			name:     "keep",
+			privacy:  model.LocationKeep,
+			expected: imaging.Exif{"Make": "Sony", "GPSLatitude": 49.26, "GPSLongitude": -123.1},
+		},
+		{
+			name:     "unknown user",
+			err:      pgdb.ErrNotFound,
+			expected: imaging.Exif{"Make": "Sony"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			db.EXPECT().GetLocationPrivacy(mock.Anything, testUserID).Return(tt.privacy, tt.err)
+			handler := PhotoHandler{DB: db}
+
+			ctx := context.WithValue(context.Background(), util2.ContextLogger, slog.Default())
+			data, err := handler.readExif(ctx, testUserID, testGPSJPEG(t))
+			if err != nil {
+				t.Fatalf("Failed to read EXIF data: %v", err)
+			}
+
+			var exif imaging.Exif
+			if err := json.Unmarshal([]byte(*data), &exif); err != nil {
+				t.Fatalf("Failed to parse EXIF data: %v", err)
+			}
+			if len(exif) != len(tt.expected) || exif["Make"] != tt.expected["Make"] {
+				t.Fatalf("Expected %v, got %v", tt.expected, exif)
+			}
+			for _, tag := range []string{"GPSLatitude", "GPSLongitude"} {
+				if want, ok := tt.expected[tag].(float64); ok {
+					if got, _ := exif[tag].(float64); got < want-1e-9 || got > want+1e-9 {
+						t.Errorf("Expected %s %v, got %v", tag, want, exif[tag])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_ReadExif_NoLocation(t *testing.T) {
+	handler := PhotoHandler{DB: NewMockDatabase(t)}
+	ctx := context.WithValue(context.Background(), util2.ContextLogger, slog.Default())
+
+	// The privacy settings aren't looked up for photos without a location
+	data, err := handler.readExif(ctx, testUserID, testJPEG(t))
+	if err != nil || data != nil {
+		t.Errorf("Expected no EXIF data, got %v, %v", data, err)
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_Exif(t *testing.T) {
+	image := testGPSJPEG(t)
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().GetLocationPrivacy(mock.Anything, testUserID).Return(model.LocationCity, nil)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.ExifData != nil && *raw.ExifData == `{"GPSLatitude":49.3,"GPSLongitude":-123.1,"Make":"Sony"}`
+	})).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).Return(nil, nil)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+		return photo.Latitude != nil && *photo.Latitude == 49.3 && photo.Longitude != nil && *photo.Longitude == -123.1 &&
+			isVariantKey(photo.OriginalKey)
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, key string, data []byte, _ string, _ ...store.UploadOption) (string, error) {
+			// Variants, including the original, don't keep any of the EXIF data
+			if isVariantKey(key) {
+				if exif, _ := imaging.ReadExif(data); exif != nil {
+					t.Errorf("Expected variant %s without EXIF data, got %v", key, exif)
+				}
+			}
+			return "https://example.com/" + key, nil
+		})
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestPhotoHandler_GetRawPhoto(t *testing.T) {
+	rawID := "3f2e1d0c-9b8a-4c7d-8e6f-5a4b3c2d1e0f"
+	exif := `{"Make":"Sony","BodySerialNumber":"123456","GPSLatitude":49.3,"GPSLongitude":-123.1}`
+	quarantinedAt := time.Now()
+	adminID := "1c2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e5f"
+	t.Setenv("ADMIN_USER_IDS", adminID)
+
+	tests := []struct {
+		name           string
+		id             string
+		userID         string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+		expectedTags   []string
+	}{
+		{
+			name:           "invalid id",
+			id:             "raw_123456",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "not found",
+			id:   rawID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "database failure",
+			id:   rawID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{}, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "owner",
+			id:     rawID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, ExifData: &exif}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"BodySerialNumber", "GPSLatitude", "GPSLongitude", "Make"},
+		},
+		{
+			name:   "other user",
+			id:     rawID,
+			userID: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, ExifData: &exif}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"Make"},
+		},
+		{
+			name:   "quarantined, owner",
+			id:     rawID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, ExifData: &exif, QuarantinedAt: &quarantinedAt}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"BodySerialNumber", "GPSLatitude", "GPSLongitude", "Make"},
+		},
+		{
+			name:   "quarantined, admin",
+			id:     rawID,
+			userID: adminID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, ExifData: &exif, QuarantinedAt: &quarantinedAt}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"Make"},
+		},
+		{
+			name:   "quarantined, other user",
+			id:     rawID,
+			userID: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, ExifData: &exif, QuarantinedAt: &quarantinedAt}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "quarantined, anonymous",
+			id:   rawID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).
+					Return(model.RawPhoto{ID: rawID, UserID: testUserID, QuarantinedAt: &quarantinedAt}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodGet, "/photo/raw/"+tt.id, nil)
+			ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+			ctx = context.WithValue(ctx, util2.ContextUserID, tt.userID)
+			w := httptest.NewRecorder()
+
+			handler.GetRawPhoto(w, req.WithContext(ctx), tt.id)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.RawPhotoDetailsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if resp.RawPhoto.ExifData == nil || len(*resp.RawPhoto.ExifData) != len(tt.expectedTags) {
+				t.Fatalf("Expected tags %v, got %v", tt.expectedTags, resp.RawPhoto.ExifData)
+			}
+			for _, tag := range tt.expectedTags {
+				if _, ok := (*resp.RawPhoto.ExifData)[tag]; !ok {
+					t.Errorf("Expected tag %s, got %v", tag, *resp.RawPhoto.ExifData)
+				}
+			}
+		})
+	}
+}
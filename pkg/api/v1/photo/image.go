@@ -0,0 +1,189 @@
+package photo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// imageCacheControl caches resized images for a year, since the image
+// addressed by a signed query never changes
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// GetImage resizes a photo as described by a signed query. Resized images are
+// stored once rendered and served from storage afterwards. Unless the query
+// sets the format, the image is encoded in the best format the Accept header
+// allows.
+func (h PhotoHandler) GetImage(w http.ResponseWriter, r *http.Request, photoID string, params gen.GetImageParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if _, err := uuid.Parse(photoID); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", photoID)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	opts := newImageOptions(params)
+	if err := opts.Validate(); err != nil {
+		logger.Info("Invalid image options", "error", err, "id", photoID)
+		http.Error(w, util2.ErrMsgInvalidImageOptions, http.StatusBadRequest)
+		return
+	}
+
+	// The signature is checked before anything is looked up, so unsigned
+	// requests can't cause any work
+	if !imaging.Verify(config.GetImageSigningKey(), photoID, opts, params.Sig) {
+		logger.Info("Invalid image signature", "id", photoID)
+		http.Error(w, util2.ErrMsgInvalidSignature, http.StatusForbidden)
+		return
+	}
+
+	// Images without a format are served in the best format the client
+	// accepts, falling back to the format of the photo
+	if opts.Format == "" {
+		w.Header().Set("Vary", "Accept")
+		opts.Format = imaging.Negotiate(r.Header.Get("Accept"), config.GetPhotoFormats(), "")
+	}
+
+	photo, err := h.DB.GetPhotoByID(r.Context(), photoID)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", photoID)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", photoID)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+
+	// Options producing the same image share the stored image and ETag
+	opts = opts.Normalize(strings.TrimPrefix(photo.MimeType, "image/"))
+	hash := opts.Hash(photoID)
+	etag := `"` + hash + `"`
+
+	if params.IfNoneMatch != nil && matchETag(*params.IfNoneMatch, etag) {
+		w.Header().Set("Cache-Control", imageCacheControl)
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	key := imageKey(photoID, hash, opts.Format)
+	data, _, err := h.Storage.Download(r.Context(), key)
+	if errors.Is(err, store.ErrNotFound) {
+		data, err = h.renderImage(r.Context(), photo, opts)
+		if err != nil {
+			logger.Error("Failed to resize image", "error", err, "id", photoID, "key", key)
+			http.Error(w, util2.ErrMsgFailedToResize, http.StatusInternalServerError)
+			return
+		}
+
+		// The image can still be served if it can't be stored, it's rendered
+		// again by the next request
+		_, err := h.Storage.Upload(r.Context(), key, data, imaging.ContentType(opts.Format),
+			store.WithCacheControl(imageCacheControl), store.WithMetadata(map[string]string{metadataPhotoID: photoID}))
+		if err != nil {
+			logger.Warn("Failed to store resized image", "error", err, "id", photoID, "key", key)
+		}
+	} else if err != nil {
+		logger.Error("Failed to get resized image", "error", err, "id", photoID, "key", key)
+		http.Error(w, util2.ErrMsgFailedToResize, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", imaging.ContentType(opts.Format))
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		logger.Error("Failed to write image", "error", err, "id", photoID)
+	}
+}
+
+// renderImage resizes the original of a photo.
+func (h PhotoHandler) renderImage(ctx context.Context, photo model.Photo, opts imaging.Options) ([]byte, error) {
+	raw, err := h.DB.GetRawPhotoByID(ctx, photo.RawPhotoID)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := h.backend(raw.StorageBackend)
+	if err != nil {
+		return nil, err
+	}
+	original, _, err := storage.Download(ctx, raw.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download original: %w", err)
+	}
+
+	img, _, err := decoder().Decode(ctx, original, raw.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the orientation and color profile of the original are kept
+	var buf bytes.Buffer
+	meta := imaging.ReadMetadata(original)
+	if err := imaging.EncodeQuality(&buf, imaging.Transform(img, opts), opts.Format, opts.Quality, meta); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// newImageOptions converts the query parameters of a resize request.
+func newImageOptions(params gen.GetImageParams) imaging.Options {
+	var opts imaging.Options
+	if params.W != nil {
+		opts.Width = *params.W
+	}
+	if params.H != nil {
+		opts.Height = *params.H
+	}
+	if params.Fit != nil {
+		opts.Fit = string(*params.Fit)
+	}
+	if params.Fmt != nil {
+		opts.Format = string(*params.Fmt)
+	}
+	if params.Q != nil {
+		opts.Quality = *params.Q
+	}
+	return opts
+}
+
+// matchETag reports whether an If-None-Match header matches the ETag.
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// imageKey returns the storage key of a resized image, addressed by the photo
+// and the hash of the options it was resized with.
+func imageKey(photoID, hash, format string) string {
+	return fmt.Sprintf("%s%s%s", imagePrefix(photoID), hash, imaging.Extension(format))
+}
+
+// imagePrefix returns the prefix of the storage keys of the resized images of
+// a photo.
+func imagePrefix(photoID string) string {
+	return "derived/" + photoID + "/"
+}
@@ -0,0 +1,294 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+const testSigningKey = "secret"
+
+// testRawPhoto is the original the test photo was processed from.
+var testRawPhoto = model.RawPhoto{
+	ID:             "5d2c8e1f-7a3b-4c6d-8e9f-0a1b2c3d4e5f",
+	UserID:         testUserID,
+	MimeType:       "image/jpeg",
+	SHA256Hash:     "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	StorageBackend: testBackend,
+	StorageKey:     "raw/" + testUserID + "/2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824.jpg",
+}
+
+var testPhoto = model.Photo{ID: testPhotoID, RawPhotoID: testRawPhoto.ID, MimeType: "image/jpeg"}
+
+// newImageRequest creates a resize request signed with the test key.
+func newImageRequest(t *testing.T, photoID string, opts imaging.Options) (*http.Request, gen.GetImageParams) {
+	t.Setenv("IMAGE_SIGNING_KEY", testSigningKey)
+
+	params := gen.GetImageParams{Sig: imaging.Sign([]byte(testSigningKey), photoID, opts)}
+	if opts.Width > 0 {
+		params.W = &opts.Width
+	}
+	if opts.Height > 0 {
+		params.H = &opts.Height
+	}
+	if opts.Fit != "" {
+		fit := gen.GetImageParamsFit(opts.Fit)
+		params.Fit = &fit
+	}
+	if opts.Format != "" {
+		format := gen.GetImageParamsFmt(opts.Format)
+		params.Fmt = &format
+	}
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/img/"+photoID+"?"+imaging.SignedQuery([]byte(testSigningKey), photoID, opts), nil)
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	return req.WithContext(ctx), params
+}
+
+func TestPhotoHandler_GetImage_Render(t *testing.T) {
+	opts := imaging.Options{Width: 8, Height: 4, Fit: imaging.FitCover}
+	hash := opts.Normalize(imaging.FormatJPEG).Hash(testPhotoID)
+	key := "derived/" + testPhotoID + "/" + hash + ".jpg"
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+	db.EXPECT().GetRawPhotoByID(mock.Anything, testRawPhoto.ID).Return(testRawPhoto, nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Download(mock.Anything, key).Return(nil, "", store.ErrNotFound)
+	storage.EXPECT().Download(mock.Anything, testRawPhoto.StorageKey).Return(testJPEG(t), "image/jpeg", nil)
+	storage.EXPECT().Upload(mock.Anything, key, mock.Anything, "image/jpeg", mock.Anything).
+		Return("https://example.com/"+key, nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	req, params := newImageRequest(t, testPhotoID, opts)
+	w := httptest.NewRecorder()
+
+	handler.GetImage(w, req, testPhotoID, params)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.Equal(t, imageCacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, `"`+hash+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	img, format, err := imaging.Decode(w.Body.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, imaging.FormatJPEG, format)
+	assert.Equal(t, 8, img.Bounds().Dx())
+	assert.Equal(t, 4, img.Bounds().Dy())
+}
+
+func TestPhotoHandler_GetImage_Negotiate(t *testing.T) {
+	opts := imaging.Options{Width: 8}
+	hash := imaging.Options{Width: 8, Format: imaging.FormatWebP}.Normalize(imaging.FormatJPEG).Hash(testPhotoID)
+	key := "derived/" + testPhotoID + "/" + hash + ".webp"
+
+	// Browsers accepting WebP get WebP instead of the format of the photo
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+	db.EXPECT().GetRawPhotoByID(mock.Anything, testRawPhoto.ID).Return(testRawPhoto, nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Download(mock.Anything, key).Return(nil, "", store.ErrNotFound)
+	storage.EXPECT().Download(mock.Anything, testRawPhoto.StorageKey).Return(testJPEG(t), "image/jpeg", nil)
+	storage.EXPECT().Upload(mock.Anything, key, mock.Anything, "image/webp", mock.Anything).
+		Return("https://example.com/"+key, nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	req, params := newImageRequest(t, testPhotoID, opts)
+	req.Header.Set("Accept", "image/avif,image/webp,image/apng,image/*,*/*;q=0.8")
+	w := httptest.NewRecorder()
+
+	handler.GetImage(w, req, testPhotoID, params)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/webp", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.Equal(t, `"`+hash+`"`, w.Header().Get("ETag"))
+
+	img, format, err := imaging.Decode(w.Body.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, imaging.FormatWebP, format)
+	assert.Equal(t, 8, img.Bounds().Dx())
+}
+
+func TestPhotoHandler_GetImage_Stored(t *testing.T) {
+	opts := imaging.Options{Width: 8, Format: imaging.FormatPNG}
+	hash := opts.Normalize(imaging.FormatJPEG).Hash(testPhotoID)
+
+	// The stored image is served without resizing the original again
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Download(mock.Anything, "derived/"+testPhotoID+"/"+hash+".png").
+		Return(testPNG(t), "image/png", nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	req, params := newImageRequest(t, testPhotoID, opts)
+	w := httptest.NewRecorder()
+
+	handler.GetImage(w, req, testPhotoID, params)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, testPNG(t), w.Body.Bytes())
+
+	// The requested format isn't negotiated
+	assert.Empty(t, w.Header().Get("Vary"))
+}
+
+func TestPhotoHandler_GetImage_StoreFailure(t *testing.T) {
+	opts := imaging.Options{Width: 8}
+
+	// The image is served even if it can't be stored
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+	db.EXPECT().GetRawPhotoByID(mock.Anything, testRawPhoto.ID).Return(testRawPhoto, nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Download(mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "derived/")
+	})).Return(nil, "", store.ErrNotFound)
+	storage.EXPECT().Download(mock.Anything, testRawPhoto.StorageKey).Return(testJPEG(t), "image/jpeg", nil)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, "image/jpeg", mock.Anything).
+		Return("", errors.New("upload failed"))
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	req, params := newImageRequest(t, testPhotoID, opts)
+	w := httptest.NewRecorder()
+
+	handler.GetImage(w, req, testPhotoID, params)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestPhotoHandler_GetImage_NotModified(t *testing.T) {
+	opts := imaging.Options{Width: 8}
+	etag := `"` + opts.Normalize(imaging.FormatJPEG).Hash(testPhotoID) + `"`
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+
+	handler := PhotoHandler{DB: db, Storage: store.NewMockStorage(t)}
+	req, params := newImageRequest(t, testPhotoID, opts)
+	ifNoneMatch := `"other", W/` + etag
+	params.IfNoneMatch = &ifNoneMatch
+	w := httptest.NewRecorder()
+
+	handler.GetImage(w, req, testPhotoID, params)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestPhotoHandler_GetImage_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		opts           imaging.Options
+		modify         func(*gen.GetImageParams)
+		setupMock      func(*MockDatabase, *store.MockStorage)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "invalid ID",
+			id:             "not-a-uuid",
+			opts:           imaging.Options{Width: 8},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  util2.ErrMsgInvalidUUID,
+		},
+		{
+			name:           "too large",
+			id:             testPhotoID,
+			opts:           imaging.Options{Width: imaging.MaxDimension + 1},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  util2.ErrMsgInvalidImageOptions,
+		},
+		{
+			name: "tampered options",
+			id:   testPhotoID,
+			opts: imaging.Options{Width: 8},
+			modify: func(params *gen.GetImageParams) {
+				width := 4000
+				params.W = &width
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedError:  util2.ErrMsgInvalidSignature,
+		},
+		{
+			name: "unsigned",
+			id:   testPhotoID,
+			opts: imaging.Options{Width: 8},
+			modify: func(params *gen.GetImageParams) {
+				params.Sig = ""
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedError:  util2.ErrMsgInvalidSignature,
+		},
+		{
+			name: "photo not found",
+			id:   testPhotoID,
+			opts: imaging.Options{Width: 8},
+			setupMock: func(m *MockDatabase, s *store.MockStorage) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  util2.ErrMsgPhotoNotFound,
+		},
+		{
+			name: "original missing",
+			id:   testPhotoID,
+			opts: imaging.Options{Width: 8},
+			setupMock: func(m *MockDatabase, s *store.MockStorage) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(testPhoto, nil)
+				m.EXPECT().GetRawPhotoByID(mock.Anything, testRawPhoto.ID).Return(testRawPhoto, nil)
+				s.EXPECT().Download(mock.Anything, mock.Anything).Return(nil, "", store.ErrNotFound)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  util2.ErrMsgFailedToResize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			storage := store.NewMockStorage(t)
+			if tt.setupMock != nil {
+				tt.setupMock(db, storage)
+			}
+
+			handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+			req, params := newImageRequest(t, tt.id, tt.opts)
+			if tt.modify != nil {
+				tt.modify(&params)
+			}
+			w := httptest.NewRecorder()
+
+			handler.GetImage(w, req, tt.id, params)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedError)
+			assert.Empty(t, w.Header().Get("Cache-Control"))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package photo
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/pgdb"
+)
+
+// LikePhoto likes a photo for the requesting user.
+// POST /photo/{id}/like
+func (h PhotoHandler) LikePhoto(w http.ResponseWriter, r *http.Request, id string, params gen.LikePhotoParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Like without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	if status, message := h.checkPhotoExists(r, logger, id); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := h.DB.LikePhoto(r.Context(), userID, id); err != nil {
+		logger.Error("Failed to like photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToLikePhoto, http.StatusInternalServerError)
+		return
+	}
+
+	count, err := h.DB.CountPhotoLikes(r.Context(), id)
+	if err != nil {
+		logger.Error("Failed to count photo likes", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToLikePhoto, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Photo liked", "id", id)
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, gen.LikeResponse{
+		PhotoId:   id,
+		Liked:     true,
+		LikeCount: count,
+	})
+}
+
+// checkPhotoExists returns the HTTP status and message to respond with if the
+// photo can't be found.
+func (h PhotoHandler) checkPhotoExists(r *http.Request, logger *slog.Logger, id string) (int, string) {
+	_, err := h.DB.GetPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		return http.StatusNotFound, util2.ErrMsgPhotoNotFound
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", id)
+		return http.StatusInternalServerError, util2.ErrMsgFailedToGetPhoto
+	}
+
+	return http.StatusOK, ""
+}
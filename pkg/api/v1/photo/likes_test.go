@@ -0,0 +1,104 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+const testPhotoID = "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+
+func TestPhotoHandler_LikePhoto(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		userID         string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:   "liked",
+			id:     testPhotoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil)
+				m.EXPECT().LikePhoto(mock.Anything, testUserID, testPhotoID).Return(nil)
+				m.EXPECT().CountPhotoLikes(mock.Anything, testPhotoID).Return(3, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "without user",
+			id:             testPhotoID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid ID",
+			id:             "not-a-uuid",
+			userID:         testUserID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "photo not found",
+			id:     testPhotoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "database error",
+			id:     testPhotoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, testPhotoID).Return(model.Photo{ID: testPhotoID}, nil)
+				m.EXPECT().LikePhoto(mock.Anything, testUserID, testPhotoID).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodPost, "/photo/"+tt.id+"/like", nil)
+			ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+			ctx = context.WithValue(ctx, util2.ContextUserID, tt.userID)
+			w := httptest.NewRecorder()
+
+			handler.LikePhoto(w, req.WithContext(ctx), tt.id, gen.LikePhotoParams{})
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.LikeResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !resp.Liked || resp.LikeCount != 3 || resp.PhotoId != testPhotoID {
+				t.Errorf("Unexpected response: %+v", resp)
+			}
+		})
+	}
+}
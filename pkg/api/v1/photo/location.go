@@ -0,0 +1,155 @@
+package photo
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/geo"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// Limits of nearby photo queries
+const (
+	maxNearbyRadiusKm  = 100
+	defaultNearbyLimit = 20
+	maxNearbyLimit     = 100
+)
+
+// maxPlaceNameLength is the length of the place_name column
+const maxPlaceNameLength = 255
+
+// GetNearbyPhotos lists the photos within a radius of a location, nearest
+// first.
+// GET /photos/nearby
+func (h PhotoHandler) GetNearbyPhotos(w http.ResponseWriter, r *http.Request, params gen.GetNearbyPhotosParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if !geo.ValidCoordinates(params.Lat, params.Lon) || !(params.RadiusKm > 0) ||
+		params.RadiusKm > maxNearbyRadiusKm {
+		logger.Info("Invalid nearby location", "lat", params.Lat, "lon", params.Lon, "radius_km", params.RadiusKm)
+		http.Error(w, util2.ErrMsgInvalidLocation, http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := defaultNearbyLimit, 0
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if params.Offset != nil {
+		offset = *params.Offset
+	}
+	if limit < 1 || limit > maxNearbyLimit || offset < 0 {
+		logger.Info("Invalid nearby pagination", "limit", limit, "offset", offset)
+		http.Error(w, util2.ErrMsgInvalidPagination, http.StatusBadRequest)
+		return
+	}
+
+	// One more photo than requested tells whether there are more
+	photos, err := h.DB.GetPhotosNearby(r.Context(), params.Lat, params.Lon, params.RadiusKm, limit+1, offset)
+	if err != nil {
+		logger.Error("Failed to get nearby photos", "error", err,
+			"lat", params.Lat, "lon", params.Lon, "radius_km", params.RadiusKm)
+		http.Error(w, util2.ErrMsgFailedToGetNearbyPhotos, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gen.NearbyPhotosResponse{
+		Photos:  make([]gen.NearbyPhoto, 0, min(len(photos), limit)),
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: len(photos) > limit,
+	}
+	for i := range photos[:min(len(photos), limit)] {
+		nearby := photos[i].ToNearbyPhoto()
+		err := h.deliverURL(r.Context(), store.ClassThumbnail, photos[i].StorageBackend, photos[i].ThumbnailKey,
+			&nearby.ThumbnailUrl)
+		if err != nil {
+			logger.Error("Failed to deliver nearby photo", "error", err, "id", nearby.Id)
+			http.Error(w, util2.ErrMsgFailedToGetNearbyPhotos, http.StatusInternalServerError)
+			return
+		}
+		resp.Photos = append(resp.Photos, nearby)
+	}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// UpdatePhotoPlace sets or clears the place name of a photo of the current
+// user.
+// PUT /photo/{id}/place
+func (h PhotoHandler) UpdatePhotoPlace(w http.ResponseWriter, r *http.Request, id string) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Place update without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	var req gen.PhotoPlace
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Info("Invalid place request", "error", err)
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	// An empty place name clears it
+	var placeName *string
+	if req.PlaceName != nil {
+		if name := strings.TrimSpace(*req.PlaceName); name != "" {
+			placeName = &name
+		}
+	}
+	if placeName != nil && utf8.RuneCountInString(*placeName) > maxPlaceNameLength {
+		logger.Info("Place name too long", "id", id, "length", utf8.RuneCountInString(*placeName))
+		http.Error(w, util2.ErrMsgInvalidPlaceName, http.StatusBadRequest)
+		return
+	}
+
+	photo, err := h.DB.GetPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+	if photo.UserID != userID {
+		logger.Info("Place update of another user's photo", "id", id, "user_id", userID)
+		http.Error(w, util2.ErrMsgNotPhotoOwner, http.StatusForbidden)
+		return
+	}
+
+	err = h.DB.UpdatePhotoPlace(r.Context(), id, placeName)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to update photo place", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToUpdatePhoto, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Photo place updated", "id", id, "user_id", userID)
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, gen.PhotoPlace{PlaceName: placeName})
+}
@@ -0,0 +1,286 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+func TestPhotoHandler_GetNearbyPhotos(t *testing.T) {
+	lat, lon := 49.3017, -123.1417
+	nearby := []model.NearbyPhoto{
+		{Photo: model.Photo{ID: "a", Latitude: &lat, Longitude: &lon}, DistanceKm: 0.9},
+		{Photo: model.Photo{ID: "b", Latitude: &lat, Longitude: &lon}, DistanceKm: 2.6},
+	}
+
+	tests := []struct {
+		name            string
+		params          gen.GetNearbyPhotosParams
+		setupMock       func(*MockDatabase)
+		expectedStatus  int
+		expectedPhotos  []string
+		expectedHasMore bool
+	}{
+		{
+			name:           "invalid latitude",
+			params:         gen.GetNearbyPhotosParams{Lat: 91, Lon: 0, RadiusKm: 5},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid longitude",
+			params:         gen.GetNearbyPhotosParams{Lat: 0, Lon: -181, RadiusKm: 5},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "radius too large",
+			params:         gen.GetNearbyPhotosParams{Lat: 0, Lon: 0, RadiusKm: 101},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no radius",
+			params:         gen.GetNearbyPhotosParams{Lat: 0, Lon: 0},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid limit",
+			params:         gen.GetNearbyPhotosParams{Lat: 0, Lon: 0, RadiusKm: 5, Limit: intPtr(101)},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid offset",
+			params:         gen.GetNearbyPhotosParams{Lat: 0, Lon: 0, RadiusKm: 5, Offset: intPtr(-1)},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "database error",
+			params: gen.GetNearbyPhotosParams{Lat: 49.2827, Lon: -123.1207, RadiusKm: 5},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotosNearby(mock.Anything, 49.2827, -123.1207, 5.0, 21, 0).
+					Return(nil, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "default page",
+			params: gen.GetNearbyPhotosParams{Lat: 49.2827, Lon: -123.1207, RadiusKm: 5},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotosNearby(mock.Anything, 49.2827, -123.1207, 5.0, 21, 0).Return(nearby, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPhotos: []string{"a", "b"},
+		},
+		{
+			name: "more photos",
+			params: gen.GetNearbyPhotosParams{Lat: 49.2827, Lon: -123.1207, RadiusKm: 5,
+				Limit: intPtr(1), Offset: intPtr(3)},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotosNearby(mock.Anything, 49.2827, -123.1207, 5.0, 2, 3).Return(nearby, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedPhotos:  []string{"a"},
+			expectedHasMore: true,
+		},
+		{
+			name:   "none",
+			params: gen.GetNearbyPhotosParams{Lat: 49.2827, Lon: -123.1207, RadiusKm: 5},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotosNearby(mock.Anything, 49.2827, -123.1207, 5.0, 21, 0).
+					Return([]model.NearbyPhoto{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPhotos: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodGet, "/photos/nearby", nil)
+			req = req.WithContext(context.WithValue(req.Context(), util2.ContextLogger, slog.Default()))
+			w := httptest.NewRecorder()
+
+			handler.GetNearbyPhotos(w, req, tt.params)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.NearbyPhotosResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(resp.Photos) != len(tt.expectedPhotos) {
+				t.Fatalf("Expected photos %v, got %+v", tt.expectedPhotos, resp.Photos)
+			}
+			for i, id := range tt.expectedPhotos {
+				if resp.Photos[i].Id != id || resp.Photos[i].Latitude != lat || resp.Photos[i].Longitude != lon {
+					t.Errorf("Expected photo %s at %v, %v, got %+v", id, lat, lon, resp.Photos[i])
+				}
+			}
+			if resp.HasMore != tt.expectedHasMore {
+				t.Errorf("Expected hasMore %v, got %v", tt.expectedHasMore, resp.HasMore)
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_UpdatePhotoPlace(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	owned := model.Photo{ID: photoID, UserID: testUserID}
+	place := "Stanley Park"
+
+	tests := []struct {
+		name           string
+		id             string
+		userID         string
+		body           string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+		expectedPlace  *string
+	}{
+		{
+			name:           "invalid id",
+			id:             "photo_123456",
+			userID:         testUserID,
+			body:           `{"placeName":"Stanley Park"}`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no user",
+			id:             photoID,
+			body:           `{"placeName":"Stanley Park"}`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid body",
+			id:             photoID,
+			userID:         testUserID,
+			body:           `{"placeName":`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "place name too long",
+			id:             photoID,
+			userID:         testUserID,
+			body:           `{"placeName":"` + strings.Repeat("é", 256) + `"}`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "not found",
+			id:     photoID,
+			userID: testUserID,
+			body:   `{"placeName":"Stanley Park"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "other user",
+			id:     photoID,
+			userID: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			body:   `{"placeName":"Stanley Park"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "database error",
+			id:     photoID,
+			userID: testUserID,
+			body:   `{"placeName":"Stanley Park"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().UpdatePhotoPlace(mock.Anything, photoID, &place).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "set",
+			id:     photoID,
+			userID: testUserID,
+			body:   `{"placeName":"  Stanley Park "}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().UpdatePhotoPlace(mock.Anything, photoID, &place).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPlace:  &place,
+		},
+		{
+			name:   "clear",
+			id:     photoID,
+			userID: testUserID,
+			body:   `{"placeName":""}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().UpdatePhotoPlace(mock.Anything, photoID, (*string)(nil)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodPut, "/photo/"+tt.id+"/place", strings.NewReader(tt.body))
+			ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+			ctx = context.WithValue(ctx, util2.ContextUserID, tt.userID)
+			w := httptest.NewRecorder()
+
+			handler.UpdatePhotoPlace(w, req.WithContext(ctx), tt.id)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.PhotoPlace
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if (resp.PlaceName == nil) != (tt.expectedPlace == nil) ||
+				resp.PlaceName != nil && *resp.PlaceName != *tt.expectedPlace {
+				t.Errorf("Expected place name %v, got %v", tt.expectedPlace, resp.PlaceName)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
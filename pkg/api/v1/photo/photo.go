@@ -1,30 +1,77 @@
 package photo
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"jelly/pkg/api/v1/admin"
 	"jelly/pkg/api/v1/gen"
 	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/cdn"
 	"jelly/pkg/config"
+	"jelly/pkg/imaging"
 	"jelly/pkg/model"
 	"jelly/pkg/pgdb"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
 )
 
-// PhotoHandler implements photo upload endpoints.
+// Database defines the persistence operations used by the photo handlers.
+type Database interface {
+	CreateRawPhoto(ctx context.Context, photo model.RawPhoto) error
+	GetRawPhotoByID(ctx context.Context, rawPhotoID string) (model.RawPhoto, error)
+	GetRawPhotoByHash(ctx context.Context, userID, sha256Hash string) (model.RawPhoto, error)
+	GetLocationPrivacy(ctx context.Context, userID string) (model.LocationPrivacy, error)
+	CreateScanResult(ctx context.Context, result model.ScanResult) error
+	CreatePhoto(ctx context.Context, photo model.Photo) error
+	GetPhotoByID(ctx context.Context, photoID string) (model.Photo, error)
+	GetPhotoByRawPhotoID(ctx context.Context, rawPhotoID string) (model.Photo, error)
+	UpdatePhotoPlace(ctx context.Context, photoID string, placeName *string) error
+	GetPhotosNearby(ctx context.Context, lat, lon, radiusKm float64, limit, offset int) ([]model.NearbyPhoto, error)
+	GetSimilarPhotos(ctx context.Context, hash int64, excludeRawPhotoID string, maxDistance, limit int) (
+		[]model.SimilarPhoto, error)
+	GetSimilarUserPhotos(ctx context.Context, userID string, hash int64, maxDistance, limit int) (
+		[]model.SimilarPhoto, error)
+	DeletePhoto(ctx context.Context, photoID string, deletionDuration time.Duration) error
+
+	LikePhoto(ctx context.Context, userID, photoID string) error
+	CountPhotoLikes(ctx context.Context, photoID string) (int, error)
+	CreateComment(ctx context.Context, comment model.Comment) error
+	CountPhotoComments(ctx context.Context, photoID string) (int, error)
+
+	CreateUpload(ctx context.Context, upload model.Upload) error
+	GetUpload(ctx context.Context, uploadID string) (model.Upload, error)
+	UpdateUploadOffset(ctx context.Context, uploadID string, from, to int64) error
+	CompleteUpload(ctx context.Context, uploadID, rawPhotoID string) error
+	DeleteUpload(ctx context.Context, uploadID string) error
+}
+
+// PhotoHandler implements photo upload endpoints. Uploaded files are scanned
+// by the Scanner, if set.
 type PhotoHandler struct {
-	DB *pgdb.Client
+	DB      Database
+	Storage store.Storage
+	Backend string             // ID of the storage backend rows refer to Storage by
+	URLs    *store.URLResolver // Resolves the URLs clients fetch stored objects from
+	CDN     cdn.CDN            // Purges deleted objects from the CDN cache, if set
+	Scanner scan.Scanner
 }
 
 // UploadPhoto handles photo upload with optional caption and tags and processing.
 // POST /photo
-func (h PhotoHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+func (h PhotoHandler) UploadPhoto(w http.ResponseWriter, r *http.Request, params gen.UploadPhotoParams) {
 	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
 
 	// Parse multipart form using configured max file size
@@ -53,6 +100,14 @@ func (h PhotoHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	// Raw photos are deduplicated per user, so anonymous uploads are rejected
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Upload without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
 	// Read file data
 	bytes, err := io.ReadAll(file)
 	if err != nil {
@@ -61,83 +116,155 @@ func (h PhotoHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// rawMetadata is for the original unprocessed photo
-	rawMetadata := model.RawPhoto{
-		ID: uuid.New().String(),
-		// UserID:           "",
-		OriginalFilename: fileHeader.Filename,
-		StorageURL:       "",
-		FileSize:         fileHeader.Size,
-		MimeType:         http.DetectContentType(bytes),
-		MD5Hash:          util2.CalculateMD5(bytes),
-		UploadedAt:       time.Now(),
+	// Get optional caption and tags
+	caption := r.FormValue("caption")
+	tags := []string{}
+	if tagValues := r.Form["tags"]; len(tagValues) > 0 {
+		tags = tagValues
 	}
 
-	// Check if valid image file type
-	if rawMetadata.MimeType != "image/jpeg" && rawMetadata.MimeType != "image/png" {
-		logger.Info("Unsupported file type", "mime_type", rawMetadata.MimeType)
-		http.Error(w, util2.ErrMsgUnsupportedFileType, http.StatusBadRequest)
+	raw := newRawPhoto(userID, fileHeader.Filename, bytes)
+	processed, status, message := h.processUpload(r.Context(), logger, raw, bytes, &caption, tags)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	resp, err := h.newUploadResponse(r.Context(), processed)
+	if err != nil {
+		logger.Error("Failed to deliver photo", "error", err, "photo_id", processed.photo.ID)
+		http.Error(w, util2.ErrMsgFailedToProcess, http.StatusInternalServerError)
 		return
 	}
 
-	// Async upload the raw image
+	logger.Info("Photo uploaded", "photo_id", processed.photo.ID, "raw_photo_id", processed.raw.ID,
+		"filename", fileHeader.Filename, "duplicate", processed.duplicate)
 
-	// Process the image, this will also update the rawMetadata with dimensions
-	// and EXIF data if available.
-	// processed, err := util.ProcessPhoto(bytes, rawMetadata)
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// newRawPhoto creates the metadata of a raw photo from the uploaded bytes.
+func newRawPhoto(userID, filename string, data []byte) model.RawPhoto {
+	return model.RawPhoto{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		OriginalFilename: filename,
+		FileSize:         int64(len(data)),
+		MimeType:         detectContentType(data),
+		MD5Hash:          util2.CalculateMD5(data),
+		SHA256Hash:       util2.CalculateSHA256(data),
+		UploadedAt:       time.Now(),
+	}
+}
 
-	// Update rawMetadata with processed data and write to database
+// isSupportedType reports whether photos of the MIME type can be uploaded.
+func isSupportedType(mimeType string) bool {
+	_, ok := fileExtensions[mimeType]
+	return ok && slices.Contains(config.GetPhotoAllowedTypes(), mimeType)
+}
 
-	// Deferred db and s3 cleanup if any of the upcoming operations fail
-	defer func() {
-		if err != nil {
-			// updateMetadata := model.RawPhoto{
-			// 	// TODO add configuration to set deletion schedule
-			// 	ScheduleDeletion: util.GetTimePointer(time.Now().Add(7 * 24 * time.Hour)),
-			// }
-			// if dbErr := h.DB.UpdateRawPhoto(r.Context(), rawMetadata.ID, updateMetadata); dbErr != nil {
-			// 	logger.Error("Failed to update metadata for scheduled deletion",
-			// 		"error", dbErr,
-			// 		"raw_photo_id", rawMetadata.ID,
-			// 		"schedule_deletion", updateMetadata.ScheduleDeletion,
-			// 	)
-			// 	return
-			// }
+// unsupportedTypeMessage returns the error message of an unsupported file
+// type, listing the types that can be uploaded.
+func unsupportedTypeMessage() string {
+	return fmt.Sprintf("%s, accepted types are %s", util2.ErrMsgUnsupportedFileType,
+		strings.Join(config.GetPhotoAllowedTypes(), ", "))
+}
+
+// tiffSignatures are the byte orders TIFF files start with, which
+// http.DetectContentType doesn't know
+var tiffSignatures = [][]byte{[]byte("II*\x00"), []byte("MM\x00*")}
+
+// detectContentType returns the MIME type of the uploaded bytes.
+func detectContentType(data []byte) string {
+	for _, signature := range tiffSignatures {
+		if bytes.HasPrefix(data, signature) {
+			return "image/tiff"
 		}
-	}()
+	}
+	return http.DetectContentType(data)
+}
 
-	// Get optional caption and tags
-	caption := r.FormValue("caption")
-	var tags []string
-	if tagValues := r.Form["tags"]; len(tagValues) > 0 {
-		tags = tagValues
+// saveRawPhoto stores the raw photo under a content-addressed key and records
+// it in the database. If the user has already uploaded the same content, the
+// existing raw photo is returned and duplicate is true, unless it was
+// quarantined, which returns errQuarantined.
+func (h PhotoHandler) saveRawPhoto(ctx context.Context, raw model.RawPhoto, data []byte) (
+	saved model.RawPhoto, duplicate bool, err error) {
+	existing, err := h.DB.GetRawPhotoByHash(ctx, raw.UserID, raw.SHA256Hash)
+	if err == nil && existing.QuarantinedAt != nil {
+		return existing, false, errQuarantined
+	} else if err == nil {
+		return existing, true, nil
+	} else if !errors.Is(err, pgdb.ErrNotFound) {
+		return raw, false, err
+	}
+
+	raw.ExifData, err = h.readExif(ctx, raw.UserID, data)
+	if err != nil {
+		return raw, false, err
 	}
 
-	// TODO: Save photo metadata to database
-	// err = h.DB.SavePhoto(photoModel)
-	// if err != nil {
-	//     logger.Error("Failed to save photo metadata", "error", err)
-	//     http.Error(w, "Failed to save photo", http.StatusInternalServerError)
-	//     return
-	// }
+	// The key only depends on the user and content, so a concurrent upload of
+	// the same photo overwrites the object with identical bytes.
+	raw.StorageBackend = h.Backend
+	raw.StorageKey = RawPhotoKey(raw)
+	_, err = h.Storage.Upload(ctx, raw.StorageKey, data, raw.MimeType,
+		store.WithMetadata(map[string]string{metadataUserID: raw.UserID}))
+	if err != nil {
+		return raw, false, err
+	}
+
+	return h.registerRawPhoto(ctx, raw)
+}
 
-	// Create response
-	photo := gen.Photo{
-		Id:         "",
-		Url:        "",
-		Caption:    &caption,
-		Tags:       &tags,
-		UploadedAt: rawMetadata.UploadedAt,
+// registerRawPhoto records a stored raw photo in the database. If the user has
+// registered the same content concurrently, the existing raw photo is returned
+// and duplicate is true.
+func (h PhotoHandler) registerRawPhoto(ctx context.Context, raw model.RawPhoto) (
+	saved model.RawPhoto, duplicate bool, err error) {
+	err = h.DB.CreateRawPhoto(ctx, raw)
+	if errors.Is(err, pgdb.ErrDuplicate) {
+		// Lost the race against a concurrent upload of the same photo
+		existing, err := h.DB.GetRawPhotoByHash(ctx, raw.UserID, raw.SHA256Hash)
+		if err != nil {
+			return raw, false, err
+		}
+		return existing, true, nil
+	} else if err != nil {
+		return raw, false, err
 	}
 
+	return raw, false, nil
+}
+
+// newPhotoUploadResponse creates the response to an uploaded photo.
+func newPhotoUploadResponse(photo gen.Photo, duplicate bool) gen.PhotoUploadResponse {
 	resp := gen.PhotoUploadResponse{
-		Photo:   photo,
-		Message: util2.StringPtr("Photo uploaded successfully"),
+		Photo:     photo,
+		Duplicate: &duplicate,
+		Message:   util2.StringPtr("Photo uploaded successfully"),
+	}
+	if duplicate {
+		resp.Message = util2.StringPtr("Photo already uploaded")
 	}
 
-	logger.Info("Photo uploaded", "photo_id", photo.Id, "filename", fileHeader.Filename)
+	return resp
+}
 
-	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+// fileExtensions maps the MIME types that can be allowed to the extension
+// used in storage keys.
+var fileExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/tiff": ".tif",
+}
+
+// RawPhotoKey returns the storage key of a raw photo, addressed by its owner
+// and content hash.
+func RawPhotoKey(raw model.RawPhoto) string {
+	return fmt.Sprintf("raw/%s/%s%s", raw.UserID, raw.SHA256Hash, fileExtensions[raw.MimeType])
 }
 
 func (h PhotoHandler) GetPhoto(w http.ResponseWriter, r *http.Request, id string) {
@@ -151,20 +278,189 @@ func (h PhotoHandler) GetPhoto(w http.ResponseWriter, r *http.Request, id string
 	}
 
 	// Fetch photo metadata from database
-	photo, err := h.DB.GetPhotoById(r.Context(), id)
+	photo, err := h.DB.GetPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+
+	details := photo.ToPhotoDetails()
+	if err := h.deliverPhotoDetails(r.Context(), photo, &details); err != nil {
+		logger.Error("Failed to deliver photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+	details.LikeCount, err = h.DB.CountPhotoLikes(r.Context(), id)
+	if err != nil {
+		logger.Error("Failed to count photo likes", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+	details.CommentCount, err = h.DB.CountPhotoComments(r.Context(), id)
 	if err != nil {
-		// TODO
+		logger.Error("Failed to count photo comments", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gen.PhotoDetailsResponse{
+		Photo:   details,
+		Message: util2.StringPtr("Photo details retrieved successfully"),
 	}
 
-	if nil == nil {
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// DeletePhoto schedules a photo of the current user for deletion. The photo
+// is hidden right away, and permanently deleted by garbage collection once the
+// deletion delay has passed.
+// DELETE /photo/{id}
+func (h PhotoHandler) DeletePhoto(w http.ResponseWriter, r *http.Request, id string) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Photo deletion without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	photo, err := h.DB.GetPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+	if photo.UserID != userID {
+		logger.Info("Deletion of another user's photo", "id", id, "user_id", userID)
+		http.Error(w, util2.ErrMsgNotPhotoOwner, http.StatusForbidden)
+		return
+	}
+
+	// A photo deleted concurrently is already scheduled
+	err = h.DB.DeletePhoto(r.Context(), id, config.GetPhotoDeletionDelay())
+	if errors.Is(err, pgdb.ErrNotFound) {
 		logger.Info("Photo not found", "id", id)
-		util2.WriteJSONResponse(w, logger, http.StatusNotFound, gen.PhotoDetailsResponse{})
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to delete photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToDeletePhoto, http.StatusInternalServerError)
+		return
+	}
+
+	h.purgePhoto(r.Context(), photo)
+
+	logger.Info("Photo deleted", "id", id, "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgePhoto purges the variants and resized images of a photo scheduled for
+// deletion from the CDN if there is one, so they stop being served before
+// they're deleted from storage.
+func (h PhotoHandler) purgePhoto(ctx context.Context, photo model.Photo) {
+	if h.CDN == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	keys := make([]string, 0, len(photo.Variants))
+	for _, variant := range photo.Variants {
+		keys = append(keys, variant.StorageKey)
+	}
+	for object, err := range h.Storage.List(ctx, imagePrefix(photo.ID)) {
+		if err != nil {
+			util2.GetLogger(ctx).Warn("Failed to list resized images", "error", err, "id", photo.ID)
+			break
+		}
+		keys = append(keys, object.Key)
 	}
 
-	util2.WriteJSONResponse(w, logger, http.StatusOK, photo.ToPhotoDetails())
+	if len(keys) == 0 {
+		return
+	}
+	if err := h.CDN.Purge(ctx, keys); err != nil {
+		util2.GetLogger(ctx).Warn("Failed to purge photo", "error", err, "id", photo.ID, "keys", keys)
+	}
 }
 
+// GetRawPhoto returns the details of a raw photo. The URL of the raw photo and
+// sensitive EXIF tags are only returned to the owner, and quarantined raw
+// photos are hidden from everyone but the owner and admins.
 func (h PhotoHandler) GetRawPhoto(w http.ResponseWriter, r *http.Request, id string) {
-	// TODO implement me
-	panic("implement me")
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid raw photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	raw, err := h.DB.GetRawPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Raw photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get raw photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+
+	// Quarantined files are only shown to their owner and to admins reviewing
+	// them, so they can't be shared by their raw photo ID
+	if raw.QuarantinedAt != nil && util2.GetUserID(r.Context()) != raw.UserID && !admin.IsAdmin(r) {
+		logger.Info("Raw photo quarantined", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	}
+
+	// The raw photo is the file as uploaded, with all of its metadata, so only
+	// the owner can fetch it
+	owner := util2.GetUserID(r.Context()) == raw.UserID
+	details := raw.ToRawPhotoDetails()
+	if owner {
+		var url string
+		err = h.deliverURL(r.Context(), store.ClassRaw, raw.StorageBackend, raw.StorageKey, &url)
+		if err != nil {
+			logger.Error("Failed to deliver raw photo", "error", err, "id", id)
+			http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+			return
+		}
+		details.StorageUrl = &url
+	}
+	if raw.ExifData != nil {
+		var exif imaging.Exif
+		if err := json.Unmarshal([]byte(*raw.ExifData), &exif); err != nil {
+			logger.Error("Failed to parse EXIF data", "error", err, "id", id)
+			http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+			return
+		}
+		if !owner {
+			exif = exif.Public()
+		}
+		details.ExifData = (*map[string]interface{})(&exif)
+	}
+
+	resp := gen.RawPhotoDetailsResponse{
+		RawPhoto: details,
+		Message:  util2.StringPtr("Raw photo details retrieved successfully"),
+	}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
 }
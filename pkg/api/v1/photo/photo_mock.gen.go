@@ -0,0 +1,1501 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package photo
+
+import (
+	"context"
+	"jelly/pkg/model"
+	"time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockDatabase creates a new instance of MockDatabase. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDatabase(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDatabase {
+	mock := &MockDatabase{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDatabase is an autogenerated mock type for the Database type
+type MockDatabase struct {
+	mock.Mock
+}
+
+type MockDatabase_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDatabase) EXPECT() *MockDatabase_Expecter {
+	return &MockDatabase_Expecter{mock: &_m.Mock}
+}
+
+// CompleteUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CompleteUpload(ctx context.Context, uploadID string, rawPhotoID string) error {
+	ret := _mock.Called(ctx, uploadID, rawPhotoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteUpload")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, uploadID, rawPhotoID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CompleteUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompleteUpload'
+type MockDatabase_CompleteUpload_Call struct {
+	*mock.Call
+}
+
+// CompleteUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+//   - rawPhotoID string
+func (_e *MockDatabase_Expecter) CompleteUpload(ctx interface{}, uploadID interface{}, rawPhotoID interface{}) *MockDatabase_CompleteUpload_Call {
+	return &MockDatabase_CompleteUpload_Call{Call: _e.mock.On("CompleteUpload", ctx, uploadID, rawPhotoID)}
+}
+
+func (_c *MockDatabase_CompleteUpload_Call) Run(run func(ctx context.Context, uploadID string, rawPhotoID string)) *MockDatabase_CompleteUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CompleteUpload_Call) Return(err error) *MockDatabase_CompleteUpload_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CompleteUpload_Call) RunAndReturn(run func(ctx context.Context, uploadID string, rawPhotoID string) error) *MockDatabase_CompleteUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CountPhotoComments provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CountPhotoComments(ctx context.Context, photoID string) (int, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPhotoComments")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_CountPhotoComments_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountPhotoComments'
+type MockDatabase_CountPhotoComments_Call struct {
+	*mock.Call
+}
+
+// CountPhotoComments is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) CountPhotoComments(ctx interface{}, photoID interface{}) *MockDatabase_CountPhotoComments_Call {
+	return &MockDatabase_CountPhotoComments_Call{Call: _e.mock.On("CountPhotoComments", ctx, photoID)}
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) Return(n int, err error) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) RunAndReturn(run func(ctx context.Context, photoID string) (int, error)) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CountPhotoLikes provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CountPhotoLikes(ctx context.Context, photoID string) (int, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPhotoLikes")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_CountPhotoLikes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountPhotoLikes'
+type MockDatabase_CountPhotoLikes_Call struct {
+	*mock.Call
+}
+
+// CountPhotoLikes is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) CountPhotoLikes(ctx interface{}, photoID interface{}) *MockDatabase_CountPhotoLikes_Call {
+	return &MockDatabase_CountPhotoLikes_Call{Call: _e.mock.On("CountPhotoLikes", ctx, photoID)}
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) Return(n int, err error) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) RunAndReturn(run func(ctx context.Context, photoID string) (int, error)) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateComment provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CreateComment(ctx context.Context, comment model.Comment) error {
+	ret := _mock.Called(ctx, comment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateComment")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.Comment) error); ok {
+		r0 = returnFunc(ctx, comment)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CreateComment_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateComment'
+type MockDatabase_CreateComment_Call struct {
+	*mock.Call
+}
+
+// CreateComment is a helper method to define mock.On call
+//   - ctx context.Context
+//   - comment model.Comment
+func (_e *MockDatabase_Expecter) CreateComment(ctx interface{}, comment interface{}) *MockDatabase_CreateComment_Call {
+	return &MockDatabase_CreateComment_Call{Call: _e.mock.On("CreateComment", ctx, comment)}
+}
+
+func (_c *MockDatabase_CreateComment_Call) Run(run func(ctx context.Context, comment model.Comment)) *MockDatabase_CreateComment_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.Comment
+		if args[1] != nil {
+			arg1 = args[1].(model.Comment)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CreateComment_Call) Return(err error) *MockDatabase_CreateComment_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CreateComment_Call) RunAndReturn(run func(ctx context.Context, comment model.Comment) error) *MockDatabase_CreateComment_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreatePhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CreatePhoto(ctx context.Context, photo model.Photo) error {
+	ret := _mock.Called(ctx, photo)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePhoto")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.Photo) error); ok {
+		r0 = returnFunc(ctx, photo)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CreatePhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreatePhoto'
+type MockDatabase_CreatePhoto_Call struct {
+	*mock.Call
+}
+
+// CreatePhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photo model.Photo
+func (_e *MockDatabase_Expecter) CreatePhoto(ctx interface{}, photo interface{}) *MockDatabase_CreatePhoto_Call {
+	return &MockDatabase_CreatePhoto_Call{Call: _e.mock.On("CreatePhoto", ctx, photo)}
+}
+
+func (_c *MockDatabase_CreatePhoto_Call) Run(run func(ctx context.Context, photo model.Photo)) *MockDatabase_CreatePhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.Photo
+		if args[1] != nil {
+			arg1 = args[1].(model.Photo)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CreatePhoto_Call) Return(err error) *MockDatabase_CreatePhoto_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CreatePhoto_Call) RunAndReturn(run func(ctx context.Context, photo model.Photo) error) *MockDatabase_CreatePhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateRawPhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CreateRawPhoto(ctx context.Context, photo model.RawPhoto) error {
+	ret := _mock.Called(ctx, photo)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRawPhoto")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.RawPhoto) error); ok {
+		r0 = returnFunc(ctx, photo)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CreateRawPhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateRawPhoto'
+type MockDatabase_CreateRawPhoto_Call struct {
+	*mock.Call
+}
+
+// CreateRawPhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photo model.RawPhoto
+func (_e *MockDatabase_Expecter) CreateRawPhoto(ctx interface{}, photo interface{}) *MockDatabase_CreateRawPhoto_Call {
+	return &MockDatabase_CreateRawPhoto_Call{Call: _e.mock.On("CreateRawPhoto", ctx, photo)}
+}
+
+func (_c *MockDatabase_CreateRawPhoto_Call) Run(run func(ctx context.Context, photo model.RawPhoto)) *MockDatabase_CreateRawPhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.RawPhoto
+		if args[1] != nil {
+			arg1 = args[1].(model.RawPhoto)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CreateRawPhoto_Call) Return(err error) *MockDatabase_CreateRawPhoto_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CreateRawPhoto_Call) RunAndReturn(run func(ctx context.Context, photo model.RawPhoto) error) *MockDatabase_CreateRawPhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateScanResult provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CreateScanResult(ctx context.Context, result model.ScanResult) error {
+	ret := _mock.Called(ctx, result)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateScanResult")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ScanResult) error); ok {
+		r0 = returnFunc(ctx, result)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CreateScanResult_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateScanResult'
+type MockDatabase_CreateScanResult_Call struct {
+	*mock.Call
+}
+
+// CreateScanResult is a helper method to define mock.On call
+//   - ctx context.Context
+//   - result model.ScanResult
+func (_e *MockDatabase_Expecter) CreateScanResult(ctx interface{}, result interface{}) *MockDatabase_CreateScanResult_Call {
+	return &MockDatabase_CreateScanResult_Call{Call: _e.mock.On("CreateScanResult", ctx, result)}
+}
+
+func (_c *MockDatabase_CreateScanResult_Call) Run(run func(ctx context.Context, result model.ScanResult)) *MockDatabase_CreateScanResult_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.ScanResult
+		if args[1] != nil {
+			arg1 = args[1].(model.ScanResult)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CreateScanResult_Call) Return(err error) *MockDatabase_CreateScanResult_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CreateScanResult_Call) RunAndReturn(run func(ctx context.Context, result model.ScanResult) error) *MockDatabase_CreateScanResult_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CreateUpload(ctx context.Context, upload model.Upload) error {
+	ret := _mock.Called(ctx, upload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateUpload")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.Upload) error); ok {
+		r0 = returnFunc(ctx, upload)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_CreateUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateUpload'
+type MockDatabase_CreateUpload_Call struct {
+	*mock.Call
+}
+
+// CreateUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - upload model.Upload
+func (_e *MockDatabase_Expecter) CreateUpload(ctx interface{}, upload interface{}) *MockDatabase_CreateUpload_Call {
+	return &MockDatabase_CreateUpload_Call{Call: _e.mock.On("CreateUpload", ctx, upload)}
+}
+
+func (_c *MockDatabase_CreateUpload_Call) Run(run func(ctx context.Context, upload model.Upload)) *MockDatabase_CreateUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.Upload
+		if args[1] != nil {
+			arg1 = args[1].(model.Upload)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CreateUpload_Call) Return(err error) *MockDatabase_CreateUpload_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_CreateUpload_Call) RunAndReturn(run func(ctx context.Context, upload model.Upload) error) *MockDatabase_CreateUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeletePhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) DeletePhoto(ctx context.Context, photoID string, deletionDuration time.Duration) error {
+	ret := _mock.Called(ctx, photoID, deletionDuration)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePhoto")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = returnFunc(ctx, photoID, deletionDuration)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_DeletePhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeletePhoto'
+type MockDatabase_DeletePhoto_Call struct {
+	*mock.Call
+}
+
+// DeletePhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+//   - deletionDuration time.Duration
+func (_e *MockDatabase_Expecter) DeletePhoto(ctx interface{}, photoID interface{}, deletionDuration interface{}) *MockDatabase_DeletePhoto_Call {
+	return &MockDatabase_DeletePhoto_Call{Call: _e.mock.On("DeletePhoto", ctx, photoID, deletionDuration)}
+}
+
+func (_c *MockDatabase_DeletePhoto_Call) Run(run func(ctx context.Context, photoID string, deletionDuration time.Duration)) *MockDatabase_DeletePhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 time.Duration
+		if args[2] != nil {
+			arg2 = args[2].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_DeletePhoto_Call) Return(err error) *MockDatabase_DeletePhoto_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_DeletePhoto_Call) RunAndReturn(run func(ctx context.Context, photoID string, deletionDuration time.Duration) error) *MockDatabase_DeletePhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) DeleteUpload(ctx context.Context, uploadID string) error {
+	ret := _mock.Called(ctx, uploadID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUpload")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, uploadID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_DeleteUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUpload'
+type MockDatabase_DeleteUpload_Call struct {
+	*mock.Call
+}
+
+// DeleteUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+func (_e *MockDatabase_Expecter) DeleteUpload(ctx interface{}, uploadID interface{}) *MockDatabase_DeleteUpload_Call {
+	return &MockDatabase_DeleteUpload_Call{Call: _e.mock.On("DeleteUpload", ctx, uploadID)}
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) Run(run func(ctx context.Context, uploadID string)) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) Return(err error) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) RunAndReturn(run func(ctx context.Context, uploadID string) error) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLocationPrivacy provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetLocationPrivacy(ctx context.Context, userID string) (model.LocationPrivacy, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLocationPrivacy")
+	}
+
+	var r0 model.LocationPrivacy
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.LocationPrivacy, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.LocationPrivacy); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(model.LocationPrivacy)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetLocationPrivacy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLocationPrivacy'
+type MockDatabase_GetLocationPrivacy_Call struct {
+	*mock.Call
+}
+
+// GetLocationPrivacy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockDatabase_Expecter) GetLocationPrivacy(ctx interface{}, userID interface{}) *MockDatabase_GetLocationPrivacy_Call {
+	return &MockDatabase_GetLocationPrivacy_Call{Call: _e.mock.On("GetLocationPrivacy", ctx, userID)}
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) Run(run func(ctx context.Context, userID string)) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) Return(locationPrivacy model.LocationPrivacy, err error) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Return(locationPrivacy, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) RunAndReturn(run func(ctx context.Context, userID string) (model.LocationPrivacy, error)) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetPhotoByID provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetPhotoByID(ctx context.Context, photoID string) (model.Photo, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPhotoByID")
+	}
+
+	var r0 model.Photo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.Photo, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.Photo); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(model.Photo)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetPhotoByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPhotoByID'
+type MockDatabase_GetPhotoByID_Call struct {
+	*mock.Call
+}
+
+// GetPhotoByID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) GetPhotoByID(ctx interface{}, photoID interface{}) *MockDatabase_GetPhotoByID_Call {
+	return &MockDatabase_GetPhotoByID_Call{Call: _e.mock.On("GetPhotoByID", ctx, photoID)}
+}
+
+func (_c *MockDatabase_GetPhotoByID_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_GetPhotoByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotoByID_Call) Return(photo model.Photo, err error) *MockDatabase_GetPhotoByID_Call {
+	_c.Call.Return(photo, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotoByID_Call) RunAndReturn(run func(ctx context.Context, photoID string) (model.Photo, error)) *MockDatabase_GetPhotoByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetPhotoByRawPhotoID provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetPhotoByRawPhotoID(ctx context.Context, rawPhotoID string) (model.Photo, error) {
+	ret := _mock.Called(ctx, rawPhotoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPhotoByRawPhotoID")
+	}
+
+	var r0 model.Photo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.Photo, error)); ok {
+		return returnFunc(ctx, rawPhotoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.Photo); ok {
+		r0 = returnFunc(ctx, rawPhotoID)
+	} else {
+		r0 = ret.Get(0).(model.Photo)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, rawPhotoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetPhotoByRawPhotoID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPhotoByRawPhotoID'
+type MockDatabase_GetPhotoByRawPhotoID_Call struct {
+	*mock.Call
+}
+
+// GetPhotoByRawPhotoID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - rawPhotoID string
+func (_e *MockDatabase_Expecter) GetPhotoByRawPhotoID(ctx interface{}, rawPhotoID interface{}) *MockDatabase_GetPhotoByRawPhotoID_Call {
+	return &MockDatabase_GetPhotoByRawPhotoID_Call{Call: _e.mock.On("GetPhotoByRawPhotoID", ctx, rawPhotoID)}
+}
+
+func (_c *MockDatabase_GetPhotoByRawPhotoID_Call) Run(run func(ctx context.Context, rawPhotoID string)) *MockDatabase_GetPhotoByRawPhotoID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotoByRawPhotoID_Call) Return(photo model.Photo, err error) *MockDatabase_GetPhotoByRawPhotoID_Call {
+	_c.Call.Return(photo, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotoByRawPhotoID_Call) RunAndReturn(run func(ctx context.Context, rawPhotoID string) (model.Photo, error)) *MockDatabase_GetPhotoByRawPhotoID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetPhotosNearby provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetPhotosNearby(ctx context.Context, lat float64, lon float64, radiusKm float64, limit int, offset int) ([]model.NearbyPhoto, error) {
+	ret := _mock.Called(ctx, lat, lon, radiusKm, limit, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPhotosNearby")
+	}
+
+	var r0 []model.NearbyPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, float64, float64, float64, int, int) ([]model.NearbyPhoto, error)); ok {
+		return returnFunc(ctx, lat, lon, radiusKm, limit, offset)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, float64, float64, float64, int, int) []model.NearbyPhoto); ok {
+		r0 = returnFunc(ctx, lat, lon, radiusKm, limit, offset)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.NearbyPhoto)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, float64, float64, float64, int, int) error); ok {
+		r1 = returnFunc(ctx, lat, lon, radiusKm, limit, offset)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetPhotosNearby_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPhotosNearby'
+type MockDatabase_GetPhotosNearby_Call struct {
+	*mock.Call
+}
+
+// GetPhotosNearby is a helper method to define mock.On call
+//   - ctx context.Context
+//   - lat float64
+//   - lon float64
+//   - radiusKm float64
+//   - limit int
+//   - offset int
+func (_e *MockDatabase_Expecter) GetPhotosNearby(ctx interface{}, lat interface{}, lon interface{}, radiusKm interface{}, limit interface{}, offset interface{}) *MockDatabase_GetPhotosNearby_Call {
+	return &MockDatabase_GetPhotosNearby_Call{Call: _e.mock.On("GetPhotosNearby", ctx, lat, lon, radiusKm, limit, offset)}
+}
+
+func (_c *MockDatabase_GetPhotosNearby_Call) Run(run func(ctx context.Context, lat float64, lon float64, radiusKm float64, limit int, offset int)) *MockDatabase_GetPhotosNearby_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 float64
+		if args[1] != nil {
+			arg1 = args[1].(float64)
+		}
+		var arg2 float64
+		if args[2] != nil {
+			arg2 = args[2].(float64)
+		}
+		var arg3 float64
+		if args[3] != nil {
+			arg3 = args[3].(float64)
+		}
+		var arg4 int
+		if args[4] != nil {
+			arg4 = args[4].(int)
+		}
+		var arg5 int
+		if args[5] != nil {
+			arg5 = args[5].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotosNearby_Call) Return(nearbyPhotos []model.NearbyPhoto, err error) *MockDatabase_GetPhotosNearby_Call {
+	_c.Call.Return(nearbyPhotos, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetPhotosNearby_Call) RunAndReturn(run func(ctx context.Context, lat float64, lon float64, radiusKm float64, limit int, offset int) ([]model.NearbyPhoto, error)) *MockDatabase_GetPhotosNearby_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRawPhotoByHash provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetRawPhotoByHash(ctx context.Context, userID string, sha256Hash string) (model.RawPhoto, error) {
+	ret := _mock.Called(ctx, userID, sha256Hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRawPhotoByHash")
+	}
+
+	var r0 model.RawPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (model.RawPhoto, error)); ok {
+		return returnFunc(ctx, userID, sha256Hash)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) model.RawPhoto); ok {
+		r0 = returnFunc(ctx, userID, sha256Hash)
+	} else {
+		r0 = ret.Get(0).(model.RawPhoto)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, userID, sha256Hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetRawPhotoByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRawPhotoByHash'
+type MockDatabase_GetRawPhotoByHash_Call struct {
+	*mock.Call
+}
+
+// GetRawPhotoByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - sha256Hash string
+func (_e *MockDatabase_Expecter) GetRawPhotoByHash(ctx interface{}, userID interface{}, sha256Hash interface{}) *MockDatabase_GetRawPhotoByHash_Call {
+	return &MockDatabase_GetRawPhotoByHash_Call{Call: _e.mock.On("GetRawPhotoByHash", ctx, userID, sha256Hash)}
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) Run(run func(ctx context.Context, userID string, sha256Hash string)) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) Return(rawPhoto model.RawPhoto, err error) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Return(rawPhoto, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) RunAndReturn(run func(ctx context.Context, userID string, sha256Hash string) (model.RawPhoto, error)) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRawPhotoByID provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetRawPhotoByID(ctx context.Context, rawPhotoID string) (model.RawPhoto, error) {
+	ret := _mock.Called(ctx, rawPhotoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRawPhotoByID")
+	}
+
+	var r0 model.RawPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.RawPhoto, error)); ok {
+		return returnFunc(ctx, rawPhotoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.RawPhoto); ok {
+		r0 = returnFunc(ctx, rawPhotoID)
+	} else {
+		r0 = ret.Get(0).(model.RawPhoto)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, rawPhotoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetRawPhotoByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRawPhotoByID'
+type MockDatabase_GetRawPhotoByID_Call struct {
+	*mock.Call
+}
+
+// GetRawPhotoByID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - rawPhotoID string
+func (_e *MockDatabase_Expecter) GetRawPhotoByID(ctx interface{}, rawPhotoID interface{}) *MockDatabase_GetRawPhotoByID_Call {
+	return &MockDatabase_GetRawPhotoByID_Call{Call: _e.mock.On("GetRawPhotoByID", ctx, rawPhotoID)}
+}
+
+func (_c *MockDatabase_GetRawPhotoByID_Call) Run(run func(ctx context.Context, rawPhotoID string)) *MockDatabase_GetRawPhotoByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByID_Call) Return(rawPhoto model.RawPhoto, err error) *MockDatabase_GetRawPhotoByID_Call {
+	_c.Call.Return(rawPhoto, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByID_Call) RunAndReturn(run func(ctx context.Context, rawPhotoID string) (model.RawPhoto, error)) *MockDatabase_GetRawPhotoByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSimilarPhotos provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetSimilarPhotos(ctx context.Context, hash int64, excludeRawPhotoID string, maxDistance int, limit int) ([]model.SimilarPhoto, error) {
+	ret := _mock.Called(ctx, hash, excludeRawPhotoID, maxDistance, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSimilarPhotos")
+	}
+
+	var r0 []model.SimilarPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, string, int, int) ([]model.SimilarPhoto, error)); ok {
+		return returnFunc(ctx, hash, excludeRawPhotoID, maxDistance, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, string, int, int) []model.SimilarPhoto); ok {
+		r0 = returnFunc(ctx, hash, excludeRawPhotoID, maxDistance, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SimilarPhoto)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64, string, int, int) error); ok {
+		r1 = returnFunc(ctx, hash, excludeRawPhotoID, maxDistance, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetSimilarPhotos_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSimilarPhotos'
+type MockDatabase_GetSimilarPhotos_Call struct {
+	*mock.Call
+}
+
+// GetSimilarPhotos is a helper method to define mock.On call
+//   - ctx context.Context
+//   - hash int64
+//   - excludeRawPhotoID string
+//   - maxDistance int
+//   - limit int
+func (_e *MockDatabase_Expecter) GetSimilarPhotos(ctx interface{}, hash interface{}, excludeRawPhotoID interface{}, maxDistance interface{}, limit interface{}) *MockDatabase_GetSimilarPhotos_Call {
+	return &MockDatabase_GetSimilarPhotos_Call{Call: _e.mock.On("GetSimilarPhotos", ctx, hash, excludeRawPhotoID, maxDistance, limit)}
+}
+
+func (_c *MockDatabase_GetSimilarPhotos_Call) Run(run func(ctx context.Context, hash int64, excludeRawPhotoID string, maxDistance int, limit int)) *MockDatabase_GetSimilarPhotos_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 int
+		if args[3] != nil {
+			arg3 = args[3].(int)
+		}
+		var arg4 int
+		if args[4] != nil {
+			arg4 = args[4].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetSimilarPhotos_Call) Return(similarPhotos []model.SimilarPhoto, err error) *MockDatabase_GetSimilarPhotos_Call {
+	_c.Call.Return(similarPhotos, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetSimilarPhotos_Call) RunAndReturn(run func(ctx context.Context, hash int64, excludeRawPhotoID string, maxDistance int, limit int) ([]model.SimilarPhoto, error)) *MockDatabase_GetSimilarPhotos_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSimilarUserPhotos provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetSimilarUserPhotos(ctx context.Context, userID string, hash int64, maxDistance int, limit int) ([]model.SimilarPhoto, error) {
+	ret := _mock.Called(ctx, userID, hash, maxDistance, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSimilarUserPhotos")
+	}
+
+	var r0 []model.SimilarPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64, int, int) ([]model.SimilarPhoto, error)); ok {
+		return returnFunc(ctx, userID, hash, maxDistance, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64, int, int) []model.SimilarPhoto); ok {
+		r0 = returnFunc(ctx, userID, hash, maxDistance, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SimilarPhoto)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int64, int, int) error); ok {
+		r1 = returnFunc(ctx, userID, hash, maxDistance, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetSimilarUserPhotos_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSimilarUserPhotos'
+type MockDatabase_GetSimilarUserPhotos_Call struct {
+	*mock.Call
+}
+
+// GetSimilarUserPhotos is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - hash int64
+//   - maxDistance int
+//   - limit int
+func (_e *MockDatabase_Expecter) GetSimilarUserPhotos(ctx interface{}, userID interface{}, hash interface{}, maxDistance interface{}, limit interface{}) *MockDatabase_GetSimilarUserPhotos_Call {
+	return &MockDatabase_GetSimilarUserPhotos_Call{Call: _e.mock.On("GetSimilarUserPhotos", ctx, userID, hash, maxDistance, limit)}
+}
+
+func (_c *MockDatabase_GetSimilarUserPhotos_Call) Run(run func(ctx context.Context, userID string, hash int64, maxDistance int, limit int)) *MockDatabase_GetSimilarUserPhotos_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int64
+		if args[2] != nil {
+			arg2 = args[2].(int64)
+		}
+		var arg3 int
+		if args[3] != nil {
+			arg3 = args[3].(int)
+		}
+		var arg4 int
+		if args[4] != nil {
+			arg4 = args[4].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetSimilarUserPhotos_Call) Return(similarPhotos []model.SimilarPhoto, err error) *MockDatabase_GetSimilarUserPhotos_Call {
+	_c.Call.Return(similarPhotos, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetSimilarUserPhotos_Call) RunAndReturn(run func(ctx context.Context, userID string, hash int64, maxDistance int, limit int) ([]model.SimilarPhoto, error)) *MockDatabase_GetSimilarUserPhotos_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetUpload(ctx context.Context, uploadID string) (model.Upload, error) {
+	ret := _mock.Called(ctx, uploadID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUpload")
+	}
+
+	var r0 model.Upload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.Upload, error)); ok {
+		return returnFunc(ctx, uploadID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.Upload); ok {
+		r0 = returnFunc(ctx, uploadID)
+	} else {
+		r0 = ret.Get(0).(model.Upload)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, uploadID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetUpload'
+type MockDatabase_GetUpload_Call struct {
+	*mock.Call
+}
+
+// GetUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+func (_e *MockDatabase_Expecter) GetUpload(ctx interface{}, uploadID interface{}) *MockDatabase_GetUpload_Call {
+	return &MockDatabase_GetUpload_Call{Call: _e.mock.On("GetUpload", ctx, uploadID)}
+}
+
+func (_c *MockDatabase_GetUpload_Call) Run(run func(ctx context.Context, uploadID string)) *MockDatabase_GetUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetUpload_Call) Return(upload model.Upload, err error) *MockDatabase_GetUpload_Call {
+	_c.Call.Return(upload, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetUpload_Call) RunAndReturn(run func(ctx context.Context, uploadID string) (model.Upload, error)) *MockDatabase_GetUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LikePhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) LikePhoto(ctx context.Context, userID string, photoID string) error {
+	ret := _mock.Called(ctx, userID, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LikePhoto")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, userID, photoID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_LikePhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LikePhoto'
+type MockDatabase_LikePhoto_Call struct {
+	*mock.Call
+}
+
+// LikePhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - photoID string
+func (_e *MockDatabase_Expecter) LikePhoto(ctx interface{}, userID interface{}, photoID interface{}) *MockDatabase_LikePhoto_Call {
+	return &MockDatabase_LikePhoto_Call{Call: _e.mock.On("LikePhoto", ctx, userID, photoID)}
+}
+
+func (_c *MockDatabase_LikePhoto_Call) Run(run func(ctx context.Context, userID string, photoID string)) *MockDatabase_LikePhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_LikePhoto_Call) Return(err error) *MockDatabase_LikePhoto_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_LikePhoto_Call) RunAndReturn(run func(ctx context.Context, userID string, photoID string) error) *MockDatabase_LikePhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdatePhotoPlace provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) UpdatePhotoPlace(ctx context.Context, photoID string, placeName *string) error {
+	ret := _mock.Called(ctx, photoID, placeName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePhotoPlace")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, *string) error); ok {
+		r0 = returnFunc(ctx, photoID, placeName)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_UpdatePhotoPlace_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdatePhotoPlace'
+type MockDatabase_UpdatePhotoPlace_Call struct {
+	*mock.Call
+}
+
+// UpdatePhotoPlace is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+//   - placeName *string
+func (_e *MockDatabase_Expecter) UpdatePhotoPlace(ctx interface{}, photoID interface{}, placeName interface{}) *MockDatabase_UpdatePhotoPlace_Call {
+	return &MockDatabase_UpdatePhotoPlace_Call{Call: _e.mock.On("UpdatePhotoPlace", ctx, photoID, placeName)}
+}
+
+func (_c *MockDatabase_UpdatePhotoPlace_Call) Run(run func(ctx context.Context, photoID string, placeName *string)) *MockDatabase_UpdatePhotoPlace_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 *string
+		if args[2] != nil {
+			arg2 = args[2].(*string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_UpdatePhotoPlace_Call) Return(err error) *MockDatabase_UpdatePhotoPlace_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_UpdatePhotoPlace_Call) RunAndReturn(run func(ctx context.Context, photoID string, placeName *string) error) *MockDatabase_UpdatePhotoPlace_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateUploadOffset provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) UpdateUploadOffset(ctx context.Context, uploadID string, from int64, to int64) error {
+	ret := _mock.Called(ctx, uploadID, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUploadOffset")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64, int64) error); ok {
+		r0 = returnFunc(ctx, uploadID, from, to)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_UpdateUploadOffset_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateUploadOffset'
+type MockDatabase_UpdateUploadOffset_Call struct {
+	*mock.Call
+}
+
+// UpdateUploadOffset is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+//   - from int64
+//   - to int64
+func (_e *MockDatabase_Expecter) UpdateUploadOffset(ctx interface{}, uploadID interface{}, from interface{}, to interface{}) *MockDatabase_UpdateUploadOffset_Call {
+	return &MockDatabase_UpdateUploadOffset_Call{Call: _e.mock.On("UpdateUploadOffset", ctx, uploadID, from, to)}
+}
+
+func (_c *MockDatabase_UpdateUploadOffset_Call) Run(run func(ctx context.Context, uploadID string, from int64, to int64)) *MockDatabase_UpdateUploadOffset_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int64
+		if args[2] != nil {
+			arg2 = args[2].(int64)
+		}
+		var arg3 int64
+		if args[3] != nil {
+			arg3 = args[3].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_UpdateUploadOffset_Call) Return(err error) *MockDatabase_UpdateUploadOffset_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_UpdateUploadOffset_Call) RunAndReturn(run func(ctx context.Context, uploadID string, from int64, to int64) error) *MockDatabase_UpdateUploadOffset_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -4,18 +4,98 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
 
 	"jelly/pkg/api/v1/gen"
 	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/cdn"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
 )
 
+const testUserID = "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f"
+
+// testImage returns a small gradient image to encode as upload fixtures.
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func testJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// isVariantKey reports whether a storage key is that of a photo variant.
+func isVariantKey(key string) bool {
+	return strings.HasPrefix(key, "photos/")
+}
+
+// expectVariantUploads expects the variants of a processed photo to be
+// stored.
+func expectVariantUploads(storage *store.MockStorage) {
+	storage.EXPECT().Upload(mock.Anything, mock.MatchedBy(isVariantKey), mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, key string, _ []byte, _ string, _ ...store.UploadOption) (
+			string, error) {
+			return "https://example.com/" + key, nil
+		})
+}
+
+// newUploadRequest creates a multipart upload request for the given file with
+// the logger and user set in the context.
+func newUploadRequest(t *testing.T, filename string, data []byte, userID string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fileWriter, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	fileWriter.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/photo", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
 func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 	// Set test environment variable for max file size
 	originalEnv := os.Getenv("PHOTO_MAX_FILE_SIZE_MB")
@@ -28,7 +108,53 @@ func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 		}
 	}()
 
-	handler := PhotoHandler{}
+	image := testJPEG(t)
+	sha256Hash := util2.CalculateSHA256(image)
+	expectedKey := "raw/" + testUserID + "/" + sha256Hash + ".jpg"
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, sha256Hash).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.UserID == testUserID && raw.SHA256Hash == sha256Hash &&
+			raw.MD5Hash == util2.CalculateMD5(image) && raw.MimeType == "image/jpeg" &&
+			raw.StorageBackend == testBackend && raw.StorageKey == expectedKey && raw.PerceptualHash != nil
+	})).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return([]model.SimilarPhoto{{Photo: model.Photo{ID: "similar", UserID: testUserID}, Distance: 3}}, nil)
+
+	db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+		return photo.UserID == testUserID && photo.StorageBackend == testBackend &&
+			photo.OriginalKey == photo.Variants[8].StorageKey && photo.Variants[8].Name == "original" &&
+			len(photo.Variants) == 9 && photo.ThumbnailKey == photo.Variants[0].StorageKey &&
+			photo.Variants[0].StorageBackend == testBackend &&
+			photo.Variants[0].Format == "jpeg" && photo.Variants[1].Format == "webp" &&
+			photo.Caption != nil && *photo.Caption == "Test caption" &&
+			photo.BlurHash != nil && len(*photo.BlurHash) == 28 && photo.DominantColor != nil
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, expectedKey, image, "image/jpeg", mock.MatchedBy(
+		func(opts []store.UploadOption) bool {
+			o := store.NewUploadOptions(opts...)
+			return o.CacheControl == "" && o.Metadata["user-id"] == testUserID
+		})).Return("https://example.com/"+expectedKey, nil)
+	// Each variant is also rendered as WebP, and cached for good. The original
+	// is only rendered in the format of the photo.
+	isVariantUpload := mock.MatchedBy(func(opts []store.UploadOption) bool {
+		o := store.NewUploadOptions(opts...)
+		return o.CacheControl == imageCacheControl && o.Metadata["user-id"] == testUserID &&
+			o.Metadata["photo-id"] != ""
+	})
+	for contentType, times := range map[string]int{"image/jpeg": 5, "image/webp": 4} {
+		storage.EXPECT().Upload(mock.Anything, mock.MatchedBy(isVariantKey), mock.Anything, contentType, isVariantUpload).
+			RunAndReturn(func(_ context.Context, key string, _ []byte, _ string, _ ...store.UploadOption) (
+				string, error) {
+				return "https://example.com/" + key, nil
+			}).Times(times)
+	}
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
 
 	// Create multipart form data
 	body := &bytes.Buffer{}
@@ -39,7 +165,7 @@ func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create form file: %v", err)
 	}
-	fileWriter.Write([]byte("fake image data"))
+	fileWriter.Write(image)
 
 	// Add caption
 	writer.WriteField("caption", "Test caption")
@@ -53,14 +179,15 @@ func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/photo", body)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// Add logger to context
+	// Add logger and user to context
 	logger := slog.Default()
 	ctx := context.WithValue(req.Context(), util2.ContextLogger, logger)
+	ctx = context.WithValue(ctx, util2.ContextUserID, testUserID)
 	req = req.WithContext(ctx)
 
 	w := httptest.NewRecorder()
 
-	handler.UploadPhoto(w, req)
+	handler.UploadPhoto(w, req, gen.UploadPhotoParams{})
 
 	// Check status code
 	if w.Code != http.StatusOK {
@@ -84,8 +211,8 @@ func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 		t.Error("Expected photo ID to be set")
 	}
 
-	if resp.Photo.Url == "" {
-		t.Error("Expected photo URL to be set")
+	if !strings.HasPrefix(resp.Photo.Url, "https://example.com/photos/"+resp.Photo.Id+"/original.") {
+		t.Errorf("Expected photo URL of the original, got %s", resp.Photo.Url)
 	}
 
 	if resp.Photo.Caption == nil || *resp.Photo.Caption != "Test caption" {
@@ -105,9 +232,25 @@ func TestPhotoHandler_UploadPhoto_Success(t *testing.T) {
 		t.Error("Expected uploadedAt to be set")
 	}
 
+	if resp.Photo.Width == nil || *resp.Photo.Width != 16 || resp.Photo.Height == nil || *resp.Photo.Height != 16 {
+		t.Errorf("Expected a 16x16 photo, got %v x %v", resp.Photo.Width, resp.Photo.Height)
+	}
+
+	if resp.Photo.BlurHash == nil || resp.Photo.DominantColor == nil {
+		t.Errorf("Expected a placeholder, got %v and %v", resp.Photo.BlurHash, resp.Photo.DominantColor)
+	}
+
 	if resp.Message == nil || *resp.Message != "Photo uploaded successfully" {
 		t.Errorf("Expected message 'Photo uploaded successfully', got %v", resp.Message)
 	}
+
+	if resp.Duplicate == nil || *resp.Duplicate {
+		t.Errorf("Expected duplicate to be false, got %v", resp.Duplicate)
+	}
+
+	if resp.SimilarPhotos == nil || len(*resp.SimilarPhotos) != 1 || (*resp.SimilarPhotos)[0].Id != "similar" {
+		t.Errorf("Expected the similar photo, got %v", resp.SimilarPhotos)
+	}
 }
 
 func TestPhotoHandler_UploadPhoto_NoFile(t *testing.T) {
@@ -129,7 +272,7 @@ func TestPhotoHandler_UploadPhoto_NoFile(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	handler.UploadPhoto(w, req)
+	handler.UploadPhoto(w, req, gen.UploadPhotoParams{})
 
 	// Check status code
 	if w.Code != http.StatusBadRequest {
@@ -157,7 +300,7 @@ func TestPhotoHandler_UploadPhoto_InvalidForm(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	handler.UploadPhoto(w, req)
+	handler.UploadPhoto(w, req, gen.UploadPhotoParams{})
 
 	// Check status code
 	if w.Code != http.StatusBadRequest {
@@ -192,33 +335,48 @@ func TestPhotoHandler_UploadPhoto_WithoutLogger(t *testing.T) {
 		}
 	}()
 
-	handler.UploadPhoto(w, req)
+	handler.UploadPhoto(w, req, gen.UploadPhotoParams{})
 }
 
 func TestPhotoHandler_UploadPhoto_MinimalData(t *testing.T) {
-	handler := PhotoHandler{}
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.Anything).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return([]model.SimilarPhoto{}, nil)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.Anything).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, "image/png", mock.Anything).
+		Return("https://example.com/minimal.png", nil)
+	storage.EXPECT().Upload(mock.Anything, mock.MatchedBy(isVariantKey), mock.Anything, "image/webp", mock.Anything).
+		Return("https://example.com/minimal.webp", nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
 
 	// Create multipart form data with only file
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
-	fileWriter, err := writer.CreateFormFile("file", "minimal.jpg")
+	fileWriter, err := writer.CreateFormFile("file", "minimal.png")
 	if err != nil {
 		t.Fatalf("Failed to create form file: %v", err)
 	}
-	fileWriter.Write([]byte("minimal image data"))
+	fileWriter.Write(testPNG(t))
 	writer.Close()
 
 	req := httptest.NewRequest(http.MethodPost, "/photo", body)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// Add logger to context
+	// Add logger and user to context
 	logger := slog.Default()
 	ctx := context.WithValue(req.Context(), util2.ContextLogger, logger)
+	ctx = context.WithValue(ctx, util2.ContextUserID, testUserID)
 	req = req.WithContext(ctx)
 
 	w := httptest.NewRecorder()
 
-	handler.UploadPhoto(w, req)
+	handler.UploadPhoto(w, req, gen.UploadPhotoParams{})
 
 	// Check status code
 	if w.Code != http.StatusOK {
@@ -240,3 +398,591 @@ func TestPhotoHandler_UploadPhoto_MinimalData(t *testing.T) {
 		t.Errorf("Expected empty tags array, got %v", resp.Photo.Tags)
 	}
 }
+
+func TestPhotoHandler_UploadPhoto_Duplicate(t *testing.T) {
+	image := testJPEG(t)
+	existing := model.RawPhoto{
+		ID:             "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b",
+		UserID:         testUserID,
+		StorageBackend: testBackend,
+		StorageKey:     "raw/existing.jpg",
+		SHA256Hash:     util2.CalculateSHA256(image),
+		UploadedAt:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	photo := model.Photo{
+		ID:             "5d2c8e1f-7a3b-4c6d-8e9f-0a1b2c3d4e5f",
+		RawPhotoID:     existing.ID,
+		UserID:         testUserID,
+		StorageBackend: existing.StorageBackend,
+		OriginalKey:    existing.StorageKey,
+		UploadedAt:     existing.UploadedAt,
+	}
+
+	// The existing photo is returned without storing or processing the upload
+	// again
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, existing.SHA256Hash).
+		Return(existing, nil)
+	db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, existing.ID).Return(photo, nil)
+
+	handler := PhotoHandler{DB: db, Storage: store.NewMockStorage(t), Backend: testBackend,
+		URLs: newTestURLResolver(t)}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp gen.PhotoUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if resp.Duplicate == nil || !*resp.Duplicate {
+		t.Errorf("Expected duplicate to be true, got %v", resp.Duplicate)
+	}
+
+	if resp.Photo.Id != photo.ID || resp.Photo.Url != "https://example.com/raw/existing.jpg" {
+		t.Errorf("Expected existing photo %s, got %s", photo.ID, resp.Photo.Id)
+	}
+
+	if !resp.Photo.UploadedAt.Equal(existing.UploadedAt) {
+		t.Errorf("Expected uploadedAt %v, got %v", existing.UploadedAt, resp.Photo.UploadedAt)
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_ConcurrentDuplicate(t *testing.T) {
+	image := testJPEG(t)
+	photoID := "5d2c8e1f-7a3b-4c6d-8e9f-0a1b2c3d4e5f"
+	existing := model.RawPhoto{
+		ID:         "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b",
+		UserID:     testUserID,
+		SHA256Hash: util2.CalculateSHA256(image),
+	}
+
+	// A concurrent upload inserts the same photo between the lookup and insert
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, existing.SHA256Hash).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound).Once()
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.Anything).
+		Return(pgdb.ErrDuplicate)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, existing.SHA256Hash).
+		Return(existing, nil).Once()
+	db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, existing.ID).
+		Return(model.Photo{ID: photoID, RawPhotoID: existing.ID}, nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, image, "image/jpeg", mock.Anything).
+		Return("https://example.com/raw/existing.jpg", nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp gen.PhotoUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if resp.Duplicate == nil || !*resp.Duplicate || resp.Photo.Id != photoID {
+		t.Errorf("Expected duplicate of %s, got %s (%v)", photoID, resp.Photo.Id, resp.Duplicate)
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_DuplicateUnprocessed(t *testing.T) {
+	image := testJPEG(t)
+	existing := model.RawPhoto{
+		ID:             "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b",
+		UserID:         testUserID,
+		StorageBackend: testBackend,
+		StorageKey:     "raw/existing.jpg",
+		SHA256Hash:     util2.CalculateSHA256(image),
+	}
+
+	// Processing the existing raw photo failed before, so it's processed again
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, existing.SHA256Hash).
+		Return(existing, nil)
+	db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, existing.ID).
+		Return(model.Photo{}, pgdb.ErrNotFound)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+		return photo.RawPhotoID == existing.ID && isVariantKey(photo.OriginalKey)
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.MatchedBy(isVariantKey), mock.Anything, "image/jpeg", mock.Anything).
+		Return("https://example.com/photos/variant.jpg", nil).Times(5)
+	storage.EXPECT().Upload(mock.Anything, mock.MatchedBy(isVariantKey), mock.Anything, "image/webp", mock.Anything).
+		Return("https://example.com/photos/variant.webp", nil).Times(4)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp gen.PhotoUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if resp.Duplicate == nil || !*resp.Duplicate || !strings.HasPrefix(resp.Photo.Url, "https://example.com/photos/") {
+		t.Errorf("Expected duplicate processed from %s, got %s (%v)", existing.StorageKey, resp.Photo.Url, resp.Duplicate)
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_ProcessFailure(t *testing.T) {
+	image := testJPEG(t)
+
+	// Variants stored before the failure are deleted again
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.Anything).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return(nil, errors.New("db down"))
+	db.EXPECT().CreatePhoto(mock.Anything, mock.Anything).Return(errors.New("insert failed"))
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, "image/jpeg", mock.Anything).
+		Return("https://example.com/photo.jpg", nil).Times(6)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, "image/webp", mock.Anything).
+		Return("https://example.com/photo.webp", nil).Times(4)
+	storage.EXPECT().Delete(mock.Anything, mock.MatchedBy(isVariantKey)).Return(nil).Times(9)
+
+	// And purged from the CDN
+	fake := cdn.NewFake("https://cdn.example.com", storage)
+	handler := PhotoHandler{DB: db, Storage: storage, CDN: fake}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), util2.ErrMsgFailedToProcess) {
+		t.Errorf("Expected error message about processing the photo, got %s", w.Body.String())
+	}
+
+	if purged := fake.Purged(); len(purged) != 9 || !isVariantKey(purged[0]) {
+		t.Errorf("Expected the 9 variants to be purged, got %v", purged)
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_InvalidImage(t *testing.T) {
+	// A JPEG header followed by garbage passes the type check but can't be
+	// decoded, so it's quarantined
+	data := append([]byte{0xff, 0xd8, 0xff, 0xe0}, []byte("not really a jpeg")...)
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, util2.CalculateSHA256(data)).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.QuarantinedAt != nil && raw.QuarantineReason != nil
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, "quarantine/"+testUserID+"/"+util2.CalculateSHA256(data), data,
+		"application/octet-stream", mock.Anything).Return("https://example.com/quarantine/test", nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", data, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), util2.ErrMsgInvalidImage) {
+		t.Errorf("Expected error message about the image, got %s", w.Body.String())
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_StorageFailure(t *testing.T) {
+	image := testJPEG(t)
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, image, "image/jpeg", mock.Anything).
+		Return("", errors.New("upload failed"))
+
+	handler := PhotoHandler{DB: db, Storage: storage}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), util2.ErrMsgFailedToSavePhoto) {
+		t.Errorf("Expected error message about saving the photo, got %s", w.Body.String())
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_WithoutUser(t *testing.T) {
+	handler := PhotoHandler{}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", testJPEG(t), ""), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), util2.ErrMsgUserRequired) {
+		t.Errorf("Expected error message about the user, got %s", w.Body.String())
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_UnsupportedType(t *testing.T) {
+	handler := PhotoHandler{}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", []byte("fake image data"), testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), util2.ErrMsgUnsupportedFileType) {
+		t.Errorf("Expected error message about the file type, got %s", w.Body.String())
+	}
+
+	// The message lists the accepted types
+	if !strings.Contains(w.Body.String(), "image/jpeg, image/png, image/gif, image/webp, image/tiff") {
+		t.Errorf("Expected the accepted types, got %s", w.Body.String())
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_NotAllowedType(t *testing.T) {
+	t.Setenv("PHOTO_ALLOWED_TYPES", "image/jpeg")
+	handler := PhotoHandler{}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "test.png", testPNG(t), testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "accepted types are image/jpeg\n") {
+		t.Errorf("Expected only JPEG to be accepted, got %s", w.Body.String())
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_AnimatedGIF(t *testing.T) {
+	// Two frames alternating the colors of the test image
+	g := &gif.GIF{}
+	for _, frame := range []image.Image{testImage(), invert(testImage())} {
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), frame, image.Point{}, draw.Src)
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, 50)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	image := buf.Bytes()
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.MimeType == "image/gif"
+	})).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).Return(nil, nil)
+
+	// The variants and the original show the first frame as PNG, and the
+	// animation is kept in an animated variant
+	var variants []model.PhotoVariant
+	db.EXPECT().CreatePhoto(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, photo model.Photo) error {
+		variants = photo.Variants
+		return nil
+	})
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, key string, _ []byte, _ string, _ ...store.UploadOption) (
+			string, error) {
+			return "https://example.com/" + key, nil
+		})
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend, URLs: newTestURLResolver(t)}
+	w := httptest.NewRecorder()
+
+	handler.UploadPhoto(w, newUploadRequest(t, "animated.gif", image, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(variants) != 10 || variants[0].Format != "png" || variants[9].Format != "png" {
+		t.Fatalf("Expected 8 still variants starting with PNG, the animation and the original, got %+v", variants)
+	}
+	animated := variants[8]
+	if animated.Name != "animated" || animated.Format != "gif" ||
+		!strings.Contains(animated.StorageKey, "/animated.") || !strings.HasSuffix(animated.StorageKey, ".gif") {
+		t.Errorf("Expected the animated variant last, got %+v", animated)
+	}
+}
+
+// invert returns the negative of an image.
+func invert(img image.Image) image.Image {
+	out := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			out.Set(x, y, color.RGBA{R: 255 - c.R, G: 255 - c.G, B: 255 - c.B, A: c.A})
+		}
+	}
+	return out
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := map[string][]byte{
+		"image/tiff": []byte("II*\x00\x08\x00\x00\x00"),
+		"image/gif":  []byte("GIF89a"),
+		"image/webp": []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+		"image/jpeg": testJPEG(t),
+	}
+	for want, data := range tests {
+		if got := detectContentType(data); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+	if got := detectContentType([]byte("MM\x00*\x00\x00\x00\x08")); got != "image/tiff" {
+		t.Errorf("Expected big endian TIFF, got %s", got)
+	}
+}
+
+func TestPhotoHandler_GetPhoto(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+
+	tests := []struct {
+		name           string
+		id             string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:           "invalid id",
+			id:             "photo_123456",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "not found",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "database failure",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{}, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "found",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{ID: photoID}, nil)
+				m.EXPECT().CountPhotoLikes(mock.Anything, photoID).Return(42, nil)
+				m.EXPECT().CountPhotoComments(mock.Anything, photoID).Return(7, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "count failure",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{ID: photoID}, nil)
+				m.EXPECT().CountPhotoLikes(mock.Anything, photoID).Return(0, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodGet, "/photo/"+tt.id, nil)
+			req = req.WithContext(context.WithValue(req.Context(), util2.ContextLogger, slog.Default()))
+			w := httptest.NewRecorder()
+
+			handler.GetPhoto(w, req, tt.id)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var resp gen.PhotoDetailsResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if resp.Photo.Id != photoID {
+					t.Errorf("Expected photo %s, got %s", photoID, resp.Photo.Id)
+				}
+				if resp.Photo.LikeCount != 42 || resp.Photo.CommentCount != 7 {
+					t.Errorf("Expected 42 likes and 7 comments, got %d and %d",
+						resp.Photo.LikeCount, resp.Photo.CommentCount)
+				}
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_DeletePhoto(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	owned := model.Photo{ID: photoID, UserID: testUserID}
+
+	tests := []struct {
+		name           string
+		id             string
+		userID         string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:           "invalid id",
+			id:             "photo_123456",
+			userID:         testUserID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no user",
+			id:             photoID,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "not found",
+			id:     photoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "another user's photo",
+			id:     photoID,
+			userID: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "deleted concurrently",
+			id:     photoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().DeletePhoto(mock.Anything, photoID, 720*time.Hour).Return(pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "database failure",
+			id:     photoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().DeletePhoto(mock.Anything, photoID, 720*time.Hour).Return(errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "deleted",
+			id:     photoID,
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(owned, nil)
+				m.EXPECT().DeletePhoto(mock.Anything, photoID, 720*time.Hour).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodDelete, "/photo/"+tt.id, nil)
+			ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+			ctx = context.WithValue(ctx, util2.ContextUserID, tt.userID)
+			w := httptest.NewRecorder()
+
+			handler.DeletePhoto(w, req.WithContext(ctx), tt.id)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_DeletePhoto_PurgesCDN(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	photo := model.Photo{ID: photoID, UserID: testUserID, Variants: []model.PhotoVariant{
+		{PhotoID: photoID, Name: "original", StorageKey: "photos/" + photoID + "/original.0a1b2c.jpg"},
+		{PhotoID: photoID, Name: "thumb", StorageKey: "photos/" + photoID + "/thumb.0a1b2c.jpg"},
+	}}
+	db := NewMockDatabase(t)
+	db.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+	db.EXPECT().DeletePhoto(mock.Anything, photoID, 720*time.Hour).Return(nil)
+
+	// The variants and resized images stop being served before garbage
+	// collection deletes them
+	storage := store.NewLocalStorage(t.TempDir(), "http://localhost:8080/media")
+	resized := imageKey(photoID, "3d4e5f", "webp")
+	if _, err := storage.Upload(context.Background(), resized, []byte("resized"), "image/webp"); err != nil {
+		t.Fatal(err)
+	}
+	fake := cdn.NewFake("https://cdn.example.com", storage)
+	handler := PhotoHandler{DB: db, Storage: storage, CDN: fake}
+
+	req := httptest.NewRequest(http.MethodDelete, "/photo/"+photoID, nil)
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, testUserID)
+	w := httptest.NewRecorder()
+
+	handler.DeletePhoto(w, req.WithContext(ctx), photoID)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	expected := []string{photo.Variants[0].StorageKey, photo.Variants[1].StorageKey, resized}
+	if purged := fake.Purged(); !slices.Equal(purged, expected) {
+		t.Errorf("Expected %v to be purged, got %v", expected, purged)
+	}
+}
@@ -0,0 +1,183 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/store"
+)
+
+// createPhoto processes a raw photo into a photo, rendering and storing each
+// configured variant in each configured format with the metadata, and records
+// it with its placeholder and the location of the raw photo. The first variant
+// is the thumbnail. Animated GIFs also have an animated variant, the others
+// show the first frame. The original is a full size variant, so the raw photo
+// is never served in its place. If the photo can't be recorded, the stored
+// variants are deleted.
+func (h PhotoHandler) createPhoto(ctx context.Context, raw model.RawPhoto, data []byte, img image.Image,
+	format string, caption *string, tags []string) (model.Photo, error) {
+	now := time.Now()
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	lat, lon := photoLocation(raw)
+	placeholder := imaging.NewPlaceholder(img)
+	photo := model.Photo{
+		ID:             uuid.New().String(),
+		RawPhotoID:     raw.ID,
+		UserID:         raw.UserID,
+		Filename:       raw.OriginalFilename,
+		StorageBackend: h.Backend,
+		Caption:        caption,
+		Tags:           tags,
+		Width:          &width,
+		Height:         &height,
+		BlurHash:       &placeholder.BlurHash,
+		DominantColor:  &placeholder.DominantColor,
+		Latitude:       lat,
+		Longitude:      lon,
+		UploadedAt:     now,
+		UpdatedAt:      now,
+	}
+
+	// Variants are served publicly, so only the orientation and color profile
+	// are kept of the metadata
+	meta := imaging.ReadMetadata(data)
+	renditions, err := imaging.Render(img, format, meta, config.GetPhotoVariants(), config.GetPhotoFormats())
+	if err != nil {
+		return photo, err
+	}
+
+	animation, err := renderAnimation(ctx, data)
+	if err != nil {
+		return photo, err
+	} else if animation != nil {
+		renditions = append(renditions, *animation)
+	}
+
+	original, err := renderOriginal(img, format, meta)
+	if err != nil {
+		return photo, err
+	}
+	renditions = append(renditions, original)
+
+	// Variants are stored under keys of this version of the photo, so cached
+	// variants of other versions are never served in its place
+	version := strconv.FormatInt(now.UnixNano(), 36)
+	for _, rendition := range renditions {
+		key := variantKey(photo.ID, version, rendition)
+		_, err := h.Storage.Upload(ctx, key, rendition.Data, imaging.ContentType(rendition.Format),
+			store.WithCacheControl(imageCacheControl),
+			store.WithMetadata(map[string]string{metadataPhotoID: photo.ID, metadataUserID: photo.UserID}))
+		if err != nil {
+			h.deleteVariants(ctx, photo.Variants)
+			return photo, fmt.Errorf("failed to store variant %s: %w", rendition.Variant.Name, err)
+		}
+
+		photo.Variants = append(photo.Variants, model.PhotoVariant{
+			PhotoID:        photo.ID,
+			Name:           rendition.Variant.Name,
+			Width:          rendition.Width,
+			Height:         rendition.Height,
+			Format:         rendition.Format,
+			StorageBackend: h.Backend,
+			StorageKey:     key,
+			FileSize:       int64(len(rendition.Data)),
+			CreatedAt:      now,
+		})
+		if rendition.Variant.Name == originalVariant {
+			photo.OriginalKey = key
+			photo.FileSize = int64(len(rendition.Data))
+			photo.MimeType = imaging.ContentType(rendition.Format)
+		}
+	}
+	if len(photo.Variants) > 0 {
+		photo.ThumbnailKey = photo.Variants[0].StorageKey
+	}
+
+	if err := h.DB.CreatePhoto(ctx, photo); err != nil {
+		h.deleteVariants(ctx, photo.Variants)
+		return photo, err
+	}
+
+	return photo, nil
+}
+
+// animatedVariant is the variant keeping the animation of animated GIFs
+const animatedVariant = "animated"
+
+// renderAnimation renders the animated variant of an animated GIF, or returns
+// nil for other images. Animations with too many pixels in all of their frames
+// have none.
+func renderAnimation(ctx context.Context, data []byte) (*imaging.Rendition, error) {
+	animation, err := decoder().DecodeAnimation(ctx, data)
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		util2.GetLogger(ctx).Info("Animation too large to render", "error", err)
+		return nil, nil
+	} else if err != nil || animation == nil {
+		return nil, err
+	}
+
+	v := imaging.Variant{Name: animatedVariant, Width: config.GetPhotoAnimationWidth(), Format: imaging.FormatGIF}
+	rendition, err := imaging.RenderAnimation(animation, v)
+	if err != nil {
+		return nil, err
+	}
+	return &rendition, nil
+}
+
+// originalVariant is the full size variant served as the original of photos
+const originalVariant = "original"
+
+// renderOriginal renders the original of a photo at full size. Like the other
+// variants, only the orientation and color profile are kept of the metadata,
+// so the location and camera details of the raw photo aren't served.
+func renderOriginal(img image.Image, format string, meta imaging.Metadata) (imaging.Rendition, error) {
+	v := imaging.Variant{Name: originalVariant, Width: img.Bounds().Dx()}
+	renditions, err := imaging.Render(img, format, meta, []imaging.Variant{v}, nil)
+	if err != nil {
+		return imaging.Rendition{}, err
+	}
+	return renditions[0], nil
+}
+
+// deleteVariants removes stored variants of a photo that couldn't be created,
+// and purges them from the CDN if there is one.
+func (h PhotoHandler) deleteVariants(ctx context.Context, variants []model.PhotoVariant) {
+	ctx = context.WithoutCancel(ctx)
+	keys := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		if err := h.Storage.Delete(ctx, variant.StorageKey); err != nil {
+			util2.GetLogger(ctx).Warn("Failed to delete photo variant", "error", err, "key", variant.StorageKey)
+			continue
+		}
+		keys = append(keys, variant.StorageKey)
+	}
+
+	if h.CDN != nil && len(keys) > 0 {
+		if err := h.CDN.Purge(ctx, keys); err != nil {
+			util2.GetLogger(ctx).Warn("Failed to purge photo variants", "error", err, "keys", keys)
+		}
+	}
+}
+
+// Metadata stored with objects, so their owners can be found from storage
+const (
+	metadataPhotoID = "photo-id"
+	metadataUserID  = "user-id"
+)
+
+// variantKey returns the storage key of a variant of a version of a photo in
+// the format of the rendition.
+func variantKey(photoID, version string, rendition imaging.Rendition) string {
+	return fmt.Sprintf("photos/%s/%s.%s%s", photoID, rendition.Variant.Name, version,
+		imaging.Extension(rendition.Format))
+}
@@ -0,0 +1,93 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// maxQuarantineReasonLength is the length of the quarantine_reason column
+const maxQuarantineReasonLength = 255
+
+// quarantineContentType is the content type of quarantined files, so they are
+// never served as images
+const quarantineContentType = "application/octet-stream"
+
+// errQuarantined is returned for uploads of a file that was quarantined
+var errQuarantined = errors.New("file is quarantined")
+
+// decoder decodes photos within the configured limits. It's shared by all
+// requests, so their memory is bounded together.
+var decoder = sync.OnceValue(func() *imaging.Decoder {
+	return imaging.NewDecoder(config.GetPhotoMaxPixels(), config.GetPhotoDecodeTimeout(),
+		config.GetPhotoDecodeMemoryBytes())
+})
+
+// rejection returns the response to a photo that couldn't be decoded, and
+// whether the file is quarantined. Photos that weren't decoded for lack of
+// resources or time aren't at fault, since a busy server decodes slower, so
+// they aren't quarantined and can be retried.
+func rejection(err error) (status int, msg string, quarantine bool) {
+	switch {
+	case errors.Is(err, imaging.ErrBusy) || errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, util2.ErrMsgServerBusy, false
+	case errors.Is(err, imaging.ErrDecodeTimeout):
+		return http.StatusServiceUnavailable, util2.ErrMsgImageDecodeTimeout, false
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		return http.StatusBadRequest, util2.ErrMsgImageTooLarge, true
+	case errors.Is(err, imaging.ErrFormatMismatch):
+		return http.StatusBadRequest, util2.ErrMsgImageFormatMismatch, true
+	case errors.Is(err, imaging.ErrTruncated):
+		return http.StatusBadRequest, util2.ErrMsgImageTruncated, true
+	default:
+		return http.StatusBadRequest, util2.ErrMsgInvalidImage, true
+	}
+}
+
+// quarantine keeps a rejected file for review. It's stored apart from raw
+// photos and recorded as a quarantined raw photo, which is never processed.
+// A file the user has already uploaded is left as it is.
+func (h PhotoHandler) quarantine(ctx context.Context, raw model.RawPhoto, data []byte, reason string) error {
+	_, err := h.DB.GetRawPhotoByHash(ctx, raw.UserID, raw.SHA256Hash)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, pgdb.ErrNotFound) {
+		return err
+	}
+
+	if len(reason) > maxQuarantineReasonLength {
+		reason = strings.ToValidUTF8(reason[:maxQuarantineReasonLength], "")
+	}
+	now := time.Now()
+	raw.QuarantinedAt = &now
+	raw.QuarantineReason = &reason
+
+	raw.StorageBackend = h.Backend
+	raw.StorageKey = QuarantineKey(raw)
+	_, err = h.Storage.Upload(ctx, raw.StorageKey, data, quarantineContentType,
+		store.WithMetadata(map[string]string{metadataUserID: raw.UserID}))
+	if err != nil {
+		return err
+	}
+
+	_, _, err = h.registerRawPhoto(ctx, raw)
+	return err
+}
+
+// QuarantineKey returns the storage key of a quarantined file, addressed by
+// its owner and content hash. The key has no extension, since the format of
+// the file can't be trusted.
+func QuarantineKey(raw model.RawPhoto) string {
+	return fmt.Sprintf("quarantine/%s/%s", raw.UserID, raw.SHA256Hash)
+}
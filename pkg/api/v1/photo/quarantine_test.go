@@ -0,0 +1,260 @@
+package photo
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// testPNGBomb returns a small PNG image whose header declares a billion
+// pixels.
+func testPNGBomb(t *testing.T) []byte {
+	data := testPNG(t)
+	ihdr := data[12:29] // Type and payload of the first chunk
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestPhotoHandler_UploadPhoto_Quarantine(t *testing.T) {
+	jpegData := testJPEG(t)
+
+	tests := []struct {
+		name       string
+		filename   string
+		data       []byte
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "too many pixels",
+			filename:   "bomb.png",
+			data:       testPNGBomb(t),
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    util2.ErrMsgImageTooLarge,
+		},
+		{
+			name:       "format mismatch",
+			filename:   "photo.png",
+			data:       jpegData,
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    util2.ErrMsgImageFormatMismatch,
+		},
+		{
+			name:       "truncated",
+			filename:   "photo.jpg",
+			data:       jpegData[:len(jpegData)-2],
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    util2.ErrMsgImageTruncated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := util2.CalculateSHA256(tt.data)
+
+			db := NewMockDatabase(t)
+			db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+			db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+				return raw.UserID == testUserID && raw.SHA256Hash == hash &&
+					raw.OriginalFilename == tt.filename && raw.QuarantinedAt != nil &&
+					raw.StorageBackend == testBackend && raw.StorageKey == "quarantine/"+testUserID+"/"+hash &&
+					raw.QuarantineReason != nil && *raw.QuarantineReason != ""
+			})).Return(nil)
+
+			// The file is stored apart from raw photos, and nothing is
+			// rendered of it
+			storage := store.NewMockStorage(t)
+			storage.EXPECT().Upload(mock.Anything, "quarantine/"+testUserID+"/"+hash, tt.data,
+				"application/octet-stream", mock.Anything).Return("https://example.com/quarantine/"+hash, nil)
+
+			handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+			w := httptest.NewRecorder()
+
+			handler.UploadPhoto(w, newUploadRequest(t, tt.filename, tt.data, testUserID), gen.UploadPhotoParams{})
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMsg) {
+				t.Errorf("Expected error message %q, got %s", tt.wantMsg, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_AlreadyQuarantined(t *testing.T) {
+	jpegData := testJPEG(t)
+	quarantinedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	reason := "image is truncated"
+	existing := model.RawPhoto{
+		ID:               "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b",
+		UserID:           testUserID,
+		QuarantinedAt:    &quarantinedAt,
+		QuarantineReason: &reason,
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantMsg string
+	}{
+		// A rejected file is only quarantined once
+		{name: "rejected again", data: jpegData[:len(jpegData)-2], wantMsg: util2.ErrMsgImageTruncated},
+		// A quarantined file is never processed, even if it's accepted now
+		{name: "accepted now", data: jpegData, wantMsg: util2.ErrMsgFileQuarantined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, util2.CalculateSHA256(tt.data)).
+				Return(existing, nil)
+
+			handler := PhotoHandler{DB: db, Storage: store.NewMockStorage(t)}
+			w := httptest.NewRecorder()
+
+			handler.UploadPhoto(w, newUploadRequest(t, "photo.jpg", tt.data, testUserID), gen.UploadPhotoParams{})
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMsg) {
+				t.Errorf("Expected error message %q, got %s", tt.wantMsg, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_UploadPhoto_QuarantineFailure(t *testing.T) {
+	jpegData := testJPEG(t)
+	data := jpegData[:len(jpegData)-2]
+
+	db := NewMockDatabase(t)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, mock.Anything).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, mock.Anything, data, mock.Anything, mock.Anything).
+		Return("", errors.New("upload failed"))
+
+	handler := PhotoHandler{DB: db, Storage: storage}
+	w := httptest.NewRecorder()
+
+	// The file is rejected all the same
+	handler.UploadPhoto(w, newUploadRequest(t, "photo.jpg", data, testUserID), gen.UploadPhotoParams{})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), util2.ErrMsgImageTruncated) {
+		t.Errorf("Expected error message about the truncated image, got %s", w.Body.String())
+	}
+}
+
+// expectQuarantine expects the data uploaded by the test user to be
+// quarantined.
+func expectQuarantine(db *MockDatabase, storage *store.MockStorage, data []byte) {
+	hash := util2.CalculateSHA256(data)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	storage.EXPECT().Upload(mock.Anything, "quarantine/"+testUserID+"/"+hash, data,
+		"application/octet-stream", mock.Anything).Return("https://example.com/quarantine/"+hash, nil)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.SHA256Hash == hash && raw.QuarantinedAt != nil && raw.QuarantineReason != nil
+	})).Return(nil)
+}
+
+func TestPhotoHandler_PatchUpload_Quarantine(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+	jpegData := testJPEG(t)
+	data := jpegData[:len(jpegData)-2]
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	receivedUpload(t, uploads, "photo.jpg", data)
+	storage := store.NewMockStorage(t)
+	expectQuarantine(db, storage, data)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	w := patchFinalOffset(handler, uploads[testUploadID])
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), util2.ErrMsgImageTruncated) {
+		t.Errorf("Expected error message about the truncated image, got %s", w.Body.String())
+	}
+	if uploads[testUploadID].CompletedAt != nil {
+		t.Error("Expected the upload not to be completed")
+	}
+}
+
+func TestPhotoHandler_CompletePhotoUpload_Quarantine(t *testing.T) {
+	jpegData := testJPEG(t)
+	data := jpegData[:len(jpegData)-2]
+	upload := newReceivedDirectUpload(data, false)
+	key := directUploadKey(*upload)
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	uploads[testUploadID] = upload
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Stat(mock.Anything, key).Return(store.ObjectInfo{Key: key, Size: upload.UploadLength,
+		ContentType: "image/jpeg", SHA256: *upload.SHA256Hash}, nil)
+	storage.EXPECT().Download(mock.Anything, key).Return(data, "image/jpeg", nil)
+	expectQuarantine(db, storage, data)
+	storage.EXPECT().Delete(mock.Anything, key).Return(nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+	w := httptest.NewRecorder()
+
+	handler.CompletePhotoUpload(w, newJSONRequest(t, "/photo/upload-complete",
+		gen.PhotoUploadCompleteRequest{UploadId: testUploadID}, testUserID))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), util2.ErrMsgImageTruncated) {
+		t.Errorf("Expected error message about the truncated image, got %s", w.Body.String())
+	}
+	if uploads[testUploadID].CompletedAt != nil {
+		t.Error("Expected the upload not to be completed")
+	}
+}
+
+func TestRejection(t *testing.T) {
+	tests := []struct {
+		err            error
+		wantStatus     int
+		wantQuarantine bool
+	}{
+		{err: imaging.ErrBusy, wantStatus: http.StatusServiceUnavailable},
+		{err: imaging.ErrDecodeTimeout, wantStatus: http.StatusServiceUnavailable},
+		{err: imaging.ErrTooManyPixels, wantStatus: http.StatusBadRequest, wantQuarantine: true},
+		{err: errors.New("failed to decode image"), wantStatus: http.StatusBadRequest, wantQuarantine: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			status, _, quarantine := rejection(tt.err)
+			if status != tt.wantStatus || quarantine != tt.wantQuarantine {
+				t.Errorf("Expected status %d and quarantine %v, got %d and %v",
+					tt.wantStatus, tt.wantQuarantine, status, quarantine)
+			}
+		})
+	}
+}
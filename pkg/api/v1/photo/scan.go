@@ -0,0 +1,57 @@
+package photo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+)
+
+// scanUpload scans an uploaded file, and records verdicts other than a plain
+// allow for admins to review. Without a scanner, every file is allowed.
+func (h PhotoHandler) scanUpload(ctx context.Context, raw model.RawPhoto, data []byte) (scan.Result, error) {
+	if h.Scanner == nil {
+		return scan.Result{Verdict: scan.Allow}, nil
+	}
+
+	result, err := h.Scanner.Scan(ctx, data)
+	if err != nil {
+		return result, err
+	}
+	if !result.Verdict.Valid() {
+		return result, fmt.Errorf("unknown verdict %q of scanner %s", result.Verdict, result.Scanner)
+	}
+	if result.Verdict == scan.Allow && len(result.Labels) == 0 {
+		return result, nil
+	}
+
+	err = h.DB.CreateScanResult(ctx, model.ScanResult{
+		ID:         uuid.New().String(),
+		UserID:     raw.UserID,
+		Filename:   raw.OriginalFilename,
+		SHA256Hash: raw.SHA256Hash,
+		Scanner:    result.Scanner,
+		Verdict:    result.Verdict,
+		Labels:     result.Labels,
+		ScannedAt:  time.Now(),
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to record scan result: %w", err)
+	}
+
+	return result, nil
+}
+
+// scanReason returns the quarantine reason of a file quarantined by a scan.
+func scanReason(result scan.Result) string {
+	reason := "quarantined by " + result.Scanner
+	if len(result.Labels) > 0 {
+		reason += ": " + strings.Join(result.Labels, ", ")
+	}
+	return reason
+}
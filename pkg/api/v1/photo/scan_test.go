@@ -0,0 +1,153 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
+)
+
+// stubScanner returns the same result for every file.
+type stubScanner struct {
+	result scan.Result
+	err    error
+}
+
+func (s stubScanner) Scan(context.Context, []byte) (scan.Result, error) {
+	return s.result, s.err
+}
+
+func TestPhotoHandler_UploadPhoto_Scan(t *testing.T) {
+	image := testJPEG(t)
+	hash := util2.CalculateSHA256(image)
+
+	// isScanResult matches the recorded result of scanning the upload
+	isScanResult := func(verdict scan.Verdict, labels ...string) interface{} {
+		return mock.MatchedBy(func(result model.ScanResult) bool {
+			return result.UserID == testUserID && result.SHA256Hash == hash && result.Filename == "test.jpg" &&
+				result.Scanner == "moderation" && result.Verdict == verdict &&
+				strings.Join(result.Labels, ",") == strings.Join(labels, ",")
+		})
+	}
+
+	tests := []struct {
+		name           string
+		scanner        stubScanner
+		setupMock      func(*MockDatabase, *store.MockStorage)
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name: "rejected",
+			scanner: stubScanner{result: scan.Result{Scanner: "moderation", Verdict: scan.Reject,
+				Labels: []string{"violence"}}},
+			setupMock: func(db *MockDatabase, storage *store.MockStorage) {
+				db.EXPECT().CreateScanResult(mock.Anything, isScanResult(scan.Reject, "violence")).Return(nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedMsg:    util2.ErrMsgFileRejected,
+		},
+		{
+			name: "quarantined",
+			scanner: stubScanner{result: scan.Result{Scanner: "moderation", Verdict: scan.Quarantine,
+				Labels: []string{"nudity", "suggestive"}}},
+			setupMock: func(db *MockDatabase, storage *store.MockStorage) {
+				db.EXPECT().CreateScanResult(mock.Anything, isScanResult(scan.Quarantine, "nudity", "suggestive")).
+					Return(nil)
+				db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+				storage.EXPECT().Upload(mock.Anything, "quarantine/"+testUserID+"/"+hash, image,
+					"application/octet-stream", mock.Anything).Return("https://example.com/quarantine/"+hash, nil)
+				db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+					return raw.QuarantineReason != nil &&
+						*raw.QuarantineReason == "quarantined by moderation: nudity, suggestive"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedMsg:    util2.ErrMsgFileQuarantined,
+		},
+		{
+			name:           "scanner unavailable",
+			scanner:        stubScanner{err: errors.New("connection refused")},
+			setupMock:      func(db *MockDatabase, storage *store.MockStorage) {},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    util2.ErrMsgFailedToScan,
+		},
+		{
+			name:           "unknown verdict",
+			scanner:        stubScanner{result: scan.Result{Scanner: "moderation", Verdict: "maybe"}},
+			setupMock:      func(db *MockDatabase, storage *store.MockStorage) {},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    util2.ErrMsgFailedToScan,
+		},
+		{
+			name: "verdict not recorded",
+			scanner: stubScanner{result: scan.Result{Scanner: "moderation", Verdict: scan.Reject,
+				Labels: []string{"violence"}}},
+			setupMock: func(db *MockDatabase, storage *store.MockStorage) {
+				db.EXPECT().CreateScanResult(mock.Anything, mock.Anything).Return(errors.New("db down"))
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    util2.ErrMsgFailedToScan,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			storage := store.NewMockStorage(t)
+			tt.setupMock(db, storage)
+
+			handler := PhotoHandler{DB: db, Storage: storage, Scanner: tt.scanner}
+			w := httptest.NewRecorder()
+
+			handler.UploadPhoto(w, newUploadRequest(t, "test.jpg", image, testUserID), gen.UploadPhotoParams{})
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedMsg) {
+				t.Errorf("Expected error message %q, got %s", tt.expectedMsg, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPhotoHandler_ScanUpload(t *testing.T) {
+	raw := newRawPhoto(testUserID, "test.jpg", testJPEG(t))
+
+	// Clean files aren't recorded
+	handler := PhotoHandler{DB: NewMockDatabase(t), Scanner: scan.Noop{}}
+	result, err := handler.scanUpload(context.Background(), raw, nil)
+	if err != nil || result.Verdict != scan.Allow {
+		t.Errorf("Expected allow, got %v and %v", result.Verdict, err)
+	}
+
+	// Nor are they without a scanner
+	result, err = PhotoHandler{}.scanUpload(context.Background(), raw, nil)
+	if err != nil || result.Verdict != scan.Allow {
+		t.Errorf("Expected allow, got %v and %v", result.Verdict, err)
+	}
+
+	// Allowed files with labels are
+	db := NewMockDatabase(t)
+	db.EXPECT().CreateScanResult(mock.Anything, mock.MatchedBy(func(result model.ScanResult) bool {
+		return result.Verdict == scan.Allow && len(result.Labels) == 1 && result.Labels[0] == "suggestive"
+	})).Return(nil)
+	handler = PhotoHandler{DB: db, Scanner: stubScanner{result: scan.Result{Scanner: "moderation",
+		Verdict: scan.Allow, Labels: []string{"suggestive"}}}}
+	result, err = handler.scanUpload(context.Background(), raw, nil)
+	if err != nil || result.Verdict != scan.Allow {
+		t.Errorf("Expected allow, got %v and %v", result.Verdict, err)
+	}
+}
@@ -0,0 +1,128 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// Limits of similar photo queries
+const (
+	defaultSimilarLimit = 20
+	maxSimilarLimit     = 100
+
+	// uploadSimilarLimit is the number of similar photos reported on upload
+	uploadSimilarLimit = 5
+)
+
+// GetSimilarPhotos lists the photos that look like a photo, most similar
+// first.
+// GET /photo/{id}/similar
+func (h PhotoHandler) GetSimilarPhotos(w http.ResponseWriter, r *http.Request, id string,
+	params gen.GetSimilarPhotosParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	if _, err := uuid.Parse(id); err != nil {
+		logger.Info("Invalid photo ID", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgInvalidUUID, http.StatusBadRequest)
+		return
+	}
+
+	maxDistance, limit := config.GetPhotoSimilarityThreshold(), defaultSimilarLimit
+	if params.MaxDistance != nil {
+		maxDistance = *params.MaxDistance
+	}
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if maxDistance < 0 || maxDistance > 64 || limit < 1 || limit > maxSimilarLimit {
+		logger.Info("Invalid similar photos query", "max_distance", maxDistance, "limit", limit)
+		http.Error(w, util2.ErrMsgInvalidSimilarQuery, http.StatusBadRequest)
+		return
+	}
+
+	photo, err := h.DB.GetPhotoByID(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("Photo not found", "id", id)
+		http.Error(w, util2.ErrMsgPhotoNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get photo", "error", err, "id", id)
+		http.Error(w, util2.ErrMsgFailedToGetPhoto, http.StatusInternalServerError)
+		return
+	}
+
+	raw, err := h.DB.GetRawPhotoByID(r.Context(), photo.RawPhotoID)
+	if err != nil {
+		logger.Error("Failed to get raw photo", "error", err, "id", id, "raw_photo_id", photo.RawPhotoID)
+		http.Error(w, util2.ErrMsgFailedToGetSimilarPhotos, http.StatusInternalServerError)
+		return
+	}
+
+	// Photos uploaded before hashing was introduced have no hash
+	resp := gen.SimilarPhotosResponse{Photos: []gen.SimilarPhoto{}}
+	if raw.PerceptualHash != nil {
+		similar, err := h.DB.GetSimilarPhotos(r.Context(), *raw.PerceptualHash, raw.ID, maxDistance, limit)
+		if err != nil {
+			logger.Error("Failed to get similar photos", "error", err, "id", id)
+			http.Error(w, util2.ErrMsgFailedToGetSimilarPhotos, http.StatusInternalServerError)
+			return
+		}
+		resp.Photos, err = h.toSimilarPhotos(r.Context(), similar)
+		if err != nil {
+			logger.Error("Failed to deliver similar photos", "error", err, "id", id)
+			http.Error(w, util2.ErrMsgFailedToGetSimilarPhotos, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// similarUploads returns the user's photos that look like a newly uploaded
+// raw photo. Lookup failures are only logged, since they don't affect the
+// upload.
+func (h PhotoHandler) similarUploads(ctx context.Context, raw model.RawPhoto) []gen.SimilarPhoto {
+	if raw.PerceptualHash == nil {
+		return nil
+	}
+
+	similar, err := h.DB.GetSimilarUserPhotos(ctx, raw.UserID, *raw.PerceptualHash,
+		config.GetPhotoSimilarityThreshold(), uploadSimilarLimit)
+	if err != nil {
+		util2.GetLogger(ctx).Warn("Failed to get similar photos", "error", err, "raw_photo_id", raw.ID)
+		return nil
+	}
+
+	photos, err := h.toSimilarPhotos(ctx, similar)
+	if err != nil {
+		util2.GetLogger(ctx).Warn("Failed to deliver similar photos", "error", err, "raw_photo_id", raw.ID)
+		return nil
+	}
+	return photos
+}
+
+// toSimilarPhotos converts similar photos with the URLs their thumbnails are
+// fetched from.
+func (h PhotoHandler) toSimilarPhotos(ctx context.Context, photos []model.SimilarPhoto) ([]gen.SimilarPhoto, error) {
+	similar := make([]gen.SimilarPhoto, len(photos))
+	for i := range photos {
+		similar[i] = photos[i].ToSimilarPhoto()
+		err := h.deliverURL(ctx, store.ClassThumbnail, photos[i].StorageBackend, photos[i].ThumbnailKey,
+			&similar[i].ThumbnailUrl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return similar, nil
+}
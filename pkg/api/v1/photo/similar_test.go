@@ -0,0 +1,144 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+func TestPhotoHandler_GetSimilarPhotos(t *testing.T) {
+	photoID := "0b7e4a51-3c2d-4e8f-9a1b-2c3d4e5f6a7b"
+	rawID := "3f2e1d0c-9b8a-4c7d-8e6f-5a4b3c2d1e0f"
+	hash := int64(-0x0f0f0f0f0f0f0f10)
+	photo := model.Photo{ID: photoID, RawPhotoID: rawID}
+	similar := []model.SimilarPhoto{
+		{Photo: model.Photo{ID: "a"}, Distance: 0},
+		{Photo: model.Photo{ID: "b"}, Distance: 4},
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		params         gen.GetSimilarPhotosParams
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+		expectedPhotos []string
+	}{
+		{
+			name:           "invalid id",
+			id:             "photo_123456",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid max distance",
+			id:             photoID,
+			params:         gen.GetSimilarPhotosParams{MaxDistance: intPtr(65)},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid limit",
+			id:             photoID,
+			params:         gen.GetSimilarPhotosParams{Limit: intPtr(0)},
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "not found",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(model.Photo{}, pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "database error",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{ID: rawID, PerceptualHash: &hash}, nil)
+				m.EXPECT().GetSimilarPhotos(mock.Anything, hash, rawID, 10, 20).Return(nil, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "not hashed",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{ID: rawID}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPhotos: []string{},
+		},
+		{
+			name: "default query",
+			id:   photoID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{ID: rawID, PerceptualHash: &hash}, nil)
+				m.EXPECT().GetSimilarPhotos(mock.Anything, hash, rawID, 10, 20).Return(similar, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPhotos: []string{"a", "b"},
+		},
+		{
+			name:   "custom query",
+			id:     photoID,
+			params: gen.GetSimilarPhotosParams{MaxDistance: intPtr(0), Limit: intPtr(1)},
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetPhotoByID(mock.Anything, photoID).Return(photo, nil)
+				m.EXPECT().GetRawPhotoByID(mock.Anything, rawID).Return(model.RawPhoto{ID: rawID, PerceptualHash: &hash}, nil)
+				m.EXPECT().GetSimilarPhotos(mock.Anything, hash, rawID, 0, 1).Return(similar[:1], nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPhotos: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := PhotoHandler{DB: db}
+
+			req := httptest.NewRequest(http.MethodGet, "/photo/"+tt.id+"/similar", nil)
+			req = req.WithContext(context.WithValue(req.Context(), util2.ContextLogger, slog.Default()))
+			w := httptest.NewRecorder()
+
+			handler.GetSimilarPhotos(w, req, tt.id, tt.params)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.SimilarPhotosResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(resp.Photos) != len(tt.expectedPhotos) {
+				t.Fatalf("Expected photos %v, got %+v", tt.expectedPhotos, resp.Photos)
+			}
+			for i, id := range tt.expectedPhotos {
+				if resp.Photos[i].Id != id || resp.Photos[i].Distance != similar[i].Distance {
+					t.Errorf("Expected photo %s, got %+v", id, resp.Photos[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package photo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/config"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+// Resumable uploads implement the core tus 1.0 protocol with the creation,
+// termination and expiration extensions. See: https://tus.io/protocols/resumable-upload
+const (
+	tusVersion       = "1.0.0"
+	tusExtensions    = "creation,termination,expiration"
+	tusContentType   = "application/offset+octet-stream"
+	headerPhotoID    = "Photo-Id"
+	headerTusVersion = "Tus-Resumable"
+)
+
+// uploadLocks serializes chunks written to the same upload, since a chunk is
+// written to the scratch file before its offset is committed.
+var uploadLocks = newKeyedMutex()
+
+// GetUploadCapabilities reports the supported tus version and extensions.
+// OPTIONS /uploads
+func (h PhotoHandler) GetUploadCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(headerTusVersion, tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(config.GetPhotoMaxFileSizeBytes(), 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUpload creates a resumable upload and its scratch file.
+// POST /uploads
+func (h PhotoHandler) CreateUpload(w http.ResponseWriter, r *http.Request, params gen.CreateUploadParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+	w.Header().Set(headerTusVersion, tusVersion)
+
+	if !isSupportedTusVersion(params.TusResumable) {
+		writeTusVersionError(w)
+		return
+	}
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Upload without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	if params.UploadLength == nil || *params.UploadLength <= 0 {
+		http.Error(w, util2.ErrMsgInvalidUploadLength, http.StatusBadRequest)
+		return
+	}
+
+	maxFileSize := config.GetPhotoMaxFileSizeBytes()
+	if *params.UploadLength > maxFileSize {
+		logger.Info("File size too large",
+			"max_size_mb", maxFileSize/(1024*1024), "file_size_mb", *params.UploadLength/(1024*1024))
+		http.Error(w, util2.ErrMsgFileTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(params.UploadMetadata)
+	if err != nil {
+		logger.Info("Invalid upload metadata", "error", err)
+		http.Error(w, util2.ErrMsgInvalidUploadMetadata, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	upload := model.Upload{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Filename:     metadata["filename"],
+		UploadLength: *params.UploadLength,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(config.GetUploadExpiration()),
+	}
+	if len(metadata) > 0 {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			logger.Error("Failed to encode upload metadata", "error", err)
+			http.Error(w, util2.ErrMsgFailedToCreateUpload, http.StatusInternalServerError)
+			return
+		}
+		upload.Metadata = util2.StringPtr(string(encoded))
+	}
+
+	// Create the scratch file before the row, so every upload that can be
+	// found has somewhere to write to.
+	if err := createScratchFile(upload.ID); err != nil {
+		logger.Error("Failed to create scratch file", "error", err, "upload_id", upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCreateUpload, http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.DB.CreateUpload(r.Context(), upload); err != nil {
+		logger.Error("Failed to create upload", "error", err, "upload_id", upload.ID)
+		removeScratchFile(logger, upload.ID)
+		http.Error(w, util2.ErrMsgFailedToCreateUpload, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Upload created", "upload_id", upload.ID, "upload_length", upload.UploadLength)
+
+	// Relative to the request URL, so it resolves under whichever API prefix
+	// the client used.
+	w.Header().Set("Location", "uploads/"+upload.ID)
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetUploadOffset returns how many bytes of the upload have been received.
+// HEAD /uploads/{id}
+func (h PhotoHandler) GetUploadOffset(w http.ResponseWriter, r *http.Request, id string,
+	params gen.GetUploadOffsetParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+	w.Header().Set(headerTusVersion, tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+
+	// HEAD responses have no body, so errors are only reported by status
+	if !isSupportedTusVersion(params.TusResumable) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	upload, status := h.getUpload(r, logger, id, false)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	setUploadHeaders(w, upload)
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.UploadLength, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchUpload writes a chunk to the upload at the given offset. When the last
+// chunk is received, the upload is handed to the upload pipeline.
+// PATCH /uploads/{id}
+func (h PhotoHandler) PatchUpload(w http.ResponseWriter, r *http.Request, id string,
+	params gen.PatchUploadParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+	w.Header().Set(headerTusVersion, tusVersion)
+
+	if !isSupportedTusVersion(params.TusResumable) {
+		writeTusVersionError(w)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != tusContentType {
+		http.Error(w, util2.ErrMsgUnsupportedContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if params.UploadOffset == nil || *params.UploadOffset < 0 {
+		http.Error(w, util2.ErrMsgInvalidUploadOffset, http.StatusBadRequest)
+		return
+	}
+
+	unlock := uploadLocks.Lock(id)
+	defer unlock()
+
+	upload, status := h.getUpload(r, logger, id, false)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if upload.UploadOffset != *params.UploadOffset {
+		logger.Info("Upload offset mismatch", "upload_id", id,
+			"offset", upload.UploadOffset, "requested_offset", *params.UploadOffset)
+		http.Error(w, util2.ErrMsgUploadOffsetMismatch, http.StatusConflict)
+		return
+	}
+
+	if !upload.IsComplete() {
+		written, err := writeChunk(upload, r.Body)
+		if written > 0 {
+			// Keep the bytes received before an interrupted request, so the
+			// client can resume from them.
+			dbErr := h.DB.UpdateUploadOffset(context.WithoutCancel(r.Context()), id,
+				upload.UploadOffset, upload.UploadOffset+written)
+			if errors.Is(dbErr, pgdb.ErrConflict) {
+				// Another instance received a chunk at the same offset first
+				logger.Info("Upload offset changed concurrently", "upload_id", id,
+					"offset", upload.UploadOffset)
+				http.Error(w, util2.ErrMsgUploadOffsetMismatch, http.StatusConflict)
+				return
+			} else if dbErr != nil {
+				logger.Error("Failed to update upload offset", "error", dbErr, "upload_id", id)
+				http.Error(w, util2.ErrMsgFailedToWriteUpload, http.StatusInternalServerError)
+				return
+			}
+			upload.UploadOffset += written
+		}
+
+		if errors.Is(err, errChunkTooLarge) {
+			http.Error(w, util2.ErrMsgChunkTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			logger.Error("Failed to write chunk", "error", err, "upload_id", id)
+			http.Error(w, util2.ErrMsgFailedToWriteUpload, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Completion is retried by resending an empty chunk at the final offset,
+	// in case handing the upload off failed previously.
+	if upload.IsComplete() && upload.CompletedAt == nil {
+		raw, status, message := h.completeUpload(r.Context(), logger, upload)
+		if status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+		upload.RawPhotoID = &raw.ID
+
+		logger.Info("Upload completed", "upload_id", id, "raw_photo_id", raw.ID)
+	}
+
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TerminateUpload discards an upload and its received bytes.
+// DELETE /uploads/{id}
+func (h PhotoHandler) TerminateUpload(w http.ResponseWriter, r *http.Request, id string,
+	params gen.TerminateUploadParams) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+	w.Header().Set(headerTusVersion, tusVersion)
+
+	if !isSupportedTusVersion(params.TusResumable) {
+		writeTusVersionError(w)
+		return
+	}
+
+	unlock := uploadLocks.Lock(id)
+	defer unlock()
+
+	// Expired uploads can still be terminated
+	upload, status := h.getUpload(r, logger, id, false)
+	if status != http.StatusOK && status != http.StatusGone {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if err := h.DB.DeleteUpload(r.Context(), upload.ID); err != nil {
+		logger.Error("Failed to delete upload", "error", err, "upload_id", id)
+		http.Error(w, util2.ErrMsgFailedToDeleteUpload, http.StatusInternalServerError)
+		return
+	}
+	removeScratchFile(logger, upload.ID)
+
+	logger.Info("Upload terminated", "upload_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getUpload fetches a resumable or direct upload owned by the requesting user
+// and returns the HTTP status describing its state.
+func (h PhotoHandler) getUpload(r *http.Request, logger *slog.Logger, id string, direct bool) (model.Upload, int) {
+	if _, err := uuid.Parse(id); err != nil {
+		return model.Upload{}, http.StatusNotFound
+	}
+
+	upload, err := h.DB.GetUpload(r.Context(), id)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		return model.Upload{}, http.StatusNotFound
+	} else if err != nil {
+		logger.Error("Failed to get upload", "error", err, "upload_id", id)
+		return model.Upload{}, http.StatusInternalServerError
+	}
+
+	// Don't reveal uploads of other users, or of the other kind
+	if upload.UserID != util2.GetUserID(r.Context()) || upload.Direct != direct {
+		return model.Upload{}, http.StatusNotFound
+	}
+
+	if upload.CompletedAt == nil && time.Now().After(upload.ExpiresAt) {
+		return upload, http.StatusGone
+	}
+
+	return upload, http.StatusOK
+}
+
+// completeUpload hands the received bytes to the upload pipeline. On error, the
+// HTTP status and message to respond with are returned.
+func (h PhotoHandler) completeUpload(ctx context.Context, logger *slog.Logger, upload model.Upload) (
+	raw model.RawPhoto, status int, message string) {
+	data, err := os.ReadFile(scratchPath(upload.ID))
+	if err != nil {
+		logger.Error("Failed to read scratch file", "error", err, "upload_id", upload.ID)
+		return raw, http.StatusInternalServerError, util2.ErrMsgFailedToReadFile
+	}
+	data = data[:min(int64(len(data)), upload.UploadLength)]
+
+	processed, status, message := h.processUpload(ctx, logger.With("upload_id", upload.ID),
+		newRawPhoto(upload.UserID, upload.Filename, data), data, nil, []string{})
+	if status != http.StatusOK {
+		return raw, status, message
+	}
+	raw = processed.raw
+
+	if err := h.DB.CompleteUpload(ctx, upload.ID, raw.ID); err != nil {
+		logger.Error("Failed to complete upload", "error", err, "upload_id", upload.ID)
+		return raw, http.StatusInternalServerError, util2.ErrMsgFailedToSavePhoto
+	}
+	removeScratchFile(logger, upload.ID)
+
+	return raw, http.StatusOK, ""
+}
+
+// setUploadHeaders sets the headers describing the state of an upload.
+func setUploadHeaders(w http.ResponseWriter, upload model.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	if upload.RawPhotoID != nil {
+		w.Header().Set(headerPhotoID, *upload.RawPhotoID)
+	} else {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func isSupportedTusVersion(version *gen.TusResumable) bool {
+	return version != nil && *version == tusVersion
+}
+
+func writeTusVersionError(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", tusVersion)
+	http.Error(w, util2.ErrMsgUnsupportedTusVersion, http.StatusPreconditionFailed)
+}
+
+// parseUploadMetadata decodes the Upload-Metadata header, a comma separated
+// list of keys and optional base64 encoded values.
+func parseUploadMetadata(header *string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if header == nil || strings.TrimSpace(*header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(*header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for metadata key %s: %w", key, err)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// errChunkTooLarge is returned when a chunk extends past the upload length.
+var errChunkTooLarge = errors.New("chunk exceeds upload length")
+
+func scratchPath(uploadID string) string {
+	return filepath.Join(config.GetUploadScratchPath(), uploadID)
+}
+
+func createScratchFile(uploadID string) error {
+	if err := os.MkdirAll(config.GetUploadScratchPath(), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(scratchPath(uploadID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func removeScratchFile(logger *slog.Logger, uploadID string) {
+	if err := os.Remove(scratchPath(uploadID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Failed to remove scratch file", "error", err, "upload_id", uploadID)
+	}
+}
+
+// writeChunk writes the chunk to the scratch file at the upload's offset and
+// returns the number of bytes written, which is non-zero even on error if part
+// of the chunk was received.
+func writeChunk(upload model.Upload, chunk io.Reader) (int64, error) {
+	f, err := os.OpenFile(scratchPath(upload.ID), os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	remaining := upload.UploadLength - upload.UploadOffset
+	w := io.NewOffsetWriter(f, upload.UploadOffset)
+	written, err := io.Copy(w, io.LimitReader(chunk, remaining))
+	if err != nil {
+		return written, err
+	}
+
+	// Any byte past the remaining length means the chunk is too large
+	if n, _ := chunk.Read(make([]byte, 1)); n > 0 {
+		return written, errChunkTooLarge
+	}
+
+	return written, f.Sync()
+}
+
+// keyedMutex provides a mutex per key, releasing keys nobody holds.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: map[string]*keyedMutexEntry{}}
+}
+
+// Lock locks the mutex of the key and returns the function unlocking it.
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	entry, ok := k.locks[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		k.locks[key] = entry
+	}
+	entry.refs++
+	k.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		k.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
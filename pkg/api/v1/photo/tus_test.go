@@ -0,0 +1,554 @@
+package photo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
+)
+
+const testUploadID = "3d6f0a2b-5c4e-4f1a-8b9c-0d1e2f3a4b5c"
+
+// newTusRequest creates a tus request with the logger and user set in the
+// context.
+func newTusRequest(method, target string, body []byte, userID string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+func tusVersionPtr() *gen.TusResumable {
+	version := tusVersion
+	return &version
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+// fakeUploads backs the upload methods of a MockDatabase with a map, so a
+// test can drive an upload through several requests.
+func fakeUploads(db *MockDatabase) map[string]*model.Upload {
+	uploads := map[string]*model.Upload{}
+
+	db.EXPECT().CreateUpload(mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, upload model.Upload) error {
+			uploads[upload.ID] = &upload
+			return nil
+		}).Maybe()
+	db.EXPECT().GetUpload(mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, id string) (model.Upload, error) {
+			if upload, ok := uploads[id]; ok {
+				return *upload, nil
+			}
+			return model.Upload{}, pgdb.ErrNotFound
+		}).Maybe()
+	db.EXPECT().UpdateUploadOffset(mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, id string, from, to int64) error {
+			if uploads[id].UploadOffset != from {
+				return pgdb.ErrConflict
+			}
+			uploads[id].UploadOffset = to
+			return nil
+		}).Maybe()
+	db.EXPECT().CompleteUpload(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, id, rawPhotoID string) error {
+			now := time.Now()
+			uploads[id].RawPhotoID = &rawPhotoID
+			uploads[id].CompletedAt = &now
+			return nil
+		}).Maybe()
+	db.EXPECT().DeleteUpload(mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, id string) error {
+			delete(uploads, id)
+			return nil
+		}).Maybe()
+
+	return uploads
+}
+
+func TestPhotoHandler_GetUploadCapabilities(t *testing.T) {
+	handler := PhotoHandler{}
+	w := httptest.NewRecorder()
+
+	handler.GetUploadCapabilities(w, httptest.NewRequest(http.MethodOptions, "/uploads", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, tusVersion, w.Header().Get("Tus-Resumable"))
+	assert.Equal(t, tusVersion, w.Header().Get("Tus-Version"))
+	assert.Contains(t, w.Header().Get("Tus-Extension"), "creation")
+	assert.NotEmpty(t, w.Header().Get("Tus-Max-Size"))
+}
+
+func TestPhotoHandler_CreateUpload_Validation(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+	t.Setenv("PHOTO_MAX_FILE_SIZE_MB", "1")
+
+	tests := []struct {
+		name           string
+		params         gen.CreateUploadParams
+		userID         string
+		expectedStatus int
+	}{
+		{
+			name:           "missing tus version",
+			params:         gen.CreateUploadParams{UploadLength: int64Ptr(10)},
+			userID:         testUserID,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "missing user",
+			params:         gen.CreateUploadParams{TusResumable: tusVersionPtr(), UploadLength: int64Ptr(10)},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing length",
+			params:         gen.CreateUploadParams{TusResumable: tusVersionPtr()},
+			userID:         testUserID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too large",
+			params:         gen.CreateUploadParams{TusResumable: tusVersionPtr(), UploadLength: int64Ptr(2 << 20)},
+			userID:         testUserID,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "invalid metadata",
+			params: gen.CreateUploadParams{TusResumable: tusVersionPtr(), UploadLength: int64Ptr(10),
+				UploadMetadata: util2.StringPtr("filename not-base64!")},
+			userID:         testUserID,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := PhotoHandler{DB: NewMockDatabase(t)}
+			w := httptest.NewRecorder()
+
+			handler.CreateUpload(w, newTusRequest(http.MethodPost, "/uploads", nil, tt.userID), tt.params)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestPhotoHandler_ResumableUpload(t *testing.T) {
+	scratch := t.TempDir()
+	t.Setenv("UPLOAD_SCRATCH_PATH", scratch)
+
+	image := testJPEG(t)
+	sha256Hash := util2.CalculateSHA256(image)
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, sha256Hash).
+		Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.OriginalFilename == "beach.jpg" && raw.FileSize == int64(len(image))
+	})).Return(nil)
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return([]model.SimilarPhoto{}, nil)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+		return photo.Filename == "beach.jpg" && len(photo.Variants) == 9
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, "raw/"+testUserID+"/"+sha256Hash+".jpg", image, "image/jpeg", mock.Anything).
+		Return("https://example.com/raw.jpg", nil)
+	expectVariantUploads(storage)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+
+	// Create the upload
+	w := httptest.NewRecorder()
+	handler.CreateUpload(w, newTusRequest(http.MethodPost, "/uploads", nil, testUserID),
+		gen.CreateUploadParams{
+			TusResumable:   tusVersionPtr(),
+			UploadLength:   int64Ptr(int64(len(image))),
+			UploadMetadata: util2.StringPtr("filename YmVhY2guanBn,private"),
+		})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.True(t, strings.HasPrefix(w.Header().Get("Location"), "uploads/"))
+	require.NotEmpty(t, w.Header().Get("Upload-Expires"))
+
+	id := strings.TrimPrefix(w.Header().Get("Location"), "uploads/")
+	require.Contains(t, uploads, id)
+	assert.Equal(t, "beach.jpg", uploads[id].Filename)
+	assert.FileExists(t, filepath.Join(scratch, id))
+
+	patch := func(offset int, chunk []byte, userID string) *httptest.ResponseRecorder {
+		req := newTusRequest(http.MethodPatch, "/uploads/"+id, chunk, userID)
+		req.Header.Set("Content-Type", tusContentType)
+		w := httptest.NewRecorder()
+		handler.PatchUpload(w, req, id, gen.PatchUploadParams{
+			TusResumable: tusVersionPtr(),
+			UploadOffset: int64Ptr(int64(offset)),
+		})
+		return w
+	}
+	head := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.GetUploadOffset(w, newTusRequest(http.MethodHead, "/uploads/"+id, nil, testUserID), id,
+			gen.GetUploadOffsetParams{TusResumable: tusVersionPtr()})
+		return w
+	}
+
+	// Send the first half
+	half := len(image) / 2
+	w = patch(0, image[:half], testUserID)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, strconv.Itoa(half), w.Header().Get("Upload-Offset"))
+
+	// Other users can't see or write the upload
+	w = patch(half, image[half:], "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// A chunk at the wrong offset is rejected
+	w = patch(0, image[:half], testUserID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// The client resumes from the reported offset
+	w = head()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strconv.Itoa(half), w.Header().Get("Upload-Offset"))
+	assert.Equal(t, strconv.Itoa(len(image)), w.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// The last chunk completes the upload
+	w = patch(half, image[half:], testUserID)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, strconv.Itoa(len(image)), w.Header().Get("Upload-Offset"))
+	assert.NotEmpty(t, w.Header().Get("Photo-Id"))
+	assert.NoFileExists(t, filepath.Join(scratch, id))
+
+	w = head()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, *uploads[id].RawPhotoID, w.Header().Get("Photo-Id"))
+}
+
+func TestPhotoHandler_PatchUpload_ChunkTooLarge(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	uploads[testUploadID] = &model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		UploadLength: 4,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	require.NoError(t, createScratchFile(testUploadID))
+
+	handler := PhotoHandler{DB: db}
+	req := newTusRequest(http.MethodPatch, "/uploads/"+testUploadID, []byte("too large"), testUserID)
+	req.Header.Set("Content-Type", tusContentType)
+	w := httptest.NewRecorder()
+
+	handler.PatchUpload(w, req, testUploadID, gen.PatchUploadParams{
+		TusResumable: tusVersionPtr(),
+		UploadOffset: int64Ptr(0),
+	})
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestPhotoHandler_PatchUpload_Concurrent(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+	require.NoError(t, createScratchFile(testUploadID))
+
+	// Both requests read the offset before either commits, as when they're
+	// received by different instances
+	db := NewMockDatabase(t)
+	db.EXPECT().GetUpload(mock.Anything, testUploadID).Return(model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		UploadLength: 4,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}, nil)
+	var mu sync.Mutex
+	var offset int64
+	db.EXPECT().UpdateUploadOffset(mock.Anything, testUploadID, int64(0), int64(2)).
+		RunAndReturn(func(ctx context.Context, id string, from, to int64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if offset != from {
+				return pgdb.ErrConflict
+			}
+			offset = to
+			return nil
+		})
+
+	handler := PhotoHandler{DB: db}
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := newTusRequest(http.MethodPatch, "/uploads/"+testUploadID, []byte("da"), testUserID)
+			req.Header.Set("Content-Type", tusContentType)
+			w := httptest.NewRecorder()
+			handler.PatchUpload(w, req, testUploadID, gen.PatchUploadParams{
+				TusResumable: tusVersionPtr(),
+				UploadOffset: int64Ptr(0),
+			})
+			codes[i] = w.Code
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(codes)
+	assert.Equal(t, []int{http.StatusNoContent, http.StatusConflict}, codes)
+	assert.Equal(t, int64(2), offset)
+}
+
+// receivedUpload adds a resumable upload of the data to the uploads, with all
+// of its bytes received but not handed off yet.
+func receivedUpload(t *testing.T, uploads map[string]*model.Upload, filename string, data []byte) {
+	uploads[testUploadID] = &model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		Filename:     filename,
+		UploadLength: int64(len(data)),
+		UploadOffset: int64(len(data)),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	require.NoError(t, os.WriteFile(scratchPath(testUploadID), data, 0o600))
+}
+
+// patchFinalOffset sends an empty chunk at the final offset of a received
+// upload, which hands it off.
+func patchFinalOffset(handler PhotoHandler, upload *model.Upload) *httptest.ResponseRecorder {
+	req := newTusRequest(http.MethodPatch, "/uploads/"+upload.ID, nil, upload.UserID)
+	req.Header.Set("Content-Type", tusContentType)
+	w := httptest.NewRecorder()
+	handler.PatchUpload(w, req, upload.ID, gen.PatchUploadParams{
+		TusResumable: tusVersionPtr(),
+		UploadOffset: int64Ptr(upload.UploadLength),
+	})
+	return w
+}
+
+func TestPhotoHandler_PatchUpload_Scan(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+
+	image := testJPEG(t)
+	hash := util2.CalculateSHA256(image)
+
+	// Quarantined files are neither stored as raw photos nor processed
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	receivedUpload(t, uploads, "beach.jpg", image)
+	db.EXPECT().CreateScanResult(mock.Anything, mock.MatchedBy(func(result model.ScanResult) bool {
+		return result.SHA256Hash == hash && result.Verdict == scan.Quarantine
+	})).Return(nil)
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.MatchedBy(func(raw model.RawPhoto) bool {
+		return raw.QuarantinedAt != nil
+	})).Return(nil)
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, "quarantine/"+testUserID+"/"+hash, image,
+		"application/octet-stream", mock.Anything).Return("https://example.com/quarantine/"+hash, nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend,
+		Scanner: stubScanner{result: scan.Result{Scanner: "moderation", Verdict: scan.Quarantine}}}
+
+	w := patchFinalOffset(handler, uploads[testUploadID])
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), util2.ErrMsgFileQuarantined)
+	assert.Nil(t, uploads[testUploadID].CompletedAt)
+}
+
+func TestPhotoHandler_PatchUpload_RetryProcessing(t *testing.T) {
+	t.Setenv("UPLOAD_SCRATCH_PATH", t.TempDir())
+
+	image := testJPEG(t)
+	hash := util2.CalculateSHA256(image)
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	receivedUpload(t, uploads, "beach.jpg", image)
+
+	var saved model.RawPhoto
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).Return(model.RawPhoto{}, pgdb.ErrNotFound).Once()
+	db.EXPECT().CreateRawPhoto(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, raw model.RawPhoto) error {
+			saved = raw
+			return nil
+		})
+	db.EXPECT().GetSimilarUserPhotos(mock.Anything, testUserID, mock.Anything, 10, 5).
+		Return([]model.SimilarPhoto{}, nil)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.Anything).Return(errors.New("db down")).Once()
+
+	storage := store.NewMockStorage(t)
+	storage.EXPECT().Upload(mock.Anything, "raw/"+testUserID+"/"+hash+".jpg", image, "image/jpeg", mock.Anything).
+		Return("https://example.com/raw.jpg", nil)
+	expectVariantUploads(storage)
+	storage.EXPECT().Delete(mock.Anything, mock.MatchedBy(isVariantKey)).Return(nil)
+
+	handler := PhotoHandler{DB: db, Storage: storage, Backend: testBackend}
+
+	// The raw photo is stored, but the upload isn't complete until it has
+	// been processed
+	w := patchFinalOffset(handler, uploads[testUploadID])
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Nil(t, uploads[testUploadID].CompletedAt)
+
+	// Retrying processes the stored raw photo into a photo
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, hash).RunAndReturn(
+		func(context.Context, string, string) (model.RawPhoto, error) {
+			return saved, nil
+		})
+	db.EXPECT().GetPhotoByRawPhotoID(mock.Anything, mock.Anything).Return(model.Photo{}, pgdb.ErrNotFound)
+	db.EXPECT().CreatePhoto(mock.Anything, mock.MatchedBy(func(photo model.Photo) bool {
+		return photo.RawPhotoID == saved.ID
+	})).Return(nil)
+
+	w = patchFinalOffset(handler, uploads[testUploadID])
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, saved.ID, w.Header().Get("Photo-Id"))
+	assert.NotNil(t, uploads[testUploadID].CompletedAt)
+}
+
+func TestPhotoHandler_PatchUpload_Validation(t *testing.T) {
+	handler := PhotoHandler{}
+
+	t.Run("content type", func(t *testing.T) {
+		req := newTusRequest(http.MethodPatch, "/uploads/"+testUploadID, []byte("data"), testUserID)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		w := httptest.NewRecorder()
+
+		handler.PatchUpload(w, req, testUploadID, gen.PatchUploadParams{
+			TusResumable: tusVersionPtr(),
+			UploadOffset: int64Ptr(0),
+		})
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("missing offset", func(t *testing.T) {
+		req := newTusRequest(http.MethodPatch, "/uploads/"+testUploadID, []byte("data"), testUserID)
+		req.Header.Set("Content-Type", tusContentType)
+		w := httptest.NewRecorder()
+
+		handler.PatchUpload(w, req, testUploadID, gen.PatchUploadParams{TusResumable: tusVersionPtr()})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPhotoHandler_ExpiredUpload(t *testing.T) {
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	uploads[testUploadID] = &model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		UploadLength: 4,
+		ExpiresAt:    time.Now().Add(-time.Minute),
+	}
+
+	handler := PhotoHandler{DB: db}
+	head := func() int {
+		w := httptest.NewRecorder()
+		handler.GetUploadOffset(w, newTusRequest(http.MethodHead, "/uploads/"+testUploadID, nil, testUserID),
+			testUploadID, gen.GetUploadOffsetParams{TusResumable: tusVersionPtr()})
+		return w.Code
+	}
+	patch := func() int {
+		req := newTusRequest(http.MethodPatch, "/uploads/"+testUploadID, []byte("data"), testUserID)
+		req.Header.Set("Content-Type", tusContentType)
+		w := httptest.NewRecorder()
+		handler.PatchUpload(w, req, testUploadID, gen.PatchUploadParams{
+			TusResumable: tusVersionPtr(),
+			UploadOffset: int64Ptr(0),
+		})
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusGone, head())
+	assert.Equal(t, http.StatusGone, patch())
+	assert.Zero(t, uploads[testUploadID].UploadOffset)
+
+	// Once garbage collected, the upload is missing
+	delete(uploads, testUploadID)
+	assert.Equal(t, http.StatusNotFound, head())
+	assert.Equal(t, http.StatusNotFound, patch())
+}
+
+func TestPhotoHandler_TerminateUpload(t *testing.T) {
+	scratch := t.TempDir()
+	t.Setenv("UPLOAD_SCRATCH_PATH", scratch)
+
+	db := NewMockDatabase(t)
+	uploads := fakeUploads(db)
+	uploads[testUploadID] = &model.Upload{
+		ID:           testUploadID,
+		UserID:       testUserID,
+		UploadLength: 4,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	require.NoError(t, createScratchFile(testUploadID))
+
+	handler := PhotoHandler{DB: db}
+	w := httptest.NewRecorder()
+
+	handler.TerminateUpload(w, newTusRequest(http.MethodDelete, "/uploads/"+testUploadID, nil, testUserID),
+		testUploadID, gen.TerminateUploadParams{TusResumable: tusVersionPtr()})
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NotContains(t, uploads, testUploadID)
+	_, err := os.Stat(filepath.Join(scratch, testUploadID))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Terminating again reports the upload as missing
+	w = httptest.NewRecorder()
+	handler.TerminateUpload(w, newTusRequest(http.MethodDelete, "/uploads/"+testUploadID, nil, testUserID),
+		testUploadID, gen.TerminateUploadParams{TusResumable: tusVersionPtr()})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata, err := parseUploadMetadata(util2.StringPtr("filename YmVhY2guanBn, filetype aW1hZ2UvanBlZw==,flag"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"filename": "beach.jpg",
+		"filetype": "image/jpeg",
+		"flag":     "",
+	}, metadata)
+
+	metadata, err = parseUploadMetadata(nil)
+	require.NoError(t, err)
+	assert.Empty(t, metadata)
+
+	_, err = parseUploadMetadata(util2.StringPtr("filename %%%"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/imaging"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/scan"
+	"jelly/pkg/store"
+)
+
+// processedUpload is an upload that went through the upload pipeline.
+type processedUpload struct {
+	raw       model.RawPhoto
+	photo     model.Photo
+	duplicate bool
+
+	// similar are the photos of the user that look like the upload
+	similar []gen.SimilarPhoto
+}
+
+// processUpload runs an uploaded file through the upload pipeline shared by
+// every way of uploading photos: the file is scanned, decoded, stored as a raw
+// photo and processed into a photo. Files refused by the scan or that can't be
+// decoded are quarantined for review. A file the user has already uploaded
+// returns the photo processed from it, processing it again if that failed the
+// first time. On error, the HTTP status and message to respond with are
+// returned.
+func (h PhotoHandler) processUpload(ctx context.Context, logger *slog.Logger, raw model.RawPhoto, data []byte,
+	caption *string, tags []string) (processed processedUpload, status int, message string) {
+	// Check if valid image file type
+	if !isSupportedType(raw.MimeType) {
+		logger.Info("Unsupported file type", "mime_type", raw.MimeType)
+		return processed, http.StatusBadRequest, unsupportedTypeMessage()
+	}
+
+	// Files are scanned before anything else is done with them
+	result, err := h.scanUpload(ctx, raw, data)
+	if err != nil {
+		logger.Error("Failed to scan file", "error", err, "filename", raw.OriginalFilename)
+		return processed, http.StatusServiceUnavailable, util2.ErrMsgFailedToScan
+	}
+	if result.Verdict != scan.Allow {
+		logger.Info("File refused by scan", "scanner", result.Scanner, "verdict", result.Verdict,
+			"labels", result.Labels, "filename", raw.OriginalFilename)
+		msg := util2.ErrMsgFileRejected
+		if result.Verdict == scan.Quarantine {
+			msg = util2.ErrMsgFileQuarantined
+			if err := h.quarantine(ctx, raw, data, scanReason(result)); err != nil {
+				logger.Warn("Failed to quarantine file", "error", err, "raw_photo_id", raw.ID)
+			}
+		}
+		return processed, http.StatusUnprocessableEntity, msg
+	}
+
+	// Decode the photo before storing it, so files that only look like images
+	// are rejected. Rejected files are quarantined for review.
+	img, format, err := decoder().Decode(ctx, data, raw.OriginalFilename)
+	if err != nil {
+		status, msg, quarantine := rejection(err)
+		logger.Info("Invalid image", "error", err, "mime_type", raw.MimeType,
+			"filename", raw.OriginalFilename, "quarantine", quarantine)
+		if quarantine {
+			if err := h.quarantine(ctx, raw, data, err.Error()); err != nil {
+				logger.Warn("Failed to quarantine file", "error", err, "raw_photo_id", raw.ID)
+			}
+		}
+		return processed, status, msg
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	hash := int64(imaging.PerceptualHash(img))
+	raw.Width, raw.Height = &width, &height
+	raw.PerceptualHash = &hash
+
+	processed.raw, processed.duplicate, err = h.saveRawPhoto(ctx, raw, data)
+	if errors.Is(err, errQuarantined) {
+		logger.Info("Upload of a quarantined file", "raw_photo_id", processed.raw.ID)
+		return processed, http.StatusBadRequest, util2.ErrMsgFileQuarantined
+	} else if err != nil {
+		logger.Error("Failed to save raw photo", "error", err, "raw_photo_id", processed.raw.ID)
+		return processed, http.StatusInternalServerError, util2.ErrMsgFailedToSavePhoto
+	}
+
+	// Copies that aren't byte-identical are only reported, since the user may
+	// have edited the photo on purpose. Photos are looked up before this one
+	// is created, so it isn't reported as a copy of itself.
+	if !processed.duplicate {
+		processed.similar = h.similarUploads(ctx, processed.raw)
+	}
+
+	// A duplicate returns the photo processed from the existing raw photo, and
+	// is processed again if that failed the first time
+	err = pgdb.ErrNotFound
+	if processed.duplicate {
+		processed.photo, err = h.DB.GetPhotoByRawPhotoID(ctx, processed.raw.ID)
+	}
+	if errors.Is(err, pgdb.ErrNotFound) {
+		processed.photo, err = h.createPhoto(ctx, processed.raw, data, img, format, caption, tags)
+	}
+	if err != nil {
+		logger.Error("Failed to process photo", "error", err, "raw_photo_id", processed.raw.ID)
+		return processed, http.StatusInternalServerError, util2.ErrMsgFailedToProcess
+	}
+
+	return processed, http.StatusOK, ""
+}
+
+// newUploadResponse creates the response to an upload that went through the
+// upload pipeline, with the URL the photo is fetched from.
+func (h PhotoHandler) newUploadResponse(ctx context.Context, processed processedUpload) (
+	gen.PhotoUploadResponse, error) {
+	uploaded := processed.photo.ToPhoto()
+	err := h.deliverURL(ctx, store.ClassOriginal, processed.photo.StorageBackend, processed.photo.OriginalKey,
+		&uploaded.Url)
+	if err != nil {
+		return gen.PhotoUploadResponse{}, err
+	}
+
+	resp := newPhotoUploadResponse(uploaded, processed.duplicate)
+	if len(processed.similar) > 0 {
+		resp.SimilarPhotos = &processed.similar
+	}
+	return resp, nil
+}
@@ -0,0 +1,97 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+// Database defines the persistence operations used by the user handlers.
+type Database interface {
+	GetLocationPrivacy(ctx context.Context, userID string) (model.LocationPrivacy, error)
+	UpdateLocationPrivacy(ctx context.Context, userID string, privacy model.LocationPrivacy) error
+}
+
+// UserHandler implements the settings endpoints of the current user.
+type UserHandler struct {
+	DB Database
+}
+
+// GetPrivacySettings returns the privacy settings of the current user.
+// GET /user/privacy
+func (h UserHandler) GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Privacy settings without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	privacy, err := h.DB.GetLocationPrivacy(r.Context(), userID)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("User not found", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to get privacy settings", "error", err, "user_id", userID)
+		http.Error(w, util2.ErrMsgFailedToGetSettings, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gen.PrivacySettings{Location: gen.PrivacySettingsLocation(privacy)}
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, resp)
+}
+
+// UpdatePrivacySettings updates the privacy settings of the current user.
+// PUT /user/privacy
+func (h UserHandler) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(util2.ContextLogger).(*slog.Logger)
+
+	userID := util2.GetUserID(r.Context())
+	if _, err := uuid.Parse(userID); err != nil {
+		logger.Info("Privacy settings without a valid user", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserRequired, http.StatusForbidden)
+		return
+	}
+
+	var req gen.PrivacySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Info("Invalid privacy settings request", "error", err)
+		http.Error(w, util2.ErrMsgInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	privacy := model.LocationPrivacy(req.Location)
+	if !privacy.Valid() {
+		logger.Info("Invalid location privacy", "location", req.Location)
+		http.Error(w, util2.ErrMsgInvalidLocationPrivacy, http.StatusBadRequest)
+		return
+	}
+
+	err := h.DB.UpdateLocationPrivacy(r.Context(), userID, privacy)
+	if errors.Is(err, pgdb.ErrNotFound) {
+		logger.Info("User not found", "user_id", userID)
+		http.Error(w, util2.ErrMsgUserNotFound, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to update privacy settings", "error", err, "user_id", userID)
+		http.Error(w, util2.ErrMsgFailedToUpdateSettings, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Privacy settings updated", "user_id", userID, "location", privacy)
+
+	util2.WriteJSONResponse(w, logger, http.StatusOK, req)
+}
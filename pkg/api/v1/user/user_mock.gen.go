@@ -0,0 +1,168 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package user
+
+import (
+	"context"
+	"jelly/pkg/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockDatabase creates a new instance of MockDatabase. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDatabase(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDatabase {
+	mock := &MockDatabase{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDatabase is an autogenerated mock type for the Database type
+type MockDatabase struct {
+	mock.Mock
+}
+
+type MockDatabase_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDatabase) EXPECT() *MockDatabase_Expecter {
+	return &MockDatabase_Expecter{mock: &_m.Mock}
+}
+
+// GetLocationPrivacy provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetLocationPrivacy(ctx context.Context, userID string) (model.LocationPrivacy, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLocationPrivacy")
+	}
+
+	var r0 model.LocationPrivacy
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.LocationPrivacy, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.LocationPrivacy); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(model.LocationPrivacy)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetLocationPrivacy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLocationPrivacy'
+type MockDatabase_GetLocationPrivacy_Call struct {
+	*mock.Call
+}
+
+// GetLocationPrivacy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockDatabase_Expecter) GetLocationPrivacy(ctx interface{}, userID interface{}) *MockDatabase_GetLocationPrivacy_Call {
+	return &MockDatabase_GetLocationPrivacy_Call{Call: _e.mock.On("GetLocationPrivacy", ctx, userID)}
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) Run(run func(ctx context.Context, userID string)) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) Return(locationPrivacy model.LocationPrivacy, err error) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Return(locationPrivacy, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetLocationPrivacy_Call) RunAndReturn(run func(ctx context.Context, userID string) (model.LocationPrivacy, error)) *MockDatabase_GetLocationPrivacy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateLocationPrivacy provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) UpdateLocationPrivacy(ctx context.Context, userID string, privacy model.LocationPrivacy) error {
+	ret := _mock.Called(ctx, userID, privacy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateLocationPrivacy")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, model.LocationPrivacy) error); ok {
+		r0 = returnFunc(ctx, userID, privacy)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_UpdateLocationPrivacy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateLocationPrivacy'
+type MockDatabase_UpdateLocationPrivacy_Call struct {
+	*mock.Call
+}
+
+// UpdateLocationPrivacy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - privacy model.LocationPrivacy
+func (_e *MockDatabase_Expecter) UpdateLocationPrivacy(ctx interface{}, userID interface{}, privacy interface{}) *MockDatabase_UpdateLocationPrivacy_Call {
+	return &MockDatabase_UpdateLocationPrivacy_Call{Call: _e.mock.On("UpdateLocationPrivacy", ctx, userID, privacy)}
+}
+
+func (_c *MockDatabase_UpdateLocationPrivacy_Call) Run(run func(ctx context.Context, userID string, privacy model.LocationPrivacy)) *MockDatabase_UpdateLocationPrivacy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 model.LocationPrivacy
+		if args[2] != nil {
+			arg2 = args[2].(model.LocationPrivacy)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_UpdateLocationPrivacy_Call) Return(err error) *MockDatabase_UpdateLocationPrivacy_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_UpdateLocationPrivacy_Call) RunAndReturn(run func(ctx context.Context, userID string, privacy model.LocationPrivacy) error) *MockDatabase_UpdateLocationPrivacy_Call {
+	_c.Call.Return(run)
+	return _c
+}
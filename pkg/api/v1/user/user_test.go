@@ -0,0 +1,178 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/api/v1/gen"
+	util2 "jelly/pkg/api/v1/util"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+)
+
+const testUserID = "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f"
+
+func newRequest(method, body, userID string) *http.Request {
+	req := httptest.NewRequest(method, "/user/privacy", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), util2.ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, util2.ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+func TestUserHandler_GetPrivacySettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:           "no user",
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "not found",
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetLocationPrivacy(mock.Anything, testUserID).Return("", pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "database error",
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetLocationPrivacy(mock.Anything, testUserID).Return("", errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "success",
+			userID: testUserID,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().GetLocationPrivacy(mock.Anything, testUserID).Return(model.LocationCity, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := UserHandler{DB: db}
+			w := httptest.NewRecorder()
+
+			handler.GetPrivacySettings(w, newRequest(http.MethodGet, "", tt.userID))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.PrivacySettings
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Location != gen.City {
+				t.Errorf("Expected location city, got %s", resp.Location)
+			}
+		})
+	}
+}
+
+func TestUserHandler_UpdatePrivacySettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		body           string
+		setupMock      func(*MockDatabase)
+		expectedStatus int
+	}{
+		{
+			name:           "no user",
+			body:           `{"location":"keep"}`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid body",
+			userID:         testUserID,
+			body:           `{"location":`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid location",
+			userID:         testUserID,
+			body:           `{"location":"street"}`,
+			setupMock:      func(m *MockDatabase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "not found",
+			userID: testUserID,
+			body:   `{"location":"keep"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().UpdateLocationPrivacy(mock.Anything, testUserID, model.LocationKeep).Return(pgdb.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "database error",
+			userID: testUserID,
+			body:   `{"location":"keep"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().UpdateLocationPrivacy(mock.Anything, testUserID, model.LocationKeep).
+					Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "success",
+			userID: testUserID,
+			body:   `{"location":"keep"}`,
+			setupMock: func(m *MockDatabase) {
+				m.EXPECT().UpdateLocationPrivacy(mock.Anything, testUserID, model.LocationKeep).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMockDatabase(t)
+			tt.setupMock(db)
+			handler := UserHandler{DB: db}
+			w := httptest.NewRecorder()
+
+			handler.UpdatePrivacySettings(w, newRequest(http.MethodPut, tt.body, tt.userID))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp gen.PrivacySettings
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Location != gen.Keep {
+				t.Errorf("Expected location keep, got %s", resp.Location)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CookieSession is the cookie carrying the session of the user
+const CookieSession = "user"
+
+// AuthConfig configures the Authenticate middleware.
+type AuthConfig struct {
+	// SessionSecret is the HMAC key session cookies are signed with, shared
+	// with whatever signs users in. Without a secret, no session is valid.
+	SessionSecret []byte
+
+	// APIKeys are the keys of trusted clients, e.g. other services. Requests
+	// with other keys are anonymous.
+	APIKeys []string
+}
+
+// validAPIKey reports whether the key is one of the API keys.
+func (cfg AuthConfig) validAPIKey(key string) bool {
+	valid := false
+	for _, k := range cfg.APIKeys {
+		// Every key is compared so the time taken doesn't tell which matched
+		if hmac.Equal([]byte(k), []byte(key)) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// Authenticate identifies the user making a request by their session cookie,
+// and the client by its API key. Sessions are the ID of the user and an
+// expiration, signed with the session secret, so users can't claim to be
+// someone else. Requests without a valid session are anonymous, and the
+// endpoints requiring a user refuse them.
+//
+// The logger is extended with the user, so the middleware must run after
+// LogRequest.
+func Authenticate(cfg AuthConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				logger := GetLogger(r.Context())
+
+				var userID string
+				if c, err := r.Cookie(CookieSession); err == nil {
+					var ok bool
+					userID, ok = VerifySession(cfg.SessionSecret, c.Value, time.Now())
+					if !ok {
+						logger.Info("Invalid session")
+					}
+				}
+
+				var apiKey string
+				if key := r.Header.Get(HeaderAPIKey); key != "" {
+					if cfg.validAPIKey(key) {
+						apiKey = apiKeyID(key)
+					} else {
+						logger.Info("Invalid API key")
+					}
+				}
+
+				logger = logger.With("user", userID, "api_key", apiKey)
+				ctx := context.WithValue(r.Context(), ContextLogger, logger)
+				ctx = context.WithValue(ctx, ContextUserID, userID)
+				ctx = context.WithValue(ctx, ContextAPIKey, apiKey)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			},
+		)
+	}
+}
+
+// SignSession returns the value of the session cookie of a user, valid until
+// the expiration.
+func SignSession(secret []byte, userID string, expires time.Time) string {
+	payload := userID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signSession(secret, payload)
+}
+
+// VerifySession returns the ID of the user of a session cookie, and whether
+// the session is valid: signed with the secret, for a user ID, and not
+// expired at the time.
+func VerifySession(secret []byte, value string, now time.Time) (string, bool) {
+	if len(secret) == 0 {
+		return "", false
+	}
+
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signSession(secret, payload)), []byte(signature)) {
+		return "", false
+	}
+
+	userID, expiresStr, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return "", false
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", false
+	}
+	return userID, true
+}
+
+// signSession returns the signature of the payload of a session cookie.
+func signSession(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// apiKeyID returns the ID of an API key, a digest so the key itself isn't
+// logged or stored.
+func apiKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSessionSecret = []byte("secret")
+
+// newAuthenticatedServer routes requests through the Authenticate middleware
+// to a handler writing the ID of the user and of the API key.
+func newAuthenticatedServer() http.Handler {
+	return Authenticate(AuthConfig{SessionSecret: testSessionSecret, APIKeys: []string{"service-key"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(GetUserID(r.Context()) + GetAPIKey(r.Context())))
+		}),
+	)
+}
+
+func newSessionRequest(session string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/photo", nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: CookieSession, Value: session})
+	}
+	return req.WithContext(context.WithValue(req.Context(), ContextLogger, slog.Default()))
+}
+
+func TestAuthenticate(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	valid := SignSession(testSessionSecret, testUserID, expires)
+	otherUser := "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+	tests := []struct {
+		name     string
+		session  string
+		expected string
+	}{
+		{name: "valid session", session: valid, expected: testUserID},
+		{name: "no session", session: ""},
+		{name: "bare user ID", session: testUserID},
+		{name: "expired", session: SignSession(testSessionSecret, testUserID, time.Now().Add(-time.Minute))},
+		{name: "other secret", session: SignSession([]byte("other"), testUserID, expires)},
+		{name: "other user", session: otherUser + strings.TrimPrefix(valid, testUserID)},
+		{name: "not a user ID", session: SignSession(testSessionSecret, "admin", expires)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newAuthenticatedServer().ServeHTTP(w, newSessionRequest(tt.session))
+			if got := w.Body.String(); got != tt.expected {
+				t.Errorf("Expected user %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_APIKeys(t *testing.T) {
+	for key, expected := range map[string]string{"service-key": apiKeyID("service-key"), "other-key": ""} {
+		req := newSessionRequest("")
+		req.Header.Set(HeaderAPIKey, key)
+
+		w := httptest.NewRecorder()
+		newAuthenticatedServer().ServeHTTP(w, req)
+		if got := w.Body.String(); got != expected {
+			t.Errorf("Expected API key %q for %q, got %q", expected, key, got)
+		}
+	}
+}
+
+func TestVerifySession_NoSecret(t *testing.T) {
+	session := SignSession(nil, testUserID, time.Now().Add(time.Hour))
+	if _, ok := VerifySession(nil, session, time.Now()); ok {
+		t.Error("Expected no session to be valid without a secret")
+	}
+}
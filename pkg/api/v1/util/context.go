@@ -7,6 +7,8 @@ import (
 
 const (
 	ContextLogger = "logger"
+	ContextUserID = "user_id"
+	ContextAPIKey = "api_key"
 )
 
 // Key is the type for all context.Context keys.
@@ -23,3 +25,17 @@ func GetLogger(ctx context.Context) *slog.Logger {
 	}
 	return log
 }
+
+// GetUserID returns the ID of the user making the request, authenticated by
+// their session, or an empty string if the request is anonymous.
+func GetUserID(ctx context.Context) string {
+	userID, _ := ctx.Value(ContextUserID).(string)
+	return userID
+}
+
+// GetAPIKey returns the ID of the valid API key of the client making the
+// request, or an empty string if there is none.
+func GetAPIKey(ctx context.Context) string {
+	apiKey, _ := ctx.Value(ContextAPIKey).(string)
+	return apiKey
+}
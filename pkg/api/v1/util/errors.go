@@ -8,4 +8,84 @@ const (
 	ErrMsgFailedToReadFile    = "Failed to read file"
 	ErrMsgUnsupportedFileType = "Unsupported file type"
 	ErrMsgInvalidUUID         = "Invalid UUID format for ID"
+	ErrMsgUserRequired        = "User is required"
+	ErrMsgFailedToSavePhoto   = "Failed to save photo"
+	ErrMsgPhotoNotFound       = "Photo not found"
+	ErrMsgFailedToGetPhoto    = "Failed to get photo"
+	ErrMsgFailedToDeletePhoto = "Failed to delete photo"
+	ErrMsgInvalidImage        = "File is not a valid image"
+	ErrMsgFailedToProcess     = "Failed to process photo"
+
+	// Image validation error messages
+	ErrMsgImageTooLarge       = "Image has too many pixels"
+	ErrMsgImageFormatMismatch = "Image format does not match the file extension"
+	ErrMsgImageTruncated      = "Image is truncated"
+	ErrMsgImageDecodeTimeout  = "Image took too long to decode"
+	ErrMsgFileQuarantined     = "File was rejected and is quarantined"
+	ErrMsgServerBusy          = "Too many images are being processed, retry later"
+	ErrMsgFileRejected        = "File was rejected"
+	ErrMsgFailedToScan        = "Failed to scan file"
+
+	// Resumable upload error messages
+	ErrMsgUnsupportedTusVersion  = "Unsupported tus version"
+	ErrMsgInvalidUploadLength    = "Upload-Length must be a positive integer"
+	ErrMsgInvalidUploadMetadata  = "Invalid Upload-Metadata"
+	ErrMsgInvalidUploadOffset    = "Upload-Offset must be a non-negative integer"
+	ErrMsgUploadOffsetMismatch   = "Upload-Offset does not match the current offset"
+	ErrMsgUnsupportedContentType = "Content-Type must be application/offset+octet-stream"
+	ErrMsgChunkTooLarge          = "Chunk exceeds the upload length"
+	ErrMsgFailedToCreateUpload   = "Failed to create upload"
+	ErrMsgFailedToWriteUpload    = "Failed to write upload"
+	ErrMsgFailedToDeleteUpload   = "Failed to delete upload"
+
+	// Direct upload error messages
+	ErrMsgInvalidRequestBody     = "Invalid request body"
+	ErrMsgInvalidChecksum        = "sha256 must be a hex encoded SHA-256 checksum"
+	ErrMsgUploadNotFound         = "Upload not found"
+	ErrMsgUploadExpired          = "Upload has expired"
+	ErrMsgUploadNotReceived      = "Photo has not been uploaded to storage"
+	ErrMsgUploadMismatch         = "Uploaded photo does not match the upload request"
+	ErrMsgFailedToCompleteUpload = "Failed to complete upload"
+	ErrMsgDirectUploadDisabled   = "Photos are encrypted and can't be uploaded directly, upload them to /photo"
+
+	// Idempotency error messages
+	ErrMsgInvalidIdempotencyKey = "Idempotency-Key must be at most 255 characters"
+	ErrMsgIdempotencyKeyInUse   = "A request with this Idempotency-Key is still in progress"
+	ErrMsgIdempotencyKeyReused  = "Idempotency-Key was already used with a different request"
+
+	// Like and comment error messages
+	ErrMsgFailedToLikePhoto     = "Failed to like photo"
+	ErrMsgInvalidComment        = "Comment must be between 1 and 2000 characters"
+	ErrMsgFailedToCreateComment = "Failed to create comment"
+
+	// Image resizing error messages
+	ErrMsgInvalidImageOptions = "Invalid image options"
+	ErrMsgInvalidSignature    = "Invalid image signature"
+	ErrMsgFailedToResize      = "Failed to resize image"
+
+	// Geolocation error messages
+	ErrMsgInvalidLocation         = "lat, lon and radius_km must be a valid location and a radius of up to 100 km"
+	ErrMsgInvalidPagination       = "limit must be between 1 and 100 and offset must not be negative"
+	ErrMsgInvalidPlaceName        = "placeName must be at most 255 characters"
+	ErrMsgNotPhotoOwner           = "Only the owner of the photo can change it"
+	ErrMsgFailedToGetNearbyPhotos = "Failed to get nearby photos"
+	ErrMsgFailedToUpdatePhoto     = "Failed to update photo"
+
+	// Similar photo error messages
+	ErrMsgInvalidSimilarQuery      = "max_distance must be between 0 and 64 and limit between 1 and 100"
+	ErrMsgFailedToGetSimilarPhotos = "Failed to get similar photos"
+
+	// User settings error messages
+	ErrMsgUserNotFound           = "User not found"
+	ErrMsgInvalidLocationPrivacy = "location must be one of strip, city or keep"
+	ErrMsgFailedToGetSettings    = "Failed to get settings"
+	ErrMsgFailedToUpdateSettings = "Failed to update settings"
+
+	// Admin error messages
+	ErrMsgAdminRequired           = "Only admins can use this endpoint"
+	ErrMsgInvalidScanQuery        = "verdict must be one of allow, quarantine or reject, limit between 1 and 100 and offset must not be negative"
+	ErrMsgFailedToListScanResults = "Failed to list scan results"
+
+	// Rate limit error messages
+	ErrMsgTooManyRequests = "Too many requests, retry later"
 )
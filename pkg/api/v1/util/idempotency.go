@@ -0,0 +1,270 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"jelly/pkg/model"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyMaxBodySize = 32 << 20
+	idempotencyKeyLease       = time.Minute
+)
+
+// IdempotencyStore persists the requests made with an Idempotency-Key header.
+type IdempotencyStore interface {
+	CreateIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (model.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key model.IdempotencyKey) error
+	RenewIdempotencyKey(ctx context.Context, userID, key string, expiresAt time.Time) error
+	DeleteIdempotencyKey(ctx context.Context, userID, key string) error
+}
+
+// IdempotencyConfig configures the Idempotency middleware.
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+
+	// TTL is how long a response is replayed to retries of the request
+	TTL time.Duration
+
+	// Lease is how long a key is held by a request in progress. The lease is
+	// renewed while the request is handled, so the key of a request whose
+	// server crashed is released soon after rather than when the TTL passes.
+	Lease time.Duration
+
+	// MaxBodySize limits the request bodies read to fingerprint requests
+	MaxBodySize int64
+
+	// Patterns are the route patterns, e.g. "POST /photo", whose requests
+	// honor the Idempotency-Key header
+	Patterns []string
+}
+
+// Idempotency makes retries of requests with an Idempotency-Key header safe.
+// The first request with a key is handled and its response stored; retries
+// with the same key and request body get the stored response, retries while
+// the first request is in progress get 409, and requests reusing the key with
+// a different body get 422. Server errors aren't stored, so a request failing
+// with one can be retried. Keys are only honored for authenticated users, so
+// anonymous requests with a key get 403.
+//
+// Keys are scoped to the user, so the middleware must run after Authenticate.
+func Idempotency(cfg IdempotencyConfig) func(http.Handler) http.Handler {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = idempotencyKeyMaxBodySize
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = idempotencyKeyLease
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				key := r.Header.Get(HeaderIdempotencyKey)
+				if key == "" || !slices.Contains(cfg.Patterns, r.Pattern) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				logger := GetLogger(r.Context())
+				if len(key) > maxIdempotencyKeyLength {
+					http.Error(w, ErrMsgInvalidIdempotencyKey, http.StatusBadRequest)
+					return
+				}
+
+				// Anonymous requests would share the keys of all anonymous
+				// clients
+				userID := GetUserID(r.Context())
+				if userID == "" {
+					logger.Info("Idempotency key without a user")
+					http.Error(w, ErrMsgUserRequired, http.StatusForbidden)
+					return
+				}
+
+				// The body is needed for the fingerprint and the handler
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodySize))
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, ErrMsgFileTooLarge, http.StatusRequestEntityTooLarge)
+					return
+				} else if err != nil {
+					logger.Info("Failed to read request body", "error", err)
+					http.Error(w, ErrMsgFailedToReadFile, http.StatusBadRequest)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				now := time.Now()
+				record := model.IdempotencyKey{
+					UserID:      userID,
+					Key:         key,
+					Fingerprint: fingerprint(r, body),
+					CreatedAt:   now,
+					ExpiresAt:   now.Add(cfg.Lease),
+				}
+
+				existing, created, err := cfg.Store.CreateIdempotencyKey(r.Context(), record)
+				if err != nil {
+					logger.Error("Failed to create idempotency key", "error", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+
+				if !created {
+					replay(w, logger, existing, record.Fingerprint)
+					return
+				}
+
+				// Release the key if the handler panics, so the request can be
+				// retried
+				ctx := context.WithoutCancel(r.Context())
+				completed := false
+				defer func() {
+					if !completed {
+						if err := cfg.Store.DeleteIdempotencyKey(ctx, record.UserID, key); err != nil {
+							logger.Error("Failed to delete idempotency key", "error", err)
+						}
+					}
+				}()
+
+				// The key is held for as long as the request is handled
+				defer renewLease(ctx, logger, cfg, record)()
+
+				rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+				next.ServeHTTP(rec, r)
+
+				if rec.status >= http.StatusInternalServerError {
+					return
+				}
+
+				headers, err := json.Marshal(rec.header)
+				if err != nil {
+					logger.Error("Failed to encode response headers", "error", err)
+					return
+				}
+				record.StatusCode = &rec.status
+				record.ResponseHeaders = StringPtr(string(headers))
+				record.ResponseBody = rec.body.Bytes()
+				record.ExpiresAt = time.Now().Add(cfg.TTL)
+
+				if err := cfg.Store.CompleteIdempotencyKey(ctx, record); err != nil {
+					logger.Error("Failed to complete idempotency key", "error", err)
+					return
+				}
+				completed = true
+			},
+		)
+	}
+}
+
+// renewLease renews the lease of a key held by a request in progress until
+// the returned function is called.
+func renewLease(ctx context.Context, logger *slog.Logger, cfg IdempotencyConfig,
+	record model.IdempotencyKey) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(cfg.Lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := cfg.Store.RenewIdempotencyKey(ctx, record.UserID, record.Key, time.Now().Add(cfg.Lease))
+				if err != nil {
+					logger.Error("Failed to renew idempotency key", "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// replay answers a retry with the response stored for the key.
+func replay(w http.ResponseWriter, logger *slog.Logger, existing model.IdempotencyKey, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		logger.Info("Idempotency key reused with a different request", "key", existing.Key)
+		http.Error(w, ErrMsgIdempotencyKeyReused, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !existing.IsComplete() {
+		logger.Info("Idempotency key in use", "key", existing.Key)
+		http.Error(w, ErrMsgIdempotencyKeyInUse, http.StatusConflict)
+		return
+	}
+
+	var headers http.Header
+	if existing.ResponseHeaders != nil {
+		_ = json.Unmarshal([]byte(*existing.ResponseHeaders), &headers)
+	}
+	for name, values := range headers {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(*existing.StatusCode)
+	_, _ = w.Write(existing.ResponseBody)
+}
+
+// fingerprint hashes the method, path, query and body of a request. Multipart bodies
+// are separated by a random boundary, which is normalized so a retry encoding
+// the same form gets the same fingerprint.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte("--"+params["boundary"]), []byte("--boundary"))
+	}
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while recording it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.header = rec.ResponseWriter.Header().Clone()
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
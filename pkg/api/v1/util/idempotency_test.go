@@ -0,0 +1,379 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"jelly/pkg/model"
+)
+
+const testUserID = "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f"
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore.
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]model.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{keys: map[string]model.IdempotencyKey{}}
+}
+
+func (s *memoryIdempotencyStore) CreateIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (
+	model.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.keys[key.UserID+"/"+key.Key]; ok && time.Now().Before(existing.ExpiresAt) {
+		return existing, false, nil
+	}
+	s.keys[key.UserID+"/"+key.Key] = key
+	return key, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key model.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.UserID+"/"+key.Key] = key
+	return nil
+}
+
+func (s *memoryIdempotencyStore) RenewIdempotencyKey(ctx context.Context, userID, key string,
+	expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.keys[userID+"/"+key]; ok && !existing.IsComplete() {
+		existing.ExpiresAt = expiresAt
+		s.keys[userID+"/"+key] = existing
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) DeleteIdempotencyKey(ctx context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, userID+"/"+key)
+	return nil
+}
+
+// newIdempotentServer routes POST /photo through the Idempotency middleware to
+// the handler, the same way the generated router applies middlewares.
+func newIdempotentServer(store IdempotencyStore, handler http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+	wrapped := Idempotency(IdempotencyConfig{
+		Store:    store,
+		TTL:      time.Hour,
+		Patterns: []string{"POST /photo"},
+	})(handler)
+	mux.Handle("POST /photo", wrapped)
+	mux.Handle("POST /other", wrapped)
+	return mux
+}
+
+func newIdempotentRequest(target, key, body, userID string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+
+	ctx := context.WithValue(req.Context(), ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+// countingHandler echoes the request body and counts how often it was called.
+func countingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/photo/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	first := httptest.NewRecorder()
+	server.ServeHTTP(first, newIdempotentRequest("/photo", "key-1", `{"n":1}`, testUserID))
+
+	retry := httptest.NewRecorder()
+	server.ServeHTTP(retry, newIdempotentRequest("/photo", "key-1", `{"n":1}`, testUserID))
+
+	if calls != 1 {
+		t.Errorf("Expected the handler to be called once, got %d", calls)
+	}
+	if retry.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, retry.Code)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected body %q, got %q", first.Body.String(), retry.Body.String())
+	}
+	if retry.Header().Get("Location") != "/photo/1" {
+		t.Errorf("Expected the Location header to be replayed, got %q", retry.Header().Get("Location"))
+	}
+	if retry.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("Expected %s header on the replay", HeaderIdempotentReplayed)
+	}
+	if first.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Errorf("Expected no %s header on the first response", HeaderIdempotentReplayed)
+	}
+}
+
+func TestIdempotency_DifferentBody(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	server.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("/photo", "key-1", `{"n":1}`, testUserID))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{"n":2}`, testUserID))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to be called once, got %d", calls)
+	}
+}
+
+func TestIdempotency_Concurrent(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := newIdempotentServer(newMemoryIdempotencyStore(), func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.ServeHTTP(first, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	close(release)
+	<-done
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if first.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, first.Code)
+	}
+}
+
+func TestIdempotency_ServerErrorIsRetried(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	if w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("Expected the retry to be handled, got status %d after %d calls", w.Code, calls)
+	}
+}
+
+func TestIdempotency_Multipart(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	// Each encoding of the same form uses a different random boundary
+	newMultipartRequest := func(data string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "photo.jpg")
+		part.Write([]byte(data))
+		writer.Close()
+
+		req := newIdempotentRequest("/photo", "key-1", "", testUserID)
+		req.Body = io.NopCloser(body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	server.ServeHTTP(httptest.NewRecorder(), newMultipartRequest("photo"))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newMultipartRequest("photo"))
+	if calls != 1 || w.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("Expected the retry to be replayed, got %d calls", calls)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, newMultipartRequest("other photo"))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+}
+
+func TestIdempotency_PassThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		key    string
+		userID string
+	}{
+		{name: "without key", target: "/photo"},
+		{name: "other operation", target: "/other", key: "key-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+			for range 2 {
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, newIdempotentRequest(tt.target, tt.key, `{}`, testUserID))
+				if w.Header().Get(HeaderIdempotentReplayed) != "" {
+					t.Errorf("Expected no replay")
+				}
+			}
+
+			if calls != 2 {
+				t.Errorf("Expected the handler to be called twice, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestIdempotency_KeysAreScopedToUsers(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	server.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	server.ServeHTTP(httptest.NewRecorder(),
+		newIdempotentRequest("/photo", "key-1", `{}`, "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"))
+
+	if calls != 2 {
+		t.Errorf("Expected the handler to be called for each user, got %d", calls)
+	}
+}
+
+func TestIdempotency_KeyTooLong(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", strings.Repeat("k", 256), `{}`, testUserID))
+
+	if w.Code != http.StatusBadRequest || calls != 0 {
+		t.Errorf("Expected status %d without calling the handler, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestIdempotency_QueryIsFingerprinted(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	server.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("/photo?album=1", "key-1", `{}`, testUserID))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo?album=2", "key-1", `{}`, testUserID))
+	if w.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("Expected status %d after 1 call, got %d after %d", http.StatusUnprocessableEntity, w.Code, calls)
+	}
+}
+
+func TestIdempotency_UserRequired(t *testing.T) {
+	calls := 0
+	server := newIdempotentServer(newMemoryIdempotencyStore(), countingHandler(&calls))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, ""))
+
+	if w.Code != http.StatusForbidden || calls != 0 {
+		t.Errorf("Expected status %d without calling the handler, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestIdempotency_Lease(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := Idempotency(IdempotencyConfig{
+		Store:    store,
+		TTL:      time.Hour,
+		Lease:    20 * time.Millisecond,
+		Patterns: []string{"POST /photo"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	mux := http.NewServeMux()
+	mux.Handle("POST /photo", server)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	}()
+	<-started
+
+	// The lease is renewed while the request is handled
+	time.Sleep(50 * time.Millisecond)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d while the request is handled, got %d", http.StatusConflict, w.Code)
+	}
+	close(release)
+	<-done
+
+	// Completed keys are held for the TTL
+	key := store.keys[testUserID+"/key-1"]
+	if !key.IsComplete() || time.Until(key.ExpiresAt) < 59*time.Minute {
+		t.Errorf("Expected the key to be completed until the TTL passes, got %+v", key)
+	}
+}
+
+func TestIdempotency_ExpiredLeaseIsReclaimed(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+
+	// A request whose server crashed left its key in progress
+	store.keys[testUserID+"/key-1"] = model.IdempotencyKey{UserID: testUserID, Key: "key-1",
+		ExpiresAt: time.Now().Add(-time.Second)}
+
+	calls := 0
+	server := newIdempotentServer(store, countingHandler(&calls))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newIdempotentRequest("/photo", "key-1", `{}`, testUserID))
+	if w.Code != http.StatusCreated || calls != 1 {
+		t.Errorf("Expected the retry to be handled, got status %d after %d calls", w.Code, calls)
+	}
+}
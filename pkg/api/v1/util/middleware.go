@@ -17,12 +17,6 @@ func LogRequest(next http.Handler) http.Handler {
 				"agent", r.UserAgent(),
 				"tls", r.TLS,
 				"proto", r.Proto,
-				"user", func() string {
-					if c, err := r.Cookie("user"); err == nil {
-						return c.Value
-					}
-					return ""
-				}(),
 			)
 			slogWithReq.Info("Request")
 
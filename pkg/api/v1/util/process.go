@@ -2,17 +2,25 @@ package util
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"time"
 
 	"jelly/pkg/model"
 )
 
+// CalculateMD5 is kept for comparing against S3 ETags; use CalculateSHA256 to
+// identify photo content.
 func CalculateMD5(bytes []byte) string {
 	hash := md5.Sum(bytes)
 	return hex.EncodeToString(hash[:])
 }
 
+func CalculateSHA256(bytes []byte) string {
+	hash := sha256.Sum256(bytes)
+	return hex.EncodeToString(hash[:])
+}
+
 func GetTimePointer(t time.Time) *time.Time {
 	if t.IsZero() {
 		return nil
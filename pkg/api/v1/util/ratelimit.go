@@ -0,0 +1,121 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"jelly/pkg/ratelimit"
+)
+
+const (
+	HeaderAPIKey             = "X-API-Key"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimitConfig configures the RateLimit middleware.
+type RateLimitConfig struct {
+	Limiter ratelimit.Limiter
+
+	// Default is the limit of operations without a limit in Limits
+	Default ratelimit.Limit
+
+	// Limits are the limits of operations by operationId
+	Limits map[string]ratelimit.Limit
+
+	// Operations maps route patterns, e.g. "POST /photo", to the operationIds
+	// of the API spec
+	Operations map[string]string
+}
+
+// limit returns the operationId and limit of a request.
+func (cfg RateLimitConfig) limit(r *http.Request) (string, ratelimit.Limit) {
+	operation, ok := cfg.Operations[r.Pattern]
+	if !ok {
+		operation = r.Pattern
+	}
+	if limit, ok := cfg.Limits[operation]; ok {
+		return operation, limit
+	}
+	return operation, cfg.Default
+}
+
+// RateLimit limits the rate of requests per client and operation with token
+// buckets. Clients are identified by their authenticated user ID, their valid
+// API key or else their IP address. Responses carry the RateLimit headers
+// describing the bucket, and requests exceeding the limit get 429 with
+// Retry-After. Requests are let through if the limiter fails, so an outage of
+// its backend doesn't take the API down.
+//
+// Clients are identified by user, so the middleware must run after
+// Authenticate.
+func RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				operation, limit := cfg.limit(r)
+				if limit.Unlimited() {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				logger := GetLogger(r.Context())
+				client := clientKey(r)
+				result, err := cfg.Limiter.Allow(r.Context(), operation+":"+client, limit)
+				if err != nil {
+					logger.Error("Failed to check rate limit", "error", err, "operation", operation)
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				w.Header().Set(HeaderRateLimitLimit, strconv.Itoa(limit.Requests))
+				w.Header().Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+				w.Header().Set(HeaderRateLimitReset, seconds(result.ResetAfter))
+				w.Header().Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%s", limit.Requests, seconds(limit.Per)))
+
+				if !result.Allowed {
+					logger.Info("Rate limit exceeded", "operation", operation, "client", client,
+						"limit", limit.String())
+					w.Header().Set(HeaderRetryAfter, seconds(result.RetryAfter))
+					http.Error(w, ErrMsgTooManyRequests, http.StatusTooManyRequests)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			},
+		)
+	}
+}
+
+// clientKey identifies the client making a request by its authenticated user
+// ID, its valid API key or else its IP address. Anonymous clients are
+// identified by IP address, so they can't get fresh buckets by changing their
+// cookies or headers.
+func clientKey(r *http.Request) string {
+	if userID := GetUserID(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+
+	if apiKey := GetAPIKey(r.Context()); apiKey != "" {
+		return "key:" + apiKey
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds formats a duration as whole seconds, rounded up so clients don't
+// retry too early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
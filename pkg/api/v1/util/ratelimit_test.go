@@ -0,0 +1,189 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"jelly/pkg/ratelimit"
+)
+
+// failingLimiter is a Limiter whose backend is down.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// newRateLimitedServer routes requests through the RateLimit middleware to a
+// handler answering 200, the same way the generated router applies
+// middlewares.
+func newRateLimitedServer(limiter ratelimit.Limiter) http.Handler {
+	mux := http.NewServeMux()
+	wrapped := RateLimit(RateLimitConfig{
+		Limiter: limiter,
+		Default: ratelimit.Limit{Requests: 2, Per: time.Second},
+		Limits: map[string]ratelimit.Limit{
+			"uploadPhoto": {Requests: 1, Per: time.Hour},
+			"healthCheck": {},
+		},
+		Operations: map[string]string{
+			"POST /photo": "uploadPhoto",
+			"GET /health": "healthCheck",
+			"GET /photo":  "getPhoto",
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	mux.Handle("POST /photo", wrapped)
+	mux.Handle("GET /photo", wrapped)
+	mux.Handle("GET /health", wrapped)
+	return mux
+}
+
+func newRateLimitedRequest(method, target, userID string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	ctx := context.WithValue(req.Context(), ContextLogger, slog.Default())
+	ctx = context.WithValue(ctx, ContextUserID, userID)
+	return req.WithContext(ctx)
+}
+
+func TestRateLimit_PerOperation(t *testing.T) {
+	server := newRateLimitedServer(ratelimit.NewMemory())
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newRateLimitedRequest(http.MethodPost, "/photo", testUserID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get(HeaderRateLimitLimit); got != "1" {
+		t.Errorf("Expected %s 1, got %q", HeaderRateLimitLimit, got)
+	}
+	if got := w.Header().Get(HeaderRateLimitRemaining); got != "0" {
+		t.Errorf("Expected %s 0, got %q", HeaderRateLimitRemaining, got)
+	}
+	if got := w.Header().Get(HeaderRateLimitReset); got != "3600" {
+		t.Errorf("Expected %s 3600, got %q", HeaderRateLimitReset, got)
+	}
+	if got := w.Header().Get(HeaderRateLimitPolicy); got != "1;w=3600" {
+		t.Errorf("Expected %s 1;w=3600, got %q", HeaderRateLimitPolicy, got)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, newRateLimitedRequest(http.MethodPost, "/photo", testUserID))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get(HeaderRetryAfter); got != "3600" {
+		t.Errorf("Expected %s 3600, got %q", HeaderRetryAfter, got)
+	}
+
+	// Other operations have their own buckets
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, newRateLimitedRequest(http.MethodGet, "/photo", testUserID))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get(HeaderRateLimitPolicy); got != "2;w=1" {
+		t.Errorf("Expected the default limit, got %q", got)
+	}
+}
+
+func TestRateLimit_Clients(t *testing.T) {
+	withUser := func(userID string) func(*http.Request) {
+		return func(r *http.Request) {
+			*r = *r.WithContext(context.WithValue(r.Context(), ContextUserID, userID))
+		}
+	}
+	withAPIKey := func(apiKey string) func(*http.Request) {
+		return func(r *http.Request) {
+			*r = *r.WithContext(context.WithValue(r.Context(), ContextAPIKey, apiKey))
+		}
+	}
+	withIP := func(addr string) func(*http.Request) {
+		return func(r *http.Request) { r.RemoteAddr = addr }
+	}
+
+	tests := []struct {
+		name   string
+		client func(*http.Request)
+		other  func(*http.Request)
+	}{
+		{name: "users", client: withUser(testUserID), other: withUser("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d")},
+		{name: "api keys", client: withAPIKey("key"), other: withAPIKey("other-key")},
+		{name: "ip addresses", client: withIP("192.0.2.1:1234"), other: withIP("192.0.2.2:1234")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRateLimitedServer(ratelimit.NewMemory())
+			newRequest := func(identify func(*http.Request)) *http.Request {
+				req := newRateLimitedRequest(http.MethodPost, "/photo", "")
+				identify(req)
+				return req
+			}
+
+			server.ServeHTTP(httptest.NewRecorder(), newRequest(tt.client))
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, newRequest(tt.client))
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected status %d for the same client, got %d", http.StatusTooManyRequests, w.Code)
+			}
+
+			w = httptest.NewRecorder()
+			server.ServeHTTP(w, newRequest(tt.other))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status %d for another client, got %d", http.StatusOK, w.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimit_AnonymousClients(t *testing.T) {
+	server := newRateLimitedServer(ratelimit.NewMemory())
+
+	// Cookies and headers that aren't authenticated don't give anonymous
+	// clients fresh buckets
+	for i, header := range []string{"key", "other-key"} {
+		req := newRateLimitedRequest(http.MethodPost, "/photo", "")
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set(HeaderAPIKey, header)
+		req.AddCookie(&http.Cookie{Name: CookieSession, Value: header})
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if expected := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != expected {
+			t.Errorf("Expected status %d for request %d, got %d", expected, i, w.Code)
+		}
+	}
+}
+
+func TestRateLimit_Unlimited(t *testing.T) {
+	server := newRateLimitedServer(ratelimit.NewMemory())
+
+	for range 5 {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, newRateLimitedRequest(http.MethodGet, "/health", ""))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get(HeaderRateLimitLimit) != "" {
+			t.Errorf("Expected no %s header", HeaderRateLimitLimit)
+		}
+	}
+}
+
+func TestRateLimit_LimiterFailure(t *testing.T) {
+	server := newRateLimitedServer(failingLimiter{})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, newRateLimitedRequest(http.MethodPost, "/photo", testUserID))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the request to be let through, got status %d", w.Code)
+	}
+}
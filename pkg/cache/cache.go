@@ -0,0 +1,42 @@
+// Package cache provides caches of encoded values and a Loader reading values
+// through them.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get when a key isn't cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores encoded values for a limited time.
+type Cache interface {
+	// Get returns the value of the key, or ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores the value of the key for the given time
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the keys, keys that aren't cached are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Noop is a Cache that stores nothing, for when caching is disabled.
+type Noop struct{}
+
+// Get returns ErrMiss
+func (Noop) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, ErrMiss
+}
+
+// Set does nothing
+func (Noop) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+// Delete does nothing
+func (Noop) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
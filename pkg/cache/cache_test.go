@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotFound = errors.New("not found")
+
+// caches returns each Cache implementation.
+func caches(t *testing.T) map[string]Cache {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return map[string]Cache{
+		"lru":   NewLRU(10),
+		"redis": NewRedis(client),
+	}
+}
+
+func TestCache(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := c.Get(ctx, "a")
+			assert.ErrorIs(t, err, ErrMiss)
+
+			require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+			require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+
+			value, err := c.Get(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("1"), value)
+
+			require.NoError(t, c.Delete(ctx, "a", "missing"))
+			_, err = c.Get(ctx, "a")
+			assert.ErrorIs(t, err, ErrMiss)
+
+			value, err = c.Get(ctx, "b")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("2"), value)
+		})
+	}
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+	_, _ = c.Get(ctx, "a") // b is now the least recently used
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	_, err := c.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrMiss)
+	_, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = c.Get(ctx, "c")
+	assert.NoError(t, err)
+}
+
+func TestLRU_Expires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	now = now.Add(time.Minute)
+
+	_, err := c.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Empty(t, c.entries)
+}
+
+func TestRedis_Expires(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	c := NewRedis(client)
+
+	require.NoError(t, c.Set(context.Background(), "a", []byte("1"), time.Minute))
+	mr.FastForward(time.Minute)
+
+	_, err := c.Get(context.Background(), "a")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+type photo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestLoader(c Cache) *Loader {
+	return &Loader{Cache: c, TTL: time.Minute, NegativeTTL: time.Second, NotFound: errNotFound}
+}
+
+func TestLoad_ReadsThrough(t *testing.T) {
+	l := newTestLoader(NewLRU(10))
+	loads := 0
+	load := func(ctx context.Context) (photo, error) {
+		loads++
+		return photo{ID: "1", Name: "sunset"}, nil
+	}
+
+	for range 3 {
+		value, err := Load(context.Background(), l, "photo:1", load)
+		require.NoError(t, err)
+		assert.Equal(t, photo{ID: "1", Name: "sunset"}, value)
+	}
+	assert.Equal(t, 1, loads)
+
+	l.Invalidate(context.Background(), "photo:1")
+	_, err := Load(context.Background(), l, "photo:1", load)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loads)
+}
+
+func TestLoad_NegativeCaching(t *testing.T) {
+	l := newTestLoader(NewLRU(10))
+	loads := 0
+	load := func(ctx context.Context) (photo, error) {
+		loads++
+		return photo{}, errNotFound
+	}
+
+	for range 3 {
+		_, err := Load(context.Background(), l, "photo:1", load)
+		assert.ErrorIs(t, err, errNotFound)
+	}
+	assert.Equal(t, 1, loads)
+}
+
+func TestLoad_ErrorsAreNotCached(t *testing.T) {
+	l := newTestLoader(NewLRU(10))
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return 0, errors.New("connection refused")
+	}
+
+	for range 2 {
+		_, err := Load(context.Background(), l, "count", load)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 2, loads)
+}
+
+func TestLoad_Singleflight(t *testing.T) {
+	l := newTestLoader(NewLRU(10))
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		loads.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := Load(context.Background(), l, "count", load)
+			assert.NoError(t, err)
+			assert.Equal(t, 42, value)
+		}()
+	}
+
+	// Let the callers pile up on the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestLoad_CacheFailure(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	mr.Close()
+
+	l := newTestLoader(NewRedis(client))
+	value, err := Load(context.Background(), l, "count", func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+}
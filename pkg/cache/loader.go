@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// notFoundValue is cached for keys whose value wasn't found. JSON never
+// encodes a value as a single zero byte.
+var notFoundValue = []byte{0}
+
+// Loader reads JSON encoded values through a Cache. Concurrent misses of a key
+// share a single load, so a popular key expiring doesn't stampede the
+// backend, and values that aren't found are cached for a shorter time.
+//
+// Failures of the cache are logged and the values loaded from the backend,
+// so an outage of the cache only costs performance.
+type Loader struct {
+	Cache Cache
+
+	// TTL is how long loaded values are cached
+	TTL time.Duration
+
+	// NegativeTTL is how long NotFound errors are cached, zero disables
+	// negative caching
+	NegativeTTL time.Duration
+
+	// NotFound is the error of values that don't exist
+	NotFound error
+
+	group singleflight.Group
+}
+
+// Load returns the value of the key from the cache, or loads and caches it.
+func Load[T any](ctx context.Context, l *Loader, key string, load func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	cached, err := l.Cache.Get(ctx, key)
+	switch {
+	case err == nil && bytes.Equal(cached, notFoundValue):
+		return zero, l.NotFound
+	case err == nil:
+		var value T
+		if err := json.Unmarshal(cached, &value); err == nil {
+			return value, nil
+		}
+		slog.WarnContext(ctx, "Failed to decode cached value", "error", err, "key", key)
+	case !errors.Is(err, ErrMiss):
+		slog.WarnContext(ctx, "Failed to get cached value", "error", err, "key", key)
+	}
+
+	// The load is shared by the callers, so it must not be canceled with the
+	// context of the first one
+	value, err, _ := l.group.Do(key, func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+
+		value, err := load(ctx)
+		if err != nil {
+			if l.NotFound != nil && errors.Is(err, l.NotFound) && l.NegativeTTL > 0 {
+				l.set(ctx, key, notFoundValue, l.NegativeTTL)
+			}
+			return value, err
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to encode cached value", "error", err, "key", key)
+			return value, nil
+		}
+		l.set(ctx, key, encoded, l.TTL)
+		return value, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	return value.(T), nil
+}
+
+func (l *Loader) set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if err := l.Cache.Set(ctx, key, value, ttl); err != nil {
+		slog.WarnContext(ctx, "Failed to cache value", "error", err, "key", key)
+	}
+}
+
+// Invalidate removes cached values after they were changed. A load in
+// progress may still cache the old value, so values are only stale for up to
+// the TTL.
+func (l *Loader) Invalidate(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		l.group.Forget(key)
+	}
+
+	if err := l.Cache.Delete(ctx, keys...); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate cached values", "error", err, "keys", keys)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the cached values in Redis.
+const redisKeyPrefix = "cache:"
+
+// Redis is a Cache storing values in Redis, so instances behind a load
+// balancer share them and see each other's invalidations.
+type Redis struct {
+	client redis.Cmdable
+}
+
+// NewRedis creates a Cache storing values in Redis.
+func NewRedis(client redis.Cmdable) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value of the key, or ErrMiss
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cached value: %w", err)
+	}
+	return value, nil
+}
+
+// Set stores the value of the key for the given time
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set cached value: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the keys, keys that aren't cached are ignored
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisKeyPrefix + key
+	}
+	if err := c.client.Del(ctx, prefixed...).Err(); err != nil {
+		return fmt.Errorf("failed to delete cached values: %w", err)
+	}
+	return nil
+}
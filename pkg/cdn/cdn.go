@@ -0,0 +1,25 @@
+// Package cdn signs the URLs objects are fetched from through a CDN, and
+// purges objects from its cache when they're deleted or replaced.
+package cdn
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// CDN defines the operations of a CDN in front of storage
+type CDN interface {
+	// SignURL returns the URL of an object on the CDN, valid until the
+	// expiration passes
+	SignURL(key string, expiration time.Duration) (string, error)
+
+	// Purge removes objects from the cache of the CDN, so they're fetched
+	// from storage again
+	Purge(ctx context.Context, keys []string) error
+}
+
+// escapePath returns the URL path of a key.
+func escapePath(key string) string {
+	return (&url.URL{Path: "/" + key}).EscapedPath()
+}
@@ -0,0 +1,187 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// CloudFront API, which is global and signed in us-east-1
+const (
+	cloudFrontEndpoint = "https://cloudfront.amazonaws.com"
+	cloudFrontRegion   = "us-east-1"
+	cloudFrontService  = "cloudfront"
+	cloudFrontXMLNS    = "http://cloudfront.amazonaws.com/doc/2020-05-31/"
+)
+
+// maxInvalidationPaths is how many paths are invalidated by a request, well
+// under the paths CloudFront allows in progress at once
+const maxInvalidationPaths = 1000
+
+// CloudFront signs URLs of a CloudFront distribution with canned policies, and
+// purges objects by creating invalidations.
+type CloudFront struct {
+	baseURL        string
+	distributionID string
+	keyPairID      string // ID of the public key of the distribution's key group
+	privateKey     *rsa.PrivateKey
+	credentials    aws.CredentialsProvider
+	endpoint       string
+	client         *http.Client
+}
+
+// NewCloudFront creates a CloudFront signer of URLs under the base URL of the
+// distribution. Invalidations are created with the AWS credentials.
+func NewCloudFront(baseURL, distributionID, keyPairID string, privateKey *rsa.PrivateKey,
+	credentials aws.CredentialsProvider) *CloudFront {
+	return &CloudFront{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		distributionID: distributionID,
+		keyPairID:      keyPairID,
+		privateKey:     privateKey,
+		credentials:    credentials,
+		endpoint:       cloudFrontEndpoint,
+		client:         &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ParsePrivateKey parses a PEM encoded RSA private key, in PKCS #1 or PKCS #8
+// form, as CloudFront generates them.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// SignURL returns the URL of an object signed with a canned policy, which
+// only allows fetching that URL until it expires
+func (c *CloudFront) SignURL(key string, expiration time.Duration) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("key cannot be empty")
+	}
+	if expiration <= 0 {
+		return "", fmt.Errorf("expiration must be positive")
+	}
+
+	resource := c.baseURL + escapePath(key)
+	expires := time.Now().Add(expiration).Unix()
+	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`,
+		resource, expires)
+
+	hash := sha1.Sum([]byte(policy))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA1, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign URL: %w", err)
+	}
+
+	// CloudFront uses its own URL safe base64 alphabet
+	encoded := strings.NewReplacer("+", "-", "=", "_", "/", "~").
+		Replace(base64.StdEncoding.EncodeToString(signature))
+
+	return fmt.Sprintf("%s?Expires=%d&Signature=%s&Key-Pair-Id=%s", resource, expires, encoded,
+		url.QueryEscape(c.keyPairID)), nil
+}
+
+// invalidationBatch is the body of a CreateInvalidation request
+type invalidationBatch struct {
+	XMLName         xml.Name `xml:"InvalidationBatch"`
+	XMLNS           string   `xml:"xmlns,attr"`
+	CallerReference string   `xml:"CallerReference"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Paths           []string `xml:"Paths>Items>Path"`
+}
+
+// Purge creates invalidations of the paths of the objects, a batch at a time.
+// Invalidations complete asynchronously.
+func (c *CloudFront) Purge(ctx context.Context, keys []string) error {
+	for batch := range slices.Chunk(keys, maxInvalidationPaths) {
+		paths := make([]string, len(batch))
+		for i, key := range batch {
+			paths[i] = escapePath(key)
+		}
+		if err := c.invalidate(ctx, paths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CloudFront) invalidate(ctx context.Context, paths []string) error {
+	// CloudFront doesn't create invalidations reusing the reference of another
+	reference := make([]byte, 16)
+	_, _ = rand.Read(reference)
+
+	body, err := xml.Marshal(invalidationBatch{
+		XMLNS:           cloudFrontXMLNS,
+		CallerReference: hex.EncodeToString(reference),
+		Quantity:        len(paths),
+		Paths:           paths,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode invalidation: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/2020-05-31/distribution/%s/invalidation", c.endpoint,
+		url.PathEscape(c.distributionID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create invalidation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256(body)
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]),
+		cloudFrontService, cloudFrontRegion, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to sign invalidation request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to create invalidation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to create invalidation: %s: %s", resp.Status, message)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package cdn
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"jelly/pkg/store"
+)
+
+// Fake is a CDN serving objects from storage and caching them in memory until
+// they're purged, for tests and local development. URLs are signed with the
+// HMAC token scheme. Responses tell whether they were cached with an X-Cache
+// header, HIT or MISS, like most CDNs.
+type Fake struct {
+	signer  *HMAC
+	storage store.Storage
+
+	mu     sync.Mutex
+	cache  map[string]fakeObject
+	purged []string
+}
+
+// fakeObject is a cached object
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+// NewFake creates a Fake CDN of the storage served under the base URL. URLs
+// are signed with a random key.
+func NewFake(baseURL string, storage store.Storage) *Fake {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+
+	return &Fake{
+		signer:  NewHMAC(baseURL, secret, ""),
+		storage: storage,
+		cache:   map[string]fakeObject{},
+	}
+}
+
+// SignURL returns the URL of an object with its expiration and token
+func (f *Fake) SignURL(key string, expiration time.Duration) (string, error) {
+	return f.signer.SignURL(key, expiration)
+}
+
+// Purge removes objects from the cache and records their keys
+func (f *Fake) Purge(ctx context.Context, keys []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		delete(f.cache, key)
+	}
+	f.purged = append(f.purged, keys...)
+	return nil
+}
+
+// Purged returns the keys purged so far, in order.
+func (f *Fake) Purged() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.purged...)
+}
+
+// ServeHTTP serves objects with a valid token from the cache, or from storage
+// if they aren't cached yet. It is mounted at the base URL of the CDN.
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if err := f.signer.Verify(escapePath(key), r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	object, ok := f.cache[key]
+	f.mu.Unlock()
+
+	w.Header().Set("X-Cache", "HIT")
+	if !ok {
+		data, contentType, err := f.storage.Download(r.Context(), key)
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		object = fakeObject{data: data, contentType: contentType}
+		f.mu.Lock()
+		f.cache[key] = object
+		f.mu.Unlock()
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	w.Header().Set("Content-Type", object.contentType)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(object.data)
+	}
+}
@@ -0,0 +1,63 @@
+package cdn
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/store"
+)
+
+// get fetches a URL and returns its status, X-Cache header and body.
+func get(t *testing.T, url string) (int, string, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get("X-Cache"), string(body)
+}
+
+func TestFake(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	storage := store.NewLocalStorage(t.TempDir(), server.URL+"/files")
+	fake := NewFake(server.URL+"/cdn", storage)
+	mux.Handle("/cdn/", http.StripPrefix("/cdn/", fake))
+	ctx := context.Background()
+
+	_, err := storage.Upload(ctx, "photos/p/thumb.jpg", []byte("v1"), "image/jpeg")
+	require.NoError(t, err)
+	url, err := fake.SignURL("photos/p/thumb.jpg", time.Minute)
+	require.NoError(t, err)
+
+	// Objects are cached once fetched, even when they're replaced
+	status, cache, body := get(t, url)
+	assert.Equal(t, []any{http.StatusOK, "MISS", "v1"}, []any{status, cache, body})
+	_, err = storage.Upload(ctx, "photos/p/thumb.jpg", []byte("v2"), "image/jpeg")
+	require.NoError(t, err)
+	status, cache, body = get(t, url)
+	assert.Equal(t, []any{http.StatusOK, "HIT", "v1"}, []any{status, cache, body})
+
+	// Until they're purged
+	require.NoError(t, fake.Purge(ctx, []string{"photos/p/thumb.jpg"}))
+	status, cache, body = get(t, url)
+	assert.Equal(t, []any{http.StatusOK, "MISS", "v2"}, []any{status, cache, body})
+	assert.Equal(t, []string{"photos/p/thumb.jpg"}, fake.Purged())
+
+	// Unsigned and missing objects aren't served
+	status, _, _ = get(t, server.URL+"/cdn/photos/p/thumb.jpg")
+	assert.Equal(t, http.StatusForbidden, status)
+	missing, err := fake.SignURL("photos/p/missing.jpg", time.Minute)
+	require.NoError(t, err)
+	status, _, _ = get(t, missing)
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,80 @@
+package cdn
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMAC_SignURL(t *testing.T) {
+	signer := NewHMAC("https://cdn.example.com/", []byte("secret"), "")
+
+	signed, err := signer.SignURL("photos/p/my photo.jpg", time.Hour)
+	require.NoError(t, err)
+	parsed, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/photos/p/my%20photo.jpg", strings.Split(signed, "?")[0])
+
+	// The token is the HMAC of the path and expiration
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("/photos/p/my%20photo.jpg\n" + parsed.Query().Get("expires")))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), parsed.Query().Get("token"))
+	assert.NoError(t, signer.Verify(parsed.EscapedPath(), parsed.Query()))
+
+	// Other paths, keys and expirations aren't valid
+	assert.ErrorIs(t, signer.Verify("/photos/p/other.jpg", parsed.Query()), ErrInvalidToken)
+	assert.ErrorIs(t, NewHMAC("https://cdn.example.com", []byte("other"), "").
+		Verify(parsed.EscapedPath(), parsed.Query()), ErrInvalidToken)
+	query := parsed.Query()
+	query.Set("expires", "4102444800")
+	assert.ErrorIs(t, signer.Verify(parsed.EscapedPath(), query), ErrInvalidToken)
+
+	// Nor are URLs past their expiration
+	expired, err := signer.SignURL("photos/p/my photo.jpg", time.Nanosecond)
+	require.NoError(t, err)
+	parsed, err = url.Parse(expired)
+	require.NoError(t, err)
+	assert.ErrorIs(t, signer.Verify(parsed.EscapedPath(), parsed.Query()), ErrInvalidToken)
+}
+
+func TestHMAC_Purge(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var req purgeRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		keys = append(keys, req.Keys...)
+	}))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	require.NoError(t, NewHMAC("https://cdn.example.com", []byte("secret"), server.URL).
+		Purge(ctx, []string{"photos/p/thumb.jpg", "photos/p/large.jpg"}))
+	assert.Equal(t, []string{"photos/p/thumb.jpg", "photos/p/large.jpg"}, keys)
+
+	assert.Error(t, NewHMAC("https://cdn.example.com", []byte("other"), server.URL).
+		Purge(ctx, []string{"photos/p/thumb.jpg"}))
+
+	// Without a purge URL there's nothing to purge
+	assert.NoError(t, NewHMAC("https://cdn.example.com", []byte("secret"), "").
+		Purge(ctx, []string{"photos/p/thumb.jpg"}))
+}
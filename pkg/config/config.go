@@ -2,18 +2,77 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"jelly/pkg/imaging"
+	"jelly/pkg/ratelimit"
 )
 
 // Config represents the application configuration
 type Config struct {
 	Photo struct {
-		MaxFileSizeMB int `yaml:"max_file_size_mb" env:"PHOTO_MAX_FILE_SIZE_MB"`
+		MaxFileSizeMB       int    `yaml:"max_file_size_mb" env:"PHOTO_MAX_FILE_SIZE_MB"`
+		Variants            string `yaml:"variants" env:"PHOTO_VARIANTS"`
+		Formats             string `yaml:"formats" env:"PHOTO_FORMATS"`
+		AllowedTypes        string `yaml:"allowed_types" env:"PHOTO_ALLOWED_TYPES"`
+		AnimationWidth      int    `yaml:"animation_width" env:"PHOTO_ANIMATION_WIDTH"`
+		SimilarityThreshold int    `yaml:"similarity_threshold" env:"PHOTO_SIMILARITY_THRESHOLD"`
+		MaxPixels           int    `yaml:"max_pixels" env:"PHOTO_MAX_PIXELS"`
+		DecodeTimeout       string `yaml:"decode_timeout" env:"PHOTO_DECODE_TIMEOUT"`
+		DecodeMemoryMB      int    `yaml:"decode_memory_mb" env:"PHOTO_DECODE_MEMORY_MB"`
 	} `yaml:"photo"`
+	Image struct {
+		SigningKey string `yaml:"signing_key" env:"IMAGE_SIGNING_KEY"`
+	} `yaml:"image"`
+	Upload struct {
+		ScratchPath   string `yaml:"scratch_path" env:"UPLOAD_SCRATCH_PATH"`
+		Expiration    string `yaml:"expiration" env:"UPLOAD_EXPIRATION"`
+		URLExpiration string `yaml:"url_expiration" env:"UPLOAD_URL_EXPIRATION"`
+	} `yaml:"upload"`
+	Idempotency struct {
+		TTL   string `yaml:"ttl" env:"IDEMPOTENCY_TTL"`
+		Lease string `yaml:"lease" env:"IDEMPOTENCY_LEASE"`
+	} `yaml:"idempotency"`
+	RateLimit struct {
+		Backend    string `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+		Default    string `yaml:"default" env:"RATE_LIMIT_DEFAULT"`
+		Operations string `yaml:"operations" env:"RATE_LIMIT_OPERATIONS"`
+	} `yaml:"rate_limit"`
+	Cache struct {
+		Backend     string `yaml:"backend" env:"CACHE_BACKEND"`
+		TTL         string `yaml:"ttl" env:"CACHE_TTL"`
+		NegativeTTL string `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL"`
+		Size        int    `yaml:"size" env:"CACHE_SIZE"`
+	} `yaml:"cache"`
+	Scan struct {
+		Backend       string `yaml:"backend" env:"SCAN_BACKEND"`
+		ClamAVAddress string `yaml:"clamav_address" env:"SCAN_CLAMAV_ADDRESS"`
+		Timeout       string `yaml:"timeout" env:"SCAN_TIMEOUT"`
+	} `yaml:"scan"`
+	Auth struct {
+		SessionSecret string `yaml:"session_secret" env:"AUTH_SESSION_SECRET"`
+		SessionTTL    string `yaml:"session_ttl" env:"AUTH_SESSION_TTL"`
+		APIKeys       string `yaml:"api_keys" env:"AUTH_API_KEYS"`
+	} `yaml:"auth"`
+	Admin struct {
+		UserIDs string `yaml:"user_ids" env:"ADMIN_USER_IDS"`
+		APIKey  string `yaml:"api_key" env:"ADMIN_API_KEY"`
+	} `yaml:"admin"`
+	GC struct {
+		Interval  string `yaml:"interval" env:"GC_INTERVAL"`
+		OrphanAge string `yaml:"orphan_age" env:"GC_ORPHAN_AGE"`
+	} `yaml:"gc"`
+	Redis struct {
+		URL string `yaml:"url" env:"REDIS_URL"`
+	} `yaml:"redis"`
 	Database struct {
 		Host     string `yaml:"host" env:"DB_HOST"`
 		Port     int    `yaml:"port" env:"DB_PORT"`
@@ -27,11 +86,45 @@ type Config struct {
 		WriteTimeout string `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
 	} `yaml:"server"`
 	Storage struct {
-		Type      string `yaml:"type" env:"STORAGE_TYPE"`
-		LocalPath string `yaml:"local_path" env:"STORAGE_LOCAL_PATH"`
-		S3Bucket  string `yaml:"s3_bucket" env:"STORAGE_S3_BUCKET"`
-		S3Region  string `yaml:"s3_region" env:"STORAGE_S3_REGION"`
+		Type       string `yaml:"type" env:"STORAGE_TYPE"`
+		LocalPath  string `yaml:"local_path" env:"STORAGE_LOCAL_PATH"`
+		BaseURL    string `yaml:"base_url" env:"STORAGE_BASE_URL"`
+		S3Bucket   string `yaml:"s3_bucket" env:"STORAGE_S3_BUCKET"`
+		S3Region   string `yaml:"s3_region" env:"STORAGE_S3_REGION"`
+		S3Endpoint string `yaml:"s3_endpoint" env:"STORAGE_S3_ENDPOINT"`
+		Access     string `yaml:"access" env:"STORAGE_ACCESS"`
+		BackendID  string `yaml:"backend_id" env:"STORAGE_BACKEND_ID"`
+
+		RawURLExpiration       string `yaml:"raw_url_expiration" env:"STORAGE_RAW_URL_EXPIRATION"`
+		OriginalURLExpiration  string `yaml:"original_url_expiration" env:"STORAGE_ORIGINAL_URL_EXPIRATION"`
+		ThumbnailURLExpiration string `yaml:"thumbnail_url_expiration" env:"STORAGE_THUMBNAIL_URL_EXPIRATION"`
+
+		Timeout          string `yaml:"timeout" env:"STORAGE_TIMEOUT"`
+		Retries          int    `yaml:"retries" env:"STORAGE_RETRIES"`
+		RetryDelay       string `yaml:"retry_delay" env:"STORAGE_RETRY_DELAY"`
+		BreakerThreshold int    `yaml:"breaker_threshold" env:"STORAGE_BREAKER_THRESHOLD"`
+		BreakerCooldown  string `yaml:"breaker_cooldown" env:"STORAGE_BREAKER_COOLDOWN"`
+
+		Encryption             string `yaml:"encryption" env:"STORAGE_ENCRYPTION"`
+		EncryptionKey          string `yaml:"encryption_key" env:"STORAGE_ENCRYPTION_KEY"`
+		EncryptionPreviousKeys string `yaml:"encryption_previous_keys" env:"STORAGE_ENCRYPTION_PREVIOUS_KEYS"`
+		EncryptionKeyFile      string `yaml:"encryption_key_file" env:"STORAGE_ENCRYPTION_KEY_FILE"`
+		EncryptionPrefixes     string `yaml:"encryption_prefixes" env:"STORAGE_ENCRYPTION_PREFIXES"`
+		EncryptionBaseURL      string `yaml:"encryption_base_url" env:"STORAGE_ENCRYPTION_BASE_URL"`
+		EncryptionURLSecret    string `yaml:"encryption_url_secret" env:"STORAGE_ENCRYPTION_URL_SECRET"`
+
+		Mirror               string `yaml:"mirror" env:"STORAGE_MIRROR"`
+		MirrorRepairInterval string `yaml:"mirror_repair_interval" env:"STORAGE_MIRROR_REPAIR_INTERVAL"`
 	} `yaml:"storage"`
+	CDN struct {
+		Type           string `yaml:"type" env:"CDN_TYPE"`
+		BaseURL        string `yaml:"base_url" env:"CDN_BASE_URL"`
+		DistributionID string `yaml:"distribution_id" env:"CDN_DISTRIBUTION_ID"`
+		KeyPairID      string `yaml:"key_pair_id" env:"CDN_KEY_PAIR_ID"`
+		PrivateKeyPath string `yaml:"private_key_path" env:"CDN_PRIVATE_KEY_PATH"`
+		Secret         string `yaml:"secret" env:"CDN_SECRET"`
+		PurgeURL       string `yaml:"purge_url" env:"CDN_PURGE_URL"`
+	} `yaml:"cdn"`
 }
 
 // Load reads configuration from config.yaml and sets environment variables
@@ -73,7 +166,8 @@ func Load() (*Config, error) {
 }
 
 // setEnvFromConfig sets environment variables from config values
-// Only sets if the environment variable doesn't already exist
+// Only sets if the environment variable doesn't already exist, in which case
+// the config value is replaced with the environment variable.
 func setEnvFromConfig(config *Config) {
 	setEnvFromStruct(reflect.ValueOf(config).Elem())
 }
@@ -112,12 +206,34 @@ func setEnvFromStruct(v reflect.Value) {
 		}
 
 		// User set env var override config file
-		if value != "" && os.Getenv(envTag) == "" {
+		if env := os.Getenv(envTag); env != "" {
+			setFieldFromEnv(field, envTag, env)
+		} else if value != "" {
 			os.Setenv(envTag, value)
 		}
 	}
 }
 
+// setFieldFromEnv overwrites a config field with the value of its environment
+// variable so the returned Config reflects the same values as the environment.
+func setFieldFromEnv(field reflect.Value, envTag, env string) {
+	if !field.CanSet() {
+		return
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Int:
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			fmt.Printf("Invalid %s value: %s, using config file value\n", envTag, env)
+			return
+		}
+		field.SetInt(int64(n))
+	}
+}
+
 // GetPhotoMaxFileSizeBytes returns the maximum file size in bytes from environment variable
 func GetPhotoMaxFileSizeBytes() int64 {
 	valueStr := os.Getenv("PHOTO_MAX_FILE_SIZE_MB")
@@ -135,3 +251,682 @@ func GetPhotoMaxFileSizeBytes() int64 {
 
 	return int64(maxSizeMB) << 20 // Convert MB to bytes
 }
+
+// defaultPhotoVariants are the variants rendered unless configured otherwise
+const defaultPhotoVariants = "thumb=150x150,small=320,medium=640,large=1080"
+
+// GetPhotoVariants returns the resized variants rendered of each photo from
+// environment variable, formatted as "thumb=150x150,small=320,medium=640:jpeg"
+func GetPhotoVariants() []imaging.Variant {
+	valueStr := os.Getenv("PHOTO_VARIANTS")
+	if valueStr == "" {
+		valueStr = defaultPhotoVariants
+	}
+
+	variants, err := imaging.ParseVariants(valueStr)
+	if err != nil || len(variants) == 0 {
+		fmt.Printf("Invalid PHOTO_VARIANTS value: %s, using default %s\n", valueStr, defaultPhotoVariants)
+		variants, _ = imaging.ParseVariants(defaultPhotoVariants)
+	}
+
+	return variants
+}
+
+// defaultPhotoFormats are the formats variants are also rendered in unless
+// configured otherwise
+const defaultPhotoFormats = "webp"
+
+// GetPhotoFormats returns the formats each variant is rendered in besides its
+// own from environment variable, formatted as "webp,avif", or none if set to
+// none. Images are served in these formats to clients that accept them.
+func GetPhotoFormats() []string {
+	valueStr := os.Getenv("PHOTO_FORMATS")
+	if valueStr == "" {
+		valueStr = defaultPhotoFormats
+	} else if valueStr == "none" {
+		return nil
+	}
+
+	formats, err := imaging.ParseFormats(valueStr)
+	if err != nil {
+		fmt.Printf("Invalid PHOTO_FORMATS value: %s, using default %s\n", valueStr, defaultPhotoFormats)
+		formats, _ = imaging.ParseFormats(defaultPhotoFormats)
+	}
+
+	return formats
+}
+
+// defaultPhotoAllowedTypes are the MIME types of the photos that can be
+// uploaded unless configured otherwise
+const defaultPhotoAllowedTypes = "image/jpeg,image/png,image/gif,image/webp,image/tiff"
+
+// GetPhotoAllowedTypes returns the MIME types of the photos that can be
+// uploaded from environment variable, formatted as "image/jpeg,image/png"
+func GetPhotoAllowedTypes() []string {
+	valueStr := os.Getenv("PHOTO_ALLOWED_TYPES")
+	if valueStr == "" {
+		valueStr = defaultPhotoAllowedTypes
+	}
+
+	var types []string
+	for _, mimeType := range strings.Split(valueStr, ",") {
+		mimeType = strings.TrimSpace(mimeType)
+		format, ok := strings.CutPrefix(mimeType, "image/")
+		if !ok || !imaging.Decodable(format) {
+			fmt.Printf("Invalid PHOTO_ALLOWED_TYPES value: %s, using default %s\n", valueStr, defaultPhotoAllowedTypes)
+			return strings.Split(defaultPhotoAllowedTypes, ",")
+		}
+		types = append(types, mimeType)
+	}
+
+	return types
+}
+
+// GetPhotoAnimationWidth returns the width animated GIFs are resized to for
+// their animated variant from environment variable
+func GetPhotoAnimationWidth() int {
+	valueStr := os.Getenv("PHOTO_ANIMATION_WIDTH")
+	if valueStr == "" {
+		// Default to 480 pixels if not set
+		valueStr = "480"
+	}
+
+	width, err := strconv.Atoi(valueStr)
+	if err != nil || width <= 0 || width > imaging.MaxDimension {
+		fmt.Printf("Invalid PHOTO_ANIMATION_WIDTH value: %s, using default 480\n", valueStr)
+		width = 480
+	}
+
+	return width
+}
+
+// GetPhotoSimilarityThreshold returns the Hamming distance between perceptual
+// hashes up to which photos are considered similar from environment variable
+func GetPhotoSimilarityThreshold() int {
+	valueStr := os.Getenv("PHOTO_SIMILARITY_THRESHOLD")
+	if valueStr == "" {
+		// Default to 10 of 64 bits if not set
+		valueStr = "10"
+	}
+
+	threshold, err := strconv.Atoi(valueStr)
+	if err != nil || threshold < 0 || threshold > 64 {
+		fmt.Printf("Invalid PHOTO_SIMILARITY_THRESHOLD value: %s, using default 10\n", valueStr)
+		threshold = 10
+	}
+
+	return threshold
+}
+
+// GetPhotoMaxPixels returns the largest number of pixels of an image that is
+// decoded from environment variable
+func GetPhotoMaxPixels() int64 {
+	valueStr := os.Getenv("PHOTO_MAX_PIXELS")
+	if valueStr == "" {
+		// Default to 50 megapixels if not set
+		valueStr = "50000000"
+	}
+
+	maxPixels, err := strconv.ParseInt(valueStr, 10, 64)
+	if err != nil || maxPixels <= 0 {
+		fmt.Printf("Invalid PHOTO_MAX_PIXELS value: %s, using default 50000000\n", valueStr)
+		maxPixels = 50_000_000
+	}
+
+	return maxPixels
+}
+
+// GetPhotoDecodeTimeout returns how long an image can take to decode from
+// environment variable
+func GetPhotoDecodeTimeout() time.Duration {
+	valueStr := os.Getenv("PHOTO_DECODE_TIMEOUT")
+	if valueStr == "" {
+		// Default to 10 seconds if not set
+		valueStr = "10s"
+	}
+
+	timeout, err := time.ParseDuration(valueStr)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Invalid PHOTO_DECODE_TIMEOUT value: %s, using default 10s\n", valueStr)
+		timeout = 10 * time.Second
+	}
+
+	return timeout
+}
+
+// GetPhotoDecodeMemoryBytes returns the memory in bytes of the images being
+// decoded at once from environment variable
+func GetPhotoDecodeMemoryBytes() int64 {
+	valueStr := os.Getenv("PHOTO_DECODE_MEMORY_MB")
+	if valueStr == "" {
+		// Default to 1GB if not set
+		valueStr = "1024"
+	}
+
+	memoryMB, err := strconv.Atoi(valueStr)
+	if err != nil || memoryMB <= 0 {
+		fmt.Printf("Invalid PHOTO_DECODE_MEMORY_MB value: %s, using default 1024MB\n", valueStr)
+		memoryMB = 1024
+	}
+
+	return int64(memoryMB) << 20 // Convert MB to bytes
+}
+
+// GetPhotoDeletionDelay returns how long deleted photos are kept before they
+// are permanently deleted from environment variable
+func GetPhotoDeletionDelay() time.Duration {
+	valueStr := os.Getenv("PHOTO_DELETION_DELAY")
+	if valueStr == "" {
+		// Default to 30 days if not set
+		valueStr = "720h"
+	}
+
+	delay, err := time.ParseDuration(valueStr)
+	if err != nil || delay < 0 {
+		fmt.Printf("Invalid PHOTO_DELETION_DELAY value: %s, using default 720h\n", valueStr)
+		delay = 720 * time.Hour
+	}
+
+	return delay
+}
+
+// GetImageSigningKey returns the key signing image resize requests from
+// environment variable. Without a key, no request is authorized.
+func GetImageSigningKey() []byte {
+	return []byte(os.Getenv("IMAGE_SIGNING_KEY"))
+}
+
+// GetUploadScratchPath returns the directory partial resumable uploads are
+// written to from environment variable
+func GetUploadScratchPath() string {
+	path := os.Getenv("UPLOAD_SCRATCH_PATH")
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "jelly-uploads")
+	}
+	return path
+}
+
+// GetUploadExpiration returns how long a resumable upload can be resumed from
+// environment variable
+func GetUploadExpiration() time.Duration {
+	valueStr := os.Getenv("UPLOAD_EXPIRATION")
+	if valueStr == "" {
+		// Default to 24 hours if not set
+		valueStr = "24h"
+	}
+
+	expiration, err := time.ParseDuration(valueStr)
+	if err != nil || expiration <= 0 {
+		fmt.Printf("Invalid UPLOAD_EXPIRATION value: %s, using default 24h\n", valueStr)
+		expiration = 24 * time.Hour
+	}
+
+	return expiration
+}
+
+// GetUploadURLExpiration returns how long a presigned upload request is valid
+// from environment variable
+func GetUploadURLExpiration() time.Duration {
+	valueStr := os.Getenv("UPLOAD_URL_EXPIRATION")
+	if valueStr == "" {
+		// Default to 15 minutes if not set
+		valueStr = "15m"
+	}
+
+	expiration, err := time.ParseDuration(valueStr)
+	if err != nil || expiration <= 0 {
+		fmt.Printf("Invalid UPLOAD_URL_EXPIRATION value: %s, using default 15m\n", valueStr)
+		expiration = 15 * time.Minute
+	}
+
+	return expiration
+}
+
+// GetIdempotencyTTL returns how long responses to requests with an
+// Idempotency-Key are replayed from environment variable
+func GetIdempotencyTTL() time.Duration {
+	valueStr := os.Getenv("IDEMPOTENCY_TTL")
+	if valueStr == "" {
+		// Default to 24 hours if not set
+		valueStr = "24h"
+	}
+
+	ttl, err := time.ParseDuration(valueStr)
+	if err != nil || ttl <= 0 {
+		fmt.Printf("Invalid IDEMPOTENCY_TTL value: %s, using default 24h\n", valueStr)
+		ttl = 24 * time.Hour
+	}
+
+	return ttl
+}
+
+// GetIdempotencyLease returns how long the key of a request in progress is
+// held without being renewed from environment variable
+func GetIdempotencyLease() time.Duration {
+	valueStr := os.Getenv("IDEMPOTENCY_LEASE")
+	if valueStr == "" {
+		// Default to 1 minute if not set
+		valueStr = "1m"
+	}
+
+	lease, err := time.ParseDuration(valueStr)
+	if err != nil || lease <= 0 {
+		fmt.Printf("Invalid IDEMPOTENCY_LEASE value: %s, using default 1m\n", valueStr)
+		lease = time.Minute
+	}
+
+	return lease
+}
+
+// GetRateLimitDefault returns the rate limit of operations without a limit of
+// their own from environment variable
+func GetRateLimitDefault() ratelimit.Limit {
+	valueStr := os.Getenv("RATE_LIMIT_DEFAULT")
+	if valueStr == "" {
+		// Default to 20 requests per second if not set
+		valueStr = "20/s"
+	}
+
+	limit, err := ratelimit.ParseLimit(valueStr)
+	if err != nil {
+		fmt.Printf("Invalid RATE_LIMIT_DEFAULT value: %s, using default 20/s\n", valueStr)
+		limit = ratelimit.Limit{Requests: 20, Per: time.Second}
+	}
+
+	return limit
+}
+
+// GetRateLimitOperations returns the rate limits of operations by operationId
+// from environment variable, formatted as "uploadPhoto=100/h,likePhoto=60/m"
+func GetRateLimitOperations() map[string]ratelimit.Limit {
+	valueStr := os.Getenv("RATE_LIMIT_OPERATIONS")
+	if valueStr == "" {
+		// Default to limiting uploads and writes if not set
+		valueStr = "healthCheck=none,getUploadCapabilities=none,uploadPhoto=100/h,createUpload=100/h," +
+			"createPhotoUploadUrl=100/h,likePhoto=60/m,createComment=30/m"
+	}
+
+	limits := map[string]ratelimit.Limit{}
+	for _, entry := range strings.Split(valueStr, ",") {
+		operation, limitStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			fmt.Printf("Invalid RATE_LIMIT_OPERATIONS entry: %s, ignoring it\n", entry)
+			continue
+		}
+
+		limit, err := ratelimit.ParseLimit(limitStr)
+		if err != nil {
+			fmt.Printf("Invalid RATE_LIMIT_OPERATIONS entry: %s, ignoring it\n", entry)
+			continue
+		}
+		limits[strings.TrimSpace(operation)] = limit
+	}
+
+	return limits
+}
+
+// GetCacheTTL returns how long photos and their counts are cached from
+// environment variable
+func GetCacheTTL() time.Duration {
+	valueStr := os.Getenv("CACHE_TTL")
+	if valueStr == "" {
+		// Default to 5 minutes if not set
+		valueStr = "5m"
+	}
+
+	ttl, err := time.ParseDuration(valueStr)
+	if err != nil || ttl <= 0 {
+		fmt.Printf("Invalid CACHE_TTL value: %s, using default 5m\n", valueStr)
+		ttl = 5 * time.Minute
+	}
+
+	return ttl
+}
+
+// GetCacheNegativeTTL returns how long missing photos are cached from
+// environment variable
+func GetCacheNegativeTTL() time.Duration {
+	valueStr := os.Getenv("CACHE_NEGATIVE_TTL")
+	if valueStr == "" {
+		// Default to 30 seconds if not set
+		valueStr = "30s"
+	}
+
+	ttl, err := time.ParseDuration(valueStr)
+	if err != nil || ttl < 0 {
+		fmt.Printf("Invalid CACHE_NEGATIVE_TTL value: %s, using default 30s\n", valueStr)
+		ttl = 30 * time.Second
+	}
+
+	return ttl
+}
+
+// GetCacheSize returns the number of entries of the in-memory cache from
+// environment variable
+func GetCacheSize() int {
+	valueStr := os.Getenv("CACHE_SIZE")
+	if valueStr == "" {
+		// Default to 10000 entries if not set
+		valueStr = "10000"
+	}
+
+	size, err := strconv.Atoi(valueStr)
+	if err != nil || size <= 0 {
+		fmt.Printf("Invalid CACHE_SIZE value: %s, using default 10000\n", valueStr)
+		size = 10000
+	}
+
+	return size
+}
+
+// GetScanTimeout returns how long scanning an uploaded file can take from
+// environment variable
+func GetScanTimeout() time.Duration {
+	valueStr := os.Getenv("SCAN_TIMEOUT")
+	if valueStr == "" {
+		// Default to 30 seconds if not set
+		valueStr = "30s"
+	}
+
+	timeout, err := time.ParseDuration(valueStr)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Invalid SCAN_TIMEOUT value: %s, using default 30s\n", valueStr)
+		timeout = 30 * time.Second
+	}
+
+	return timeout
+}
+
+// GetAuthSessionSecret returns the key session cookies are signed with from
+// environment variable. Without a secret, no session is valid.
+func GetAuthSessionSecret() []byte {
+	return []byte(os.Getenv("AUTH_SESSION_SECRET"))
+}
+
+// GetAuthSessionTTL returns how long the sessions signed by `jelly session`
+// are valid from environment variable
+func GetAuthSessionTTL() time.Duration {
+	valueStr := os.Getenv("AUTH_SESSION_TTL")
+	if valueStr == "" {
+		// Default to 24 hours if not set
+		valueStr = "24h"
+	}
+
+	ttl, err := time.ParseDuration(valueStr)
+	if err != nil || ttl <= 0 {
+		fmt.Printf("Invalid AUTH_SESSION_TTL value: %s, using default 24h\n", valueStr)
+		ttl = 24 * time.Hour
+	}
+
+	return ttl
+}
+
+// GetAuthAPIKeys returns the API keys of trusted clients from environment
+// variable, formatted as "key1,key2"
+func GetAuthAPIKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("AUTH_API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetAdminUserIDs returns the IDs of the users allowed to use the admin
+// endpoints from environment variable, formatted as "id1,id2"
+func GetAdminUserIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// GetAdminAPIKey returns the API key of the clients allowed to use the admin
+// endpoints from environment variable. Without a key, only admin users are.
+func GetAdminAPIKey() string {
+	return os.Getenv("ADMIN_API_KEY")
+}
+
+// GetGCInterval returns how often the server collects garbage from
+// environment variable, or 0 if it doesn't
+func GetGCInterval() time.Duration {
+	valueStr := os.Getenv("GC_INTERVAL")
+	if valueStr == "" {
+		// Default to 1 hour if not set
+		valueStr = "1h"
+	}
+
+	interval, err := time.ParseDuration(valueStr)
+	if err != nil || interval < 0 {
+		fmt.Printf("Invalid GC_INTERVAL value: %s, using default 1h\n", valueStr)
+		interval = time.Hour
+	}
+
+	return interval
+}
+
+// GetGCOrphanAge returns how old storage objects without a row must be to be
+// collected from environment variable
+func GetGCOrphanAge() time.Duration {
+	valueStr := os.Getenv("GC_ORPHAN_AGE")
+	if valueStr == "" {
+		// Default to 48 hours if not set
+		valueStr = "48h"
+	}
+
+	age, err := time.ParseDuration(valueStr)
+	if err != nil || age <= 0 {
+		fmt.Printf("Invalid GC_ORPHAN_AGE value: %s, using default 48h\n", valueStr)
+		age = 48 * time.Hour
+	}
+
+	return age
+}
+
+// GetStorageRawURLExpiration returns how long URLs of raw photos in private
+// storage or on a CDN are valid from environment variable
+func GetStorageRawURLExpiration() time.Duration {
+	valueStr := os.Getenv("STORAGE_RAW_URL_EXPIRATION")
+	if valueStr == "" {
+		// Default to 5 minutes if not set
+		valueStr = "5m"
+	}
+
+	expiration, err := time.ParseDuration(valueStr)
+	if err != nil || expiration <= 0 {
+		fmt.Printf("Invalid STORAGE_RAW_URL_EXPIRATION value: %s, using default 5m\n", valueStr)
+		expiration = 5 * time.Minute
+	}
+
+	return expiration
+}
+
+// GetStorageOriginalURLExpiration returns how long URLs of the originals of
+// photos in private storage or on a CDN are valid from environment variable
+func GetStorageOriginalURLExpiration() time.Duration {
+	valueStr := os.Getenv("STORAGE_ORIGINAL_URL_EXPIRATION")
+	if valueStr == "" {
+		// Default to 15 minutes if not set
+		valueStr = "15m"
+	}
+
+	expiration, err := time.ParseDuration(valueStr)
+	if err != nil || expiration <= 0 {
+		fmt.Printf("Invalid STORAGE_ORIGINAL_URL_EXPIRATION value: %s, using default 15m\n", valueStr)
+		expiration = 15 * time.Minute
+	}
+
+	return expiration
+}
+
+// GetStorageThumbnailURLExpiration returns how long URLs of thumbnails and the
+// other variants of photos in private storage or on a CDN are valid from
+// environment variable
+func GetStorageThumbnailURLExpiration() time.Duration {
+	valueStr := os.Getenv("STORAGE_THUMBNAIL_URL_EXPIRATION")
+	if valueStr == "" {
+		// Default to 1 hour if not set
+		valueStr = "1h"
+	}
+
+	expiration, err := time.ParseDuration(valueStr)
+	if err != nil || expiration <= 0 {
+		fmt.Printf("Invalid STORAGE_THUMBNAIL_URL_EXPIRATION value: %s, using default 1h\n", valueStr)
+		expiration = time.Hour
+	}
+
+	return expiration
+}
+
+// GetStorageTimeout returns how long an attempt of a storage operation can
+// take from environment variable
+func GetStorageTimeout() time.Duration {
+	valueStr := os.Getenv("STORAGE_TIMEOUT")
+	if valueStr == "" {
+		// Default to 30 seconds if not set
+		valueStr = "30s"
+	}
+
+	timeout, err := time.ParseDuration(valueStr)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Invalid STORAGE_TIMEOUT value: %s, using default 30s\n", valueStr)
+		timeout = 30 * time.Second
+	}
+
+	return timeout
+}
+
+// GetStorageRetries returns how many times storage operations failing with
+// transient errors are retried from environment variable
+func GetStorageRetries() int {
+	valueStr := os.Getenv("STORAGE_RETRIES")
+	if valueStr == "" {
+		// Default to 3 retries if not set
+		valueStr = "3"
+	}
+
+	retries, err := strconv.Atoi(valueStr)
+	if err != nil || retries < 0 {
+		fmt.Printf("Invalid STORAGE_RETRIES value: %s, using default 3\n", valueStr)
+		retries = 3
+	}
+
+	return retries
+}
+
+// GetStorageRetryDelay returns the backoff before the first retry of a
+// storage operation, doubled by each retry, from environment variable
+func GetStorageRetryDelay() time.Duration {
+	valueStr := os.Getenv("STORAGE_RETRY_DELAY")
+	if valueStr == "" {
+		// Default to 100 milliseconds if not set
+		valueStr = "100ms"
+	}
+
+	delay, err := time.ParseDuration(valueStr)
+	if err != nil || delay <= 0 {
+		fmt.Printf("Invalid STORAGE_RETRY_DELAY value: %s, using default 100ms\n", valueStr)
+		delay = 100 * time.Millisecond
+	}
+
+	return delay
+}
+
+// GetStorageBreakerThreshold returns how many consecutive storage operations
+// must fail for the circuit breaker to open from environment variable
+func GetStorageBreakerThreshold() int {
+	valueStr := os.Getenv("STORAGE_BREAKER_THRESHOLD")
+	if valueStr == "" {
+		// Default to 5 operations if not set
+		valueStr = "5"
+	}
+
+	threshold, err := strconv.Atoi(valueStr)
+	if err != nil || threshold <= 0 {
+		fmt.Printf("Invalid STORAGE_BREAKER_THRESHOLD value: %s, using default 5\n", valueStr)
+		threshold = 5
+	}
+
+	return threshold
+}
+
+// GetStorageBreakerCooldown returns how long the circuit breaker of storage
+// stays open before probing storage from environment variable
+func GetStorageBreakerCooldown() time.Duration {
+	valueStr := os.Getenv("STORAGE_BREAKER_COOLDOWN")
+	if valueStr == "" {
+		// Default to 30 seconds if not set
+		valueStr = "30s"
+	}
+
+	cooldown, err := time.ParseDuration(valueStr)
+	if err != nil || cooldown <= 0 {
+		fmt.Printf("Invalid STORAGE_BREAKER_COOLDOWN value: %s, using default 30s\n", valueStr)
+		cooldown = 30 * time.Second
+	}
+
+	return cooldown
+}
+
+// GetStorageEncryptionPrefixes returns the prefixes of the keys of encrypted
+// objects from environment variable, formatted as "raw/,other/"
+func GetStorageEncryptionPrefixes() []string {
+	valueStr := os.Getenv("STORAGE_ENCRYPTION_PREFIXES")
+	if valueStr == "" {
+		// Default to raw photos if not set
+		valueStr = "raw/"
+	}
+
+	var prefixes []string
+	for _, prefix := range strings.Split(valueStr, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// GetStorageBackendID returns the ID rows refer to the storage backend by from
+// environment variable
+func GetStorageBackendID() string {
+	id := os.Getenv("STORAGE_BACKEND_ID")
+	if id == "" {
+		id = "default"
+	}
+	return id
+}
+
+// GetStorageMirrorRepairInterval returns how often objects the mirror of
+// storage failed to be written are repaired from environment variable
+func GetStorageMirrorRepairInterval() time.Duration {
+	valueStr := os.Getenv("STORAGE_MIRROR_REPAIR_INTERVAL")
+	if valueStr == "" {
+		// Default to 1 minute if not set
+		valueStr = "1m"
+	}
+
+	interval, err := time.ParseDuration(valueStr)
+	if err != nil || interval <= 0 {
+		fmt.Printf("Invalid STORAGE_MIRROR_REPAIR_INTERVAL value: %s, using default 1m\n", valueStr)
+		interval = time.Minute
+	}
+
+	return interval
+}
+
+// DatabaseURL returns the Postgres connection string for the configured
+// database.
+func (c *Config) DatabaseURL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Database.User, c.Database.Password),
+		Host:     fmt.Sprintf("%s:%d", c.Database.Host, c.Database.Port),
+		Path:     c.Database.Name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
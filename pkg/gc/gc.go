@@ -0,0 +1,397 @@
+// Package gc permanently deletes photos and raw photos whose scheduled
+// deletion has passed, uploads that expired before they were completed, and
+// the storage objects no row refers to anymore.
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"jelly/pkg/cdn"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+// batchSize is how many expired rows are read at a time
+const batchSize = 100
+
+// Prefixes of the storage objects owned by rows
+const (
+	rawPrefix        = "raw/"
+	quarantinePrefix = "quarantine/"
+	photosPrefix     = "photos/"
+	derivedPrefix    = "derived/"
+)
+
+// Database defines the persistence operations used by the collector.
+type Database interface {
+	GetExpiredPhotos(ctx context.Context, after string, limit int) ([]model.Photo, error)
+	PurgePhoto(ctx context.Context, photoID string) (likes, comments int64, err error)
+	GetExpiredRawPhotos(ctx context.Context, after string, limit int) ([]model.RawPhoto, error)
+	PurgeRawPhoto(ctx context.Context, rawPhotoID string) error
+	GetExpiredUploads(ctx context.Context, after string, limit int) ([]model.Upload, error)
+	GetUpload(ctx context.Context, uploadID string) (model.Upload, error)
+	DeleteUpload(ctx context.Context, uploadID string) error
+	CountPhotoLikes(ctx context.Context, photoID string) (int, error)
+	CountPhotoComments(ctx context.Context, photoID string) (int, error)
+	GetRawPhotoByHash(ctx context.Context, userID, sha256Hash string) (model.RawPhoto, error)
+	PhotoExists(ctx context.Context, photoID string) (bool, error)
+	PhotoVariantExists(ctx context.Context, storageKey string) (bool, error)
+}
+
+// Collector deletes expired rows and the storage objects of deleted rows.
+type Collector struct {
+	DB      Database
+	Storage store.Storage
+	Backend string  // ID of the storage backend rows refer to Storage by
+	CDN     cdn.CDN // Purges deleted objects from the CDN cache, if set
+
+	// OrphanAge is how old objects without a row must be to be deleted, so
+	// objects uploaded before their row is created aren't
+	OrphanAge time.Duration
+
+	// ScratchPath is the directory resumable uploads are received in, whose
+	// files are deleted with their expired uploads if set
+	ScratchPath string
+
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool
+}
+
+// Report summarizes a collection.
+type Report struct {
+	DryRun      bool
+	Likes       int64
+	Comments    int64
+	Photos      int
+	RawPhotos   int
+	Uploads     int   // Uploads that expired before they were completed
+	Objects     int   // Objects of the deleted rows
+	Orphans     int   // Objects without a row
+	OrphanBytes int64 // Size of the objects without a row
+	Errors      int   // Rows and objects that failed to be deleted
+}
+
+// String formats the report for people.
+func (r Report) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("Dry run, nothing was deleted\n")
+	}
+	fmt.Fprintf(&b, "Photos:      %d\n", r.Photos)
+	fmt.Fprintf(&b, "Likes:       %d\n", r.Likes)
+	fmt.Fprintf(&b, "Comments:    %d\n", r.Comments)
+	fmt.Fprintf(&b, "Raw photos:  %d\n", r.RawPhotos)
+	fmt.Fprintf(&b, "Uploads:     %d\n", r.Uploads)
+	fmt.Fprintf(&b, "Objects:     %d\n", r.Objects)
+	fmt.Fprintf(&b, "Orphans:     %d (%d bytes)\n", r.Orphans, r.OrphanBytes)
+	fmt.Fprintf(&b, "Errors:      %d\n", r.Errors)
+	return b.String()
+}
+
+// LogValue logs the report as a group.
+func (r Report) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("dry_run", r.DryRun),
+		slog.Int("photos", r.Photos),
+		slog.Int64("likes", r.Likes),
+		slog.Int64("comments", r.Comments),
+		slog.Int("raw_photos", r.RawPhotos),
+		slog.Int("uploads", r.Uploads),
+		slog.Int("objects", r.Objects),
+		slog.Int("orphans", r.Orphans),
+		slog.Int64("orphan_bytes", r.OrphanBytes),
+		slog.Int("errors", r.Errors),
+	)
+}
+
+// Run deletes the expired photos with their likes and comments, then the
+// expired raw photos and uploads, then the objects without a row. Rows are deleted before
+// their objects, so objects that fail to be deleted are left as orphans for a
+// later run rather than rows referring to missing objects. Failures to delete
+// a row or object are logged and counted, an error is only returned if the
+// rows or objects can't be listed.
+func (c *Collector) Run(ctx context.Context) (Report, error) {
+	report := Report{DryRun: c.DryRun}
+
+	if err := c.collectPhotos(ctx, &report); err != nil {
+		return report, err
+	}
+	if err := c.collectRawPhotos(ctx, &report); err != nil {
+		return report, err
+	}
+	if err := c.collectUploads(ctx, &report); err != nil {
+		return report, err
+	}
+	for _, prefix := range []string{rawPrefix, quarantinePrefix, photosPrefix, derivedPrefix} {
+		if err := c.collectOrphans(ctx, prefix, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// collectPhotos deletes the expired photos, their likes, comments, variants
+// and resized images.
+func (c *Collector) collectPhotos(ctx context.Context, report *Report) error {
+	for after := ""; ; {
+		photos, err := c.DB.GetExpiredPhotos(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range photos {
+			if !c.stored(p.StorageBackend, report, "photo_id", p.ID) {
+				continue
+			}
+			likes, comments, err := c.purgePhoto(ctx, p.ID)
+			if err != nil {
+				slog.Error("Failed to purge photo", "error", err, "photo_id", p.ID)
+				report.Errors++
+				continue
+			}
+			report.Photos++
+			report.Likes += likes
+			report.Comments += comments
+
+			keys := make([]string, 0, len(p.Variants))
+			for _, variant := range p.Variants {
+				keys = append(keys, variant.StorageKey)
+			}
+			// Resized images left behind are deleted as orphans by a later run
+			for object, err := range c.Storage.List(ctx, derivedPrefix+p.ID+"/") {
+				if err != nil {
+					slog.Error("Failed to list resized images", "error", err, "photo_id", p.ID)
+					report.Errors++
+					break
+				}
+				keys = append(keys, object.Key)
+			}
+			c.delete(ctx, keys, report)
+			report.Objects += len(keys)
+		}
+
+		if len(photos) < batchSize {
+			return nil
+		}
+		after = photos[len(photos)-1].ID
+	}
+}
+
+// purgePhoto deletes a photo, or counts its likes and comments in a dry run.
+func (c *Collector) purgePhoto(ctx context.Context, photoID string) (likes, comments int64, err error) {
+	if !c.DryRun {
+		return c.DB.PurgePhoto(ctx, photoID)
+	}
+
+	n, err := c.DB.CountPhotoLikes(ctx, photoID)
+	if err != nil {
+		return 0, 0, err
+	}
+	m, err := c.DB.CountPhotoComments(ctx, photoID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(n), int64(m), nil
+}
+
+// collectRawPhotos deletes the expired raw photos no photo is processed from.
+func (c *Collector) collectRawPhotos(ctx context.Context, report *Report) error {
+	for after := ""; ; {
+		raws, err := c.DB.GetExpiredRawPhotos(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var keys []string
+		for _, raw := range raws {
+			if !c.stored(raw.StorageBackend, report, "raw_photo_id", raw.ID) {
+				continue
+			}
+			if !c.DryRun {
+				if err := c.DB.PurgeRawPhoto(ctx, raw.ID); err != nil {
+					slog.Error("Failed to purge raw photo", "error", err, "raw_photo_id", raw.ID)
+					report.Errors++
+					continue
+				}
+			}
+			report.RawPhotos++
+			keys = append(keys, raw.StorageKey)
+		}
+		c.delete(ctx, keys, report)
+		report.Objects += len(keys)
+
+		if len(raws) < batchSize {
+			return nil
+		}
+		after = raws[len(raws)-1].ID
+	}
+}
+
+// collectUploads deletes the uploads that expired before they were completed,
+// and the scratch files of the resumable ones. The objects of direct uploads
+// are deleted as orphans.
+func (c *Collector) collectUploads(ctx context.Context, report *Report) error {
+	for after := ""; ; {
+		uploads, err := c.DB.GetExpiredUploads(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range uploads {
+			if !c.DryRun {
+				if err := c.DB.DeleteUpload(ctx, upload.ID); err != nil {
+					slog.Error("Failed to delete upload", "error", err, "upload_id", upload.ID)
+					report.Errors++
+					continue
+				}
+				if !upload.Direct && c.ScratchPath != "" {
+					err := os.Remove(filepath.Join(c.ScratchPath, upload.ID))
+					if err != nil && !errors.Is(err, os.ErrNotExist) {
+						slog.Error("Failed to remove scratch file", "error", err, "upload_id", upload.ID)
+						report.Errors++
+					}
+				}
+			}
+			report.Uploads++
+		}
+
+		if len(uploads) < batchSize {
+			return nil
+		}
+		after = uploads[len(uploads)-1].ID
+	}
+}
+
+// stored reports whether the objects of a row are in Storage. Rows whose
+// objects are in another storage backend are kept along with their objects,
+// and counted as failures, so they aren't purged with their objects left
+// behind.
+func (c *Collector) stored(backend string, report *Report, args ...any) bool {
+	if backend == c.Backend {
+		return true
+	}
+	slog.Error("Row is in another storage backend", append(args, "backend", backend)...)
+	report.Errors++
+	return false
+}
+
+// collectOrphans deletes the objects with the prefix that no row refers to,
+// a batch at a time.
+func (c *Collector) collectOrphans(ctx context.Context, prefix string, report *Report) error {
+	var orphans []string
+	for object, err := range c.Storage.List(ctx, prefix) {
+		if err != nil {
+			c.delete(ctx, orphans, report)
+			return err
+		}
+		if time.Since(object.LastModified) < c.OrphanAge {
+			continue
+		}
+
+		referenced, err := c.referenced(ctx, object.Key)
+		if err != nil {
+			slog.Error("Failed to check object", "error", err, "key", object.Key)
+			report.Errors++
+			continue
+		}
+		if referenced {
+			continue
+		}
+
+		slog.Debug("Found orphaned object", "key", object.Key, "size", object.Size, "dry_run", c.DryRun)
+		orphans = append(orphans, object.Key)
+		report.Orphans++
+		report.OrphanBytes += object.Size
+
+		if len(orphans) == batchSize {
+			c.delete(ctx, orphans, report)
+			orphans = orphans[:0]
+		}
+	}
+	c.delete(ctx, orphans, report)
+
+	return nil
+}
+
+// referenced reports whether a row refers to the object. Objects whose keys
+// aren't laid out by the photo handlers are considered referenced, so they're
+// never deleted.
+func (c *Collector) referenced(ctx context.Context, key string) (bool, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 {
+		return true, nil
+	}
+
+	switch parts[0] + "/" {
+	case rawPrefix, quarantinePrefix:
+		// Raw photos are addressed by their owner and content hash, and direct
+		// uploads are staged next to them by their ID until they're completed
+		name := strings.TrimSuffix(parts[2], path.Ext(parts[2]))
+		if _, err := uuid.Parse(name); err == nil {
+			upload, err := c.DB.GetUpload(ctx, name)
+			if errors.Is(err, pgdb.ErrNotFound) {
+				return false, nil
+			}
+			return err == nil && upload.CompletedAt == nil, err
+		}
+		_, err := c.DB.GetRawPhotoByHash(ctx, parts[1], name)
+		if errors.Is(err, pgdb.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	case photosPrefix:
+		return c.DB.PhotoVariantExists(ctx, key)
+	case derivedPrefix:
+		// Resized images are cached for as long as their photo exists
+		return c.DB.PhotoExists(ctx, parts[1])
+	default:
+		return true, nil
+	}
+}
+
+// delete deletes objects unless this is a dry run, logging and counting
+// failures, and purges the deleted objects from the CDN.
+func (c *Collector) delete(ctx context.Context, keys []string, report *Report) {
+	if c.DryRun || len(keys) == 0 {
+		return
+	}
+
+	err := c.Storage.DeleteMany(ctx, keys)
+	var deleteErr *store.DeleteError
+	switch {
+	case errors.As(err, &deleteErr):
+		for key, err := range deleteErr.Errors {
+			slog.Error("Failed to delete object", "error", err, "key", key)
+		}
+		report.Errors += len(deleteErr.Errors)
+		keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+			_, failed := deleteErr.Errors[key]
+			return failed
+		})
+	case err != nil:
+		slog.Error("Failed to delete objects", "error", err, "keys", keys)
+		report.Errors += len(keys)
+		return
+	}
+
+	if c.CDN == nil || len(keys) == 0 {
+		return
+	}
+	if err := c.CDN.Purge(ctx, keys); err != nil {
+		slog.Error("Failed to purge objects", "error", err, "keys", keys)
+		report.Errors++
+	}
+}
@@ -0,0 +1,849 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package gc
+
+import (
+	"context"
+	"jelly/pkg/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockDatabase creates a new instance of MockDatabase. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDatabase(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDatabase {
+	mock := &MockDatabase{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDatabase is an autogenerated mock type for the Database type
+type MockDatabase struct {
+	mock.Mock
+}
+
+type MockDatabase_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDatabase) EXPECT() *MockDatabase_Expecter {
+	return &MockDatabase_Expecter{mock: &_m.Mock}
+}
+
+// CountPhotoComments provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CountPhotoComments(ctx context.Context, photoID string) (int, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPhotoComments")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_CountPhotoComments_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountPhotoComments'
+type MockDatabase_CountPhotoComments_Call struct {
+	*mock.Call
+}
+
+// CountPhotoComments is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) CountPhotoComments(ctx interface{}, photoID interface{}) *MockDatabase_CountPhotoComments_Call {
+	return &MockDatabase_CountPhotoComments_Call{Call: _e.mock.On("CountPhotoComments", ctx, photoID)}
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) Return(n int, err error) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoComments_Call) RunAndReturn(run func(ctx context.Context, photoID string) (int, error)) *MockDatabase_CountPhotoComments_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CountPhotoLikes provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) CountPhotoLikes(ctx context.Context, photoID string) (int, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPhotoLikes")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_CountPhotoLikes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountPhotoLikes'
+type MockDatabase_CountPhotoLikes_Call struct {
+	*mock.Call
+}
+
+// CountPhotoLikes is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) CountPhotoLikes(ctx interface{}, photoID interface{}) *MockDatabase_CountPhotoLikes_Call {
+	return &MockDatabase_CountPhotoLikes_Call{Call: _e.mock.On("CountPhotoLikes", ctx, photoID)}
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) Return(n int, err error) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockDatabase_CountPhotoLikes_Call) RunAndReturn(run func(ctx context.Context, photoID string) (int, error)) *MockDatabase_CountPhotoLikes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) DeleteUpload(ctx context.Context, uploadID string) error {
+	ret := _mock.Called(ctx, uploadID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUpload")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, uploadID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_DeleteUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUpload'
+type MockDatabase_DeleteUpload_Call struct {
+	*mock.Call
+}
+
+// DeleteUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+func (_e *MockDatabase_Expecter) DeleteUpload(ctx interface{}, uploadID interface{}) *MockDatabase_DeleteUpload_Call {
+	return &MockDatabase_DeleteUpload_Call{Call: _e.mock.On("DeleteUpload", ctx, uploadID)}
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) Run(run func(ctx context.Context, uploadID string)) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) Return(err error) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_DeleteUpload_Call) RunAndReturn(run func(ctx context.Context, uploadID string) error) *MockDatabase_DeleteUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetExpiredPhotos provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetExpiredPhotos(ctx context.Context, after string, limit int) ([]model.Photo, error) {
+	ret := _mock.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiredPhotos")
+	}
+
+	var r0 []model.Photo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) ([]model.Photo, error)); ok {
+		return returnFunc(ctx, after, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) []model.Photo); ok {
+		r0 = returnFunc(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Photo)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = returnFunc(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetExpiredPhotos_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetExpiredPhotos'
+type MockDatabase_GetExpiredPhotos_Call struct {
+	*mock.Call
+}
+
+// GetExpiredPhotos is a helper method to define mock.On call
+//   - ctx context.Context
+//   - after string
+//   - limit int
+func (_e *MockDatabase_Expecter) GetExpiredPhotos(ctx interface{}, after interface{}, limit interface{}) *MockDatabase_GetExpiredPhotos_Call {
+	return &MockDatabase_GetExpiredPhotos_Call{Call: _e.mock.On("GetExpiredPhotos", ctx, after, limit)}
+}
+
+func (_c *MockDatabase_GetExpiredPhotos_Call) Run(run func(ctx context.Context, after string, limit int)) *MockDatabase_GetExpiredPhotos_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredPhotos_Call) Return(photos []model.Photo, err error) *MockDatabase_GetExpiredPhotos_Call {
+	_c.Call.Return(photos, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredPhotos_Call) RunAndReturn(run func(ctx context.Context, after string, limit int) ([]model.Photo, error)) *MockDatabase_GetExpiredPhotos_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetExpiredRawPhotos provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetExpiredRawPhotos(ctx context.Context, after string, limit int) ([]model.RawPhoto, error) {
+	ret := _mock.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiredRawPhotos")
+	}
+
+	var r0 []model.RawPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) ([]model.RawPhoto, error)); ok {
+		return returnFunc(ctx, after, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) []model.RawPhoto); ok {
+		r0 = returnFunc(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.RawPhoto)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = returnFunc(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetExpiredRawPhotos_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetExpiredRawPhotos'
+type MockDatabase_GetExpiredRawPhotos_Call struct {
+	*mock.Call
+}
+
+// GetExpiredRawPhotos is a helper method to define mock.On call
+//   - ctx context.Context
+//   - after string
+//   - limit int
+func (_e *MockDatabase_Expecter) GetExpiredRawPhotos(ctx interface{}, after interface{}, limit interface{}) *MockDatabase_GetExpiredRawPhotos_Call {
+	return &MockDatabase_GetExpiredRawPhotos_Call{Call: _e.mock.On("GetExpiredRawPhotos", ctx, after, limit)}
+}
+
+func (_c *MockDatabase_GetExpiredRawPhotos_Call) Run(run func(ctx context.Context, after string, limit int)) *MockDatabase_GetExpiredRawPhotos_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredRawPhotos_Call) Return(rawPhotos []model.RawPhoto, err error) *MockDatabase_GetExpiredRawPhotos_Call {
+	_c.Call.Return(rawPhotos, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredRawPhotos_Call) RunAndReturn(run func(ctx context.Context, after string, limit int) ([]model.RawPhoto, error)) *MockDatabase_GetExpiredRawPhotos_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetExpiredUploads provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetExpiredUploads(ctx context.Context, after string, limit int) ([]model.Upload, error) {
+	ret := _mock.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiredUploads")
+	}
+
+	var r0 []model.Upload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) ([]model.Upload, error)); ok {
+		return returnFunc(ctx, after, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int) []model.Upload); ok {
+		r0 = returnFunc(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Upload)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = returnFunc(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetExpiredUploads_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetExpiredUploads'
+type MockDatabase_GetExpiredUploads_Call struct {
+	*mock.Call
+}
+
+// GetExpiredUploads is a helper method to define mock.On call
+//   - ctx context.Context
+//   - after string
+//   - limit int
+func (_e *MockDatabase_Expecter) GetExpiredUploads(ctx interface{}, after interface{}, limit interface{}) *MockDatabase_GetExpiredUploads_Call {
+	return &MockDatabase_GetExpiredUploads_Call{Call: _e.mock.On("GetExpiredUploads", ctx, after, limit)}
+}
+
+func (_c *MockDatabase_GetExpiredUploads_Call) Run(run func(ctx context.Context, after string, limit int)) *MockDatabase_GetExpiredUploads_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredUploads_Call) Return(uploads []model.Upload, err error) *MockDatabase_GetExpiredUploads_Call {
+	_c.Call.Return(uploads, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetExpiredUploads_Call) RunAndReturn(run func(ctx context.Context, after string, limit int) ([]model.Upload, error)) *MockDatabase_GetExpiredUploads_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRawPhotoByHash provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetRawPhotoByHash(ctx context.Context, userID string, sha256Hash string) (model.RawPhoto, error) {
+	ret := _mock.Called(ctx, userID, sha256Hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRawPhotoByHash")
+	}
+
+	var r0 model.RawPhoto
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (model.RawPhoto, error)); ok {
+		return returnFunc(ctx, userID, sha256Hash)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) model.RawPhoto); ok {
+		r0 = returnFunc(ctx, userID, sha256Hash)
+	} else {
+		r0 = ret.Get(0).(model.RawPhoto)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, userID, sha256Hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetRawPhotoByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRawPhotoByHash'
+type MockDatabase_GetRawPhotoByHash_Call struct {
+	*mock.Call
+}
+
+// GetRawPhotoByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - sha256Hash string
+func (_e *MockDatabase_Expecter) GetRawPhotoByHash(ctx interface{}, userID interface{}, sha256Hash interface{}) *MockDatabase_GetRawPhotoByHash_Call {
+	return &MockDatabase_GetRawPhotoByHash_Call{Call: _e.mock.On("GetRawPhotoByHash", ctx, userID, sha256Hash)}
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) Run(run func(ctx context.Context, userID string, sha256Hash string)) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) Return(rawPhoto model.RawPhoto, err error) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Return(rawPhoto, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetRawPhotoByHash_Call) RunAndReturn(run func(ctx context.Context, userID string, sha256Hash string) (model.RawPhoto, error)) *MockDatabase_GetRawPhotoByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUpload provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) GetUpload(ctx context.Context, uploadID string) (model.Upload, error) {
+	ret := _mock.Called(ctx, uploadID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUpload")
+	}
+
+	var r0 model.Upload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (model.Upload, error)); ok {
+		return returnFunc(ctx, uploadID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) model.Upload); ok {
+		r0 = returnFunc(ctx, uploadID)
+	} else {
+		r0 = ret.Get(0).(model.Upload)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, uploadID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_GetUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetUpload'
+type MockDatabase_GetUpload_Call struct {
+	*mock.Call
+}
+
+// GetUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - uploadID string
+func (_e *MockDatabase_Expecter) GetUpload(ctx interface{}, uploadID interface{}) *MockDatabase_GetUpload_Call {
+	return &MockDatabase_GetUpload_Call{Call: _e.mock.On("GetUpload", ctx, uploadID)}
+}
+
+func (_c *MockDatabase_GetUpload_Call) Run(run func(ctx context.Context, uploadID string)) *MockDatabase_GetUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_GetUpload_Call) Return(upload model.Upload, err error) *MockDatabase_GetUpload_Call {
+	_c.Call.Return(upload, err)
+	return _c
+}
+
+func (_c *MockDatabase_GetUpload_Call) RunAndReturn(run func(ctx context.Context, uploadID string) (model.Upload, error)) *MockDatabase_GetUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PhotoExists provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) PhotoExists(ctx context.Context, photoID string) (bool, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PhotoExists")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_PhotoExists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PhotoExists'
+type MockDatabase_PhotoExists_Call struct {
+	*mock.Call
+}
+
+// PhotoExists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) PhotoExists(ctx interface{}, photoID interface{}) *MockDatabase_PhotoExists_Call {
+	return &MockDatabase_PhotoExists_Call{Call: _e.mock.On("PhotoExists", ctx, photoID)}
+}
+
+func (_c *MockDatabase_PhotoExists_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_PhotoExists_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_PhotoExists_Call) Return(b bool, err error) *MockDatabase_PhotoExists_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockDatabase_PhotoExists_Call) RunAndReturn(run func(ctx context.Context, photoID string) (bool, error)) *MockDatabase_PhotoExists_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PhotoVariantExists provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) PhotoVariantExists(ctx context.Context, storageKey string) (bool, error) {
+	ret := _mock.Called(ctx, storageKey)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PhotoVariantExists")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, storageKey)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, storageKey)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, storageKey)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_PhotoVariantExists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PhotoVariantExists'
+type MockDatabase_PhotoVariantExists_Call struct {
+	*mock.Call
+}
+
+// PhotoVariantExists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - storageKey string
+func (_e *MockDatabase_Expecter) PhotoVariantExists(ctx interface{}, storageKey interface{}) *MockDatabase_PhotoVariantExists_Call {
+	return &MockDatabase_PhotoVariantExists_Call{Call: _e.mock.On("PhotoVariantExists", ctx, storageKey)}
+}
+
+func (_c *MockDatabase_PhotoVariantExists_Call) Run(run func(ctx context.Context, storageKey string)) *MockDatabase_PhotoVariantExists_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_PhotoVariantExists_Call) Return(b bool, err error) *MockDatabase_PhotoVariantExists_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockDatabase_PhotoVariantExists_Call) RunAndReturn(run func(ctx context.Context, storageKey string) (bool, error)) *MockDatabase_PhotoVariantExists_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgePhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) PurgePhoto(ctx context.Context, photoID string) (int64, int64, error) {
+	ret := _mock.Called(ctx, photoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgePhoto")
+	}
+
+	var r0 int64
+	var r1 int64
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int64, int64, error)); ok {
+		return returnFunc(ctx, photoID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = returnFunc(ctx, photoID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) int64); ok {
+		r1 = returnFunc(ctx, photoID)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = returnFunc(ctx, photoID)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// MockDatabase_PurgePhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgePhoto'
+type MockDatabase_PurgePhoto_Call struct {
+	*mock.Call
+}
+
+// PurgePhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - photoID string
+func (_e *MockDatabase_Expecter) PurgePhoto(ctx interface{}, photoID interface{}) *MockDatabase_PurgePhoto_Call {
+	return &MockDatabase_PurgePhoto_Call{Call: _e.mock.On("PurgePhoto", ctx, photoID)}
+}
+
+func (_c *MockDatabase_PurgePhoto_Call) Run(run func(ctx context.Context, photoID string)) *MockDatabase_PurgePhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_PurgePhoto_Call) Return(likes int64, comments int64, err error) *MockDatabase_PurgePhoto_Call {
+	_c.Call.Return(likes, comments, err)
+	return _c
+}
+
+func (_c *MockDatabase_PurgePhoto_Call) RunAndReturn(run func(ctx context.Context, photoID string) (int64, int64, error)) *MockDatabase_PurgePhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeRawPhoto provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) PurgeRawPhoto(ctx context.Context, rawPhotoID string) error {
+	ret := _mock.Called(ctx, rawPhotoID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeRawPhoto")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, rawPhotoID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockDatabase_PurgeRawPhoto_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeRawPhoto'
+type MockDatabase_PurgeRawPhoto_Call struct {
+	*mock.Call
+}
+
+// PurgeRawPhoto is a helper method to define mock.On call
+//   - ctx context.Context
+//   - rawPhotoID string
+func (_e *MockDatabase_Expecter) PurgeRawPhoto(ctx interface{}, rawPhotoID interface{}) *MockDatabase_PurgeRawPhoto_Call {
+	return &MockDatabase_PurgeRawPhoto_Call{Call: _e.mock.On("PurgeRawPhoto", ctx, rawPhotoID)}
+}
+
+func (_c *MockDatabase_PurgeRawPhoto_Call) Run(run func(ctx context.Context, rawPhotoID string)) *MockDatabase_PurgeRawPhoto_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_PurgeRawPhoto_Call) Return(err error) *MockDatabase_PurgeRawPhoto_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockDatabase_PurgeRawPhoto_Call) RunAndReturn(run func(ctx context.Context, rawPhotoID string) error) *MockDatabase_PurgeRawPhoto_Call {
+	_c.Call.Return(run)
+	return _c
+}
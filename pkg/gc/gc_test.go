@@ -0,0 +1,268 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"jelly/pkg/cdn"
+	"jelly/pkg/model"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+const (
+	testUserID  = "3b8e7d2a-1c4f-4e6a-9b5d-8f7a6c5e4d3b"
+	testPhotoID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	testRawID   = "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f"
+	testUpload  = "0e4b1d7c-5a2f-4c8e-b3d6-9f1a7e2c4b8d"
+	pendingID   = "9a3c5e7b-2d4f-4a6c-8e1b-3d5f7a9c1e2b"
+	testHash    = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	orphanHash  = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+	testBackend = "default"
+)
+
+var (
+	testPhoto = model.Photo{
+		ID:             testPhotoID,
+		StorageBackend: testBackend,
+		Variants: []model.PhotoVariant{
+			{PhotoID: testPhotoID, Name: "thumb", StorageKey: "photos/" + testPhotoID + "/thumb.jpg"},
+			{PhotoID: testPhotoID, Name: "large", StorageKey: "photos/" + testPhotoID + "/large.jpg"},
+		},
+	}
+	testRawPhoto = model.RawPhoto{ID: testRawID, UserID: testUserID, SHA256Hash: testHash, MimeType: "image/jpeg",
+		StorageBackend: testBackend, StorageKey: "raw/" + testUserID + "/" + testHash + ".jpg"}
+)
+
+// listing iterates over the objects, then the error if there is one.
+func listing(objects []store.ObjectInfo, err error) iter.Seq2[store.ObjectInfo, error] {
+	return func(yield func(store.ObjectInfo, error) bool) {
+		for _, object := range objects {
+			if !yield(object, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(store.ObjectInfo{}, err)
+		}
+	}
+}
+
+// setupExpired expects the expired photo, raw photo and upload to be listed.
+func setupExpired(db *MockDatabase) {
+	db.EXPECT().GetExpiredPhotos(mock.Anything, "", batchSize).Return([]model.Photo{testPhoto}, nil)
+	db.EXPECT().GetExpiredRawPhotos(mock.Anything, "", batchSize).Return([]model.RawPhoto{testRawPhoto}, nil)
+	db.EXPECT().GetExpiredUploads(mock.Anything, "", batchSize).
+		Return([]model.Upload{{ID: testUpload, UserID: testUserID}}, nil)
+}
+
+// setupDerived expects the resized images of the expired photo to be listed.
+func setupDerived(storage *store.MockStorage) {
+	storage.EXPECT().List(mock.Anything, "derived/"+testPhotoID+"/").Return(listing([]store.ObjectInfo{
+		{Key: "derived/" + testPhotoID + "/0a1b2c.webp", Size: 50},
+	}, nil))
+}
+
+// setupObjects expects the objects to be listed: an orphaned raw photo, one
+// uploaded too recently to be collected, the staged photos of a pending and
+// the expired upload, a referenced and an orphaned variant, and a referenced
+// and an orphaned resized image.
+func setupObjects(db *MockDatabase, storage *store.MockStorage) {
+	old := time.Now().Add(-72 * time.Hour)
+	storage.EXPECT().List(mock.Anything, "raw/").Return(listing([]store.ObjectInfo{
+		{Key: "raw/" + testUserID + "/" + orphanHash + ".png", Size: 100, LastModified: old},
+		{Key: "raw/" + testUserID + "/" + testHash + ".jpg", Size: 200, LastModified: time.Now()},
+		{Key: "raw/" + testUserID + "/" + pendingID + ".jpg", Size: 300, LastModified: old},
+		{Key: "raw/" + testUserID + "/" + testUpload + ".jpg", Size: 5, LastModified: old},
+	}, nil))
+	storage.EXPECT().List(mock.Anything, "quarantine/").Return(listing(nil, nil))
+	storage.EXPECT().List(mock.Anything, "photos/").Return(listing([]store.ObjectInfo{
+		{Key: "photos/kept/thumb.jpg", Size: 10, LastModified: old},
+		{Key: "photos/orphan/thumb.jpg", Size: 20, LastModified: old},
+		{Key: "photos/readme.txt", Size: 30, LastModified: old},
+	}, nil))
+	storage.EXPECT().List(mock.Anything, "derived/").Return(listing([]store.ObjectInfo{
+		{Key: "derived/kept/0a1b2c.jpg", Size: 40, LastModified: old},
+		{Key: "derived/orphan/0a1b2c.jpg", Size: 80, LastModified: old},
+	}, nil))
+
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, orphanHash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	db.EXPECT().GetUpload(mock.Anything, pendingID).Return(model.Upload{ID: pendingID, Direct: true}, nil)
+	db.EXPECT().GetUpload(mock.Anything, testUpload).Return(model.Upload{}, pgdb.ErrNotFound)
+	db.EXPECT().PhotoVariantExists(mock.Anything, "photos/kept/thumb.jpg").Return(true, nil)
+	db.EXPECT().PhotoVariantExists(mock.Anything, "photos/orphan/thumb.jpg").Return(false, nil)
+	db.EXPECT().PhotoExists(mock.Anything, "kept").Return(true, nil)
+	db.EXPECT().PhotoExists(mock.Anything, "orphan").Return(false, nil)
+}
+
+func TestCollector_Run(t *testing.T) {
+	db := NewMockDatabase(t)
+	storage := store.NewMockStorage(t)
+	setupExpired(db)
+	setupDerived(storage)
+	setupObjects(db, storage)
+
+	db.EXPECT().PurgePhoto(mock.Anything, testPhotoID).Return(2, 1, nil)
+	db.EXPECT().PurgeRawPhoto(mock.Anything, testRawID).Return(nil)
+	db.EXPECT().DeleteUpload(mock.Anything, testUpload).Return(nil)
+	scratch := t.TempDir()
+	if err := os.WriteFile(filepath.Join(scratch, testUpload), []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var deleted []string
+	for _, keys := range [][]string{
+		{"photos/" + testPhotoID + "/thumb.jpg", "photos/" + testPhotoID + "/large.jpg",
+			"derived/" + testPhotoID + "/0a1b2c.webp"},
+		{"raw/" + testUserID + "/" + testHash + ".jpg"},
+		{"raw/" + testUserID + "/" + orphanHash + ".png", "raw/" + testUserID + "/" + testUpload + ".jpg"},
+		{"photos/orphan/thumb.jpg"},
+		{"derived/orphan/0a1b2c.jpg"},
+	} {
+		storage.EXPECT().DeleteMany(mock.Anything, keys).Return(nil).Once()
+		deleted = append(deleted, keys...)
+	}
+
+	fake := cdn.NewFake("https://cdn.example.com", storage)
+	collector := &Collector{
+		DB:          db,
+		Storage:     storage,
+		Backend:     testBackend,
+		CDN:         fake,
+		OrphanAge:   48 * time.Hour,
+		ScratchPath: scratch,
+	}
+	report, err := collector.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Report{Likes: 2, Comments: 1, Photos: 1, RawPhotos: 1, Uploads: 1, Objects: 4, Orphans: 4,
+		OrphanBytes: 205}
+	if report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+	if _, err := os.Stat(filepath.Join(scratch, testUpload)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the scratch file of the expired upload to be removed, got %v", err)
+	}
+	if purged := fake.Purged(); !slices.Equal(purged, deleted) {
+		t.Errorf("Expected the deleted objects %v to be purged, got %v", deleted, purged)
+	}
+}
+
+func TestCollector_Run_DryRun(t *testing.T) {
+	db := NewMockDatabase(t)
+	storage := store.NewMockStorage(t)
+	setupExpired(db)
+	setupDerived(storage)
+	setupObjects(db, storage)
+
+	// Nothing is purged or deleted, the mocks fail on unexpected calls
+	db.EXPECT().CountPhotoLikes(mock.Anything, testPhotoID).Return(2, nil)
+	db.EXPECT().CountPhotoComments(mock.Anything, testPhotoID).Return(1, nil)
+
+	collector := &Collector{DB: db, Storage: storage, Backend: testBackend, OrphanAge: 48 * time.Hour, DryRun: true}
+	report, err := collector.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Report{DryRun: true, Likes: 2, Comments: 1, Photos: 1, RawPhotos: 1, Uploads: 1, Objects: 4,
+		Orphans: 4, OrphanBytes: 205}
+	if report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+}
+
+func TestCollector_Run_Failures(t *testing.T) {
+	db := NewMockDatabase(t)
+	storage := store.NewMockStorage(t)
+	setupExpired(db)
+
+	// A photo that fails to be purged keeps its objects
+	db.EXPECT().PurgePhoto(mock.Anything, testPhotoID).Return(0, 0, errors.New("connection reset"))
+	db.EXPECT().PurgeRawPhoto(mock.Anything, testRawID).Return(nil)
+	rawKey := "raw/" + testUserID + "/" + testHash + ".jpg"
+	storage.EXPECT().DeleteMany(mock.Anything, []string{rawKey}).
+		Return(&store.DeleteError{Errors: map[string]error{rawKey: errors.New("access denied")}})
+	db.EXPECT().DeleteUpload(mock.Anything, testUpload).Return(errors.New("connection reset"))
+
+	// Orphans found before listing fails are still deleted
+	orphanKey := "raw/" + testUserID + "/" + orphanHash + ".png"
+	storage.EXPECT().List(mock.Anything, "raw/").Return(listing([]store.ObjectInfo{
+		{Key: orphanKey, Size: 100, LastModified: time.Now().Add(-72 * time.Hour)},
+	}, errors.New("access denied")))
+	db.EXPECT().GetRawPhotoByHash(mock.Anything, testUserID, orphanHash).Return(model.RawPhoto{}, pgdb.ErrNotFound)
+	storage.EXPECT().DeleteMany(mock.Anything, []string{orphanKey}).Return(errors.New("timeout"))
+
+	fake := cdn.NewFake("https://cdn.example.com", storage)
+	collector := &Collector{DB: db, Storage: storage, Backend: testBackend, CDN: fake, OrphanAge: 48 * time.Hour}
+	report, err := collector.Run(context.Background())
+	if err == nil {
+		t.Fatalf("Expected an error listing objects")
+	}
+
+	expected := Report{RawPhotos: 1, Objects: 1, Orphans: 1, OrphanBytes: 100, Errors: 4}
+	if report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+
+	// Objects that failed to be deleted are still served, so they aren't purged
+	if purged := fake.Purged(); len(purged) != 0 {
+		t.Errorf("Expected nothing to be purged, got %v", purged)
+	}
+}
+
+func TestCollector_Run_OtherBackend(t *testing.T) {
+	db := NewMockDatabase(t)
+	storage := store.NewMockStorage(t)
+	setupExpired(db)
+	db.EXPECT().DeleteUpload(mock.Anything, testUpload).Return(nil)
+	storage.EXPECT().List(mock.Anything, mock.Anything).Return(listing(nil, nil))
+
+	// Rows in another backend are kept with their objects, the mocks fail on
+	// unexpected purges and deletions
+	collector := &Collector{DB: db, Storage: storage, Backend: "mirror", OrphanAge: 48 * time.Hour}
+	report, err := collector.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Report{Uploads: 1, Errors: 2}
+	if report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+}
+
+func TestCollector_Run_Batches(t *testing.T) {
+	db := NewMockDatabase(t)
+	storage := store.NewMockStorage(t)
+
+	// A full batch is followed by a query for the photos after its last one
+	batch := make([]model.Photo, batchSize)
+	for i := range batch {
+		batch[i] = model.Photo{ID: string(rune('a' + i%26))}
+	}
+	batch[batchSize-1].ID = "last"
+	db.EXPECT().GetExpiredPhotos(mock.Anything, "", batchSize).Return(batch, nil)
+	db.EXPECT().GetExpiredPhotos(mock.Anything, "last", batchSize).Return(nil, nil)
+	db.EXPECT().PurgePhoto(mock.Anything, mock.Anything).Return(0, 0, nil)
+	db.EXPECT().GetExpiredRawPhotos(mock.Anything, "", batchSize).Return(nil, nil)
+	db.EXPECT().GetExpiredUploads(mock.Anything, "", batchSize).Return(nil, nil)
+	storage.EXPECT().List(mock.Anything, mock.Anything).Return(listing(nil, nil))
+
+	report, err := (&Collector{DB: db, Storage: storage}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Photos != batchSize {
+		t.Errorf("Expected %d photos, got %d", batchSize, report.Photos)
+	}
+}
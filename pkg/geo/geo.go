@@ -0,0 +1,57 @@
+// Package geo provides the distance and bounding box calculations used to find
+// photos near a location, without requiring a spatial database extension.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the Earth
+const EarthRadiusKm = 6371.0
+
+// Box is a latitude and longitude range in decimal degrees. Both ranges are
+// inclusive.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// ValidCoordinates reports whether a latitude and longitude are in range.
+func ValidCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// Distance returns the great-circle distance in kilometers between two points
+// using the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(min(a, 1)))
+}
+
+// BoundingBox returns a box containing every point within radiusKm of a
+// point. Boxes reaching a pole or crossing the antimeridian span all
+// longitudes, so they can always be queried as a single range.
+func BoundingBox(lat, lon, radiusKm float64) Box {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	box := Box{MinLat: lat - dLat, MaxLat: lat + dLat, MinLon: -180, MaxLon: 180}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat, box.MaxLat = max(box.MinLat, -90), min(box.MaxLat, 90)
+		return box
+	}
+
+	// The longitude range widens with the latitude, as meridians converge
+	dLon := degrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(lat))))
+	if lon-dLon >= -180 && lon+dLon <= 180 {
+		box.MinLon, box.MaxLon = lon-dLon, lon+dLon
+	}
+	return box
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
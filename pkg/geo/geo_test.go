@@ -0,0 +1,52 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// Vancouver to Seattle
+	assert.InDelta(t, 195, Distance(49.2827, -123.1207, 47.6062, -122.3321), 1)
+	assert.Equal(t, 0.0, Distance(49.2827, -123.1207, 49.2827, -123.1207))
+
+	// Across the antimeridian
+	assert.InDelta(t, 22.2, Distance(0, 179.9, 0, -179.9), 0.1)
+}
+
+func TestBoundingBox(t *testing.T) {
+	lat, lon, radius := 49.2827, -123.1207, 10.0
+	box := BoundingBox(lat, lon, radius)
+
+	// Points at the radius in every direction are inside the box
+	for _, p := range [][2]float64{
+		{lat + 0.0899, lon}, {lat - 0.0899, lon},
+		{lat, lon + 0.1378}, {lat, lon - 0.1378},
+	} {
+		assert.InDelta(t, radius, Distance(lat, lon, p[0], p[1]), 0.01)
+		assert.True(t, p[0] >= box.MinLat && p[0] <= box.MaxLat, "latitude %v outside %+v", p[0], box)
+		assert.True(t, p[1] >= box.MinLon && p[1] <= box.MaxLon, "longitude %v outside %+v", p[1], box)
+	}
+	assert.Less(t, box.MaxLon-box.MinLon, 0.3)
+}
+
+func TestBoundingBox_Wrapping(t *testing.T) {
+	// Near a pole
+	box := BoundingBox(89.95, 10, 10)
+	assert.InDelta(t, 89.86, box.MinLat, 0.01)
+	assert.Equal(t, 90.0, box.MaxLat)
+	assert.Equal(t, -180.0, box.MinLon)
+	assert.Equal(t, 180.0, box.MaxLon)
+
+	// Across the antimeridian
+	box = BoundingBox(0, 179.99, 10)
+	assert.Equal(t, -180.0, box.MinLon)
+	assert.Equal(t, 180.0, box.MaxLon)
+}
+
+func TestValidCoordinates(t *testing.T) {
+	assert.True(t, ValidCoordinates(-90, 180))
+	assert.False(t, ValidCoordinates(90.1, 0))
+	assert.False(t, ValidCoordinates(0, -180.1))
+}
@@ -0,0 +1,143 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+
+	"golang.org/x/image/draw"
+)
+
+// DecodeAnimation checks an animated GIF and decodes all of its frames, which
+// together must have no more than the maximum pixels. It returns nil for
+// images that aren't animated GIFs.
+func (d *Decoder) DecodeAnimation(ctx context.Context, data []byte) (*gif.GIF, error) {
+	cfg, format, err := d.Check(data, "")
+	if err != nil {
+		return nil, err
+	}
+	frames, _ := gifFrames(data)
+	if format != FormatGIF || frames < 2 {
+		return nil, nil
+	}
+
+	// Frames are decoded as paletted images of at most the size of the canvas
+	size := int64(frames) * int64(cfg.Width) * int64(cfg.Height)
+	if size > d.maxPixels || size > d.budget {
+		return nil, fmt.Errorf("%w: %d frames of %dx%d", ErrTooManyPixels, frames, cfg.Width, cfg.Height)
+	}
+
+	return within(ctx, d, size, func() (*gif.GIF, error) {
+		return gif.DecodeAll(bytes.NewReader(data))
+	})
+}
+
+// RenderAnimation resizes an animated GIF to the width of the variant and
+// encodes it as an animated GIF. Frames are composited the way they're
+// displayed, so each resized frame is whole.
+func RenderAnimation(g *gif.GIF, v Variant) (Rendition, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	out := &gif.GIF{LoopCount: g.LoopCount}
+
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// Resized frames are dithered back to the palette of the frame
+		resized := Resize(canvas, v.Width, 0)
+		paletted := image.NewPaletted(resized.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), resized, image.Point{})
+		out.Image = append(out.Image, paletted)
+		if i < len(g.Delay) {
+			out.Delay = append(out.Delay, g.Delay[i])
+		} else {
+			out.Delay = append(out.Delay, 0)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	if len(out.Image) == 0 {
+		return Rendition{}, fmt.Errorf("failed to encode variant %s: animation has no frames", v.Name)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return Rendition{}, fmt.Errorf("failed to encode variant %s: %w", v.Name, err)
+	}
+
+	bounds := out.Image[0].Bounds()
+	return Rendition{
+		Variant: v,
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Format:  FormatGIF,
+		Data:    buf.Bytes(),
+	}, nil
+}
+
+// gifFrames counts the frames of a GIF by walking its blocks, and reports
+// whether it ends with the trailer.
+func gifFrames(data []byte) (frames int, trailer bool) {
+	// The header and logical screen descriptor, followed by the global color
+	// table if the descriptor has one
+	if len(data) < 13 {
+		return 0, false
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension, its label and data sub-blocks
+			i = skipSubBlocks(data, i+2)
+		case 0x2c: // Image descriptor, its local color table, LZW code size and data sub-blocks
+			if i+10 > len(data) {
+				return frames, false
+			}
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			frames++
+			i = skipSubBlocks(data, i+1)
+		case 0x3b: // Trailer
+			return frames, true
+		default:
+			return frames, false
+		}
+	}
+
+	return frames, false
+}
+
+// skipSubBlocks returns the offset following the data sub-blocks at offset i,
+// which end with an empty sub-block.
+func skipSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		n := int(data[i])
+		i += 1 + n
+		if n == 0 {
+			break
+		}
+	}
+	return i
+}
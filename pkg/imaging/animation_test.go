@@ -0,0 +1,112 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/webp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/draw"
+)
+
+// encodeGIF encodes the frames as a GIF, animated if there are several.
+func encodeGIF(t testing.TB, frames ...image.Image) []byte {
+	g := &gif.GIF{}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), palette.WebSafe)
+		draw.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min, draw.Src)
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, g))
+	return buf.Bytes()
+}
+
+func encodeWebP(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, webp.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// solid returns an image of a single color.
+func solid(r image.Rectangle, c color.Color) image.Image {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestGIFFrames(t *testing.T) {
+	data := encodeGIF(t, testImage(40, 30), testImage(40, 30), testImage(40, 30))
+	frames, trailer := gifFrames(data)
+	assert.Equal(t, 3, frames)
+	assert.True(t, trailer)
+
+	frames, trailer = gifFrames(data[:len(data)/2])
+	assert.Less(t, frames, 3)
+	assert.False(t, trailer)
+
+	frames, trailer = gifFrames([]byte("GIF89a"))
+	assert.Zero(t, frames)
+	assert.False(t, trailer)
+}
+
+func TestDecoder_DecodeAnimation(t *testing.T) {
+	d := NewDecoder(10000, time.Second, 1<<20)
+
+	g, err := d.DecodeAnimation(context.Background(), encodeGIF(t, testImage(40, 30), testImage(40, 30)))
+	require.NoError(t, err)
+	require.NotNil(t, g)
+	assert.Len(t, g.Image, 2)
+
+	// Stills aren't animations
+	g, err = d.DecodeAnimation(context.Background(), encodeGIF(t, testImage(40, 30)))
+	require.NoError(t, err)
+	assert.Nil(t, g)
+	g, err = d.DecodeAnimation(context.Background(), encodeJPEG(t, testImage(40, 30)))
+	require.NoError(t, err)
+	assert.Nil(t, g)
+
+	// Frames fitting the limit on their own can't exceed it together
+	frame := testImage(80, 80)
+	_, err = d.DecodeAnimation(context.Background(), encodeGIF(t, frame, frame))
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+}
+
+func TestRenderAnimation(t *testing.T) {
+	red := solid(image.Rect(0, 0, 200, 100), color.RGBA{R: 255, A: 255})
+	// The second frame only covers the left half of the canvas
+	blue := solid(image.Rect(0, 0, 100, 100), color.RGBA{B: 255, A: 255})
+
+	g, err := gif.DecodeAll(bytes.NewReader(encodeGIF(t, red, blue)))
+	require.NoError(t, err)
+	g.LoopCount = 3
+
+	rendition, err := RenderAnimation(g, Variant{Name: "animated", Width: 50})
+	require.NoError(t, err)
+	assert.Equal(t, FormatGIF, rendition.Format)
+	assert.Equal(t, 50, rendition.Width)
+	assert.Equal(t, 25, rendition.Height)
+
+	out, err := gif.DecodeAll(bytes.NewReader(rendition.Data))
+	require.NoError(t, err)
+	require.Len(t, out.Image, 2)
+	assert.Equal(t, []int{10, 10}, out.Delay)
+	assert.Equal(t, 3, out.LoopCount)
+
+	// Frames are whole, the second keeps the right half of the first
+	second := out.Image[1]
+	assert.Equal(t, image.Rect(0, 0, 50, 25), second.Bounds())
+	r, _, b, _ := second.At(5, 12).RGBA()
+	assert.True(t, b > r, "expected blue on the left")
+	r, _, b, _ = second.At(45, 12).RGBA()
+	assert.True(t, r > b, "expected red on the right")
+}
@@ -0,0 +1,201 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Errors of images rejected by a Decoder
+var (
+	ErrTooManyPixels  = errors.New("image has too many pixels")
+	ErrFormatMismatch = errors.New("image format doesn't match its extension")
+	ErrTruncated      = errors.New("image is truncated")
+	ErrDecodeTimeout  = errors.New("image took too long to decode")
+	ErrBusy           = errors.New("too many images being decoded")
+)
+
+// extensionFormats maps file extensions to the format of the images named
+// with them
+var extensionFormats = map[string]string{
+	".jpg":  FormatJPEG,
+	".jpeg": FormatJPEG,
+	".jpe":  FormatJPEG,
+	".jfif": FormatJPEG,
+	".png":  FormatPNG,
+	".gif":  FormatGIF,
+	".webp": FormatWebP,
+	".tif":  FormatTIFF,
+	".tiff": FormatTIFF,
+}
+
+// Decoder decodes untrusted images within limits. Images are checked before
+// they are decoded, so they can't claim more pixels than allowed, and the
+// memory of the images being decoded at once is bounded by a budget shared by
+// all decodes.
+type Decoder struct {
+	maxPixels int64
+	timeout   time.Duration
+	budget    int64
+	memory    *semaphore.Weighted
+}
+
+// NewDecoder creates a decoder of images of up to maxPixels pixels, each
+// decoded within the timeout, using up to budget bytes for the images being
+// decoded at once.
+func NewDecoder(maxPixels int64, timeout time.Duration, budget int64) *Decoder {
+	return &Decoder{
+		maxPixels: maxPixels,
+		timeout:   timeout,
+		budget:    budget,
+		memory:    semaphore.NewWeighted(budget),
+	}
+}
+
+// Check validates an image without decoding it: its header must declare no
+// more than the maximum pixels, its format must match the extension of the
+// filename if it has one, and it must not be truncated. It returns the header
+// with the format.
+func (d *Decoder) Check(data []byte, filename string) (image.Config, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return cfg, "", ErrUnsupportedFormat
+	} else if err != nil {
+		return cfg, "", fmt.Errorf("failed to decode image header: %w", err)
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return cfg, format, fmt.Errorf("invalid image dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > d.maxPixels {
+		return cfg, format, fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" && extensionFormats[ext] != format {
+		return cfg, format, fmt.Errorf("%w: %s image named %s", ErrFormatMismatch, format, ext)
+	}
+
+	if !complete(data, format) {
+		return cfg, format, ErrTruncated
+	}
+
+	return cfg, format, nil
+}
+
+// Decode checks an image and decodes it, returning it with its format.
+// ErrDecodeTimeout is returned if it takes longer than the timeout, and
+// ErrBusy if the memory it needs isn't available in time.
+func (d *Decoder) Decode(ctx context.Context, data []byte, filename string) (image.Image, string, error) {
+	cfg, format, err := d.Check(data, filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	size := int64(cfg.Width) * int64(cfg.Height) * bytesPerPixel(cfg.ColorModel)
+	if size > d.budget {
+		return nil, "", fmt.Errorf("%w: %dx%d needs %d bytes", ErrTooManyPixels, cfg.Width, cfg.Height, size)
+	}
+
+	img, err := within(ctx, d, size, func() (image.Image, error) {
+		img, _, err := Decode(data)
+		return img, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// within calls decode with size bytes of the memory budget, within the timeout
+// of the decoder.
+func within[T any](ctx context.Context, d *Decoder, size int64, decode func() (T, error)) (T, error) {
+	var zero T
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	if err := d.memory.Acquire(ctx, size); err != nil {
+		return zero, fmt.Errorf("%w: %w", ErrBusy, err)
+	}
+
+	// Decoding can't be interrupted, so an image taking too long keeps its
+	// memory until it's decoded, when nobody is waiting for it anymore
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer d.memory.Release(size)
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("failed to decode image: %v", r)}
+			}
+		}()
+		value, err := decode()
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, ErrDecodeTimeout
+		}
+		return zero, ctx.Err()
+	}
+}
+
+// complete reports whether an image isn't cut short of the marker or chunk
+// ending its format.
+func complete(data []byte, format string) bool {
+	switch format {
+	case FormatJPEG:
+		// Entropy-coded data can't hold the end of image marker, so any after
+		// the first scan ends the image
+		sos := jpegSegments(data, func(byte, []byte) {})
+		return sos >= 0 && data[sos+1] == markerSOS && bytes.Contains(data[sos:], []byte{0xff, markerEOI})
+	case FormatPNG:
+		var end bool
+		pngChunks(data, func(typ string, _ []byte) {
+			end = end || typ == "IEND"
+		})
+		return end
+	case FormatGIF:
+		_, trailer := gifFrames(data)
+		return trailer
+	case FormatWebP:
+		// The RIFF header holds the size of the rest of the file
+		return len(data) >= 8 && int64(binary.LittleEndian.Uint32(data[4:8]))+8 <= int64(len(data))
+	default:
+		return true
+	}
+}
+
+// bytesPerPixel estimates the memory of a pixel of an image decoded in the
+// color model.
+func bytesPerPixel(model color.Model) int64 {
+	if _, ok := model.(color.Palette); ok {
+		return 1
+	}
+	switch model {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	default:
+		return 4
+	}
+}
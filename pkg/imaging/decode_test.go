@@ -0,0 +1,190 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/tiff"
+)
+
+func encodePNG(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// pngWithSize rewrites the dimensions declared by the header of a PNG image.
+func pngWithSize(t testing.TB, w, h uint32) []byte {
+	data := encodePNG(t, testImage(8, 8))
+	ihdr := data[len(pngHeader)+4 : len(pngHeader)+8+13]
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	binary.BigEndian.PutUint32(data[len(pngHeader)+8+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func encodeTIFF(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, tiff.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestDecoder_Check(t *testing.T) {
+	jpegData := encodeJPEG(t, testImage(40, 30))
+	pngData := encodePNG(t, testImage(40, 30))
+	gifData := encodeGIF(t, testImage(40, 30), testImage(40, 30))
+	webpData := encodeWebP(t, testImage(40, 30))
+	d := NewDecoder(10000, time.Second, 1<<20)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+		format   string
+		wantErr  error
+	}{
+		{name: "jpeg", data: jpegData, filename: "photo.jpg", format: FormatJPEG},
+		{name: "jpeg extension case", data: jpegData, filename: "photo.JPEG", format: FormatJPEG},
+		{name: "png", data: pngData, filename: "photo.png", format: FormatPNG},
+		{name: "no extension", data: pngData, filename: "photo", format: FormatPNG},
+		{name: "jpeg named png", data: jpegData, filename: "photo.png", wantErr: ErrFormatMismatch},
+		{name: "png named jpeg", data: pngData, filename: "photo.jpg", wantErr: ErrFormatMismatch},
+		{name: "gif", data: gifData, filename: "photo.gif", format: FormatGIF},
+		{name: "webp", data: webpData, filename: "photo.webp", format: FormatWebP},
+		{name: "tiff", data: encodeTIFF(t, testImage(40, 30)), filename: "photo.tiff", format: FormatTIFF},
+		{name: "gif named jpeg", data: gifData, filename: "photo.jpg", wantErr: ErrFormatMismatch},
+		{name: "unknown extension", data: jpegData, filename: "photo.heic", wantErr: ErrFormatMismatch},
+		{name: "too many pixels", data: encodeJPEG(t, testImage(101, 100)), filename: "photo.jpg",
+			wantErr: ErrTooManyPixels},
+		{name: "declared pixels", data: pngWithSize(t, 100000, 100000), filename: "bomb.png",
+			wantErr: ErrTooManyPixels},
+		{name: "jpeg without end", data: jpegData[:len(jpegData)-2], filename: "photo.jpg",
+			wantErr: ErrTruncated},
+		{name: "jpeg cut in scan", data: jpegData[:len(jpegData)-20], filename: "photo.jpg",
+			wantErr: ErrTruncated},
+		{name: "png without end", data: pngData[:len(pngData)-12], filename: "photo.png",
+			wantErr: ErrTruncated},
+		{name: "gif without trailer", data: gifData[:len(gifData)-1], filename: "photo.gif",
+			wantErr: ErrTruncated},
+		{name: "webp cut short", data: webpData[:len(webpData)-10], filename: "photo.webp",
+			wantErr: ErrTruncated},
+		{name: "not an image", data: []byte("not an image"), filename: "photo.jpg",
+			wantErr: ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, format, err := d.Check(tt.data, tt.filename)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+			assert.Equal(t, 40, cfg.Width)
+			assert.Equal(t, 30, cfg.Height)
+		})
+	}
+
+	// Headers that can't be read are rejected
+	_, _, err := d.Check(jpegData[:100], "photo.jpg")
+	assert.Error(t, err)
+	_, _, err = d.Check(pngWithSize(t, 0, 10), "photo.png")
+	assert.Error(t, err)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	d := NewDecoder(10000, time.Second, 1<<20)
+
+	img, format, err := d.Decode(context.Background(), encodeJPEG(t, testImage(40, 30)), "photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJPEG, format)
+	assert.Equal(t, image.Rect(0, 0, 40, 30), img.Bounds())
+
+	_, _, err = d.Decode(context.Background(), pngWithSize(t, 100000, 100000), "bomb.png")
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+
+	// The memory is released once decoded
+	assert.True(t, d.memory.TryAcquire(1<<20))
+}
+
+func TestDecoder_Memory(t *testing.T) {
+	data := encodePNG(t, testImage(40, 30))
+
+	// Images needing more than the whole budget are never decoded
+	_, _, err := NewDecoder(10000, time.Second, 40*30*4-1).Decode(context.Background(), data, "photo.png")
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+
+	// Images wait for the memory of images being decoded
+	d := NewDecoder(10000, 50*time.Millisecond, 40*30*4)
+	require.True(t, d.memory.TryAcquire(1))
+	_, _, err = d.Decode(context.Background(), data, "photo.png")
+	assert.ErrorIs(t, err, ErrBusy)
+
+	d.memory.Release(1)
+	_, _, err = d.Decode(context.Background(), data, "photo.png")
+	assert.NoError(t, err)
+}
+
+func TestDecoder_Timeout(t *testing.T) {
+	data := encodePNG(t, blobImage(1, 1500, 1500))
+	d := NewDecoder(1500*1500, time.Millisecond, 1500*1500*4)
+
+	_, _, err := d.Decode(context.Background(), data, "photo.png")
+	assert.ErrorIs(t, err, ErrDecodeTimeout)
+
+	// The image keeps its memory until it's decoded
+	assert.Eventually(t, func() bool {
+		if !d.memory.TryAcquire(1500 * 1500 * 4) {
+			return false
+		}
+		d.memory.Release(1500 * 1500 * 4)
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// fuzzSeeds adds valid, truncated and corrupted images to a fuzz test.
+func fuzzSeeds(f *testing.F) {
+	jpegData := encodeJPEG(f, testImage(16, 16))
+	pngData := encodePNG(f, testImage(16, 16))
+	exifData := withExif(jpegData, testExif(binary.BigEndian))
+	for _, data := range [][]byte{jpegData, pngData, exifData} {
+		f.Add(data)
+		f.Add(data[:len(data)/2])
+		corrupted := bytes.Clone(data)
+		corrupted[len(corrupted)/3] ^= 0xff
+		f.Add(corrupted)
+	}
+	f.Add(pngWithSize(f, 1<<31-1, 1<<31-1))
+	f.Add([]byte{})
+}
+
+func FuzzDecode(f *testing.F) {
+	fuzzSeeds(f)
+	d := NewDecoder(1<<16, 10*time.Second, 1<<20)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, format, err := d.Decode(context.Background(), data, "")
+		if err != nil {
+			return
+		}
+		assert.Contains(t, []string{FormatJPEG, FormatPNG}, format)
+		assert.LessOrEqual(t, img.Bounds().Dx()*img.Bounds().Dy(), 1<<16)
+	})
+}
+
+func FuzzReadMetadata(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ReadExif(data)
+		_ = ReadMetadata(data)
+	})
+}
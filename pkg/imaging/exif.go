@@ -0,0 +1,360 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Exif holds the EXIF tags of a photo by name. GPS coordinates and altitude
+// are decimal, negative south, west and below sea level.
+type Exif map[string]any
+
+// ErrInvalidExif is returned for EXIF data that can't be parsed
+var ErrInvalidExif = errors.New("invalid exif data")
+
+// Pointers from IFD0 to the sub-IFDs holding the Exif and GPS tags
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+)
+
+// exifTags names the tags of IFD0 and the Exif IFD that are kept. Other tags,
+// such as maker notes and thumbnails, are dropped.
+var exifTags = map[uint16]string{
+	0x010f: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x011a: "XResolution",
+	0x011b: "YResolution",
+	0x0128: "ResolutionUnit",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013b: "Artist",
+	0x8298: "Copyright",
+	0x829a: "ExposureTime",
+	0x829d: "FNumber",
+	0x8822: "ExposureProgram",
+	0x8827: "ISOSpeedRatings",
+	0x9000: "ExifVersion",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9010: "OffsetTime",
+	0x9011: "OffsetTimeOriginal",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9204: "ExposureBiasValue",
+	0x9207: "MeteringMode",
+	0x9209: "Flash",
+	0x920a: "FocalLength",
+	0xa001: "ColorSpace",
+	0xa002: "PixelXDimension",
+	0xa003: "PixelYDimension",
+	0xa405: "FocalLengthIn35mmFilm",
+	0xa430: "CameraOwnerName",
+	0xa431: "BodySerialNumber",
+	0xa432: "LensSpecification",
+	0xa433: "LensMake",
+	0xa434: "LensModel",
+	0xa435: "LensSerialNumber",
+}
+
+// gpsTags names the tags of the GPS IFD that are kept
+var gpsTags = map[uint16]string{
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x0010: "GPSImgDirectionRef",
+	0x0011: "GPSImgDirection",
+	0x001d: "GPSDateStamp",
+}
+
+// sensitiveTags identify the photographer or their equipment, in addition to
+// the GPS tags locating them
+var sensitiveTags = map[string]bool{
+	"Artist":           true,
+	"CameraOwnerName":  true,
+	"BodySerialNumber": true,
+	"LensSerialNumber": true,
+}
+
+// IsSensitiveTag reports whether a tag locates or identifies the photographer,
+// and should only be shown to them.
+func IsSensitiveTag(name string) bool {
+	return strings.HasPrefix(name, "GPS") || sensitiveTags[name]
+}
+
+// Public returns the tags that aren't sensitive.
+func (e Exif) Public() Exif {
+	public := Exif{}
+	for name, value := range e {
+		if !IsSensitiveTag(name) {
+			public[name] = value
+		}
+	}
+	return public
+}
+
+// StripLocation removes the GPS tags.
+func (e Exif) StripLocation() {
+	for name := range e {
+		if strings.HasPrefix(name, "GPS") {
+			delete(e, name)
+		}
+	}
+}
+
+// RoundLocation rounds the GPS coordinates to the decimal places, and removes
+// the other GPS tags since they could narrow the location down again.
+func (e Exif) RoundLocation(decimals int) {
+	lat, hasLat := e["GPSLatitude"].(float64)
+	lon, hasLon := e["GPSLongitude"].(float64)
+	e.StripLocation()
+
+	if hasLat && hasLon {
+		scale := math.Pow10(decimals)
+		e["GPSLatitude"] = math.Round(lat*scale) / scale
+		e["GPSLongitude"] = math.Round(lon*scale) / scale
+	}
+}
+
+// Location returns the GPS coordinates in decimal degrees, or false if unset
+// or out of range.
+func (e Exif) Location() (lat, lon float64, ok bool) {
+	lat, hasLat := e["GPSLatitude"].(float64)
+	lon, hasLon := e["GPSLongitude"].(float64)
+	if !hasLat || !hasLon || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// Orientation returns the EXIF orientation, or 0 if unset.
+func (e Exif) Orientation() int {
+	orientation, _ := e["Orientation"].(int)
+	return orientation
+}
+
+// ReadExif reads the EXIF tags of a JPEG or PNG image. A nil Exif is returned
+// for images without EXIF data.
+func ReadExif(data []byte) (Exif, error) {
+	tiff := exifData(data)
+	if tiff == nil {
+		return nil, nil
+	}
+	return parseExif(tiff)
+}
+
+// exifData returns the TIFF structure holding the EXIF data of a JPEG or PNG
+// image, or nil if it has none.
+func exifData(data []byte) []byte {
+	var tiff []byte
+	switch {
+	case isJPEG(data):
+		jpegSegments(data, func(marker byte, payload []byte) {
+			if marker == markerAPP1 && tiff == nil && bytes.HasPrefix(payload, exifHeader) {
+				tiff = payload[len(exifHeader):]
+			}
+		})
+	case isPNG(data):
+		pngChunks(data, func(typ string, payload []byte) {
+			if typ == "eXIf" && tiff == nil {
+				tiff = payload
+			}
+		})
+	}
+	return tiff
+}
+
+// parseExif parses the TIFF structure of EXIF data, following the pointers to
+// the Exif and GPS IFDs.
+func parseExif(tiff []byte) (Exif, error) {
+	if len(tiff) < 8 {
+		return nil, ErrInvalidExif
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, ErrInvalidExif
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, ErrInvalidExif
+	}
+
+	p := exifParser{tiff: tiff, order: order}
+	e := Exif{}
+	pointers, err := p.readIFD(order.Uint32(tiff[4:]), exifTags, e)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset, ok := pointers[tagExifIFD]; ok {
+		if _, err := p.readIFD(offset, exifTags, e); err != nil {
+			return nil, err
+		}
+	}
+	if offset, ok := pointers[tagGPSIFD]; ok {
+		if _, err := p.readIFD(offset, gpsTags, e); err != nil {
+			return nil, err
+		}
+		normalizeGPS(e)
+	}
+
+	return e, nil
+}
+
+// exifParser reads IFDs from the TIFF structure of EXIF data
+type exifParser struct {
+	tiff  []byte
+	order binary.ByteOrder
+}
+
+// typeSizes are the sizes in bytes of the TIFF field types
+var typeSizes = map[uint16]uint64{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	7:  1, // UNDEFINED
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+}
+
+// readIFD reads the named tags of the IFD at the offset into e, and returns
+// the offsets of the sub-IFDs it points to.
+func (p exifParser) readIFD(offset uint32, names map[uint16]string, e Exif) (map[uint16]uint32, error) {
+	if uint64(offset)+2 > uint64(len(p.tiff)) {
+		return nil, ErrInvalidExif
+	}
+	count := uint64(p.order.Uint16(p.tiff[offset:]))
+	start := uint64(offset) + 2
+	if start+count*12 > uint64(len(p.tiff)) {
+		return nil, ErrInvalidExif
+	}
+
+	pointers := map[uint16]uint32{}
+	for i := uint64(0); i < count; i++ {
+		entry := p.tiff[start+i*12 : start+i*12+12]
+		tag := p.order.Uint16(entry)
+		typ := p.order.Uint16(entry[2:])
+		n := uint64(p.order.Uint32(entry[4:]))
+
+		if tag == tagExifIFD || tag == tagGPSIFD {
+			pointers[tag] = p.order.Uint32(entry[8:])
+			continue
+		}
+
+		name, ok := names[tag]
+		size, known := typeSizes[typ]
+		if !ok || !known || n == 0 {
+			continue
+		}
+
+		// Values of up to 4 bytes are stored in the entry itself
+		value := entry[8:12]
+		if size*n > 4 {
+			at := uint64(p.order.Uint32(entry[8:]))
+			if at+size*n > uint64(len(p.tiff)) {
+				continue
+			}
+			value = p.tiff[at : at+size*n]
+		}
+
+		if v := p.decode(typ, n, value); v != nil {
+			e[name] = v
+		}
+	}
+
+	return pointers, nil
+}
+
+// decode decodes n values of a TIFF field type. Single values are returned as
+// is, multiple values as a slice.
+func (p exifParser) decode(typ uint16, n uint64, value []byte) any {
+	switch typ {
+	case 2, 7:
+		s := strings.TrimSpace(strings.TrimRight(string(value[:n]), "\x00"))
+		if s == "" || strings.ContainsFunc(s, func(r rune) bool { return r < 0x20 || r > 0x7e }) {
+			return nil
+		}
+		return s
+	case 1, 3, 4, 9:
+		ints := make([]int, n)
+		for i := range ints {
+			switch typ {
+			case 1:
+				ints[i] = int(value[i])
+			case 3:
+				ints[i] = int(p.order.Uint16(value[i*2:]))
+			case 4:
+				ints[i] = int(p.order.Uint32(value[i*4:]))
+			case 9:
+				ints[i] = int(int32(p.order.Uint32(value[i*4:])))
+			}
+		}
+		if n == 1 {
+			return ints[0]
+		}
+		return ints
+	case 5, 10:
+		floats := make([]float64, n)
+		for i := range floats {
+			num, den := p.order.Uint32(value[i*8:]), p.order.Uint32(value[i*8+4:])
+			if den == 0 {
+				return nil
+			}
+			if typ == 10 {
+				floats[i] = float64(int32(num)) / float64(int32(den))
+			} else {
+				floats[i] = float64(num) / float64(den)
+			}
+		}
+		if n == 1 {
+			return floats[0]
+		}
+		return floats
+	}
+	return nil
+}
+
+// normalizeGPS converts the GPS coordinates from degrees, minutes and seconds
+// with a reference to signed decimal degrees, and signs the altitude.
+func normalizeGPS(e Exif) {
+	for _, axis := range []struct{ name, negative string }{
+		{"GPSLatitude", "S"},
+		{"GPSLongitude", "W"},
+	} {
+		dms, ok := e[axis.name].([]float64)
+		ref, _ := e[axis.name+"Ref"].(string)
+		delete(e, axis.name+"Ref")
+		if !ok || len(dms) != 3 {
+			delete(e, axis.name)
+			continue
+		}
+
+		degrees := dms[0] + dms[1]/60 + dms[2]/3600
+		if strings.EqualFold(ref, axis.negative) {
+			degrees = -degrees
+		}
+		e[axis.name] = degrees
+	}
+
+	if ref, _ := e["GPSAltitudeRef"].(int); ref == 1 {
+		if altitude, ok := e["GPSAltitude"].(float64); ok {
+			e["GPSAltitude"] = -altitude
+		}
+	}
+	delete(e, "GPSAltitudeRef")
+}
@@ -0,0 +1,217 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testByteOrder reads and appends in a byte order
+type testByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// testEntry is an IFD entry of test EXIF data. Entries with a sub-IFD point to
+// another IFD by its index.
+type testEntry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	value  []byte
+	subIFD int
+}
+
+// buildTIFF lays out IFDs one after another, each followed by the values that
+// don't fit in its entries.
+func buildTIFF(order testByteOrder, ifds ...[]testEntry) []byte {
+	offsets := make([]uint32, len(ifds))
+	offset := uint32(8)
+	for i, ifd := range ifds {
+		offsets[i] = offset
+		offset += 2 + 12*uint32(len(ifd)) + 4
+		for _, e := range ifd {
+			if len(e.value) > 4 {
+				offset += uint32(len(e.value))
+			}
+		}
+	}
+
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+
+	for i, ifd := range ifds {
+		data := offsets[i] + 2 + 12*uint32(len(ifd)) + 4
+		var values []byte
+		tiff = order.AppendUint16(tiff, uint16(len(ifd)))
+		for _, e := range ifd {
+			tiff = order.AppendUint16(tiff, e.tag)
+			if e.subIFD > 0 {
+				tiff = order.AppendUint16(tiff, 4)
+				tiff = order.AppendUint32(tiff, 1)
+				tiff = order.AppendUint32(tiff, offsets[e.subIFD])
+				continue
+			}
+			tiff = order.AppendUint16(tiff, e.typ)
+			tiff = order.AppendUint32(tiff, e.count)
+			if len(e.value) > 4 {
+				tiff = order.AppendUint32(tiff, data+uint32(len(values)))
+				values = append(values, e.value...)
+			} else {
+				tiff = append(tiff, append(e.value, make([]byte, 4-len(e.value))...)...)
+			}
+		}
+		tiff = order.AppendUint32(tiff, 0)
+		tiff = append(tiff, values...)
+	}
+
+	return tiff
+}
+
+func rationals(order testByteOrder, values ...uint32) []byte {
+	var b []byte
+	for i := 0; i+1 < len(values); i += 2 {
+		b = order.AppendUint32(b, values[i])
+		b = order.AppendUint32(b, values[i+1])
+	}
+	return b
+}
+
+// testExif returns EXIF data of a photo taken in Vancouver.
+func testExif(order testByteOrder) []byte {
+	return buildTIFF(order,
+		[]testEntry{
+			{tag: 0x010f, typ: 2, count: 6, value: []byte("Canon\x00")},
+			{tag: 0x0112, typ: 3, count: 1, value: order.AppendUint16(nil, 6)},
+			{tag: tagExifIFD, subIFD: 1},
+			{tag: tagGPSIFD, subIFD: 2},
+			{tag: 0x927c, typ: 7, count: 8, value: []byte("makernot")}, // Unnamed tags are dropped
+		},
+		[]testEntry{
+			{tag: 0x829d, typ: 5, count: 1, value: rationals(order, 18, 10)},
+			{tag: 0x8827, typ: 3, count: 1, value: order.AppendUint16(nil, 100)},
+			{tag: 0xa431, typ: 2, count: 7, value: []byte("123456\x00")},
+		},
+		[]testEntry{
+			{tag: 0x0001, typ: 2, count: 2, value: []byte("N\x00")},
+			{tag: 0x0002, typ: 5, count: 3, value: rationals(order, 49, 1, 15, 1, 36, 1)},
+			{tag: 0x0003, typ: 2, count: 2, value: []byte("W\x00")},
+			{tag: 0x0004, typ: 5, count: 3, value: rationals(order, 123, 1, 6, 1, 0, 1)},
+			{tag: 0x0005, typ: 1, count: 1, value: []byte{0}},
+			{tag: 0x0006, typ: 5, count: 1, value: rationals(order, 70, 1)},
+		},
+	)
+}
+
+// withExif inserts an APP1 segment holding the EXIF data into a JPEG image.
+func withExif(jpegData, tiff []byte) []byte {
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+	segment := append([]byte{0xff, markerAPP1}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+	segment = append(segment, payload...)
+	return append(append(append([]byte{}, jpegData[:2]...), segment...), jpegData[2:]...)
+}
+
+func TestReadExif(t *testing.T) {
+	for _, order := range []testByteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			e, err := ReadExif(withExif(encodeJPEG(t, testImage(8, 8)), testExif(order)))
+			require.NoError(t, err)
+
+			assert.Equal(t, "Canon", e["Make"])
+			assert.Equal(t, 6, e.Orientation())
+			assert.Equal(t, 1.8, e["FNumber"])
+			assert.Equal(t, 100, e["ISOSpeedRatings"])
+			assert.Equal(t, "123456", e["BodySerialNumber"])
+			assert.InDelta(t, 49.26, e["GPSLatitude"], 1e-9)
+			assert.InDelta(t, -123.1, e["GPSLongitude"], 1e-9)
+			assert.Equal(t, 70.0, e["GPSAltitude"])
+			lat, lon, ok := e.Location()
+			assert.True(t, ok)
+			assert.InDelta(t, 49.26, lat, 1e-9)
+			assert.InDelta(t, -123.1, lon, 1e-9)
+			assert.NotContains(t, e, "GPSLatitudeRef")
+			assert.Len(t, e, 8)
+		})
+	}
+}
+
+func TestReadExif_Missing(t *testing.T) {
+	e, err := ReadExif(encodeJPEG(t, testImage(8, 8)))
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	e, err = ReadExif([]byte("not an image"))
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestReadExif_Invalid(t *testing.T) {
+	tiff := testExif(binary.BigEndian)
+	img := encodeJPEG(t, testImage(8, 8))
+
+	_, err := ReadExif(withExif(img, []byte("XX\x00\x2a\x00\x00\x00\x08")))
+	assert.ErrorIs(t, err, ErrInvalidExif)
+
+	// Truncated data can't be read past its end
+	for _, n := range []int{4, 10, 40, len(tiff) - 1} {
+		assert.NotPanics(t, func() { _, _ = ReadExif(withExif(img, tiff[:n])) })
+	}
+}
+
+func TestExif_Privacy(t *testing.T) {
+	e, err := parseExif(testExif(binary.BigEndian))
+	require.NoError(t, err)
+
+	public := e.Public()
+	assert.Equal(t, Exif{"Make": "Canon", "Orientation": 6, "FNumber": 1.8, "ISOSpeedRatings": 100}, public)
+
+	rounded, err := parseExif(testExif(binary.BigEndian))
+	require.NoError(t, err)
+	rounded.RoundLocation(1)
+	assert.Equal(t, 49.3, rounded["GPSLatitude"])
+	assert.Equal(t, -123.1, rounded["GPSLongitude"])
+	assert.NotContains(t, rounded, "GPSAltitude")
+
+	e.StripLocation()
+	_, _, ok := e.Location()
+	assert.False(t, ok)
+	assert.Equal(t, Exif{"Make": "Canon", "Orientation": 6, "FNumber": 1.8, "ISOSpeedRatings": 100,
+		"BodySerialNumber": "123456"}, e)
+}
+
+func TestEncode_Metadata(t *testing.T) {
+	profile := bytes.Repeat([]byte("icc profile "), 10000) // Split across segments
+	meta := Metadata{Orientation: 6, ICCProfile: profile}
+	src := withExif(encodeJPEG(t, testImage(8, 8)), testExif(binary.BigEndian))
+
+	for _, format := range []string{FormatJPEG, FormatPNG} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, testImage(8, 8), format, meta))
+
+			_, decoded, err := Decode(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, format, decoded)
+			assert.Equal(t, meta, ReadMetadata(buf.Bytes()))
+
+			// Nothing but the orientation is kept of the EXIF data
+			e, err := ReadExif(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, Exif{"Orientation": 6}, e)
+		})
+	}
+
+	assert.Equal(t, Metadata{Orientation: 6}, ReadMetadata(src))
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(8, 8)))
+	assert.Equal(t, Metadata{}, ReadMetadata(buf.Bytes()))
+}
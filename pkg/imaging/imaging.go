@@ -0,0 +1,279 @@
+// Package imaging decodes photos and renders the resized variants served to
+// clients.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/webp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff" // Registers the TIFF decoder
+)
+
+// Formats of decoded and encoded images, as named by the image package
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+	FormatGIF  = "gif"
+	FormatTIFF = "tiff"
+)
+
+// extensions maps the formats of images to their file extension
+var extensions = map[string]string{
+	FormatJPEG: ".jpg",
+	FormatPNG:  ".png",
+	FormatWebP: ".webp",
+	FormatAVIF: ".avif",
+	FormatGIF:  ".gif",
+	FormatTIFF: ".tif",
+}
+
+// DefaultQuality is the quality lossy formats are encoded with unless
+// requested otherwise
+const DefaultQuality = 85
+
+// avifSpeed trades AVIF compression for encoding speed, from 0 to 10. The
+// encoder is slow enough at the fastest speed.
+const avifSpeed = 10
+
+// ErrUnsupportedFormat is returned for images that can't be decoded or encoded
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// Decode decodes an image and returns it with its format.
+func Decode(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, "", ErrUnsupportedFormat
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// Encode encodes an image in the format with the metadata.
+func Encode(w io.Writer, img image.Image, format string, meta Metadata) error {
+	return EncodeQuality(w, img, format, DefaultQuality, meta)
+}
+
+// EncodeQuality encodes an image in the format with the metadata, with the
+// given quality from 1 to 100 if the format is lossy. Formats that can't carry
+// the metadata are encoded with the orientation applied to the pixels and
+// without the color profile.
+func EncodeQuality(w io.Writer, img image.Image, format string, quality int, meta Metadata) error {
+	if !carriesMetadata(format) {
+		img = Orient(img, meta.Orientation)
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatJPEG:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return err
+		}
+	case FormatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+	case FormatWebP:
+		if err := webp.Encode(&buf, img, webp.Options{Quality: quality}); err != nil {
+			return err
+		}
+	case FormatAVIF:
+		if err := avif.Encode(&buf, img, avif.Options{Quality: quality, Speed: avifSpeed}); err != nil {
+			return err
+		}
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	_, err := w.Write(writeMetadata(buf.Bytes(), format, meta))
+	return err
+}
+
+// Encodable reports whether images can be encoded in the format.
+func Encodable(format string) bool {
+	switch format {
+	case FormatJPEG, FormatPNG, FormatWebP, FormatAVIF:
+		return true
+	default:
+		return false
+	}
+}
+
+// Decodable reports whether uploaded images of the format can be decoded.
+func Decodable(format string) bool {
+	switch format {
+	case FormatJPEG, FormatPNG, FormatWebP, FormatGIF, FormatTIFF:
+		return true
+	default:
+		return false
+	}
+}
+
+// OutputFormat returns the format images decoded in the format are encoded in
+// unless requested otherwise: their own if it can be encoded, PNG for GIF to
+// keep its sharp edges and transparency, and JPEG otherwise.
+func OutputFormat(format string) string {
+	switch {
+	case Encodable(format):
+		return format
+	case format == FormatGIF:
+		return FormatPNG
+	default:
+		return FormatJPEG
+	}
+}
+
+// Lossy reports whether the format is encoded with a quality.
+func Lossy(format string) bool {
+	return format == FormatJPEG || format == FormatWebP || format == FormatAVIF
+}
+
+// carriesMetadata reports whether the orientation and color profile are
+// written to images of the format.
+func carriesMetadata(format string) bool {
+	return format == FormatJPEG || format == FormatPNG
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Extension returns the file extension of a format, e.g. ".jpg".
+func Extension(format string) string {
+	return extensions[format]
+}
+
+// ParseFormats parses comma separated formats, e.g. "webp,avif".
+func ParseFormats(s string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(s, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" {
+			continue
+		}
+		if !Encodable(format) {
+			return nil, fmt.Errorf("invalid format %q: %w", format, ErrUnsupportedFormat)
+		}
+		if slices.Contains(formats, format) {
+			return nil, fmt.Errorf("duplicate format %q", format)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// Resize scales an image to fit within width and height, keeping its aspect
+// ratio. A zero height only bounds the width. Images are never enlarged.
+func Resize(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if width > 0 && w > width {
+		scale = float64(width) / float64(w)
+	}
+	if height > 0 && h > height {
+		scale = min(scale, float64(height)/float64(h))
+	}
+	if scale == 1 {
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Fill scales and crops an image to cover width by height, cropping the
+// center. Images smaller than the area are cropped to its aspect ratio
+// without being enlarged.
+func Fill(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Crop the source to the aspect ratio of the area
+	crop := bounds
+	if w*height > h*width {
+		cw := h * width / height
+		crop.Min.X += (w - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		ch := w * height / width
+		crop.Min.Y += (h - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+
+	dw, dh := width, height
+	if crop.Dx() < width {
+		dw, dh = crop.Dx(), crop.Dy()
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, dw), max(1, dh)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+// Orient applies an EXIF orientation to an image, so it displays upright
+// without the orientation. Images are returned as is for orientation 1 or
+// unknown orientations.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src, ok := img.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+
+	// Orientations 5 to 8 transpose the image
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// The source pixel displayed at x, y
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Rotated 90° clockwise to display
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotated 90° counterclockwise to display
+				sx, sy = w-1-y, x
+			}
+			di, si := dst.PixOffset(x, y), src.PixOffset(sx, sy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
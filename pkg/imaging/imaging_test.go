@@ -0,0 +1,230 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img, format, err := Decode(encodeJPEG(t, testImage(40, 30)))
+	require.NoError(t, err)
+	assert.Equal(t, FormatJPEG, format)
+	assert.Equal(t, image.Rect(0, 0, 40, 30), img.Bounds())
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(4, 4)))
+	_, format, err = Decode(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+
+	_, _, err = Decode([]byte("not an image"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	// A truncated image is corrupt rather than unsupported
+	_, _, err = Decode(encodeJPEG(t, testImage(40, 30))[:100])
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		want          image.Rectangle
+	}{
+		{name: "width", width: 200, want: image.Rect(0, 0, 200, 100)},
+		{name: "width and height", width: 200, height: 50, want: image.Rect(0, 0, 100, 50)},
+		{name: "not enlarged", width: 1000, want: image.Rect(0, 0, 400, 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resize(testImage(400, 200), tt.width, tt.height)
+			assert.Equal(t, tt.want, got.Bounds())
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name          string
+		src           image.Image
+		width, height int
+		want          image.Rectangle
+	}{
+		{name: "landscape", src: testImage(400, 200), width: 150, height: 150, want: image.Rect(0, 0, 150, 150)},
+		{name: "portrait", src: testImage(200, 400), width: 150, height: 150, want: image.Rect(0, 0, 150, 150)},
+		{name: "wide area", src: testImage(200, 200), width: 100, height: 50, want: image.Rect(0, 0, 100, 50)},
+		{name: "not enlarged", src: testImage(100, 50), width: 150, height: 150, want: image.Rect(0, 0, 50, 50)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Fill(tt.src, tt.width, tt.height).Bounds())
+		})
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("thumb=150x150, small=320,medium=640:png")
+	require.NoError(t, err)
+	assert.Equal(t, []Variant{
+		{Name: "thumb", Width: 150, Height: 150},
+		{Name: "small", Width: 320},
+		{Name: "medium", Width: 640, Format: FormatPNG},
+	}, variants)
+
+	for _, v := range variants {
+		parsed, err := ParseVariants(v.String())
+		require.NoError(t, err)
+		assert.Equal(t, []Variant{v}, parsed)
+	}
+
+	for _, invalid := range []string{"thumb", "thumb=0", "thumb=150x", "Thumb=150", "a=1,a=2", "a=1:gif"} {
+		_, err := ParseVariants(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRender(t *testing.T) {
+	variants := []Variant{
+		{Name: "thumb", Width: 150, Height: 150},
+		{Name: "small", Width: 320},
+		{Name: "large", Width: 1080, Format: FormatPNG},
+	}
+
+	renditions, err := Render(testImage(640, 480), FormatJPEG, Metadata{}, variants, nil)
+	require.NoError(t, err)
+	require.Len(t, renditions, 3)
+
+	want := []struct {
+		width, height int
+		format        string
+	}{
+		{150, 150, FormatJPEG},
+		{320, 240, FormatJPEG},
+		{640, 480, FormatPNG},
+	}
+	for i, r := range renditions {
+		assert.Equal(t, variants[i], r.Variant)
+		assert.Equal(t, want[i].width, r.Width)
+		assert.Equal(t, want[i].height, r.Height)
+		assert.Equal(t, want[i].format, r.Format)
+
+		img, format, err := Decode(r.Data)
+		require.NoError(t, err)
+		assert.Equal(t, want[i].format, format)
+		assert.Equal(t, image.Rect(0, 0, want[i].width, want[i].height), img.Bounds())
+	}
+}
+
+func TestRender_Formats(t *testing.T) {
+	variants := []Variant{
+		{Name: "thumb", Width: 150, Height: 100},
+		{Name: "large", Width: 1080, Format: FormatWebP},
+	}
+
+	// Rotated photos are oriented in formats without metadata
+	meta := Metadata{Orientation: 6}
+	renditions, err := Render(testImage(640, 480), FormatJPEG, meta, variants, []string{FormatWebP, FormatAVIF})
+	require.NoError(t, err)
+
+	want := []struct {
+		name          string
+		width, height int
+		format        string
+	}{
+		{"thumb", 150, 100, FormatJPEG},
+		{"thumb", 100, 150, FormatWebP},
+		{"thumb", 100, 150, FormatAVIF},
+		{"large", 480, 640, FormatWebP},
+		{"large", 480, 640, FormatAVIF},
+	}
+	require.Len(t, renditions, len(want))
+	for i, r := range renditions {
+		assert.Equal(t, want[i].name, r.Variant.Name)
+		assert.Equal(t, want[i].format, r.Format)
+		assert.Equal(t, want[i].width, r.Width, want[i].name+" "+want[i].format)
+		assert.Equal(t, want[i].height, r.Height, want[i].name+" "+want[i].format)
+
+		img, format, err := Decode(r.Data)
+		require.NoError(t, err)
+		assert.Equal(t, want[i].format, format)
+		assert.Equal(t, image.Rect(0, 0, want[i].width, want[i].height), img.Bounds())
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	formats, err := ParseFormats("avif, webp,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{FormatAVIF, FormatWebP}, formats)
+
+	for _, invalid := range []string{"gif", "webp,webp", "WEBP"} {
+		_, err := ParseFormats(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image with a single white pixel at the top left
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, color.White)
+
+	// Where the pixel is displayed for each orientation
+	tests := []struct {
+		orientation int
+		bounds      image.Rectangle
+		pixel       image.Point
+	}{
+		{0, image.Rect(0, 0, 3, 2), image.Pt(0, 0)},
+		{1, image.Rect(0, 0, 3, 2), image.Pt(0, 0)},
+		{2, image.Rect(0, 0, 3, 2), image.Pt(2, 0)},
+		{3, image.Rect(0, 0, 3, 2), image.Pt(2, 1)},
+		{4, image.Rect(0, 0, 3, 2), image.Pt(0, 1)},
+		{5, image.Rect(0, 0, 2, 3), image.Pt(0, 0)},
+		{6, image.Rect(0, 0, 2, 3), image.Pt(1, 0)},
+		{7, image.Rect(0, 0, 2, 3), image.Pt(1, 2)},
+		{8, image.Rect(0, 0, 2, 3), image.Pt(0, 2)},
+	}
+
+	for _, tt := range tests {
+		got := Orient(src, tt.orientation)
+		require.Equal(t, tt.bounds, got.Bounds(), "orientation %d", tt.orientation)
+		for y := 0; y < tt.bounds.Dy(); y++ {
+			for x := 0; x < tt.bounds.Dx(); x++ {
+				r, _, _, _ := got.At(x, y).RGBA()
+				assert.Equal(t, image.Pt(x, y) == tt.pixel, r > 0, "orientation %d at %d,%d", tt.orientation, x, y)
+			}
+		}
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	assert.Equal(t, FormatJPEG, OutputFormat(FormatJPEG))
+	assert.Equal(t, FormatWebP, OutputFormat(FormatWebP))
+	assert.Equal(t, FormatPNG, OutputFormat(FormatGIF))
+	assert.Equal(t, FormatJPEG, OutputFormat(FormatTIFF))
+}
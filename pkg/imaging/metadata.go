@@ -0,0 +1,236 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// Metadata is the metadata kept when encoding a photo: how to orient it and
+// how to interpret its colors. Everything else, in particular the location
+// and camera details, is dropped.
+type Metadata struct {
+	// Orientation is the EXIF orientation, 0 if unset
+	Orientation int
+
+	// ICCProfile is the embedded color profile, nil if unset
+	ICCProfile []byte
+}
+
+// JPEG markers and segment headers
+const (
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerSOS  = 0xda
+	markerAPP1 = 0xe1
+	markerAPP2 = 0xe2
+
+	// maxSegmentPayload is the largest payload of a JPEG segment, its length
+	// field counting itself
+	maxSegmentPayload = 0xffff - 2
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+func isJPEG(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xff && data[1] == markerSOI
+}
+
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngHeader)
+}
+
+// jpegSegments calls fn with the marker and payload of each segment of a JPEG
+// image preceding the image data. It returns the offset of the start of scan
+// or end of image marker it stopped at, or -1 if the segments are malformed or
+// truncated.
+func jpegSegments(data []byte, fn func(marker byte, payload []byte)) int {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return -1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff:
+			// Fill byte
+			i++
+			continue
+		case marker == markerSOS || marker == markerEOI:
+			return i
+		case marker >= 0xd0 && marker <= 0xd7 || marker == 0x01:
+			// Markers without a payload
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return -1
+		}
+		fn(marker, data[i+4:i+2+length])
+		i += 2 + length
+	}
+	return -1
+}
+
+// pngChunks calls fn with the type and payload of each chunk of a PNG image.
+func pngChunks(data []byte, fn func(typ string, payload []byte)) {
+	for i := len(pngHeader); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if length < 0 || i+12+length > len(data) {
+			return
+		}
+		fn(string(data[i+4:i+8]), data[i+8:i+8+length])
+		i += 12 + length
+	}
+}
+
+// ReadMetadata reads the metadata of a JPEG or PNG image that is kept when it
+// is encoded again. Missing or invalid metadata is left unset.
+func ReadMetadata(data []byte) Metadata {
+	var meta Metadata
+	if e, err := ReadExif(data); err == nil {
+		meta.Orientation = e.Orientation()
+	}
+
+	switch {
+	case isJPEG(data):
+		// Profiles larger than a segment are split into numbered chunks
+		chunks := map[byte][]byte{}
+		jpegSegments(data, func(marker byte, payload []byte) {
+			if marker == markerAPP2 && bytes.HasPrefix(payload, iccHeader) && len(payload) > len(iccHeader)+2 {
+				chunks[payload[len(iccHeader)]] = payload[len(iccHeader)+2:]
+			}
+		})
+		seqs := make([]int, 0, len(chunks))
+		for seq := range chunks {
+			seqs = append(seqs, int(seq))
+		}
+		sort.Ints(seqs)
+		for _, seq := range seqs {
+			meta.ICCProfile = append(meta.ICCProfile, chunks[byte(seq)]...)
+		}
+	case isPNG(data):
+		pngChunks(data, func(typ string, payload []byte) {
+			if typ != "iCCP" || meta.ICCProfile != nil {
+				return
+			}
+			// The profile name is followed by the compression method
+			name := bytes.IndexByte(payload, 0)
+			if name < 0 || name+2 > len(payload) {
+				return
+			}
+			r, err := zlib.NewReader(bytes.NewReader(payload[name+2:]))
+			if err != nil {
+				return
+			}
+			defer r.Close()
+			meta.ICCProfile, _ = io.ReadAll(r)
+		})
+	}
+
+	return meta
+}
+
+// orientationExif returns EXIF data holding only the orientation.
+func orientationExif(orientation int) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1}
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0)       // Padding of the value
+	return append(tiff, 0, 0, 0, 0) // No next IFD
+}
+
+// writeMetadata inserts the metadata into an encoded JPEG or PNG image.
+func writeMetadata(data []byte, format string, meta Metadata) []byte {
+	if meta.Orientation == 0 && len(meta.ICCProfile) == 0 {
+		return data
+	}
+
+	switch format {
+	case FormatJPEG:
+		return writeJPEGMetadata(data, meta)
+	case FormatPNG:
+		return writePNGMetadata(data, meta)
+	default:
+		return data
+	}
+}
+
+// writeJPEGMetadata inserts APP1 and APP2 segments holding the metadata after
+// the start of image marker.
+func writeJPEGMetadata(data []byte, meta Metadata) []byte {
+	var segments bytes.Buffer
+	writeSegment := func(marker byte, parts ...[]byte) {
+		length := 2
+		for _, part := range parts {
+			length += len(part)
+		}
+		segments.Write([]byte{0xff, marker})
+		segments.Write(binary.BigEndian.AppendUint16(nil, uint16(length)))
+		for _, part := range parts {
+			segments.Write(part)
+		}
+	}
+
+	if meta.Orientation != 0 {
+		writeSegment(markerAPP1, exifHeader, orientationExif(meta.Orientation))
+	}
+
+	if len(meta.ICCProfile) > 0 {
+		size := maxSegmentPayload - len(iccHeader) - 2
+		count := (len(meta.ICCProfile) + size - 1) / size
+		for i := 0; i < count; i++ {
+			chunk := meta.ICCProfile[i*size : min((i+1)*size, len(meta.ICCProfile))]
+			writeSegment(markerAPP2, iccHeader, []byte{byte(i + 1), byte(count)}, chunk)
+		}
+	}
+
+	out := make([]byte, 0, len(data)+segments.Len())
+	out = append(out, data[:2]...)
+	out = append(out, segments.Bytes()...)
+	return append(out, data[2:]...)
+}
+
+// writePNGMetadata inserts iCCP and eXIf chunks holding the metadata after the
+// IHDR chunk, which always comes first.
+func writePNGMetadata(data []byte, meta Metadata) []byte {
+	var chunks bytes.Buffer
+	writeChunk := func(typ string, payload []byte) {
+		chunks.Write(binary.BigEndian.AppendUint32(nil, uint32(len(payload))))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(typ))
+		crc.Write(payload)
+		chunks.WriteString(typ)
+		chunks.Write(payload)
+		chunks.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	}
+
+	if len(meta.ICCProfile) > 0 {
+		var payload bytes.Buffer
+		payload.WriteString("icc\x00\x00") // Profile name and deflate compression
+		zw := zlib.NewWriter(&payload)
+		zw.Write(meta.ICCProfile)
+		zw.Close()
+		writeChunk("iCCP", payload.Bytes())
+	}
+
+	if meta.Orientation != 0 {
+		writeChunk("eXIf", orientationExif(meta.Orientation))
+	}
+
+	ihdrEnd := len(pngHeader) + 12 + int(binary.BigEndian.Uint32(data[len(pngHeader):]))
+	out := make([]byte, 0, len(data)+chunks.Len())
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, data[ihdrEnd:]...)
+}
@@ -0,0 +1,47 @@
+package imaging
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the format to serve an image in from an Accept header. The
+// formats are candidates in order of preference, and the one the header
+// accepts with the highest quality is returned. Only formats the header names
+// are accepted, since wildcards don't tell whether a client can display
+// newer formats. The fallback is returned if no format is accepted.
+func Negotiate(accept string, formats []string, fallback string) string {
+	best, bestQ := fallback, 0.0
+	for _, format := range formats {
+		if q := acceptQuality(accept, ContentType(format)); q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality an Accept header gives a media type, or 0
+// if it isn't named.
+func acceptQuality(accept, mediaType string) float64 {
+	for _, item := range strings.Split(accept, ",") {
+		params := strings.Split(item, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mediaType) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(name, "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return 0
+			}
+			q = parsed
+		}
+		return q
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	formats := []string{FormatAVIF, FormatWebP}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "browser", accept: "image/avif,image/webp,image/apng,image/*,*/*;q=0.8", want: FormatAVIF},
+		{name: "webp only", accept: "image/webp,*/*", want: FormatWebP},
+		{name: "by quality", accept: "image/avif;q=0.5, image/webp", want: FormatWebP},
+		{name: "case insensitive", accept: "Image/WebP", want: FormatWebP},
+		{name: "refused", accept: "image/avif;q=0, image/webp;q=0", want: FormatJPEG},
+		{name: "invalid quality", accept: "image/avif;q=high", want: FormatJPEG},
+		{name: "wildcards", accept: "image/*,*/*", want: FormatJPEG},
+		{name: "no header", want: FormatJPEG},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.accept, formats, FormatJPEG))
+		})
+	}
+
+	// Formats that aren't configured aren't served
+	assert.Equal(t, FormatJPEG, Negotiate("image/avif", []string{FormatWebP}, FormatJPEG))
+}
@@ -0,0 +1,67 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blobImage returns an image of random soft blobs, which unlike a gradient
+// has a distinct hash for each seed.
+func blobImage(seed int64, w, h int) image.Image {
+	rng := rand.New(rand.NewSource(seed))
+	type blob struct{ x, y, r, v float64 }
+	blobs := make([]blob, 12)
+	for i := range blobs {
+		blobs[i] = blob{rng.Float64() * float64(w), rng.Float64() * float64(h),
+			float64(w) * (0.05 + rng.Float64()*0.2), rng.Float64()*255 - 128}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := 128.0
+			for _, b := range blobs {
+				dx, dy := float64(x)-b.x, float64(y)-b.y
+				if d := dx*dx + dy*dy; d < b.r*b.r {
+					v += b.v * (1 - d/(b.r*b.r))
+				}
+			}
+			c := uint8(max(0, min(255, v)))
+			img.Set(x, y, color.RGBA{R: c, G: c / 2, B: 255 - c, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	img := blobImage(1, 640, 480)
+	hash := PerceptualHash(img)
+
+	// Re-encoded at a low quality
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 20}))
+	reencoded, _, err := Decode(buf.Bytes())
+	require.NoError(t, err)
+	assert.LessOrEqual(t, HammingDistance(hash, PerceptualHash(reencoded)), 4)
+
+	// Resized
+	assert.LessOrEqual(t, HammingDistance(hash, PerceptualHash(Resize(img, 160, 0))), 4)
+
+	// Different images
+	for seed := int64(2); seed < 6; seed++ {
+		assert.Greater(t, HammingDistance(hash, PerceptualHash(blobImage(seed, 640, 480))), 12)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	assert.Equal(t, 0, HammingDistance(0xf0f0, 0xf0f0))
+	assert.Equal(t, 64, HammingDistance(0, ^uint64(0)))
+	assert.Equal(t, 2, HammingDistance(0b1010, 0b0110))
+}
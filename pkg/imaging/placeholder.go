@@ -0,0 +1,159 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// placeholderSize is the size images are shrunk to before computing their
+// placeholder, which only keeps their broad colors
+const placeholderSize = 32
+
+// base83 is the alphabet of BlurHash strings
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Placeholder is a preview of an image shown while it loads
+type Placeholder struct {
+	// BlurHash encodes a blurred version of the image, see
+	// https://github.com/woltapp/blurhash
+	BlurHash string
+
+	// DominantColor is the most common color of the image, as #rrggbb
+	DominantColor string
+}
+
+// NewPlaceholder computes the placeholder of an image. The BlurHash has 4x3
+// components, or 3x4 for portrait images.
+func NewPlaceholder(img image.Image) Placeholder {
+	small := image.NewRGBA(image.Rect(0, 0, placeholderSize, placeholderSize))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	xComponents, yComponents := 4, 3
+	if img.Bounds().Dy() > img.Bounds().Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	return Placeholder{
+		BlurHash:      blurHash(small, xComponents, yComponents),
+		DominantColor: dominantColor(small),
+	}
+}
+
+// blurHash encodes an image with the components along each axis.
+func blurHash(img *image.RGBA, xComponents, yComponents int) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Each component is the image's correlation with a cosine basis function,
+	// computed in linear light
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalization := 2.0
+			if i == 0 && j == 0 {
+				normalization = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := math.Cos(math.Pi*float64(i*x)/float64(w)) * math.Cos(math.Pi*float64(j*y)/float64(h))
+					c := img.RGBAAt(x, y)
+					f[0] += basis * srgbToLinear(c.R)
+					f[1] += basis * srgbToLinear(c.G)
+					f[2] += basis * srgbToLinear(c.B)
+				}
+			}
+			scale := normalization / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	// The AC components are quantized relative to the largest of them
+	dc, ac := factors[0], factors[1:]
+	maximum := 1.0
+	if len(ac) > 0 {
+		actual := 0.0
+		for _, f := range ac {
+			actual = max(actual, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantized := int(max(0, min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantized+1) / 166
+		hash.WriteString(encode83(quantized, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quantize := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quantize(f[0])*19*19+quantize(f[1])*19+quantize(f[2]), 2))
+	}
+
+	return hash.String()
+}
+
+// dominantColor returns the most common color of an image. Colors are counted
+// in buckets of similar colors, and the average color of the largest bucket
+// is returned.
+func dominantColor(img *image.RGBA) string {
+	type bucket struct{ r, g, b, n int }
+	buckets := map[int]*bucket{}
+	var largest *bucket
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			key := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			b := buckets[key]
+			if b == nil {
+				b = &bucket{}
+				buckets[key] = b
+			}
+			b.r, b.g, b.b, b.n = b.r+int(c.R), b.g+int(c.G), b.b+int(c.B), b.n+1
+			if largest == nil || b.n > largest.n {
+				largest = b
+			}
+		}
+	}
+	if largest == nil {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", largest.r/largest.n, largest.g/largest.n, largest.b/largest.n)
+}
+
+// encode83 encodes a value as length base 83 digits.
+func encode83(value, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = base83[value%83]
+		value /= 83
+	}
+	return string(digits)
+}
+
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,63 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode83(s string) int {
+	value := 0
+	for _, c := range s {
+		value = value*83 + strings.IndexRune(base83, c)
+	}
+	return value
+}
+
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	return img
+}
+
+func TestNewPlaceholder_Solid(t *testing.T) {
+	p := NewPlaceholder(solidImage(100, 50, color.RGBA{R: 200, G: 100, B: 50, A: 255}))
+
+	// Size flag, maximum AC value, DC and 11 AC components
+	assert.Len(t, p.BlurHash, 1+1+4+2*11)
+	assert.Equal(t, 3+2*9, decode83(p.BlurHash[:1]))
+
+	dc := decode83(p.BlurHash[2:6])
+	assert.Equal(t, []int{200, 100, 50}, []int{dc >> 16, dc >> 8 & 0xff, dc & 0xff})
+
+	// A solid image only has the small AC components left by sampling the
+	// cosines at discrete pixels
+	assert.LessOrEqual(t, decode83(p.BlurHash[1:2]), 5)
+
+	assert.Equal(t, "#c86432", p.DominantColor)
+}
+
+func TestNewPlaceholder_Detail(t *testing.T) {
+	// Mostly blue, with a red stripe on the left
+	img := solidImage(60, 120, color.RGBA{B: 255, A: 255})
+	draw.Draw(img, image.Rect(0, 0, 20, 120), &image.Uniform{C: color.RGBA{R: 255, A: 255}}, image.Point{}, draw.Src)
+	p := NewPlaceholder(img)
+
+	// Portrait images have more vertical components
+	assert.Len(t, p.BlurHash, 1+1+4+2*11)
+	assert.Equal(t, 2+3*9, decode83(p.BlurHash[:1]))
+	assert.Greater(t, decode83(p.BlurHash[1:2]), 10)
+
+	assert.Equal(t, "#0000ff", p.DominantColor)
+}
+
+func TestEncode83(t *testing.T) {
+	assert.Equal(t, "00", encode83(0, 2))
+	assert.Equal(t, "~", encode83(82, 1))
+	assert.Equal(t, "10", encode83(83, 2))
+	assert.Equal(t, 123456, decode83(encode83(123456, 4)))
+}
@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"net/url"
+	"strconv"
+)
+
+// Ways an image is fitted to the requested width and height
+const (
+	// FitContain scales the image to fit within the area
+	FitContain = "contain"
+
+	// FitCover scales and crops the image to cover the area
+	FitCover = "cover"
+)
+
+// MaxDimension bounds the width and height of transformed images
+const MaxDimension = 4096
+
+// Options describes an on the fly transformation of a photo. Zero values are
+// unset and take the defaults applied by Normalize.
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// Validate checks the options are within bounds.
+func (o Options) Validate() error {
+	if o.Width < 0 || o.Width > MaxDimension || o.Height < 0 || o.Height > MaxDimension {
+		return fmt.Errorf("width and height must be between 1 and %d", MaxDimension)
+	}
+
+	switch o.Fit {
+	case "", FitContain:
+	case FitCover:
+		if o.Width == 0 || o.Height == 0 {
+			return fmt.Errorf("fit %s requires a width and height", FitCover)
+		}
+	default:
+		return fmt.Errorf("invalid fit %q", o.Fit)
+	}
+
+	if o.Format != "" && !Encodable(o.Format) {
+		return fmt.Errorf("invalid format %q: %w", o.Format, ErrUnsupportedFormat)
+	}
+
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// Normalize applies the defaults for a photo of the given format, so options
+// producing the same image are equal.
+func (o Options) Normalize(format string) Options {
+	if o.Fit == "" {
+		o.Fit = FitContain
+	}
+	if o.Format == "" {
+		o.Format = OutputFormat(format)
+	}
+	if !Lossy(o.Format) {
+		o.Quality = 0
+	} else if o.Quality == 0 {
+		o.Quality = DefaultQuality
+	}
+	return o
+}
+
+// Query returns the options as the query parameters of a resize request.
+func (o Options) Query() url.Values {
+	q := url.Values{}
+	if o.Width > 0 {
+		q.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		q.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" {
+		q.Set("fit", o.Fit)
+	}
+	if o.Format != "" {
+		q.Set("fmt", o.Format)
+	}
+	if o.Quality > 0 {
+		q.Set("q", strconv.Itoa(o.Quality))
+	}
+	return q
+}
+
+// canonical returns the string identifying the options applied to a photo.
+// Encoded query parameters are sorted by name.
+func (o Options) canonical(photoID string) string {
+	return photoID + "?" + o.Query().Encode()
+}
+
+// Hash returns a hex encoded digest identifying the options applied to a
+// photo, used to address the transformed image.
+func (o Options) Hash(photoID string) string {
+	sum := sha256.Sum256([]byte(o.canonical(photoID)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Sign returns the signature authorizing the options to be applied to a photo.
+func Sign(key []byte, photoID string, o Options) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(o.canonical(photoID)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature authorizes the options to be applied
+// to a photo. Nothing is authorized without a key.
+func Verify(key []byte, photoID string, o Options, signature string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(key, photoID, o)), []byte(signature))
+}
+
+// SignedQuery returns the signed query string of a resize request applying
+// the options to a photo.
+func SignedQuery(key []byte, photoID string, o Options) string {
+	q := o.Query()
+	q.Set("sig", Sign(key, photoID, o))
+	return q.Encode()
+}
+
+// Transform resizes an image as described by the options.
+func Transform(img image.Image, o Options) image.Image {
+	if o.Fit == FitCover {
+		return Fill(img, o.Width, o.Height)
+	}
+	if o.Width > 0 || o.Height > 0 {
+		return Resize(img, o.Width, o.Height)
+	}
+	return img
+}
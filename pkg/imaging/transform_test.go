@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"image"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	valid := []Options{
+		{},
+		{Width: 640},
+		{Width: 640, Height: 480, Fit: FitCover, Format: FormatPNG},
+		{Height: MaxDimension, Quality: 100},
+		{Width: 640, Format: FormatAVIF},
+	}
+	for _, o := range valid {
+		assert.NoError(t, o.Validate(), "%+v", o)
+	}
+
+	invalid := []Options{
+		{Width: -1},
+		{Width: MaxDimension + 1},
+		{Width: 640, Fit: FitCover},
+		{Fit: "stretch"},
+		{Format: "gif"},
+		{Quality: 101},
+	}
+	for _, o := range invalid {
+		assert.Error(t, o.Validate(), "%+v", o)
+	}
+}
+
+func TestOptions_Normalize(t *testing.T) {
+	assert.Equal(t, Options{Width: 640, Fit: FitContain, Format: FormatJPEG, Quality: DefaultQuality},
+		Options{Width: 640}.Normalize(FormatJPEG))
+
+	// Quality only applies to lossy formats
+	assert.Equal(t, Options{Width: 640, Fit: FitContain, Format: FormatPNG},
+		Options{Width: 640, Quality: 50}.Normalize(FormatPNG))
+	assert.Equal(t, Options{Width: 640, Fit: FitContain, Format: FormatWebP, Quality: 50},
+		Options{Width: 640, Format: FormatWebP, Quality: 50}.Normalize(FormatPNG))
+
+	// Options producing the same image address the same image
+	assert.Equal(t,
+		Options{Width: 640}.Normalize(FormatJPEG).Hash("photo"),
+		Options{Width: 640, Fit: FitContain, Format: FormatJPEG, Quality: DefaultQuality}.Normalize(FormatPNG).Hash("photo"))
+	assert.NotEqual(t,
+		Options{Width: 640}.Normalize(FormatJPEG).Hash("photo"),
+		Options{Width: 640}.Normalize(FormatJPEG).Hash("other"))
+}
+
+func TestSign(t *testing.T) {
+	key := []byte("secret")
+	o := Options{Width: 640, Height: 480, Fit: FitCover}
+
+	sig := Sign(key, "photo", o)
+	assert.True(t, Verify(key, "photo", o, sig))
+
+	assert.False(t, Verify(key, "other", o, sig), "other photo")
+	assert.False(t, Verify(key, "photo", Options{Width: 4096, Height: 480, Fit: FitCover}, sig), "other options")
+	assert.False(t, Verify([]byte("other"), "photo", o, sig), "other key")
+	assert.False(t, Verify(nil, "photo", o, Sign(nil, "photo", o)), "no key")
+
+	query, err := url.ParseQuery(SignedQuery(key, "photo", o))
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"w": {"640"}, "h": {"480"}, "fit": {FitCover}, "sig": {sig}}, query)
+}
+
+func TestTransform(t *testing.T) {
+	src := testImage(400, 200)
+
+	assert.Equal(t, image.Rect(0, 0, 100, 50), Transform(src, Options{Width: 100}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 100, 50), Transform(src, Options{Width: 100, Height: 100, Fit: FitContain}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 100, 100), Transform(src, Options{Width: 100, Height: 100, Fit: FitCover}).Bounds())
+	assert.Equal(t, src.Bounds(), Transform(src, Options{}).Bounds())
+}
@@ -0,0 +1,141 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Variant defines a resized rendition of photos.
+type Variant struct {
+	Name string
+
+	// Width bounds the width of the variant
+	Width int
+
+	// Height crops the variant to Width by Height if set, otherwise the
+	// variant keeps the aspect ratio of the photo
+	Height int
+
+	// Format is the format the variant is encoded in, empty for the format
+	// of the photo
+	Format string
+}
+
+// String formats the variant the way ParseVariants parses it.
+func (v Variant) String() string {
+	s := fmt.Sprintf("%s=%d", v.Name, v.Width)
+	if v.Height > 0 {
+		s += fmt.Sprintf("x%d", v.Height)
+	}
+	if v.Format != "" {
+		s += ":" + v.Format
+	}
+	return s
+}
+
+// variantPattern matches a variant definition such as "thumb=150x150",
+// "medium=640" or "small=320:jpeg"
+var variantPattern = regexp.MustCompile(`^([a-z0-9_-]+)=([1-9][0-9]*)(?:x([1-9][0-9]*))?(?::([a-z]+))?$`)
+
+// ParseVariants parses comma separated variant definitions. Each is a name
+// followed by a width, an optional height to crop to and an optional format,
+// e.g. "thumb=150x150,small=320,medium=640:jpeg".
+func ParseVariants(s string) ([]Variant, error) {
+	var variants []Variant
+	names := map[string]bool{}
+
+	for _, def := range strings.Split(s, ",") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+
+		m := variantPattern.FindStringSubmatch(def)
+		if m == nil {
+			return nil, fmt.Errorf("invalid variant %q", def)
+		}
+
+		v := Variant{Name: m[1], Format: m[4]}
+		v.Width, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			v.Height, _ = strconv.Atoi(m[3])
+		}
+		if v.Format != "" && !Encodable(v.Format) {
+			return nil, fmt.Errorf("invalid variant %q: %w", def, ErrUnsupportedFormat)
+		}
+		if names[v.Name] {
+			return nil, fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		names[v.Name] = true
+
+		variants = append(variants, v)
+	}
+
+	return variants, nil
+}
+
+// Rendition is an encoded variant of a photo.
+type Rendition struct {
+	Variant Variant
+	Width   int
+	Height  int
+	Format  string
+	Data    []byte
+}
+
+// Render resizes and encodes an image of the given format to each variant,
+// keeping the metadata. Each variant is rendered in its format, or the output
+// format of the image, and then in each of the other formats.
+func Render(img image.Image, format string, meta Metadata, variants []Variant, formats []string) ([]Rendition,
+	error) {
+	renditions := make([]Rendition, 0, len(variants)*(1+len(formats)))
+
+	for _, v := range variants {
+		var resized image.Image
+		if v.Height > 0 {
+			resized = Fill(img, v.Width, v.Height)
+		} else {
+			resized = Resize(img, v.Width, 0)
+		}
+
+		outFormat := v.Format
+		if outFormat == "" {
+			outFormat = OutputFormat(format)
+		}
+
+		outFormats := []string{outFormat}
+		for _, f := range formats {
+			if !slices.Contains(outFormats, f) {
+				outFormats = append(outFormats, f)
+			}
+		}
+
+		for _, f := range outFormats {
+			var buf bytes.Buffer
+			if err := Encode(&buf, resized, f, meta); err != nil {
+				return nil, fmt.Errorf("failed to encode variant %s as %s: %w", v.Name, f, err)
+			}
+
+			// Formats without the metadata are oriented before they're encoded
+			width, height := resized.Bounds().Dx(), resized.Bounds().Dy()
+			if !carriesMetadata(f) && meta.Orientation >= 5 {
+				width, height = height, width
+			}
+
+			renditions = append(renditions, Rendition{
+				Variant: v,
+				Width:   width,
+				Height:  height,
+				Format:  f,
+				Data:    buf.Bytes(),
+			})
+		}
+	}
+
+	return renditions, nil
+}
@@ -0,0 +1,162 @@
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a KMS holding master keys in a local JSON file, readable only by
+// its owner:
+//
+//	{"current": "<id>", "keys": {"<id>": "<base64 key>", ...}}
+//
+// Generate adds a new current key to the file. Servers reload the file when
+// they meet a data key wrapped with a key they don't hold yet, so they can
+// read objects rotated to a key generated after they started.
+type File struct {
+	path string
+
+	mu   sync.RWMutex
+	ring keyring
+}
+
+// keyFile is the content of a key file
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string][]byte `json:"keys"`
+}
+
+// NewFile creates a File KMS reading the keys of the file at the path. A file
+// that doesn't exist yet holds no keys.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load reads the keys of the file.
+func (f *File) load() error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		data = []byte("{}")
+	} else if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if _, ok := file.Keys[file.Current]; file.Current != "" && !ok {
+		return fmt.Errorf("current key %s is not in the key file", file.Current)
+	}
+	for id, key := range file.Keys {
+		if len(key) != KeySize {
+			return fmt.Errorf("key %s must be %d bytes, got %d", id, KeySize, len(key))
+		}
+	}
+	if file.Keys == nil {
+		file.Keys = map[string][]byte{}
+	}
+
+	f.mu.Lock()
+	f.ring = keyring{current: file.Current, keys: file.Keys}
+	f.mu.Unlock()
+	return nil
+}
+
+// Generate adds a random master key to the file and makes it the current key,
+// returning its ID. Previous keys are kept to unwrap the data keys they
+// wrapped until those are rotated.
+func (f *File) Generate() (string, error) {
+	idBytes := make([]byte, 8)
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make(map[string][]byte, len(f.ring.keys)+1)
+	for existing, key := range f.ring.keys {
+		keys[existing] = key
+	}
+	keys[id] = key
+
+	data, err := json.MarshalIndent(keyFile{Current: id, Keys: keys}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key file: %w", err)
+	}
+	if err := writeKeyFile(f.path, data); err != nil {
+		return "", err
+	}
+
+	f.ring = keyring{current: id, keys: keys}
+	return id, nil
+}
+
+// writeKeyFile replaces the key file, so it's never seen half written.
+func writeKeyFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+// KeyID returns the ID of the current master key
+func (f *File) KeyID(ctx context.Context) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.ring.keyID()
+}
+
+// Wrap encrypts a data key with the current master key
+func (f *File) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.ring.wrap(dataKey)
+}
+
+// Unwrap decrypts a data key wrapped with the master key with the ID,
+// reloading the file if the key isn't known yet
+func (f *File) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	f.mu.RLock()
+	_, ok := f.ring.keys[keyID]
+	f.mu.RUnlock()
+	if !ok {
+		if err := f.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.ring.unwrap(keyID, wrapped)
+}
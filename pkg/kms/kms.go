@@ -0,0 +1,102 @@
+// Package kms wraps the data keys objects are encrypted with using master
+// keys, so data keys can be stored with the objects they encrypt and master
+// keys can be rotated without encrypting the objects again.
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of master keys and data keys in bytes, for AES-256
+const KeySize = 32
+
+// ErrUnknownKey is returned when a data key was wrapped with a master key the
+// KMS doesn't hold
+var ErrUnknownKey = errors.New("unknown master key")
+
+// KMS defines the operations of a key management service holding master keys
+type KMS interface {
+	// KeyID returns the ID of the current master key, the one data keys are
+	// wrapped with
+	KeyID(ctx context.Context) (string, error)
+
+	// Wrap encrypts a data key with the current master key and returns the
+	// ID of the master key
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// Unwrap decrypts a data key wrapped with the master key with the ID, or
+	// returns ErrUnknownKey
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// keyring holds master keys by ID. Data keys are wrapped with AES-256-GCM,
+// authenticating the ID of the master key, and prefixed with the nonce.
+type keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k keyring) keyID() (string, error) {
+	if k.current == "" {
+		return "", errors.New("no master key")
+	}
+	return k.current, nil
+}
+
+func (k keyring) wrap(dataKey []byte) (string, []byte, error) {
+	keyID, err := k.keyID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	aead, err := newAEAD(k.keys[keyID])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return keyID, aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (k keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// newAEAD creates an AES-GCM cipher with the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
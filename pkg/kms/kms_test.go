@@ -0,0 +1,107 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic(t *testing.T) {
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{1}, KeySize)
+	newKey := bytes.Repeat([]byte{2}, KeySize)
+	dataKey := bytes.Repeat([]byte{3}, KeySize)
+
+	old, err := NewStatic(oldKey)
+	require.NoError(t, err)
+	oldID, wrapped, err := old.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+
+	// The previous key still unwraps the data keys it wrapped
+	rotated, err := NewStatic(newKey, oldKey)
+	require.NoError(t, err)
+	unwrapped, err := rotated.Unwrap(ctx, oldID, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	newID, err := rotated.KeyID(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldID, newID)
+	id, _, err := rotated.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+	assert.Equal(t, newID, id)
+
+	// Without it, they can't be unwrapped
+	current, err := NewStatic(newKey)
+	require.NoError(t, err)
+	_, err = current.Unwrap(ctx, oldID, wrapped)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Wrapped keys are authenticated with the ID of their master key
+	wrapped[len(wrapped)-1] ^= 1
+	_, err = rotated.Unwrap(ctx, oldID, wrapped)
+	assert.Error(t, err)
+
+	_, err = NewStatic([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	dataKey := bytes.Repeat([]byte{3}, KeySize)
+
+	// A missing file holds no keys until one is generated
+	file, err := NewFile(path)
+	require.NoError(t, err)
+	_, err = file.KeyID(ctx)
+	assert.Error(t, err)
+
+	firstID, err := file.Generate()
+	require.NoError(t, err)
+	id, wrapped, err := file.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+	assert.Equal(t, firstID, id)
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), stat.Mode().Perm())
+
+	// A server started before a key was generated reloads the file when it
+	// meets the key
+	server, err := NewFile(path)
+	require.NoError(t, err)
+	secondID, err := file.Generate()
+	require.NoError(t, err)
+	secondID, rewrapped, err := file.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+
+	unwrapped, err := server.Unwrap(ctx, secondID, rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	// Previous keys are kept
+	unwrapped, err = server.Unwrap(ctx, firstID, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = server.Unwrap(ctx, "missing", wrapped)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"current": "a", "keys": {}}`), 0o600))
+	_, err := NewFile(path)
+	assert.EqualError(t, err, "current key a is not in the key file")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"current": "a", "keys": {"a": "c2hvcnQ="}}`), 0o600))
+	_, err = NewFile(path)
+	assert.EqualError(t, err, "key a must be 32 bytes, got 5")
+}
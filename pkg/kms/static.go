@@ -0,0 +1,56 @@
+package kms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Static is a KMS holding master keys given by the configuration. Data keys
+// are wrapped with the current key, and unwrapped with it or the previous
+// keys, which are kept until the data keys they wrapped are rotated. Keys are
+// identified by a hash, so the same key always has the same ID.
+type Static struct {
+	ring keyring
+}
+
+// NewStatic creates a Static KMS with the current master key and the
+// previous ones.
+func NewStatic(current []byte, previous ...[]byte) (*Static, error) {
+	ring := keyring{keys: map[string][]byte{}}
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+		}
+		id := staticKeyID(key)
+		if i == 0 {
+			ring.current = id
+		}
+		ring.keys[id] = key
+	}
+
+	return &Static{ring: ring}, nil
+}
+
+// staticKeyID returns the ID of a master key, a hash of it that doesn't
+// reveal it.
+func staticKeyID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return "static-" + hex.EncodeToString(hash[:8])
+}
+
+// KeyID returns the ID of the current master key
+func (s *Static) KeyID(ctx context.Context) (string, error) {
+	return s.ring.keyID()
+}
+
+// Wrap encrypts a data key with the current master key
+func (s *Static) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	return s.ring.wrap(dataKey)
+}
+
+// Unwrap decrypts a data key wrapped with the master key with the ID
+func (s *Static) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return s.ring.unwrap(keyID, wrapped)
+}
@@ -0,0 +1,287 @@
+// Package migrate copies the objects of a storage to another storage,
+// verifying their checksums, and rewrites the backend rows refer to them in.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jelly/pkg/store"
+)
+
+// checkpointInterval is how many objects are migrated between saves of the
+// state
+const checkpointInterval = 100
+
+// Database defines the persistence operations used by the migrator.
+type Database interface {
+	RewriteStorageBackend(ctx context.Context, from, to string) (int64, error)
+}
+
+// Migrator copies all objects of a storage to another storage with their
+// metadata, then rewrites the backend ID of the rows from the ID of the source
+// storage to the ID of the destination storage. Rows store the keys of
+// objects, which stay the same.
+type Migrator struct {
+	From        store.Storage
+	To          store.Storage
+	FromBackend string   // ID rows refer to the source storage by
+	ToBackend   string   // ID rows refer to the destination storage by
+	DB          Database // Rewrites the backend IDs of rows, if set
+
+	// State is the path of the file the progress is saved to, so an
+	// interrupted migration resumes after the objects it already migrated.
+	// Progress isn't saved if it's empty.
+	State string
+
+	// DryRun reports what would be copied without copying anything
+	DryRun bool
+}
+
+// Report summarizes a migration.
+type Report struct {
+	DryRun  bool
+	Resumed string // Key the migration resumed after, empty if it started over
+	Objects int    // Objects listed
+	Copied  int
+	Skipped int   // Objects the destination already had with the same checksum
+	Bytes   int64 // Size of the copied objects
+	Rows    int64 // Rows whose backend IDs were rewritten
+	Errors  int   // Objects that failed to be copied or verified
+}
+
+// String formats the report for people.
+func (r Report) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("Dry run, nothing was copied\n")
+	}
+	if r.Resumed != "" {
+		fmt.Fprintf(&b, "Resumed after %s\n", r.Resumed)
+	}
+	fmt.Fprintf(&b, "Objects:  %d\n", r.Objects)
+	fmt.Fprintf(&b, "Copied:   %d (%d bytes)\n", r.Copied, r.Bytes)
+	fmt.Fprintf(&b, "Skipped:  %d\n", r.Skipped)
+	fmt.Fprintf(&b, "Rows:     %d\n", r.Rows)
+	fmt.Fprintf(&b, "Errors:   %d\n", r.Errors)
+	return b.String()
+}
+
+// state is the progress of a migration saved to the state file
+type state struct {
+	From  string `json:"from"`  // Base URL of the source storage
+	To    string `json:"to"`    // Base URL of the destination storage
+	After string `json:"after"` // Key all objects up to were migrated
+}
+
+// Run copies the objects ordered by key, resuming after the objects a previous
+// run saved to the state file it migrated. Objects are copied unless the
+// destination already has them with the same checksum, and the checksum of
+// their copy is verified. Failures to copy an object are logged and counted,
+// an error is only returned if the objects can't be listed or the progress
+// can't be saved. Once all objects are migrated without errors, the backend
+// IDs of the rows are rewritten and the state file is removed.
+func (m *Migrator) Run(ctx context.Context) (report Report, err error) {
+	report = Report{DryRun: m.DryRun}
+
+	current := state{From: m.From.BaseURL(), To: m.To.BaseURL()}
+	saved, err := m.load()
+	if err != nil {
+		return report, err
+	}
+	if saved != nil {
+		if saved.From != current.From || saved.To != current.To {
+			return report, fmt.Errorf("state file %s is of a migration from %s to %s", m.State, saved.From, saved.To)
+		}
+		current.After = saved.After
+		report.Resumed = saved.After
+	}
+
+	// The progress is saved up to the first failed object, which a resumed
+	// migration copies again
+	failed := false
+	defer func() {
+		if saveErr := m.save(current); err == nil {
+			err = saveErr
+		}
+	}()
+
+	for info, err := range m.From.List(ctx, "") {
+		if err != nil {
+			return report, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if info.Key <= current.After {
+			continue
+		}
+		report.Objects++
+
+		copied, size, err := m.migrate(ctx, info.Key)
+		switch {
+		case err != nil:
+			slog.Error("Failed to migrate object", "error", err, "key", info.Key)
+			report.Errors++
+			failed = true
+		case copied:
+			report.Copied++
+			report.Bytes += size
+		default:
+			report.Skipped++
+		}
+
+		if !failed {
+			current.After = info.Key
+			if report.Objects%checkpointInterval == 0 {
+				if err := m.save(current); err != nil {
+					return report, err
+				}
+			}
+		}
+	}
+
+	if m.DryRun || failed {
+		return report, nil
+	}
+
+	if m.DB != nil && m.FromBackend != m.ToBackend {
+		report.Rows, err = m.DB.RewriteStorageBackend(ctx, m.FromBackend, m.ToBackend)
+		if err != nil {
+			return report, fmt.Errorf("failed to rewrite storage backend: %w", err)
+		}
+	}
+
+	// Migrating again starts over, skipping the objects already copied
+	current.After = ""
+	return report, nil
+}
+
+// migrate copies the object unless the destination already has it with the
+// same checksum, reporting whether it was copied and its size.
+func (m *Migrator) migrate(ctx context.Context, key string) (bool, int64, error) {
+	info, err := m.From.Stat(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+	data, contentType, err := m.From.Download(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+	sum := checksum(data)
+	if info.SHA256 != "" && info.SHA256 != sum {
+		return false, 0, fmt.Errorf("checksum %s doesn't match the source checksum %s", sum, info.SHA256)
+	}
+
+	if same, err := m.verify(ctx, key, int64(len(data)), sum); err != nil {
+		return false, 0, err
+	} else if same {
+		return false, 0, nil
+	}
+	if m.DryRun {
+		return true, int64(len(data)), nil
+	}
+
+	_, err = m.To.Upload(ctx, key, data, contentType,
+		store.WithCacheControl(info.CacheControl), store.WithMetadata(info.Metadata))
+	if err != nil {
+		return false, 0, err
+	}
+
+	if same, err := m.verify(ctx, key, int64(len(data)), sum); err != nil {
+		return false, 0, err
+	} else if !same {
+		return false, 0, errors.New("copy doesn't match the checksum of the object")
+	}
+	return true, int64(len(data)), nil
+}
+
+// verify reports whether the destination has the object with the size and
+// checksum, downloading it if the destination has no checksum of it.
+func (m *Migrator) verify(ctx context.Context, key string, size int64, sum string) (bool, error) {
+	info, err := m.To.Stat(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if info.Size != size {
+		return false, nil
+	}
+	if info.SHA256 != "" {
+		return info.SHA256 == sum, nil
+	}
+
+	data, _, err := m.To.Download(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return checksum(data) == sum, nil
+}
+
+// checksum returns the hex encoded SHA-256 checksum of the data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the state file, returning nil if there is none.
+func (m *Migrator) load() (*state, error) {
+	if m.State == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(m.State)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return &s, nil
+}
+
+// save replaces the state file, so it's never seen half written, or removes it
+// once there's nothing to resume. Dry runs save nothing.
+func (m *Migrator) save(s state) error {
+	if m.State == "" || m.DryRun {
+		return nil
+	}
+	if s.After == "" {
+		if err := os.Remove(m.State); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove state file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.State), "."+filepath.Base(m.State)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.State); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package migrate
+
+import (
+	"context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockDatabase creates a new instance of MockDatabase. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDatabase(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDatabase {
+	mock := &MockDatabase{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDatabase is an autogenerated mock type for the Database type
+type MockDatabase struct {
+	mock.Mock
+}
+
+type MockDatabase_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDatabase) EXPECT() *MockDatabase_Expecter {
+	return &MockDatabase_Expecter{mock: &_m.Mock}
+}
+
+// RewriteStorageBackend provides a mock function for the type MockDatabase
+func (_mock *MockDatabase) RewriteStorageBackend(ctx context.Context, from string, to string) (int64, error) {
+	ret := _mock.Called(ctx, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RewriteStorageBackend")
+	}
+
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (int64, error)); ok {
+		return returnFunc(ctx, from, to)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) int64); ok {
+		r0 = returnFunc(ctx, from, to)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockDatabase_RewriteStorageBackend_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RewriteStorageBackend'
+type MockDatabase_RewriteStorageBackend_Call struct {
+	*mock.Call
+}
+
+// RewriteStorageBackend is a helper method to define mock.On call
+//   - ctx context.Context
+//   - from string
+//   - to string
+func (_e *MockDatabase_Expecter) RewriteStorageBackend(ctx interface{}, from interface{}, to interface{}) *MockDatabase_RewriteStorageBackend_Call {
+	return &MockDatabase_RewriteStorageBackend_Call{Call: _e.mock.On("RewriteStorageBackend", ctx, from, to)}
+}
+
+func (_c *MockDatabase_RewriteStorageBackend_Call) Run(run func(ctx context.Context, from string, to string)) *MockDatabase_RewriteStorageBackend_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDatabase_RewriteStorageBackend_Call) Return(n int64, err error) *MockDatabase_RewriteStorageBackend_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockDatabase_RewriteStorageBackend_Call) RunAndReturn(run func(ctx context.Context, from string, to string) (int64, error)) *MockDatabase_RewriteStorageBackend_Call {
+	_c.Call.Return(run)
+	return _c
+}
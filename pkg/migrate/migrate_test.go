@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/store"
+)
+
+// failingStorage is local storage failing to upload one key
+type failingStorage struct {
+	*store.LocalStorage
+	key string
+}
+
+func (s failingStorage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...store.UploadOption) (string, error) {
+	if key == s.key {
+		return "", errors.New("unavailable")
+	}
+	return s.LocalStorage.Upload(ctx, key, data, contentType, opts...)
+}
+
+// newTestStorages creates the local storages migrated from and to, the first
+// holding the objects
+func newTestStorages(t *testing.T, objects map[string]string) (*store.LocalStorage, *store.LocalStorage) {
+	from := store.NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	to := store.NewLocalStorage(t.TempDir(), "https://cdn.example.com")
+	for key, data := range objects {
+		_, err := from.Upload(context.Background(), key, []byte(data), "image/jpeg",
+			store.WithCacheControl("public, max-age=60"), store.WithMetadata(map[string]string{"key": key}))
+		require.NoError(t, err)
+	}
+	return from, to
+}
+
+func TestMigrator_Run(t *testing.T) {
+	ctx := context.Background()
+	from, to := newTestStorages(t, map[string]string{
+		"photos/p/original.jpg": "original",
+		"photos/p/thumb.jpg":    "thumb",
+		"raw/u/photo.jpg":       "raw",
+	})
+	// The destination already has an object, and a different version of another
+	_, err := to.Upload(ctx, "photos/p/thumb.jpg", []byte("thumb"), "image/jpeg")
+	require.NoError(t, err)
+	_, err = to.Upload(ctx, "raw/u/photo.jpg", []byte("old"), "image/jpeg")
+	require.NoError(t, err)
+
+	db := NewMockDatabase(t)
+	state := filepath.Join(t.TempDir(), "state.json")
+	migrator := &Migrator{From: from, To: to, FromBackend: "default", ToBackend: "cdn", DB: db, State: state,
+		DryRun: true}
+
+	report, err := migrator.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{DryRun: true, Objects: 3, Copied: 2, Skipped: 1, Bytes: 11}, report)
+	data, _, err := to.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), data)
+
+	db.EXPECT().RewriteStorageBackend(mock.Anything, "default", "cdn").Return(4, nil)
+	migrator.DryRun = false
+	report, err = migrator.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Objects: 3, Copied: 2, Skipped: 1, Bytes: 11, Rows: 4}, report)
+
+	// Objects are copied with their metadata
+	for key, want := range map[string]string{"photos/p/original.jpg": "original", "raw/u/photo.jpg": "raw"} {
+		data, _, err := to.Download(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+		info, err := to.Stat(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "public, max-age=60", info.CacheControl)
+		assert.Equal(t, key, info.Metadata["key"])
+	}
+
+	// Nothing is left to resume
+	_, err = os.Stat(state)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMigrator_Resume(t *testing.T) {
+	ctx := context.Background()
+	from, to := newTestStorages(t, map[string]string{
+		"photos/a.jpg": "a",
+		"photos/b.jpg": "b",
+		"photos/c.jpg": "c",
+	})
+	db := NewMockDatabase(t)
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	// The progress is saved up to the object that failed
+	migrator := &Migrator{From: from, To: failingStorage{LocalStorage: to, key: "photos/b.jpg"},
+		FromBackend: "default", ToBackend: "cdn", DB: db, State: state}
+	report, err := migrator.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Objects: 3, Copied: 2, Bytes: 2, Errors: 1}, report)
+
+	saved, err := os.ReadFile(state)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"from":"http://localhost:8080/files","to":"https://cdn.example.com","after":"photos/a.jpg"}`,
+		string(saved))
+
+	// Migrating other storage doesn't resume it
+	other := &Migrator{From: to, To: from, State: state}
+	_, err = other.Run(ctx)
+	assert.ErrorContains(t, err, "is of a migration from http://localhost:8080/files to https://cdn.example.com")
+
+	db.EXPECT().RewriteStorageBackend(mock.Anything, "default", "cdn").Return(0, nil)
+	migrator.To = to
+	report, err = migrator.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Resumed: "photos/a.jpg", Objects: 2, Copied: 1, Skipped: 1, Bytes: 1}, report)
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"jelly/pkg/api/v1/gen"
+)
+
+// Comment represents a comment on a photo
+type Comment struct {
+	ID        string    `json:"id" db:"id"`
+	PhotoID   string    `json:"photo_id" db:"photo_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (c *Comment) ToComment() gen.Comment {
+	return gen.Comment{
+		Id:        c.ID,
+		PhotoId:   c.PhotoID,
+		UserId:    c.UserID,
+		Content:   c.Content,
+		CreatedAt: c.CreatedAt,
+	}
+}
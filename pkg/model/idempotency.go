@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// IdempotencyKey records a request made with an Idempotency-Key header, so
+// retries of the request can be answered with the stored response. The
+// response is unset while the first request is still in progress.
+type IdempotencyKey struct {
+	UserID          string    `json:"user_id" db:"user_id"`
+	Key             string    `json:"key" db:"key"`
+	Fingerprint     string    `json:"fingerprint" db:"fingerprint"`
+	StatusCode      *int      `json:"status_code,omitempty" db:"status_code"`
+	ResponseHeaders *string   `json:"response_headers,omitempty" db:"response_headers"`
+	ResponseBody    []byte    `json:"response_body,omitempty" db:"response_body"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt       time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// IsComplete reports whether the response of the request has been stored.
+func (k *IdempotencyKey) IsComplete() bool {
+	return k.StatusCode != nil
+}
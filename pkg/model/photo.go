@@ -3,6 +3,8 @@ package model
 import (
 	"time"
 
+	"github.com/lib/pq"
+
 	"jelly/pkg/api/v1/gen"
 )
 
@@ -11,27 +13,34 @@ type RawPhoto struct {
 	ID               string     `json:"id" db:"id"`
 	UserID           string     `json:"user_id" db:"user_id"`
 	OriginalFilename string     `json:"original_filename" db:"original_filename"`
-	StorageURL       string     `json:"storage_url" db:"storage_url"`
+	StorageBackend   string     `json:"storage_backend" db:"storage_backend"` // ID of the storage backend
+	StorageKey       string     `json:"storage_key" db:"storage_key"`
 	FileSize         int64      `json:"file_size" db:"file_size"`
 	MimeType         string     `json:"mime_type" db:"mime_type"`
 	MD5Hash          string     `json:"md5_hash" db:"md5_hash"`
+	SHA256Hash       string     `json:"sha256_hash" db:"sha256_hash"`
 	Width            *int       `json:"width,omitempty" db:"width"`
 	Height           *int       `json:"height,omitempty" db:"height"`
 	ExifData         *string    `json:"exif_data,omitempty" db:"exif_data"`
+	PerceptualHash   *int64     `json:"perceptual_hash,omitempty" db:"perceptual_hash"`
 	UploadedAt       time.Time  `json:"uploaded_at" db:"uploaded_at"`
 	ProcessedAt      *time.Time `json:"processed_at,omitempty" db:"processed_at"`
 	ScheduleDeletion *time.Time `json:"schedule_deletion,omitempty" db:"schedule_deletion"`
+	QuarantinedAt    *time.Time `json:"quarantined_at,omitempty" db:"quarantined_at"`
+	QuarantineReason *string    `json:"quarantine_reason,omitempty" db:"quarantine_reason"`
 }
 
+// ToRawPhotoDetails converts the raw photo, leaving its URL to be resolved
+// from its key.
 func (rp *RawPhoto) ToRawPhotoDetails() gen.RawPhotoDetails {
 	return gen.RawPhotoDetails{
 		Id:               rp.ID,
 		UserId:           rp.UserID,
 		OriginalFilename: rp.OriginalFilename,
-		StorageUrl:       rp.StorageURL,
 		FileSize:         rp.FileSize,
 		MimeType:         rp.MimeType,
 		Md5Hash:          rp.MD5Hash,
+		Sha256Hash:       rp.SHA256Hash,
 		Width:            rp.Width,
 		Height:           rp.Height,
 		ProcessedAt:      rp.ProcessedAt,
@@ -41,24 +50,79 @@ func (rp *RawPhoto) ToRawPhotoDetails() gen.RawPhotoDetails {
 
 // Photo represents a photo in the database
 type Photo struct {
-	ID               string     `json:"id" db:"id"`
-	RawPhotoID       string     `json:"raw_photo_id" db:"raw_photo_id"`
-	UserID           string     `json:"user_id" db:"user_id"`
-	Filename         string     `json:"filename" db:"filename"`
-	OriginalURL      string     `json:"original_url" db:"original_url"`
-	ThumbnailURL     string     `json:"thumbnail_url" db:"thumbnail_url"`
-	Caption          *string    `json:"caption,omitempty" db:"caption"`
-	Tags             []string   `json:"tags,omitempty" db:"tags"`
-	FileSize         int64      `json:"file_size" db:"file_size"`
-	MimeType         string     `json:"mime_type" db:"mime_type"`
-	Width            *int       `json:"width,omitempty" db:"width"`
-	Height           *int       `json:"height,omitempty" db:"height"`
-	UploadedAt       time.Time  `json:"uploaded_at" db:"uploaded_at"`
-	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
-	ScheduleDeletion *time.Time `json:"schedule_deletion,omitempty" db:"schedule_deletion"`
+	ID               string         `json:"id" db:"id"`
+	RawPhotoID       string         `json:"raw_photo_id" db:"raw_photo_id"`
+	UserID           string         `json:"user_id" db:"user_id"`
+	Filename         string         `json:"filename" db:"filename"`
+	StorageBackend   string         `json:"storage_backend" db:"storage_backend"` // ID of the storage backend
+	OriginalKey      string         `json:"original_key" db:"original_key"`
+	ThumbnailKey     string         `json:"thumbnail_key" db:"thumbnail_key"` // Empty if there are no variants
+	Caption          *string        `json:"caption,omitempty" db:"caption"`
+	Tags             pq.StringArray `json:"tags,omitempty" db:"tags"`
+	FileSize         int64          `json:"file_size" db:"file_size"`
+	MimeType         string         `json:"mime_type" db:"mime_type"`
+	Width            *int           `json:"width,omitempty" db:"width"`
+	Height           *int           `json:"height,omitempty" db:"height"`
+	BlurHash         *string        `json:"blurhash,omitempty" db:"blurhash"`
+	DominantColor    *string        `json:"dominant_color,omitempty" db:"dominant_color"`
+	Latitude         *float64       `json:"latitude,omitempty" db:"latitude"`
+	Longitude        *float64       `json:"longitude,omitempty" db:"longitude"`
+	PlaceName        *string        `json:"place_name,omitempty" db:"place_name"`
+	UploadedAt       time.Time      `json:"uploaded_at" db:"uploaded_at"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
+	ScheduleDeletion *time.Time     `json:"schedule_deletion,omitempty" db:"schedule_deletion"`
+
+	// Variants are stored in photo_variants
+	Variants []PhotoVariant `json:"variants,omitempty" db:"-"`
+}
+
+// PhotoVariant represents a resized rendition of a photo
+type PhotoVariant struct {
+	PhotoID        string    `json:"photo_id" db:"photo_id"`
+	Name           string    `json:"name" db:"name"`
+	Width          int       `json:"width" db:"width"`
+	Height         int       `json:"height" db:"height"`
+	Format         string    `json:"format" db:"format"`
+	StorageBackend string    `json:"storage_backend" db:"storage_backend"` // ID of the storage backend
+	StorageKey     string    `json:"storage_key" db:"storage_key"`
+	FileSize       int64     `json:"file_size" db:"file_size"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// ToPhotoVariant converts the variant, leaving its URL to be resolved from
+// its key.
+func (v *PhotoVariant) ToPhotoVariant() gen.PhotoVariant {
+	return gen.PhotoVariant{
+		Name:     v.Name,
+		Width:    v.Width,
+		Height:   v.Height,
+		Format:   v.Format,
+		FileSize: v.FileSize,
+	}
+}
+
+// ToPhoto converts the photo, leaving its URL to be resolved from its key.
+func (p *Photo) ToPhoto() gen.Photo {
+	return gen.Photo{
+		Id:            p.ID,
+		Caption:       p.Caption,
+		Tags:          (*[]string)(&p.Tags),
+		Width:         p.Width,
+		Height:        p.Height,
+		BlurHash:      p.BlurHash,
+		DominantColor: p.DominantColor,
+		UploadedAt:    p.UploadedAt,
+	}
 }
 
+// ToPhotoDetails converts the photo, leaving the URLs of its original,
+// thumbnail and variants to be resolved from their keys.
 func (p *Photo) ToPhotoDetails() gen.PhotoDetails {
+	variants := make([]gen.PhotoVariant, len(p.Variants))
+	for i := range p.Variants {
+		variants[i] = p.Variants[i].ToPhotoVariant()
+	}
+
 	return gen.PhotoDetails{
 		Id:               p.ID,
 		UserId:           p.UserID,
@@ -67,13 +131,62 @@ func (p *Photo) ToPhotoDetails() gen.PhotoDetails {
 		Filename:         p.Filename,
 		Height:           p.Height,
 		Width:            p.Width,
+		BlurHash:         p.BlurHash,
+		DominantColor:    p.DominantColor,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		PlaceName:        p.PlaceName,
 		MimeType:         p.MimeType,
-		OriginalUrl:      p.OriginalURL,
-		ThumbnailUrl:     p.ThumbnailURL,
 		RawPhotoId:       p.RawPhotoID,
 		ScheduleDeletion: p.ScheduleDeletion,
-		Tags:             &p.Tags,
+		Tags:             (*[]string)(&p.Tags),
+		Variants:         variants,
 		UploadedAt:       p.UploadedAt,
 		UpdatedAt:        p.UpdatedAt,
 	}
 }
+
+// NearbyPhoto is a photo found near a location, with its distance to it
+type NearbyPhoto struct {
+	Photo
+	DistanceKm float64 `json:"distance_km" db:"distance_km"`
+}
+
+// ToNearbyPhoto converts the photo, leaving its thumbnail URL to be resolved
+// from its key.
+func (p *NearbyPhoto) ToNearbyPhoto() gen.NearbyPhoto {
+	var lat, lon float64
+	if p.Latitude != nil && p.Longitude != nil {
+		lat, lon = *p.Latitude, *p.Longitude
+	}
+
+	return gen.NearbyPhoto{
+		Id:         p.ID,
+		UserId:     p.UserID,
+		Caption:    p.Caption,
+		Latitude:   lat,
+		Longitude:  lon,
+		PlaceName:  p.PlaceName,
+		DistanceKm: p.DistanceKm,
+		UploadedAt: p.UploadedAt,
+	}
+}
+
+// SimilarPhoto is a photo that looks like another, with the Hamming distance
+// between their perceptual hashes
+type SimilarPhoto struct {
+	Photo
+	Distance int `json:"distance" db:"distance"`
+}
+
+// ToSimilarPhoto converts the photo, leaving its thumbnail URL to be resolved
+// from its key.
+func (p *SimilarPhoto) ToSimilarPhoto() gen.SimilarPhoto {
+	return gen.SimilarPhoto{
+		Id:         p.ID,
+		UserId:     p.UserID,
+		Caption:    p.Caption,
+		Distance:   p.Distance,
+		UploadedAt: p.UploadedAt,
+	}
+}
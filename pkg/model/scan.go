@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+
+	"jelly/pkg/api/v1/gen"
+	"jelly/pkg/scan"
+)
+
+// ScanResult is the verdict of scanning an uploaded file. The raw photo of a
+// quarantined file is identified by the user and SHA-256 hash.
+type ScanResult struct {
+	ID         string         `json:"id" db:"id"`
+	UserID     string         `json:"user_id" db:"user_id"`
+	Filename   string         `json:"filename" db:"filename"`
+	SHA256Hash string         `json:"sha256_hash" db:"sha256_hash"`
+	Scanner    string         `json:"scanner" db:"scanner"`
+	Verdict    scan.Verdict   `json:"verdict" db:"verdict"`
+	Labels     pq.StringArray `json:"labels" db:"labels"`
+	ScannedAt  time.Time      `json:"scanned_at" db:"scanned_at"`
+}
+
+func (s *ScanResult) ToScanResult() gen.ScanResult {
+	labels := []string(s.Labels)
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return gen.ScanResult{
+		Id:        s.ID,
+		UserId:    s.UserID,
+		Filename:  s.Filename,
+		Sha256:    s.SHA256Hash,
+		Scanner:   s.Scanner,
+		Verdict:   gen.ScanResultVerdict(s.Verdict),
+		Labels:    labels,
+		ScannedAt: s.ScannedAt,
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Upload represents a resumable upload whose chunks are still being received,
+// or a direct upload sent to storage with a presigned request. Once complete it
+// references the raw photo created from the received bytes.
+type Upload struct {
+	ID           string     `json:"id" db:"id"`
+	UserID       string     `json:"user_id" db:"user_id"`
+	Filename     string     `json:"filename" db:"filename"`
+	UploadLength int64      `json:"upload_length" db:"upload_length"`
+	UploadOffset int64      `json:"upload_offset" db:"upload_offset"`
+	Metadata     *string    `json:"metadata,omitempty" db:"metadata"`
+	Direct       bool       `json:"direct" db:"direct"`
+	MimeType     *string    `json:"mime_type,omitempty" db:"mime_type"`
+	SHA256Hash   *string    `json:"sha256_hash,omitempty" db:"sha256_hash"`
+	RawPhotoID   *string    `json:"raw_photo_id,omitempty" db:"raw_photo_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// IsComplete reports whether all bytes of the upload have been received.
+func (u *Upload) IsComplete() bool {
+	return u.UploadOffset == u.UploadLength
+}
@@ -0,0 +1,26 @@
+package model
+
+// LocationPrivacy is how the location in the EXIF data of a user's photos is
+// kept.
+type LocationPrivacy string
+
+const (
+	// LocationStrip removes the location
+	LocationStrip LocationPrivacy = "strip"
+
+	// LocationCity rounds the location to city level
+	LocationCity LocationPrivacy = "city"
+
+	// LocationKeep keeps the exact location
+	LocationKeep LocationPrivacy = "keep"
+)
+
+// Valid reports whether the privacy setting is known.
+func (p LocationPrivacy) Valid() bool {
+	switch p {
+	case LocationStrip, LocationCity, LocationKeep:
+		return true
+	default:
+		return false
+	}
+}
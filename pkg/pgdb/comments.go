@@ -0,0 +1,35 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+
+	"jelly/pkg/model"
+)
+
+// CreateComment inserts a comment on a photo.
+func (c *Client) CreateComment(ctx context.Context, comment model.Comment) error {
+	query := `
+		INSERT INTO photo_comments (id, photo_id, user_id, content, created_at)
+		VALUES (:id, :photo_id, :user_id, :content, :created_at)`
+
+	_, err := c.db.NamedExecContext(ctx, query, comment)
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// CountPhotoComments returns the number of comments on the photo.
+func (c *Client) CountPhotoComments(ctx context.Context, photoID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM photo_comments WHERE photo_id = $1`
+
+	err := c.db.GetContext(ctx, &count, query, photoID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count photo comments: %w", mapError(err))
+	}
+
+	return count, nil
+}
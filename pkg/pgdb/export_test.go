@@ -0,0 +1,7 @@
+package pgdb
+
+// Helpers of the package's tests used by tests of packages built on it
+var (
+	CreateTestUser  = createTestUser
+	CreateTestPhoto = createTestPhoto
+)
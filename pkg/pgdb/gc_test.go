@@ -0,0 +1,50 @@
+package pgdb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/gc"
+	"jelly/pkg/pgdb"
+	"jelly/pkg/store"
+)
+
+func TestCollector_Run_DeletedPhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := pgdb.NewClient(pgdb.WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	storage := store.NewLocalStorage(t.TempDir(), "http://localhost:8080/media")
+	alice := pgdb.CreateTestUser(t, client, "alice")
+	photo, err := client.GetPhotoByID(ctx, pgdb.CreateTestPhoto(t, client, alice))
+	require.NoError(t, err)
+	raw, err := client.GetRawPhotoByID(ctx, photo.RawPhotoID)
+	require.NoError(t, err)
+	_, err = storage.Upload(ctx, raw.StorageKey, []byte("raw"), "image/jpeg")
+	require.NoError(t, err)
+
+	// Deleting a photo deletes the raw photo it was processed from, and its
+	// object, in the run after the photo expires
+	require.NoError(t, client.DeletePhoto(ctx, photo.ID, -time.Minute))
+	collector := &gc.Collector{
+		DB:        client,
+		Storage:   storage,
+		Backend:   raw.StorageBackend,
+		OrphanAge: time.Hour,
+	}
+	report, err := collector.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Photos)
+	require.Equal(t, 1, report.RawPhotos)
+	require.Zero(t, report.Errors)
+
+	exists, err := storage.Exists(ctx, raw.StorageKey)
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = client.GetRawPhotoByID(ctx, raw.ID)
+	require.ErrorIs(t, err, pgdb.ErrNotFound)
+}
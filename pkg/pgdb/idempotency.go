@@ -0,0 +1,104 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jelly/pkg/model"
+)
+
+// CreateIdempotencyKey claims an idempotency key for a request. If the key is
+// already held by an unexpired request, that request is returned and created
+// is false. Expired keys are reclaimed.
+func (c *Client) CreateIdempotencyKey(ctx context.Context, key model.IdempotencyKey) (
+	existing model.IdempotencyKey, created bool, err error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, created_at, expires_at)
+		VALUES (:user_id, :key, :fingerprint, :created_at, :expires_at)
+		ON CONFLICT (user_id, key) DO UPDATE
+			SET fingerprint = excluded.fingerprint,
+				status_code = NULL,
+				response_headers = NULL,
+				response_body = NULL,
+				created_at = excluded.created_at,
+				expires_at = excluded.expires_at
+			WHERE idempotency_keys.expires_at < now()`
+
+	res, err := c.db.NamedExecContext(ctx, query, key)
+	if err != nil {
+		return model.IdempotencyKey{}, false, fmt.Errorf("failed to create idempotency key: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return model.IdempotencyKey{}, false, fmt.Errorf("failed to create idempotency key: %w", err)
+	} else if n == 1 {
+		return key, true, nil
+	}
+
+	query = `SELECT * FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	err = c.db.GetContext(ctx, &existing, query, key.UserID, key.Key)
+	if err != nil {
+		return model.IdempotencyKey{}, false, fmt.Errorf("failed to get idempotency key: %w", mapError(err))
+	}
+
+	return existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request holding the key,
+// until the key expires.
+func (c *Client) CompleteIdempotencyKey(ctx context.Context, key model.IdempotencyKey) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = :status_code, response_headers = :response_headers, response_body = :response_body,
+			expires_at = :expires_at
+		WHERE user_id = :user_id AND key = :key`
+
+	_, err := c.db.NamedExecContext(ctx, query, key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// RenewIdempotencyKey extends the lease of a key held by a request in
+// progress. Keys of completed requests are left as they are.
+func (c *Client) RenewIdempotencyKey(ctx context.Context, userID, key string, expiresAt time.Time) error {
+	query := `
+		UPDATE idempotency_keys
+		SET expires_at = $3
+		WHERE user_id = $1 AND key = $2 AND status_code IS NULL`
+
+	_, err := c.db.ExecContext(ctx, query, userID, key, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to renew idempotency key: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey releases a key, so the request can be retried.
+func (c *Client) DeleteIdempotencyKey(ctx context.Context, userID, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	_, err := c.db.ExecContext(ctx, query, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes expired keys and returns how many were
+// removed.
+func (c *Client) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at < now()`
+
+	res, err := c.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", mapError(err))
+	}
+
+	return res.RowsAffected()
+}
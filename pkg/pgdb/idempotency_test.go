@@ -0,0 +1,77 @@
+package pgdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+)
+
+func TestClient_IdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	key := model.IdempotencyKey{
+		UserID:      "6f1c2a3e-8a4b-4c5d-9e6f-7a8b9c0d1e2f",
+		Key:         "key-1",
+		Fingerprint: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		CreatedAt:   time.Now().UTC(),
+		ExpiresAt:   time.Now().UTC().Add(time.Hour),
+	}
+
+	_, created, err := client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	// A retry finds the request in progress, whose lease is renewed
+	existing, created, err := client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.False(t, existing.IsComplete())
+
+	renewed := time.Now().UTC().Add(2 * time.Hour)
+	require.NoError(t, client.RenewIdempotencyKey(ctx, key.UserID, key.Key, renewed))
+	existing, _, err = client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.WithinDuration(t, renewed, existing.ExpiresAt, time.Second)
+
+	status := http.StatusCreated
+	key.StatusCode = &status
+	key.ResponseHeaders = stringPtr(`{"Content-Type":["application/json"]}`)
+	key.ResponseBody = []byte(`{"id":"1"}`)
+	require.NoError(t, client.CompleteIdempotencyKey(ctx, key))
+
+	existing, created, err = client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, status, *existing.StatusCode)
+	require.Equal(t, key.ResponseBody, existing.ResponseBody)
+
+	// Completed keys aren't renewed
+	require.NoError(t, client.RenewIdempotencyKey(ctx, key.UserID, key.Key, time.Now().UTC().Add(48*time.Hour)))
+	existing, _, err = client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.WithinDuration(t, key.ExpiresAt, existing.ExpiresAt, time.Second)
+
+	// Expired keys are reclaimed
+	_, err = client.db.Exec(`UPDATE idempotency_keys SET expires_at = now() - interval '1 minute'`)
+	require.NoError(t, err)
+	_, created, err = client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	require.NoError(t, client.DeleteIdempotencyKey(ctx, key.UserID, key.Key))
+	_, created, err = client.CreateIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.True(t, created)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
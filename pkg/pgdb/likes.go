@@ -1,6 +1,23 @@
 package pgdb
 
-func (c *Client) LikePhoto(userID, photoID string) error {
+import (
+	"context"
+	"fmt"
+)
+
+// LikePhoto records that the user likes the photo. Liking a photo again has no
+// effect.
+func (c *Client) LikePhoto(ctx context.Context, userID, photoID string) error {
+	query := `
+		INSERT INTO photo_likes (photo_id, user_id, created_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (photo_id, user_id) DO NOTHING`
+
+	_, err := c.db.ExecContext(ctx, query, photoID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to like photo: %w", mapError(err))
+	}
+
 	return nil
 }
 
@@ -21,14 +38,14 @@ func (c *Client) GetPhotoLikes(photoID string) ([]string, error) {
 	return likes, nil
 }
 
-func (c *Client) CountPhotoLikes(photoID string) (int, error) {
+// CountPhotoLikes returns the number of likes of the photo.
+func (c *Client) CountPhotoLikes(ctx context.Context, photoID string) (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM photo_likes WHERE photo_id = $1`
 
-	err := c.db.Get(&count, query, photoID)
+	err := c.db.GetContext(ctx, &count, query, photoID)
 	if err != nil {
-		// TODO
-		return 0, err
+		return 0, fmt.Errorf("failed to count photo likes: %w", mapError(err))
 	}
 
 	return count, nil
@@ -1,25 +1,349 @@
 package pgdb
 
 import (
+	"context"
+	"fmt"
 	"time"
 
-	"github.com/google/uuid"
-
+	"jelly/pkg/geo"
 	"jelly/pkg/model"
 )
 
-func (c *Client) CreatePhoto(photo model.Photo) error {
+// CreatePhoto inserts a photo and its variants.
+func (c *Client) CreatePhoto(ctx context.Context, photo model.Photo) (err error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create photo: %w", err)
+	}
+	// Deferred in a closure so the rollback sees the returned error
+	defer func() { HandleTxError(err, tx.Tx)() }()
+
+	query := `
+		INSERT INTO photos (id, raw_photo_id, user_id, filename, storage_backend, original_key, thumbnail_key,
+			caption, tags, file_size, mime_type, width, height, blurhash, dominant_color, latitude, longitude, place_name,
+			uploaded_at, updated_at)
+		VALUES (:id, :raw_photo_id, :user_id, :filename, :storage_backend, :original_key, :thumbnail_key,
+			:caption, :tags, :file_size, :mime_type, :width, :height, :blurhash, :dominant_color, :latitude, :longitude,
+			:place_name, :uploaded_at, :updated_at)`
+
+	if _, err = tx.NamedExecContext(ctx, query, photo); err != nil {
+		return fmt.Errorf("failed to create photo: %w", mapError(err))
+	}
+
+	if len(photo.Variants) > 0 {
+		query = `
+			INSERT INTO photo_variants (photo_id, name, width, height, format, storage_backend, storage_key,
+				file_size, created_at)
+			VALUES (:photo_id, :name, :width, :height, :format, :storage_backend, :storage_key,
+				:file_size, :created_at)`
+
+		if _, err = tx.NamedExecContext(ctx, query, photo.Variants); err != nil {
+			return fmt.Errorf("failed to create photo variants: %w", mapError(err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create photo: %w", err)
+	}
+
 	return nil
 }
 
-func (c *Client) GetPhotoByID(photoID uuid.UUID) (model.Photo, error) {
-	return model.Photo{}, nil
+// GetPhotoByID returns the photo with the given ID and its variants, or
+// ErrNotFound if it doesn't exist or is scheduled for deletion.
+func (c *Client) GetPhotoByID(ctx context.Context, photoID string) (model.Photo, error) {
+	var photo model.Photo
+	query := `SELECT * FROM photos WHERE id = $1 AND schedule_deletion IS NULL`
+
+	err := c.db.GetContext(ctx, &photo, query, photoID)
+	if err != nil {
+		return model.Photo{}, fmt.Errorf("failed to get photo: %w", mapError(err))
+	}
+
+	photo.Variants, err = c.getPhotoVariants(ctx, photo.ID)
+	if err != nil {
+		return model.Photo{}, err
+	}
+
+	return photo, nil
+}
+
+// GetPhotoByRawPhotoID returns the first photo processed from the raw photo
+// that isn't scheduled for deletion and its variants, or ErrNotFound.
+func (c *Client) GetPhotoByRawPhotoID(ctx context.Context, rawPhotoID string) (model.Photo, error) {
+	var photo model.Photo
+	query := `
+		SELECT * FROM photos WHERE raw_photo_id = $1 AND schedule_deletion IS NULL
+		ORDER BY uploaded_at LIMIT 1`
+
+	err := c.db.GetContext(ctx, &photo, query, rawPhotoID)
+	if err != nil {
+		return model.Photo{}, fmt.Errorf("failed to get photo: %w", mapError(err))
+	}
+
+	photo.Variants, err = c.getPhotoVariants(ctx, photo.ID)
+	if err != nil {
+		return model.Photo{}, err
+	}
+
+	return photo, nil
 }
 
-func (c *Client) UpdatePhoto(photo model.Photo) error {
+// getPhotoVariants returns the variants of a photo in each format, smallest
+// first.
+func (c *Client) getPhotoVariants(ctx context.Context, photoID string) ([]model.PhotoVariant, error) {
+	variants := []model.PhotoVariant{}
+	query := `SELECT * FROM photo_variants WHERE photo_id = $1 ORDER BY width, height, name, format`
+
+	err := c.db.SelectContext(ctx, &variants, query, photoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo variants: %w", mapError(err))
+	}
+
+	return variants, nil
+}
+
+// UpdatePhoto updates the caption and tags of a photo, or returns ErrNotFound.
+func (c *Client) UpdatePhoto(ctx context.Context, photo model.Photo) error {
+	query := `UPDATE photos SET caption = :caption, tags = :tags, updated_at = now() WHERE id = :id`
+
+	res, err := c.db.NamedExecContext(ctx, query, photo)
+	if err != nil {
+		return fmt.Errorf("failed to update photo: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update photo: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("failed to update photo: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePhotoPlace sets the place name of a photo, clearing it if nil, or
+// returns ErrNotFound.
+func (c *Client) UpdatePhotoPlace(ctx context.Context, photoID string, placeName *string) error {
+	query := `UPDATE photos SET place_name = $2, updated_at = now() WHERE id = $1`
+
+	res, err := c.db.ExecContext(ctx, query, photoID, placeName)
+	if err != nil {
+		return fmt.Errorf("failed to update photo place: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update photo place: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("failed to update photo place: %w", ErrNotFound)
+	}
+
 	return nil
 }
 
-func (c *Client) DeletePhoto(photoID uuid.UUID, deletionDuration time.Duration) error {
+// GetPhotosNearby returns the photos within radiusKm of a location, nearest
+// first, skipping offset photos and returning at most limit. Photos of users
+// who opted out of sharing their location and photos scheduled for deletion
+// are left out. Variants aren't loaded.
+func (c *Client) GetPhotosNearby(ctx context.Context, lat, lon, radiusKm float64, limit, offset int) (
+	[]model.NearbyPhoto, error) {
+	photos := []model.NearbyPhoto{}
+	box := geo.BoundingBox(lat, lon, radiusKm)
+
+	// The bounding box is matched by the location index, the haversine
+	// distance is only computed for the photos within it
+	query := `
+		SELECT * FROM (
+			SELECT p.*, 2 * $3::float8 * asin(least(1, sqrt(
+				power(sin(radians(p.latitude - $1) / 2), 2) +
+				cos(radians($1)) * cos(radians(p.latitude)) * power(sin(radians(p.longitude - $2) / 2), 2)
+			))) AS distance_km
+			FROM photos p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.latitude IS NOT NULL AND p.schedule_deletion IS NULL
+				AND p.latitude BETWEEN $4 AND $5 AND p.longitude BETWEEN $6 AND $7
+				AND u.location_privacy <> 'strip'
+		) nearby
+		WHERE distance_km <= $8
+		ORDER BY distance_km, id
+		LIMIT $9 OFFSET $10`
+
+	err := c.db.SelectContext(ctx, &photos, query, lat, lon, geo.EarthRadiusKm,
+		box.MinLat, box.MaxLat, box.MinLon, box.MaxLon, radiusKm, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nearby photos: %w", mapError(err))
+	}
+
+	return photos, nil
+}
+
+// GetSimilarPhotos returns the photos whose raw photos have a perceptual hash
+// within maxDistance bits of the hash, nearest first and at most limit.
+// Photos of the excluded raw photo and photos scheduled for deletion are left
+// out. Variants aren't loaded.
+func (c *Client) GetSimilarPhotos(ctx context.Context, hash int64, excludeRawPhotoID string, maxDistance,
+	limit int) ([]model.SimilarPhoto, error) {
+	return c.getSimilarPhotos(ctx, "r.id <> $4", hash, maxDistance, limit, excludeRawPhotoID)
+}
+
+// GetSimilarUserPhotos returns the user's photos whose raw photos have a
+// perceptual hash within maxDistance bits of the hash, nearest first and at
+// most limit. Photos scheduled for deletion are left out. Variants aren't
+// loaded.
+func (c *Client) GetSimilarUserPhotos(ctx context.Context, userID string, hash int64, maxDistance,
+	limit int) ([]model.SimilarPhoto, error) {
+	return c.getSimilarPhotos(ctx, "r.user_id = $4", hash, maxDistance, limit, userID)
+}
+
+// getSimilarPhotos returns the photos within maxDistance of the hash that also
+// match the filter on the raw photo r, which is passed its value as $4.
+func (c *Client) getSimilarPhotos(ctx context.Context, filter string, hash int64, maxDistance, limit int,
+	value string) ([]model.SimilarPhoto, error) {
+	photos := []model.SimilarPhoto{}
+	query := `
+		SELECT * FROM (
+			SELECT p.*, bit_count((r.perceptual_hash # $1)::bit(64)) AS distance
+			FROM photos p
+			JOIN raw_photos r ON r.id = p.raw_photo_id
+			WHERE r.perceptual_hash IS NOT NULL AND p.schedule_deletion IS NULL AND ` + filter + `
+		) similar
+		WHERE distance <= $2
+		ORDER BY distance, uploaded_at DESC, id
+		LIMIT $3`
+
+	err := c.db.SelectContext(ctx, &photos, query, hash, maxDistance, limit, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get similar photos: %w", mapError(err))
+	}
+
+	return photos, nil
+}
+
+// DeletePhoto schedules a photo for deletion after the given duration, or
+// returns ErrNotFound if it doesn't exist or is already scheduled.
+func (c *Client) DeletePhoto(ctx context.Context, photoID string, deletionDuration time.Duration) error {
+	query := `
+		UPDATE photos SET schedule_deletion = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id = $1 AND schedule_deletion IS NULL`
+
+	res, err := c.db.ExecContext(ctx, query, photoID, deletionDuration.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to delete photo: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete photo: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("failed to delete photo: %w", ErrNotFound)
+	}
+
 	return nil
 }
+
+// GetExpiredPhotos returns the photos whose scheduled deletion has passed and
+// their variants, ordered by ID after the given one.
+func (c *Client) GetExpiredPhotos(ctx context.Context, after string, limit int) ([]model.Photo, error) {
+	photos := []model.Photo{}
+	query := `
+		SELECT * FROM photos
+		WHERE schedule_deletion <= now() AND CAST(id AS text) > $1
+		ORDER BY CAST(id AS text)
+		LIMIT $2`
+
+	err := c.db.SelectContext(ctx, &photos, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired photos: %w", mapError(err))
+	}
+
+	for i := range photos {
+		photos[i].Variants, err = c.getPhotoVariants(ctx, photos[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return photos, nil
+}
+
+// PurgePhoto permanently deletes a photo whose scheduled deletion has passed,
+// with its likes, comments and variants, and returns how many likes and
+// comments were deleted. The raw photo it was processed from is scheduled for
+// deletion once no photo refers to it anymore. ErrNotFound is returned if the
+// photo doesn't exist or isn't expired.
+func (c *Client) PurgePhoto(ctx context.Context, photoID string) (likes, comments int64, err error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge photo: %w", err)
+	}
+	// Deferred in a closure so the rollback sees the returned error
+	defer func() { HandleTxError(err, tx.Tx)() }()
+
+	// Locking the photo keeps likes and comments from being added meanwhile
+	var rawPhotoID string
+	query := `SELECT raw_photo_id FROM photos WHERE id = $1 AND schedule_deletion <= now() FOR UPDATE`
+	if err = tx.GetContext(ctx, &rawPhotoID, query, photoID); err != nil {
+		return 0, 0, fmt.Errorf("failed to purge photo: %w", mapError(err))
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM photo_likes WHERE photo_id = $1`, photoID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete photo likes: %w", mapError(err))
+	}
+	if likes, err = res.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete photo likes: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM photo_comments WHERE photo_id = $1`, photoID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete photo comments: %w", mapError(err))
+	}
+	if comments, err = res.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete photo comments: %w", err)
+	}
+
+	// Variants are deleted by the cascade
+	if _, err = tx.ExecContext(ctx, `DELETE FROM photos WHERE id = $1`, photoID); err != nil {
+		return 0, 0, fmt.Errorf("failed to purge photo: %w", mapError(err))
+	}
+
+	// The raw photo is left for other photos processed from it
+	query = `
+		UPDATE raw_photos r SET schedule_deletion = now()
+		WHERE r.id = $1 AND r.schedule_deletion IS NULL
+			AND NOT EXISTS (SELECT 1 FROM photos p WHERE p.raw_photo_id = r.id)`
+	if _, err = tx.ExecContext(ctx, query, rawPhotoID); err != nil {
+		return 0, 0, fmt.Errorf("failed to schedule raw photo deletion: %w", mapError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to purge photo: %w", err)
+	}
+
+	return likes, comments, nil
+}
+
+// PhotoExists reports whether the photo exists, even if it's scheduled for
+// deletion.
+func (c *Client) PhotoExists(ctx context.Context, photoID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM photos WHERE id = $1)`
+
+	err := c.db.GetContext(ctx, &exists, query, photoID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check photo: %w", mapError(err))
+	}
+
+	return exists, nil
+}
+
+// PhotoVariantExists reports whether a photo variant is stored under the key.
+func (c *Client) PhotoVariantExists(ctx context.Context, storageKey string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM photo_variants WHERE storage_key = $1)`
+
+	err := c.db.GetContext(ctx, &exists, query, storageKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to check photo variant: %w", mapError(err))
+	}
+
+	return exists, nil
+}
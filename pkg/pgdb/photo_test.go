@@ -0,0 +1,396 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+)
+
+// createTestPhoto inserts a photo, and the raw photo it was processed from,
+// owned by the user.
+func createTestPhoto(t *testing.T, client *Client, userID string) string {
+	rawID := uuid.New().String()
+	_, err := client.db.Exec(`
+		INSERT INTO raw_photos (id, user_id, original_filename, storage_backend, storage_key, file_size,
+			mime_type, md5_hash, sha256_hash)
+		VALUES ($1, $2, 'photo.jpg', 'default', 'raw/photo.jpg', 1024, 'image/jpeg', '', $3)`,
+		rawID, userID, uuid.New().String())
+	require.NoError(t, err)
+
+	id := uuid.New().String()
+	_, err = client.db.Exec(`
+		INSERT INTO photos (id, raw_photo_id, user_id, filename, storage_backend, original_key, thumbnail_key,
+			file_size, mime_type)
+		VALUES ($1, $2, $3, 'photo.jpg', 'default', 'raw/photo.jpg', 'photos/thumb.jpg', 1024, 'image/jpeg')`,
+		id, rawID, userID)
+	require.NoError(t, err)
+
+	return id
+}
+
+func TestClient_CreatePhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	existing, err := client.GetPhotoByID(ctx, createTestPhoto(t, client, alice))
+	require.NoError(t, err)
+
+	_, err = client.GetPhotoByRawPhotoID(ctx, uuid.New().String())
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// A second photo processed from the same raw photo
+	now := time.Now().Truncate(time.Microsecond)
+	blurHash, dominantColor := "LEHV6nWB2yk8pyo0adR*.7kCMdnj", "#4a6b8c"
+	photo := model.Photo{
+		ID:             uuid.New().String(),
+		RawPhotoID:     existing.RawPhotoID,
+		UserID:         alice,
+		Filename:       "photo.jpg",
+		StorageBackend: "default",
+		OriginalKey:    existing.OriginalKey,
+		Tags:           []string{},
+		FileSize:       1024,
+		MimeType:       "image/jpeg",
+		BlurHash:       &blurHash,
+		DominantColor:  &dominantColor,
+		UploadedAt:     now.Add(time.Minute),
+		UpdatedAt:      now.Add(time.Minute),
+	}
+	for _, v := range []struct {
+		name, format, ext string
+		width, height     int
+	}{{"large", "jpeg", ".jpg", 1080, 720}, {"thumb", "webp", ".webp", 150, 150}, {"thumb", "jpeg", ".jpg", 150, 150}} {
+		photo.Variants = append(photo.Variants, model.PhotoVariant{
+			PhotoID:        photo.ID,
+			Name:           v.name,
+			Width:          v.width,
+			Height:         v.height,
+			Format:         v.format,
+			StorageBackend: "default",
+			StorageKey:     "photos/" + photo.ID + "/" + v.name + v.ext,
+			FileSize:       512,
+			CreatedAt:      now,
+		})
+	}
+	photo.ThumbnailKey = "photos/" + photo.ID + "/thumb.jpg"
+	require.NoError(t, client.CreatePhoto(ctx, photo))
+
+	got, err := client.GetPhotoByID(ctx, photo.ID)
+	require.NoError(t, err)
+	require.Equal(t, &blurHash, got.BlurHash)
+	require.Equal(t, &dominantColor, got.DominantColor)
+	require.Equal(t, "default", got.StorageBackend)
+	require.Equal(t, "photos/"+photo.ID+"/thumb.jpg", got.ThumbnailKey)
+	// Each variant is recorded in each format
+	require.Len(t, got.Variants, 3)
+	require.Equal(t, "thumb", got.Variants[0].Name)
+	require.Equal(t, "jpeg", got.Variants[0].Format)
+	require.Equal(t, "thumb", got.Variants[1].Name)
+	require.Equal(t, "webp", got.Variants[1].Format)
+	require.Equal(t, "large", got.Variants[2].Name)
+
+	// The oldest photo of a raw photo is returned
+	got, err = client.GetPhotoByRawPhotoID(ctx, existing.RawPhotoID)
+	require.NoError(t, err)
+	require.Equal(t, existing.ID, got.ID)
+
+	// unless it's scheduled for deletion
+	require.NoError(t, client.DeletePhoto(ctx, existing.ID, time.Hour))
+	got, err = client.GetPhotoByRawPhotoID(ctx, existing.RawPhotoID)
+	require.NoError(t, err)
+	require.Equal(t, photo.ID, got.ID)
+
+	// Variants are created with their photo or not at all
+	photo.ID = uuid.New().String()
+	photo.Variants = append(photo.Variants, photo.Variants[0])
+	for i := range photo.Variants {
+		photo.Variants[i].PhotoID = photo.ID
+	}
+	require.ErrorIs(t, client.CreatePhoto(ctx, photo), ErrDuplicate)
+	_, err = client.GetPhotoByID(ctx, photo.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_UpdatePhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	photo, err := client.GetPhotoByID(ctx, createTestPhoto(t, client, alice))
+	require.NoError(t, err)
+
+	caption := "Beautiful sunset"
+	photo.Caption = &caption
+	photo.Tags = []string{"sunset"}
+	require.NoError(t, client.UpdatePhoto(ctx, photo))
+
+	got, err := client.GetPhotoByID(ctx, photo.ID)
+	require.NoError(t, err)
+	require.Equal(t, &caption, got.Caption)
+	require.Equal(t, []string{"sunset"}, []string(got.Tags))
+
+	photo.ID = uuid.New().String()
+	require.ErrorIs(t, client.UpdatePhoto(ctx, photo), ErrNotFound)
+}
+
+func TestClient_DeletePhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	id := createTestPhoto(t, client, alice)
+
+	require.NoError(t, client.DeletePhoto(ctx, id, 30*24*time.Hour))
+
+	var scheduled time.Time
+	require.NoError(t, client.db.Get(&scheduled, `SELECT schedule_deletion FROM photos WHERE id = $1`, id))
+	require.WithinDuration(t, time.Now().Add(30*24*time.Hour), scheduled, time.Minute)
+
+	// Photos scheduled for deletion are gone for everyone but the collector
+	_, err = client.GetPhotoByID(ctx, id)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, client.DeletePhoto(ctx, id, time.Hour), ErrNotFound)
+
+	require.ErrorIs(t, client.DeletePhoto(ctx, uuid.New().String(), time.Hour), ErrNotFound)
+}
+
+func TestClient_UpdatePhotoPlace(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	id := createTestPhoto(t, client, alice)
+
+	place := "Stanley Park"
+	require.NoError(t, client.UpdatePhotoPlace(ctx, id, &place))
+	got, err := client.GetPhotoByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, &place, got.PlaceName)
+
+	require.NoError(t, client.UpdatePhotoPlace(ctx, id, nil))
+	got, err = client.GetPhotoByID(ctx, id)
+	require.NoError(t, err)
+	require.Nil(t, got.PlaceName)
+
+	require.ErrorIs(t, client.UpdatePhotoPlace(ctx, uuid.New().String(), &place), ErrNotFound)
+}
+
+func TestClient_GetPhotosNearby(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	bob := createTestUser(t, client, "bob")
+	require.NoError(t, client.UpdateLocationPrivacy(ctx, alice, model.LocationKeep))
+
+	locate := func(userID string, lat, lon float64) string {
+		id := createTestPhoto(t, client, userID)
+		_, err := client.db.Exec(`UPDATE photos SET latitude = $2, longitude = $3 WHERE id = $1`, id, lat, lon)
+		require.NoError(t, err)
+		return id
+	}
+
+	// Around downtown Vancouver at 49.2827, -123.1207
+	stanleyPark := locate(alice, 49.3017, -123.1417) // 2.6 km
+	gastown := locate(alice, 49.2840, -123.1090)     // 0.9 km
+	locate(alice, 49.1666, -123.1336)                // Richmond, 12.9 km
+	locate(alice, 47.6062, -122.3321)                // Seattle, 195 km
+	createTestPhoto(t, client, alice)                // No location
+	deleted := locate(alice, 49.2830, -123.1210)
+	require.NoError(t, client.DeletePhoto(ctx, deleted, time.Hour))
+	optedOut := locate(bob, 49.2828, -123.1208) // Bob strips locations
+
+	photos, err := client.GetPhotosNearby(ctx, 49.2827, -123.1207, 5, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, photos, 2)
+	require.Equal(t, gastown, photos[0].ID)
+	require.InDelta(t, 0.9, photos[0].DistanceKm, 0.1)
+	require.Equal(t, stanleyPark, photos[1].ID)
+	require.InDelta(t, 2.6, photos[1].DistanceKm, 0.1)
+
+	photos, err = client.GetPhotosNearby(ctx, 49.2827, -123.1207, 5, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+	require.Equal(t, stanleyPark, photos[0].ID)
+
+	photos, err = client.GetPhotosNearby(ctx, 49.2827, -123.1207, 20, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, photos, 3)
+
+	// Photos of users who opt out later are left out too
+	require.NoError(t, client.UpdateLocationPrivacy(ctx, bob, model.LocationCity))
+	photos, err = client.GetPhotosNearby(ctx, 49.2827, -123.1207, 1, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, photos, 2)
+	require.Equal(t, optedOut, photos[0].ID)
+
+	require.NoError(t, client.UpdateLocationPrivacy(ctx, bob, model.LocationStrip))
+	photos, err = client.GetPhotosNearby(ctx, 49.2827, -123.1207, 1, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+	require.Equal(t, gastown, photos[0].ID)
+}
+
+func TestClient_GetSimilarPhotos(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	bob := createTestUser(t, client, "bob")
+
+	hashed := func(userID string, hash int64) (photoID, rawID string) {
+		photoID = createTestPhoto(t, client, userID)
+		photo, err := client.GetPhotoByID(ctx, photoID)
+		require.NoError(t, err)
+		_, err = client.db.Exec(`UPDATE raw_photos SET perceptual_hash = $2 WHERE id = $1`, photo.RawPhotoID, hash)
+		require.NoError(t, err)
+		return photoID, photo.RawPhotoID
+	}
+
+	// Hashes use all 64 bits, so they're stored as negative numbers too
+	hash := int64(-0x0f0f0f0f0f0f0f10)
+	original, originalRaw := hashed(alice, hash)
+	resized, _ := hashed(alice, hash^0b101)    // 2 bits
+	edited, _ := hashed(bob, hash^0b1111_0000) // 4 bits
+	hashed(alice, ^hash)                       // 64 bits
+	createTestPhoto(t, client, alice)          // Not hashed
+	deleted, _ := hashed(alice, hash)
+	require.NoError(t, client.DeletePhoto(ctx, deleted, time.Hour))
+
+	photos, err := client.GetSimilarPhotos(ctx, hash, originalRaw, 10, 10)
+	require.NoError(t, err)
+	require.Len(t, photos, 2)
+	require.Equal(t, resized, photos[0].ID)
+	require.Equal(t, 2, photos[0].Distance)
+	require.Equal(t, edited, photos[1].ID)
+	require.Equal(t, 4, photos[1].Distance)
+
+	photos, err = client.GetSimilarPhotos(ctx, hash, originalRaw, 3, 10)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+
+	photos, err = client.GetSimilarPhotos(ctx, hash, originalRaw, 10, 1)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+
+	// Only the user's photos, including the photo itself
+	photos, err = client.GetSimilarUserPhotos(ctx, alice, hash, 10, 10)
+	require.NoError(t, err)
+	require.Len(t, photos, 2)
+	require.Equal(t, original, photos[0].ID)
+	require.Equal(t, 0, photos[0].Distance)
+	require.Equal(t, resized, photos[1].ID)
+}
+
+func TestClient_CountPhotoLikesAndComments(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	bob := createTestUser(t, client, "bob")
+	id := createTestPhoto(t, client, alice)
+
+	require.NoError(t, client.LikePhoto(ctx, alice, id))
+	require.NoError(t, client.LikePhoto(ctx, bob, id))
+	require.NoError(t, client.LikePhoto(ctx, bob, id))
+
+	likes, err := client.CountPhotoLikes(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 2, likes)
+
+	require.NoError(t, client.CreateComment(ctx, model.Comment{
+		ID:        uuid.New().String(),
+		PhotoID:   id,
+		UserID:    bob,
+		Content:   "Great shot!",
+		CreatedAt: time.Now(),
+	}))
+
+	comments, err := client.CountPhotoComments(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, comments)
+}
+
+func TestClient_PurgePhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	bob := createTestUser(t, client, "bob")
+	expired := createTestPhoto(t, client, alice)
+	scheduled := createTestPhoto(t, client, alice)
+	kept := createTestPhoto(t, client, alice)
+
+	_, err = client.db.Exec(`
+		INSERT INTO photo_variants (photo_id, name, width, height, format, storage_backend, storage_key, file_size)
+		VALUES ($1, 'thumb', 150, 150, 'jpeg', 'default', $2, 512)`,
+		expired, "photos/"+expired+"/thumb.jpg")
+	require.NoError(t, err)
+	require.NoError(t, client.LikePhoto(ctx, bob, expired))
+	require.NoError(t, client.CreateComment(ctx, model.Comment{
+		ID:        uuid.New().String(),
+		PhotoID:   expired,
+		UserID:    bob,
+		Content:   "Great shot!",
+		CreatedAt: time.Now(),
+	}))
+	require.NoError(t, client.DeletePhoto(ctx, expired, -time.Minute))
+	require.NoError(t, client.DeletePhoto(ctx, scheduled, time.Hour))
+
+	photos, err := client.GetExpiredPhotos(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+	require.Equal(t, expired, photos[0].ID)
+	require.Len(t, photos[0].Variants, 1)
+
+	photos, err = client.GetExpiredPhotos(ctx, expired, 10)
+	require.NoError(t, err)
+	require.Empty(t, photos)
+
+	exists, err := client.PhotoVariantExists(ctx, "photos/"+expired+"/thumb.jpg")
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = client.PhotoExists(ctx, expired)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	likes, comments, err := client.PurgePhoto(ctx, expired)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), likes)
+	require.Equal(t, int64(1), comments)
+
+	exists, err = client.PhotoExists(ctx, expired)
+	require.NoError(t, err)
+	require.False(t, exists)
+	exists, err = client.PhotoVariantExists(ctx, "photos/"+expired+"/thumb.jpg")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Photos that aren't expired are kept
+	for _, id := range []string{expired, scheduled, kept} {
+		_, _, err = client.PurgePhoto(ctx, id)
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+}
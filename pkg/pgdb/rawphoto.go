@@ -1,7 +1,110 @@
 package pgdb
 
-import "jelly/pkg/model"
+import (
+	"context"
+	"fmt"
+
+	"jelly/pkg/model"
+)
+
+// CreateRawPhoto inserts a raw photo. ErrDuplicate is returned if the user has
+// already uploaded a photo with the same SHA-256 hash.
+func (c *Client) CreateRawPhoto(ctx context.Context, photo model.RawPhoto) error {
+	query := `
+		INSERT INTO raw_photos (id, user_id, original_filename, storage_backend, storage_key, file_size,
+			mime_type, md5_hash, sha256_hash, width, height, exif_data, perceptual_hash, uploaded_at,
+			quarantined_at, quarantine_reason)
+		VALUES (:id, :user_id, :original_filename, :storage_backend, :storage_key, :file_size,
+			:mime_type, :md5_hash, :sha256_hash, :width, :height, :exif_data, :perceptual_hash, :uploaded_at,
+			:quarantined_at, :quarantine_reason)`
+
+	_, err := c.db.NamedExecContext(ctx, query, photo)
+	if err != nil {
+		return fmt.Errorf("failed to create raw photo: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetRawPhotoByHash returns the user's raw photo with the given SHA-256 hash,
+// or ErrNotFound if the user has not uploaded it.
+func (c *Client) GetRawPhotoByHash(ctx context.Context, userID, sha256Hash string) (model.RawPhoto, error) {
+	var photo model.RawPhoto
+	query := `SELECT * FROM raw_photos WHERE user_id = $1 AND sha256_hash = $2`
+
+	err := c.db.GetContext(ctx, &photo, query, userID, sha256Hash)
+	if err != nil {
+		return model.RawPhoto{}, fmt.Errorf("failed to get raw photo: %w", mapError(err))
+	}
+
+	return photo, nil
+}
+
+// GetRawPhotoByID returns the raw photo with the given ID, or ErrNotFound.
+func (c *Client) GetRawPhotoByID(ctx context.Context, rawPhotoID string) (model.RawPhoto, error) {
+	var photo model.RawPhoto
+	query := `SELECT * FROM raw_photos WHERE id = $1`
+
+	err := c.db.GetContext(ctx, &photo, query, rawPhotoID)
+	if err != nil {
+		return model.RawPhoto{}, fmt.Errorf("failed to get raw photo: %w", mapError(err))
+	}
+
+	return photo, nil
+}
+
+// GetExpiredRawPhotos returns the raw photos whose scheduled deletion has
+// passed and that no photo was processed from anymore, ordered by ID after the
+// given one.
+func (c *Client) GetExpiredRawPhotos(ctx context.Context, after string, limit int) ([]model.RawPhoto, error) {
+	photos := []model.RawPhoto{}
+	query := `
+		SELECT * FROM raw_photos r
+		WHERE r.schedule_deletion <= now() AND CAST(r.id AS text) > $1
+			AND NOT EXISTS (SELECT 1 FROM photos p WHERE p.raw_photo_id = r.id)
+		ORDER BY CAST(r.id AS text)
+		LIMIT $2`
+
+	err := c.db.SelectContext(ctx, &photos, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired raw photos: %w", mapError(err))
+	}
+
+	return photos, nil
+}
+
+// PurgeRawPhoto permanently deletes a raw photo whose scheduled deletion has
+// passed, with the uploads it was completed from. ErrNotFound is returned if
+// the raw photo doesn't exist, isn't expired or a photo was processed from it.
+func (c *Client) PurgeRawPhoto(ctx context.Context, rawPhotoID string) (err error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to purge raw photo: %w", err)
+	}
+	// Deferred in a closure so the rollback sees the returned error
+	defer func() { HandleTxError(err, tx.Tx)() }()
+
+	var id string
+	query := `
+		SELECT id FROM raw_photos r
+		WHERE r.id = $1 AND r.schedule_deletion <= now()
+			AND NOT EXISTS (SELECT 1 FROM photos p WHERE p.raw_photo_id = r.id)
+		FOR UPDATE`
+	if err = tx.GetContext(ctx, &id, query, rawPhotoID); err != nil {
+		return fmt.Errorf("failed to purge raw photo: %w", mapError(err))
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM photo_uploads WHERE raw_photo_id = $1`, rawPhotoID); err != nil {
+		return fmt.Errorf("failed to delete uploads: %w", mapError(err))
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM raw_photos WHERE id = $1`, rawPhotoID); err != nil {
+		return fmt.Errorf("failed to purge raw photo: %w", mapError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to purge raw photo: %w", err)
+	}
 
-func (c *Client) CreateRawPhoto(photo model.RawPhoto) error {
 	return nil
 }
@@ -0,0 +1,162 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+)
+
+// createTestUser inserts a user so rows referencing users can be created.
+func createTestUser(t *testing.T, client *Client, username string) string {
+	id := uuid.New().String()
+	_, err := client.db.Exec(`INSERT INTO users (id, username) VALUES ($1, $2)`, id, username)
+	require.NoError(t, err)
+	return id
+}
+
+func TestClient_CreateRawPhoto_DeduplicatesPerUser(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	bob := createTestUser(t, client, "bob")
+
+	hash := int64(-0x0f0f0f0f0f0f0f10)
+	raw := model.RawPhoto{
+		ID:               uuid.New().String(),
+		UserID:           alice,
+		OriginalFilename: "photo.jpg",
+		StorageBackend:   "default",
+		StorageKey:       "raw/" + alice + "/photo.jpg",
+		FileSize:         1024,
+		MimeType:         "image/jpeg",
+		MD5Hash:          "5d41402abc4b2a76b9719d911017c592",
+		SHA256Hash:       "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		PerceptualHash:   &hash,
+		UploadedAt:       time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, client.CreateRawPhoto(ctx, raw))
+
+	got, err := client.GetRawPhotoByHash(ctx, alice, raw.SHA256Hash)
+	require.NoError(t, err)
+	require.Equal(t, raw.ID, got.ID)
+
+	got, err = client.GetRawPhotoByID(ctx, raw.ID)
+	require.NoError(t, err)
+	require.Equal(t, raw.SHA256Hash, got.SHA256Hash)
+	require.Equal(t, &hash, got.PerceptualHash)
+
+	_, err = client.GetRawPhotoByID(ctx, uuid.New().String())
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The same user uploading the same content is a duplicate
+	again := raw
+	again.ID = uuid.New().String()
+	require.ErrorIs(t, client.CreateRawPhoto(ctx, again), ErrDuplicate)
+
+	// Another user uploading the same content is not
+	_, err = client.GetRawPhotoByHash(ctx, bob, raw.SHA256Hash)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	again.UserID = bob
+	require.NoError(t, client.CreateRawPhoto(ctx, again))
+}
+
+func TestClient_CreateRawPhoto_Quarantined(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+
+	quarantinedAt := time.Now().UTC().Truncate(time.Microsecond)
+	reason := "image is truncated"
+	raw := model.RawPhoto{
+		ID:               uuid.New().String(),
+		UserID:           alice,
+		OriginalFilename: "photo.jpg",
+		StorageBackend:   "default",
+		StorageKey:       "quarantine/" + alice + "/photo",
+		FileSize:         1024,
+		MimeType:         "image/jpeg",
+		MD5Hash:          "5d41402abc4b2a76b9719d911017c592",
+		SHA256Hash:       "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		UploadedAt:       quarantinedAt,
+		QuarantinedAt:    &quarantinedAt,
+		QuarantineReason: &reason,
+	}
+	require.NoError(t, client.CreateRawPhoto(ctx, raw))
+
+	got, err := client.GetRawPhotoByHash(ctx, alice, raw.SHA256Hash)
+	require.NoError(t, err)
+	require.NotNil(t, got.QuarantinedAt)
+	require.True(t, quarantinedAt.Equal(*got.QuarantinedAt))
+	require.Equal(t, &reason, got.QuarantineReason)
+
+	// A quarantined raw photo always has a reason
+	unexplained := raw
+	unexplained.ID = uuid.New().String()
+	unexplained.SHA256Hash = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+	unexplained.QuarantineReason = nil
+	require.Error(t, client.CreateRawPhoto(ctx, unexplained))
+}
+
+func TestClient_PurgeRawPhoto(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	photo, err := client.GetPhotoByID(ctx, createTestPhoto(t, client, alice))
+	require.NoError(t, err)
+	upload := uuid.New().String()
+	require.NoError(t, client.CreateUpload(ctx, model.Upload{
+		ID:           upload,
+		UserID:       alice,
+		Filename:     "photo.jpg",
+		UploadLength: 1024,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}))
+	require.NoError(t, client.CompleteUpload(ctx, upload, photo.RawPhotoID))
+
+	// A second photo processed from the same raw photo
+	reprocessed := photo
+	reprocessed.ID = uuid.New().String()
+	reprocessed.Variants = nil
+	require.NoError(t, client.CreatePhoto(ctx, reprocessed))
+
+	// Raw photos are kept while photos processed from them are
+	require.NoError(t, client.DeletePhoto(ctx, photo.ID, -time.Minute))
+	_, _, err = client.PurgePhoto(ctx, photo.ID)
+	require.NoError(t, err)
+	raws, err := client.GetExpiredRawPhotos(ctx, "", 10)
+	require.NoError(t, err)
+	require.Empty(t, raws)
+	require.ErrorIs(t, client.PurgeRawPhoto(ctx, photo.RawPhotoID), ErrNotFound)
+
+	// and scheduled for deletion when the last of them is purged
+	require.NoError(t, client.DeletePhoto(ctx, reprocessed.ID, -time.Minute))
+	_, _, err = client.PurgePhoto(ctx, reprocessed.ID)
+	require.NoError(t, err)
+	raws, err = client.GetExpiredRawPhotos(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, raws, 1)
+	require.Equal(t, photo.RawPhotoID, raws[0].ID)
+
+	require.NoError(t, client.PurgeRawPhoto(ctx, photo.RawPhotoID))
+	_, err = client.GetRawPhotoByID(ctx, photo.RawPhotoID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = client.GetUpload(ctx, upload)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, client.PurgeRawPhoto(ctx, photo.RawPhotoID), ErrNotFound)
+}
@@ -0,0 +1,43 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+)
+
+// CreateScanResult records the verdict of scanning an uploaded file.
+func (c *Client) CreateScanResult(ctx context.Context, result model.ScanResult) error {
+	query := `
+		INSERT INTO scan_results (id, user_id, filename, sha256_hash, scanner, verdict, labels, scanned_at)
+		VALUES (:id, :user_id, :filename, :sha256_hash, :scanner, :verdict,
+			COALESCE(CAST(:labels AS text[]), '{}'), :scanned_at)`
+
+	_, err := c.db.NamedExecContext(ctx, query, result)
+	if err != nil {
+		return fmt.Errorf("failed to create scan result: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// ListScanResults returns the scan results with the verdict, or all of them if
+// the verdict is empty, most recent first.
+func (c *Client) ListScanResults(ctx context.Context, verdict scan.Verdict, limit, offset int) (
+	[]model.ScanResult, error) {
+	results := []model.ScanResult{}
+	query := `
+		SELECT * FROM scan_results
+		WHERE $1 = '' OR verdict = $1
+		ORDER BY scanned_at DESC, id
+		LIMIT $2 OFFSET $3`
+
+	err := c.db.SelectContext(ctx, &results, query, verdict, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scan results: %w", mapError(err))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,76 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+	"jelly/pkg/scan"
+)
+
+func TestClient_ScanResults(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	newResult := func(verdict scan.Verdict, labels []string, age time.Duration) model.ScanResult {
+		return model.ScanResult{
+			ID:         uuid.New().String(),
+			UserID:     alice,
+			Filename:   "photo.jpg",
+			SHA256Hash: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+			Scanner:    "clamav",
+			Verdict:    verdict,
+			Labels:     labels,
+			ScannedAt:  now.Add(-age),
+		}
+	}
+	rejected := newResult(scan.Reject, pq.StringArray{"Eicar-Test-Signature"}, time.Hour)
+	quarantined := newResult(scan.Quarantine, pq.StringArray{"nudity", "violence"}, time.Minute)
+	labeled := newResult(scan.Allow, pq.StringArray{"suggestive"}, 0)
+	for _, result := range []model.ScanResult{rejected, quarantined, labeled} {
+		require.NoError(t, client.CreateScanResult(ctx, result))
+	}
+
+	// Most recent first
+	results, err := client.ListScanResults(ctx, "", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, labeled.ID, results[0].ID)
+	require.Equal(t, quarantined.ID, results[1].ID)
+	require.Equal(t, rejected.ID, results[2].ID)
+	require.Equal(t, rejected.Labels, results[2].Labels)
+	require.True(t, rejected.ScannedAt.Equal(results[2].ScannedAt))
+
+	results, err = client.ListScanResults(ctx, scan.Quarantine, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, quarantined.ID, results[0].ID)
+	require.Equal(t, scan.Quarantine, results[0].Verdict)
+	require.Equal(t, quarantined.Labels, results[0].Labels)
+
+	results, err = client.ListScanResults(ctx, "", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, quarantined.ID, results[0].ID)
+
+	// Results without labels have none rather than null labels
+	unlabeled := newResult(scan.Quarantine, nil, 2*time.Hour)
+	require.NoError(t, client.CreateScanResult(ctx, unlabeled))
+	results, err = client.ListScanResults(ctx, scan.Quarantine, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, pq.StringArray{}, results[1].Labels)
+
+	// Unknown verdicts can't be recorded
+	require.Error(t, client.CreateScanResult(ctx, newResult("maybe", nil, 0)))
+}
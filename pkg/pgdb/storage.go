@@ -0,0 +1,89 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jelly/pkg/store"
+)
+
+// QueueStorageRepair queues the key of an object the secondary storage failed
+// to be written, or updates when it was queued if it already is.
+func (c *Client) QueueStorageRepair(ctx context.Context, key string) error {
+	query := `
+		INSERT INTO storage_repairs (key, queued_at)
+		VALUES ($1, clock_timestamp())
+		ON CONFLICT (key) DO UPDATE SET queued_at = excluded.queued_at`
+
+	_, err := c.db.ExecContext(ctx, query, key)
+	if err != nil {
+		return fmt.Errorf("failed to queue storage repair: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetStorageRepairs returns up to limit repairs queued before the time,
+// ordered by key after the given one.
+func (c *Client) GetStorageRepairs(ctx context.Context, before time.Time, after string, limit int) (
+	[]store.Repair, error) {
+	repairs := []store.Repair{}
+	query := `
+		SELECT key, queued_at FROM storage_repairs
+		WHERE queued_at < $1 AND key > $2
+		ORDER BY key
+		LIMIT $3`
+
+	err := c.db.SelectContext(ctx, &repairs, query, before, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage repairs: %w", mapError(err))
+	}
+
+	return repairs, nil
+}
+
+// DeleteStorageRepair removes a repair, unless its key was queued again since
+// it was read.
+func (c *Client) DeleteStorageRepair(ctx context.Context, repair store.Repair) error {
+	query := `DELETE FROM storage_repairs WHERE key = $1 AND queued_at = $2`
+
+	_, err := c.db.ExecContext(ctx, query, repair.Key, repair.QueuedAt)
+	if err != nil {
+		return fmt.Errorf("failed to delete storage repair: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// RewriteStorageBackend moves the raw photos, photos and photo variants
+// stored in a backend to another backend, returning how many rows were
+// rewritten. Rows are rewritten in a single transaction, and rewriting them
+// again changes nothing.
+func (c *Client) RewriteStorageBackend(ctx context.Context, from, to string) (rows int64, err error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite storage backend: %w", err)
+	}
+	// Deferred in a closure so the rollback sees the returned error
+	defer func() { HandleTxError(err, tx.Tx)() }()
+
+	for _, table := range []string{"raw_photos", "photos", "photo_variants"} {
+		query := `UPDATE ` + table + ` SET storage_backend = $2 WHERE storage_backend = $1`
+		res, err := tx.ExecContext(ctx, query, from, to)
+		if err != nil {
+			return 0, fmt.Errorf("failed to rewrite storage backend: %w", mapError(err))
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to rewrite storage backend: %w", err)
+		}
+		rows += n
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to rewrite storage backend: %w", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,86 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_StorageRepairs(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	for _, key := range []string{"raw/b.jpg", "raw/a.jpg", "raw/c.jpg", "raw/a.jpg"} {
+		require.NoError(t, client.QueueStorageRepair(ctx, key))
+	}
+
+	repairs, err := client.GetStorageRepairs(ctx, time.Now().Add(time.Minute), "", 10)
+	require.NoError(t, err)
+	require.Len(t, repairs, 3)
+	require.Equal(t, "raw/a.jpg", repairs[0].Key)
+	require.Equal(t, "raw/b.jpg", repairs[1].Key)
+	require.Equal(t, "raw/c.jpg", repairs[2].Key)
+
+	repairs, err = client.GetStorageRepairs(ctx, time.Now().Add(time.Minute), "raw/a.jpg", 1)
+	require.NoError(t, err)
+	require.Len(t, repairs, 1)
+	require.Equal(t, "raw/b.jpg", repairs[0].Key)
+
+	// Repairs queued after the time aren't returned
+	repairs, err = client.GetStorageRepairs(ctx, time.Now().Add(-time.Minute), "", 10)
+	require.NoError(t, err)
+	require.Empty(t, repairs)
+
+	// Keys queued again since they were read stay queued
+	repairs, err = client.GetStorageRepairs(ctx, time.Now().Add(time.Minute), "", 10)
+	require.NoError(t, err)
+	require.NoError(t, client.QueueStorageRepair(ctx, "raw/a.jpg"))
+	for _, repair := range repairs {
+		require.NoError(t, client.DeleteStorageRepair(ctx, repair))
+	}
+
+	repairs, err = client.GetStorageRepairs(ctx, time.Now().Add(time.Minute), "", 10)
+	require.NoError(t, err)
+	require.Len(t, repairs, 1)
+	require.Equal(t, "raw/a.jpg", repairs[0].Key)
+}
+
+func TestClient_RewriteStorageBackend(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	photoID := createTestPhoto(t, client, alice)
+	_, err = client.db.Exec(`
+		INSERT INTO photo_variants (photo_id, name, width, height, format, storage_backend, storage_key, file_size)
+		VALUES ($1, 'thumb', 100, 100, 'jpeg', 'default', 'photos/thumb.jpg', 10)`,
+		photoID)
+	require.NoError(t, err)
+	// Rows of other backends aren't rewritten
+	createTestPhoto(t, client, alice)
+	_, err = client.db.Exec(`UPDATE photos SET storage_backend = 'other' WHERE id <> $1`, photoID)
+	require.NoError(t, err)
+
+	rows, err := client.RewriteStorageBackend(ctx, "default", "s3-eu")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), rows)
+
+	var backends []string
+	err = client.db.Select(&backends, `
+		SELECT storage_backend FROM raw_photos
+		UNION ALL SELECT storage_backend FROM photos
+		UNION ALL SELECT storage_backend FROM photo_variants`)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"s3-eu", "s3-eu", "s3-eu", "other", "s3-eu"}, backends)
+
+	// Rewriting again changes nothing
+	rows, err = client.RewriteStorageBackend(ctx, "default", "s3-eu")
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
@@ -0,0 +1,99 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+
+	"jelly/pkg/model"
+)
+
+// CreateUpload inserts a resumable or direct upload.
+func (c *Client) CreateUpload(ctx context.Context, upload model.Upload) error {
+	query := `
+		INSERT INTO photo_uploads (id, user_id, filename, upload_length, upload_offset,
+			metadata, direct, mime_type, sha256_hash, created_at, expires_at)
+		VALUES (:id, :user_id, :filename, :upload_length, :upload_offset,
+			:metadata, :direct, :mime_type, :sha256_hash, :created_at, :expires_at)`
+
+	_, err := c.db.NamedExecContext(ctx, query, upload)
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetUpload returns the upload with the given ID, or ErrNotFound.
+func (c *Client) GetUpload(ctx context.Context, uploadID string) (model.Upload, error) {
+	var upload model.Upload
+	query := `SELECT * FROM photo_uploads WHERE id = $1`
+
+	err := c.db.GetContext(ctx, &upload, query, uploadID)
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("failed to get upload: %w", mapError(err))
+	}
+
+	return upload, nil
+}
+
+// UpdateUploadOffset advances the offset of an upload. ErrConflict is returned
+// if the current offset is no longer the expected one.
+func (c *Client) UpdateUploadOffset(ctx context.Context, uploadID string, from, to int64) error {
+	query := `UPDATE photo_uploads SET upload_offset = $3 WHERE id = $1 AND upload_offset = $2`
+
+	res, err := c.db.ExecContext(ctx, query, uploadID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to update upload offset: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update upload offset: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("failed to update upload offset: %w", ErrConflict)
+	}
+
+	return nil
+}
+
+// CompleteUpload marks an upload as complete and links the raw photo created
+// from it.
+func (c *Client) CompleteUpload(ctx context.Context, uploadID, rawPhotoID string) error {
+	query := `UPDATE photo_uploads SET raw_photo_id = $2, completed_at = now() WHERE id = $1`
+
+	_, err := c.db.ExecContext(ctx, query, uploadID, rawPhotoID)
+	if err != nil {
+		return fmt.Errorf("failed to complete upload: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetExpiredUploads returns the uploads that expired before they were
+// completed, ordered by ID after the given one.
+func (c *Client) GetExpiredUploads(ctx context.Context, after string, limit int) ([]model.Upload, error) {
+	uploads := []model.Upload{}
+	query := `
+		SELECT * FROM photo_uploads
+		WHERE completed_at IS NULL AND expires_at <= now() AND CAST(id AS text) > $1
+		ORDER BY CAST(id AS text)
+		LIMIT $2`
+
+	err := c.db.SelectContext(ctx, &uploads, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired uploads: %w", mapError(err))
+	}
+
+	return uploads, nil
+}
+
+// DeleteUpload removes an upload.
+func (c *Client) DeleteUpload(ctx context.Context, uploadID string) error {
+	query := `DELETE FROM photo_uploads WHERE id = $1`
+
+	_, err := c.db.ExecContext(ctx, query, uploadID)
+	if err != nil {
+		return fmt.Errorf("failed to delete upload: %w", mapError(err))
+	}
+
+	return nil
+}
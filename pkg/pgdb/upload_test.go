@@ -0,0 +1,80 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+)
+
+func TestClient_UpdateUploadOffset(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	upload := model.Upload{
+		ID:           uuid.New().String(),
+		UserID:       createTestUser(t, client, "alice"),
+		Filename:     "photo.jpg",
+		UploadLength: 100,
+		CreatedAt:    time.Now().UTC(),
+		ExpiresAt:    time.Now().UTC().Add(time.Hour),
+	}
+	require.NoError(t, client.CreateUpload(ctx, upload))
+
+	require.NoError(t, client.UpdateUploadOffset(ctx, upload.ID, 0, 60))
+
+	// A concurrent writer that read the old offset loses
+	require.ErrorIs(t, client.UpdateUploadOffset(ctx, upload.ID, 0, 40), ErrConflict)
+
+	got, err := client.GetUpload(ctx, upload.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(60), got.UploadOffset)
+
+	require.NoError(t, client.DeleteUpload(ctx, upload.ID))
+	_, err = client.GetUpload(ctx, upload.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_GetExpiredUploads(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+	newUpload := func(expiresAt time.Time) string {
+		upload := model.Upload{
+			ID:           uuid.New().String(),
+			UserID:       alice,
+			Filename:     "photo.jpg",
+			UploadLength: 100,
+			CreatedAt:    time.Now().UTC(),
+			ExpiresAt:    expiresAt,
+		}
+		require.NoError(t, client.CreateUpload(ctx, upload))
+		return upload.ID
+	}
+	expired := newUpload(time.Now().Add(-time.Minute))
+	completed := newUpload(time.Now().Add(-time.Minute))
+	newUpload(time.Now().Add(time.Hour))
+
+	// Uploads completed before they expired are kept with their raw photo
+	photo, err := client.GetPhotoByID(ctx, createTestPhoto(t, client, alice))
+	require.NoError(t, err)
+	require.NoError(t, client.CompleteUpload(ctx, completed, photo.RawPhotoID))
+
+	uploads, err := client.GetExpiredUploads(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, expired, uploads[0].ID)
+
+	uploads, err = client.GetExpiredUploads(ctx, expired, 10)
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
@@ -0,0 +1,41 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+
+	"jelly/pkg/model"
+)
+
+// GetLocationPrivacy returns how the location of the user's photos is kept,
+// or ErrNotFound if the user doesn't exist.
+func (c *Client) GetLocationPrivacy(ctx context.Context, userID string) (model.LocationPrivacy, error) {
+	var privacy model.LocationPrivacy
+	query := `SELECT location_privacy FROM users WHERE id = $1`
+
+	err := c.db.GetContext(ctx, &privacy, query, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get location privacy: %w", mapError(err))
+	}
+
+	return privacy, nil
+}
+
+// UpdateLocationPrivacy sets how the location of the user's photos is kept.
+// ErrNotFound is returned if the user doesn't exist.
+func (c *Client) UpdateLocationPrivacy(ctx context.Context, userID string, privacy model.LocationPrivacy) error {
+	query := `UPDATE users SET location_privacy = $2, updated_at = now() WHERE id = $1`
+
+	res, err := c.db.ExecContext(ctx, query, userID, privacy)
+	if err != nil {
+		return fmt.Errorf("failed to update location privacy: %w", mapError(err))
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update location privacy: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("failed to update location privacy: %w", ErrNotFound)
+	}
+
+	return nil
+}
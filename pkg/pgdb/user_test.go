@@ -0,0 +1,37 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/model"
+)
+
+func TestClient_LocationPrivacy(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(WithPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	alice := createTestUser(t, client, "alice")
+
+	// Locations are stripped unless the user chooses otherwise
+	privacy, err := client.GetLocationPrivacy(ctx, alice)
+	require.NoError(t, err)
+	require.Equal(t, model.LocationStrip, privacy)
+
+	require.NoError(t, client.UpdateLocationPrivacy(ctx, alice, model.LocationCity))
+	privacy, err = client.GetLocationPrivacy(ctx, alice)
+	require.NoError(t, err)
+	require.Equal(t, model.LocationCity, privacy)
+
+	require.Error(t, client.UpdateLocationPrivacy(ctx, alice, "exact"))
+
+	missing := uuid.New().String()
+	_, err = client.GetLocationPrivacy(ctx, missing)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, client.UpdateLocationPrivacy(ctx, missing, model.LocationKeep), ErrNotFound)
+}
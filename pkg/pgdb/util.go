@@ -4,8 +4,38 @@ import (
 	"database/sql"
 	"errors"
 	"log/slog"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicate is returned when an insert violates a unique constraint.
+	ErrDuplicate = errors.New("duplicate")
+
+	// ErrConflict is returned when a conditional update did not match the
+	// current state of the row.
+	ErrConflict = errors.New("conflict")
 )
 
+// uniqueViolation is the Postgres error code for unique constraint violations.
+const uniqueViolation = "23505"
+
+// mapError translates driver errors into the package's sentinel errors so
+// callers don't depend on the underlying driver.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+		return ErrDuplicate
+	}
+	return err
+}
+
 // HandleTxError is a helper function to handle transaction commit/rollback; it
 // will roll back the transaction if an error is passed. In other cases it will
 // do nothing.
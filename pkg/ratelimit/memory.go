@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the number of requests between sweeps of full buckets.
+const sweepInterval = 1024
+
+// Memory is a Limiter keeping the buckets in memory. The buckets aren't
+// shared between instances, so it is meant for local deployments.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	calls   int
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemory creates an in-memory Limiter.
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]bucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the key if one is left
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepInterval == 0 {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(limit.Requests), updated: now}
+	}
+	b.limit = limit
+	b.tokens = refill(limit, b.tokens, now.Sub(b.updated))
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	m.buckets[key] = b
+
+	return newResult(limit, allowed, b.tokens), nil
+}
+
+// sweep removes the buckets that have refilled, since a new bucket is full.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if refill(b.limit, b.tokens, now.Sub(b.updated)) >= float64(b.limit.Requests) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package ratelimit limits the rate of requests with token buckets. A bucket
+// holds up to Limit.Requests tokens and is refilled at Limit.Requests per
+// Limit.Per, so a client can make a burst of Requests requests and then one
+// every Per/Requests.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limiter takes tokens from the bucket of a key.
+type Limiter interface {
+	// Allow takes a token from the bucket of the key if one is left
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limit is a number of requests allowed per period. The zero Limit allows
+// every request.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Unlimited reports whether the limit allows every request.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// String formats the limit the way ParseLimit parses it.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "none"
+	}
+
+	per := l.Per.String()
+	switch l.Per {
+	case time.Second:
+		per = "s"
+	case time.Minute:
+		per = "m"
+	case time.Hour:
+		per = "h"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, per)
+}
+
+// interval returns the time it takes to refill n tokens.
+func (l Limit) interval(n float64) time.Duration {
+	return time.Duration(math.Ceil(n * float64(l.Per) / float64(l.Requests)))
+}
+
+// ParseLimit parses a limit such as "100/h", "20/s" or "5/10m". "none" and
+// "0" parse as the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "none" || s == "0" {
+		return Limit{}, nil
+	}
+
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: expected requests/period", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: invalid number of requests", s)
+	}
+
+	// A bare unit is a period of one
+	if per == "s" || per == "m" || per == "h" {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: invalid period", s)
+	}
+
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Result is the state of a bucket after a request.
+type Result struct {
+	// Allowed is true if the request took a token
+	Allowed bool
+
+	// Limit is the limit the bucket was checked against
+	Limit Limit
+
+	// Remaining is the number of requests that can be made right away
+	Remaining int
+
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+
+	// RetryAfter is the time until the next token, if the request was denied
+	RetryAfter time.Duration
+}
+
+// newResult returns the result of a request that left the given number of
+// tokens in the bucket.
+func newResult(limit Limit, allowed bool, tokens float64) Result {
+	result := Result{
+		Allowed:    allowed,
+		Limit:      limit,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: limit.interval(float64(limit.Requests) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = limit.interval(1 - tokens)
+	}
+	return result
+}
+
+// refill returns the tokens in a bucket after the given time has passed.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	if elapsed > 0 {
+		tokens += float64(elapsed) * float64(limit.Requests) / float64(limit.Per)
+	}
+	return math.Min(tokens, float64(limit.Requests))
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// limiters returns each Limiter implementation with a manual clock.
+func limiters(t *testing.T) map[string]func(*clock) Limiter {
+	return map[string]func(*clock) Limiter{
+		"memory": func(c *clock) Limiter {
+			m := NewMemory()
+			m.now = c.now
+			return m
+		},
+		"redis": func(c *clock) Limiter {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+			l := NewRedis(client)
+			l.now = c.now
+			return l
+		},
+	}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	for name, newLimiter := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{t: time.Unix(1700000000, 0)}
+			limiter := newLimiter(c)
+			limit := Limit{Requests: 3, Per: time.Minute}
+
+			for i := range 3 {
+				result, err := limiter.Allow(context.Background(), "user", limit)
+				require.NoError(t, err)
+				assert.True(t, result.Allowed, "request %d", i)
+				assert.Equal(t, 2-i, result.Remaining)
+				assert.Equal(t, time.Duration(i+1)*20*time.Second, result.ResetAfter)
+			}
+
+			result, err := limiter.Allow(context.Background(), "user", limit)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Equal(t, 0, result.Remaining)
+			assert.Equal(t, 20*time.Second, result.RetryAfter)
+			assert.Equal(t, time.Minute, result.ResetAfter)
+		})
+	}
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	for name, newLimiter := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{t: time.Unix(1700000000, 0)}
+			limiter := newLimiter(c)
+			limit := Limit{Requests: 2, Per: time.Second}
+
+			for range 2 {
+				_, err := limiter.Allow(context.Background(), "user", limit)
+				require.NoError(t, err)
+			}
+
+			c.t = c.t.Add(250 * time.Millisecond)
+			result, err := limiter.Allow(context.Background(), "user", limit)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Equal(t, 250*time.Millisecond, result.RetryAfter)
+
+			c.t = c.t.Add(250 * time.Millisecond)
+			result, err = limiter.Allow(context.Background(), "user", limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 0, result.Remaining)
+
+			// Refilling stops at the limit
+			c.t = c.t.Add(time.Hour)
+			result, err = limiter.Allow(context.Background(), "user", limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 1, result.Remaining)
+		})
+	}
+}
+
+func TestLimiter_KeysAreSeparate(t *testing.T) {
+	for name, newLimiter := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			limiter := newLimiter(&clock{t: time.Unix(1700000000, 0)})
+			limit := Limit{Requests: 1, Per: time.Hour}
+
+			result, err := limiter.Allow(context.Background(), "a", limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+
+			result, err = limiter.Allow(context.Background(), "b", limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+
+			result, err = limiter.Allow(context.Background(), "a", limit)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+		})
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	for name, newLimiter := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			limiter := newLimiter(&clock{t: time.Unix(1700000000, 0)})
+
+			for range 10 {
+				result, err := limiter.Allow(context.Background(), "user", Limit{})
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+			}
+		})
+	}
+}
+
+func TestRedis_BucketExpires(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	_, err := NewRedis(client).Allow(context.Background(), "user", Limit{Requests: 10, Per: time.Minute})
+	require.NoError(t, err)
+
+	// One token takes 6s to refill
+	assert.Equal(t, 6*time.Second, mr.TTL(redisKeyPrefix+"user"))
+}
+
+func TestRedis_Error(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	mr.Close()
+
+	_, err := NewRedis(client).Allow(context.Background(), "user", Limit{Requests: 1, Per: time.Second})
+	assert.Error(t, err)
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	m := NewMemory()
+	m.now = c.now
+
+	_, _ = m.Allow(context.Background(), "a", Limit{Requests: 1, Per: time.Second})
+	_, _ = m.Allow(context.Background(), "b", Limit{Requests: 1, Per: time.Hour})
+
+	c.t = c.t.Add(time.Minute)
+	m.sweep(c.now())
+
+	assert.NotContains(t, m.buckets, "a")
+	assert.Contains(t, m.buckets, "b")
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "100/h", want: Limit{Requests: 100, Per: time.Hour}},
+		{in: "20/s", want: Limit{Requests: 20, Per: time.Second}},
+		{in: "5/10m", want: Limit{Requests: 5, Per: 10 * time.Minute}},
+		{in: "none", want: Limit{}},
+		{in: "0", want: Limit{}},
+		{in: "100", wantErr: true},
+		{in: "x/h", wantErr: true},
+		{in: "-1/h", wantErr: true},
+		{in: "1/fortnight", wantErr: true},
+		{in: "1/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			if !got.Unlimited() {
+				roundTrip, err := ParseLimit(got.String())
+				require.NoError(t, err)
+				assert.Equal(t, got, roundTrip)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the buckets in Redis.
+const redisKeyPrefix = "ratelimit:"
+
+// takeScript refills and takes a token from a bucket stored as a hash of its
+// tokens and the time it was updated, in milliseconds. The bucket expires
+// once it has refilled, since a new bucket is full. The tokens are returned as
+// a string because Lua numbers are truncated to integers.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local per = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * capacity / per)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(math.max(now, updated)))
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil((capacity - tokens) * per / capacity)))
+
+return {allowed, tostring(tokens)}
+`)
+
+// Redis is a Limiter keeping the buckets in Redis, so instances behind a load
+// balancer share them.
+type Redis struct {
+	client redis.Scripter
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// NewRedis creates a Limiter keeping the buckets in Redis.
+func NewRedis(client redis.Scripter) *Redis {
+	return &Redis{client: client, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the key if one is left
+func (l *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+
+	values, err := takeScript.Run(ctx, l.client, []string{redisKeyPrefix + key},
+		limit.Requests, limit.Per.Milliseconds(), l.now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take token: %w", err)
+	}
+
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("failed to take token: unexpected reply %v", values)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take token: %w", err)
+	}
+
+	return newResult(limit, allowed == 1, tokens), nil
+}
@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize is the size of the chunks files are streamed to clamd in
+const clamavChunkSize = 64 << 10
+
+// ClamAV scans files for malware with a clamd daemon, streaming them with the
+// INSTREAM command. Files with malware are rejected, labeled with the name of
+// the signature found.
+type ClamAV struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAV creates a client of the clamd daemon listening at the address, a
+// host:port or the path of a Unix socket, waiting up to the timeout for each
+// scan.
+func NewClamAV(address string, timeout time.Duration) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	return &ClamAV{network: network, address: address, timeout: timeout}
+}
+
+// Scan streams the file to clamd and returns its verdict.
+func (c *ClamAV) Scan(ctx context.Context, data []byte) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	// Reads and writes are interrupted when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	// clamd replies and closes the connection as soon as the stream is too
+	// long, so its reply is read even if the stream can't be written
+	writeErr := writeStream(conn, data)
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if writeErr != nil {
+			err = writeErr
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return Result{}, fmt.Errorf("failed to scan with clamd: %w", err)
+	}
+
+	return parseClamAVReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// writeStream sends the INSTREAM command followed by the file in chunks
+// prefixed with their length, and an empty chunk ending the stream.
+func writeStream(conn net.Conn, data []byte) error {
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	for len(data) > 0 {
+		n := min(len(data), clamavChunkSize)
+		w.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	w.Write([]byte{0, 0, 0, 0})
+	return w.Flush()
+}
+
+// parseClamAVReply converts the reply to a stream scan, "stream: OK" or
+// "stream: <signature> FOUND", into a result.
+func parseClamAVReply(reply string) (Result, error) {
+	result := Result{Scanner: "clamav"}
+	status, ok := strings.CutPrefix(reply, "stream: ")
+	switch {
+	case ok && status == "OK":
+		result.Verdict = Allow
+	case ok && strings.HasSuffix(status, " FOUND"):
+		result.Verdict = Reject
+		result.Labels = []string{strings.TrimSuffix(status, " FOUND")}
+	case strings.HasSuffix(reply, " ERROR"):
+		return result, fmt.Errorf("clamd error: %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return result, errors.New("unexpected clamd reply: " + reply)
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar is the standard antivirus test file
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd serves the INSTREAM command like clamd, replying with the reply
+// to the streamed file, and returns its address. Streams longer than
+// maxLength are cut short with an error like clamd's StreamMaxLength.
+func fakeClamd(t *testing.T, maxLength int, reply func(data []byte) string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var data []byte
+				for {
+					var length uint32
+					if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+						return
+					}
+					if length == 0 {
+						break
+					}
+					if len(data)+int(length) > maxLength {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+					chunk := make([]byte, length)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				conn.Write([]byte(reply(data) + "\x00"))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// signatures replies like clamd with a single EICAR signature.
+func signatures(data []byte) string {
+	if bytes.Contains(data, []byte(eicar)) {
+		return "stream: Eicar-Test-Signature FOUND"
+	}
+	return "stream: OK"
+}
+
+func TestClamAV_Scan(t *testing.T) {
+	scanner := NewClamAV(fakeClamd(t, 1<<20, signatures), time.Second)
+	large := bytes.Repeat([]byte("0123456789abcdef"), 3*clamavChunkSize/16+100)
+
+	tests := []struct {
+		name string
+		data []byte
+		want Result
+	}{
+		{name: "clean", data: []byte("a clean photo"), want: Result{Scanner: "clamav", Verdict: Allow}},
+		{name: "empty", data: nil, want: Result{Scanner: "clamav", Verdict: Allow}},
+		{name: "malware", data: []byte(eicar), want: Result{Scanner: "clamav", Verdict: Reject,
+			Labels: []string{"Eicar-Test-Signature"}}},
+		// Files are streamed in chunks and reassembled
+		{name: "malware across chunks", data: append(large, eicar...), want: Result{Scanner: "clamav",
+			Verdict: Reject, Labels: []string{"Eicar-Test-Signature"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestClamAV_Errors(t *testing.T) {
+	// Streams longer than clamd accepts are an error, not a verdict
+	scanner := NewClamAV(fakeClamd(t, 1000, signatures), time.Second)
+	_, err := scanner.Scan(context.Background(), bytes.Repeat([]byte("x"), 3*clamavChunkSize))
+	assert.ErrorContains(t, err, "size limit exceeded")
+
+	scanner = NewClamAV(fakeClamd(t, 1<<20, func([]byte) string { return "stream: maybe" }), time.Second)
+	_, err = scanner.Scan(context.Background(), []byte("photo"))
+	assert.ErrorContains(t, err, "unexpected clamd reply")
+
+	// Nothing listening
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	_, err = NewClamAV(addr, time.Second).Scan(context.Background(), []byte("photo"))
+	assert.ErrorContains(t, err, "failed to connect")
+}
+
+func TestClamAV_Timeout(t *testing.T) {
+	// A daemon that never replies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	start := time.Now()
+	_, err = NewClamAV(ln.Addr().String(), 50*time.Millisecond).Scan(context.Background(), []byte("photo"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestNewClamAV(t *testing.T) {
+	assert.Equal(t, "tcp", NewClamAV("clamd:3310", time.Second).network)
+	assert.Equal(t, "unix", NewClamAV("/run/clamav/clamd.ctl", time.Second).network)
+}
+
+func TestNoop(t *testing.T) {
+	result, err := Noop{}.Scan(context.Background(), []byte(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, Allow, result.Verdict)
+	assert.Empty(t, result.Labels)
+	assert.Equal(t, "noop", result.Scanner)
+}
@@ -0,0 +1,52 @@
+// Package scan checks uploaded files for malware or content to moderate before
+// they are processed.
+package scan
+
+import "context"
+
+// Verdict is what is done with a scanned file.
+type Verdict string
+
+const (
+	// Allow processes the file
+	Allow Verdict = "allow"
+
+	// Quarantine keeps the file for review without processing it
+	Quarantine Verdict = "quarantine"
+
+	// Reject discards the file
+	Reject Verdict = "reject"
+)
+
+// Valid reports whether the verdict is known.
+func (v Verdict) Valid() bool {
+	switch v {
+	case Allow, Quarantine, Reject:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result is the verdict of a scanner on a file, with the labels explaining
+// it, such as the name of the malware found.
+type Result struct {
+	Scanner string
+	Verdict Verdict
+	Labels  []string
+}
+
+// Scanner scans uploaded files.
+type Scanner interface {
+	// Scan returns the verdict on a file, or an error if it couldn't be
+	// scanned.
+	Scan(ctx context.Context, data []byte) (Result, error)
+}
+
+// Noop allows every file without scanning it.
+type Noop struct{}
+
+// Scan allows the file.
+func (Noop) Scan(context.Context, []byte) (Result, error) {
+	return Result{Scanner: "noop", Verdict: Allow}, nil
+}
@@ -0,0 +1,630 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"jelly/pkg/kms"
+)
+
+// Metadata of encrypted objects
+const (
+	metaAlgorithm = "encryption-algorithm"
+	metaKeyID     = "encryption-key-id"
+	metaDataKey   = "encryption-data-key" // Base64 data key wrapped with the master key
+	metaEnvelope  = "encryption-envelope" // Set if the object starts with its envelope
+	algorithmGCM  = "AES256-GCM"
+)
+
+// gcmOverhead is how much larger objects are once encrypted, for the nonce
+// prefixed to them and the GCM tag, besides their envelope
+const gcmOverhead = 12 + 16
+
+// envelopeMagic starts the envelope of encrypted objects
+const envelopeMagic = "JENV\x01"
+
+// envelope is the header of an encrypted object, with the ID of the master
+// key and the data key wrapped with it. Objects are decrypted with their
+// envelope, so an object read at the same time as it's rotated or replaced is
+// never decrypted with the data key of another version. It is also kept in
+// the metadata of the object, which objects encrypted before envelopes were
+// stored in them only have.
+type envelope struct {
+	keyID   string
+	wrapped []byte
+}
+
+// size returns the size of the envelope in the object
+func (e envelope) size() int {
+	return len(envelopeMagic) + 2 + len(e.keyID) + 2 + len(e.wrapped)
+}
+
+// marshal encodes the envelope as the magic, then the key ID and the wrapped
+// data key each prefixed with its length.
+func (e envelope) marshal() []byte {
+	b := make([]byte, 0, e.size())
+	b = append(b, envelopeMagic...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.keyID)))
+	b = append(b, e.keyID...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.wrapped)))
+	return append(b, e.wrapped...)
+}
+
+// parseEnvelope splits an object into its envelope and the encrypted data.
+// If the object doesn't start with an envelope, ok is false and sealed is the
+// whole object.
+func parseEnvelope(data []byte) (e envelope, sealed []byte, ok bool) {
+	if !bytes.HasPrefix(data, []byte(envelopeMagic)) {
+		return envelope{}, data, false
+	}
+
+	rest := data[len(envelopeMagic):]
+	field := func() ([]byte, bool) {
+		if len(rest) < 2 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return nil, false
+		}
+		value := rest[2 : 2+n]
+		rest = rest[2+n:]
+		return value, true
+	}
+	keyID, ok := field()
+	if !ok {
+		return envelope{}, data, false
+	}
+	wrapped, ok := field()
+	if !ok {
+		return envelope{}, data, false
+	}
+	return envelope{keyID: string(keyID), wrapped: wrapped}, rest, true
+}
+
+// ErrEncrypted is returned when an object would be uploaded to storage
+// without being encrypted
+var ErrEncrypted = errors.New("objects are encrypted and can't be uploaded directly to storage")
+
+// EncryptedStorage decorates storage with envelope encryption of the objects
+// whose keys start with its prefixes. Each object is encrypted with AES-GCM
+// by a random data key, which is wrapped by the current master key of the KMS
+// and stored in the envelope starting the object, and in its metadata, with
+// the ID of the master key. Objects stored before encryption was enabled are
+// read as they are.
+//
+// Encrypted objects can't be fetched from storage directly, so their URLs
+// point to ServeHTTP, which serves them decrypted, and they can't be uploaded
+// with presigned requests.
+type EncryptedStorage struct {
+	storage  Storage
+	kms      kms.KMS
+	prefixes []string
+	baseURL  string // Base URL ServeHTTP is served from
+	secret   []byte // Key signing the URLs of encrypted objects
+}
+
+// Check that EncryptedStorage implements Storage
+var _ Storage = (*EncryptedStorage)(nil)
+
+// NewEncryptedStorage creates an EncryptedStorage encrypting the objects of
+// the storage under the prefixes with data keys wrapped by the KMS. URLs of
+// encrypted objects are signed with the secret and served under the base
+// URL. Without a secret, a key per process is used.
+func NewEncryptedStorage(storage Storage, kms kms.KMS, prefixes []string, baseURL string,
+	secret []byte) *EncryptedStorage {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+
+	return &EncryptedStorage{
+		storage:  storage,
+		kms:      kms,
+		prefixes: prefixes,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		secret:   secret,
+	}
+}
+
+// Unwrap returns the decorated storage
+func (s *EncryptedStorage) Unwrap() Storage {
+	return s.storage
+}
+
+// Encrypts tells whether the object with the key is encrypted
+func (s *EncryptedStorage) Encrypts(key string) bool {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Upload encrypts data if its key is under the prefixes, and uploads it to
+// storage
+func (s *EncryptedStorage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...UploadOption) (string, error) {
+	if !s.Encrypts(key) {
+		return s.storage.Upload(ctx, key, data, contentType, opts...)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("data cannot be empty")
+	}
+
+	encrypted, metadata, err := s.encrypt(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	return s.storage.Upload(ctx, key, encrypted, contentType, append(opts, WithMetadata(metadata))...)
+}
+
+// encrypt encrypts data with a new data key, and returns the metadata of the
+// encrypted object.
+func (s *EncryptedStorage) encrypt(ctx context.Context, data []byte) ([]byte, map[string]string, error) {
+	dataKey := make([]byte, kms.KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	keyID, wrapped, err := s.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	e := envelope{keyID: keyID, wrapped: wrapped}
+	sealed := aead.Seal(append(e.marshal(), nonce...), nonce, data, nil)
+	return sealed, encryptionMetadata(e), nil
+}
+
+// encryptionMetadata returns the metadata of an object encrypted with the
+// envelope.
+func encryptionMetadata(e envelope) map[string]string {
+	return map[string]string{
+		metaAlgorithm: algorithmGCM,
+		metaKeyID:     e.keyID,
+		metaDataKey:   base64.StdEncoding.EncodeToString(e.wrapped),
+		metaEnvelope:  "true",
+	}
+}
+
+// metadataEnvelope returns the envelope in the metadata of an encrypted
+// object.
+func metadataEnvelope(metadata map[string]string) (envelope, error) {
+	if algorithm := metadata[metaAlgorithm]; algorithm != algorithmGCM {
+		return envelope{}, fmt.Errorf("unknown encryption algorithm: %s", algorithm)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(metadata[metaDataKey])
+	if err != nil {
+		return envelope{}, fmt.Errorf("invalid data key: %w", err)
+	}
+	return envelope{keyID: metadata[metaKeyID], wrapped: wrapped}, nil
+}
+
+// decrypt decrypts an object with the data key in its envelope.
+func (s *EncryptedStorage) decrypt(ctx context.Context, e envelope, sealed []byte) ([]byte, error) {
+	dataKey, err := s.dataKey(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted object is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt object: %w", err)
+	}
+	return plaintext, nil
+}
+
+// dataKey unwraps the data key in the envelope of an encrypted object.
+func (s *EncryptedStorage) dataKey(ctx context.Context, e envelope) ([]byte, error) {
+	dataKey, err := s.kms.Unwrap(ctx, e.keyID, e.wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// newGCM creates an AES-GCM cipher with the data key.
+func newGCM(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Download retrieves data from storage, decrypting it if it's encrypted, and
+// returns data with MIME type, or ErrNotFound
+func (s *EncryptedStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	if !s.Encrypts(key) {
+		return s.storage.Download(ctx, key)
+	}
+
+	data, contentType, err := s.storage.Download(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	e, sealed, ok := parseEnvelope(data)
+	if !ok {
+		return s.decryptLegacy(ctx, key, data, contentType)
+	}
+
+	data, err = s.decrypt(ctx, e, sealed)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// decryptLegacy decrypts a downloaded object without an envelope with the
+// data key in its metadata, or returns it as it is if it isn't encrypted.
+// Rotation only rewraps the data key, so the metadata still decrypts the
+// object if it was rotated since it was downloaded.
+func (s *EncryptedStorage) decryptLegacy(ctx context.Context, key string, data []byte, contentType string) (
+	[]byte, string, error) {
+	info, err := s.storage.Stat(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Metadata[metaKeyID] == "" {
+		return data, contentType, nil
+	}
+
+	e, err := metadataEnvelope(info.Metadata)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err = s.decrypt(ctx, e, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// Delete removes an object from storage
+func (s *EncryptedStorage) Delete(ctx context.Context, key string) error {
+	return s.storage.Delete(ctx, key)
+}
+
+// DeleteMany removes objects from storage
+func (s *EncryptedStorage) DeleteMany(ctx context.Context, keys []string) error {
+	return s.storage.DeleteMany(ctx, keys)
+}
+
+// Exists checks if an object exists in storage
+func (s *EncryptedStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.storage.Exists(ctx, key)
+}
+
+// GenerateURL creates a presigned URL for temporary access. URLs of encrypted
+// objects are served decrypted by ServeHTTP.
+func (s *EncryptedStorage) GenerateURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	if !s.Encrypts(key) {
+		return s.storage.GenerateURL(ctx, key, expiration)
+	}
+	if key == "" {
+		return "", fmt.Errorf("key cannot be empty")
+	}
+
+	values := url.Values{}
+	values.Set(paramExpires, strconv.FormatInt(time.Now().Add(expiration).Unix(), 10))
+	values.Set(paramSignature, s.signature(key, values.Get(paramExpires)))
+	return fmt.Sprintf("%s/%s?%s", s.baseURL, escapeKey(key), values.Encode()), nil
+}
+
+func (s *EncryptedStorage) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BaseURL returns the URL objects are uploaded under
+func (s *EncryptedStorage) BaseURL() string {
+	return s.storage.BaseURL()
+}
+
+// Stat returns information about an object, or ErrNotFound. The size of
+// encrypted objects is the size of their plaintext, and they have no
+// checksum.
+func (s *EncryptedStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.storage.Stat(ctx, key)
+	if err != nil || info.Metadata[metaKeyID] == "" {
+		return info, err
+	}
+
+	info.Size -= gcmOverhead
+	if info.Metadata[metaEnvelope] != "" {
+		if e, err := metadataEnvelope(info.Metadata); err == nil {
+			info.Size -= int64(e.size())
+		}
+	}
+	info.SHA256 = ""
+	return info, nil
+}
+
+// Copy copies an object within storage with its metadata, which decrypts
+// the copy. Objects copied into or out of the prefixes are encrypted or
+// decrypted.
+func (s *EncryptedStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if s.Encrypts(srcKey) == s.Encrypts(dstKey) {
+		return s.storage.Copy(ctx, srcKey, dstKey)
+	}
+	return s.recrypt(ctx, srcKey, dstKey)
+}
+
+// Move moves an object within storage with its metadata. Objects moved into
+// or out of the prefixes are encrypted or decrypted.
+func (s *EncryptedStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	if s.Encrypts(srcKey) == s.Encrypts(dstKey) {
+		return s.storage.Move(ctx, srcKey, dstKey)
+	}
+	if err := s.recrypt(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, srcKey)
+}
+
+// recrypt copies an object by downloading it decrypted and uploading it again,
+// encrypted if the copy is under the prefixes, with the metadata and cache
+// control of the object.
+func (s *EncryptedStorage) recrypt(ctx context.Context, srcKey, dstKey string) error {
+	info, err := s.storage.Stat(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	data, contentType, err := s.Download(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]string{}
+	for name, value := range info.Metadata {
+		switch name {
+		case metaAlgorithm, metaKeyID, metaDataKey, metaEnvelope:
+		default:
+			metadata[name] = value
+		}
+	}
+	opts := []UploadOption{WithMetadata(metadata)}
+	if info.CacheControl != "" {
+		opts = append(opts, WithCacheControl(info.CacheControl))
+	}
+	_, err = s.Upload(ctx, dstKey, data, contentType, opts...)
+	return err
+}
+
+// List iterates over the objects whose keys start with the prefix. Sizes are
+// the sizes of the stored objects.
+func (s *EncryptedStorage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return s.storage.List(ctx, prefix)
+}
+
+// GenerateUploadURL creates a presigned PUT request, or returns ErrEncrypted
+// if the object would be encrypted
+func (s *EncryptedStorage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if s.Encrypts(key) {
+		return PresignedUpload{}, ErrEncrypted
+	}
+	return s.storage.GenerateUploadURL(ctx, key, conditions, expiration)
+}
+
+// GenerateUploadForm creates a presigned POST request, or returns
+// ErrEncrypted if the object would be encrypted
+func (s *EncryptedStorage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if s.Encrypts(key) {
+		return PresignedUpload{}, ErrEncrypted
+	}
+	return s.storage.GenerateUploadForm(ctx, key, conditions, expiration)
+}
+
+// ServeHTTP serves encrypted objects decrypted to requests signed by
+// GenerateURL. It is mounted at the base URL of the storage.
+func (s *EncryptedStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get(paramExpires)
+	signature := r.URL.Query().Get(paramSignature)
+	if !s.Encrypts(key) || !hmac.Equal([]byte(s.signature(key, expires)), []byte(signature)) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if unix, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().After(time.Unix(unix, 0)) {
+		http.Error(w, "download has expired", http.StatusForbidden)
+		return
+	}
+
+	data, contentType, err := s.Download(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		slog.Error("Failed to serve encrypted object", "error", err, "key", key)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+// RotationReport summarizes a rotation of the keys of encrypted objects
+type RotationReport struct {
+	DryRun    bool
+	KeyID     string // ID of the master key objects were rotated to
+	Objects   int    // Objects under the prefixes
+	Current   int    // Objects already encrypted with the current master key
+	Rewrapped int    // Objects whose data key was wrapped with the current master key
+	Encrypted int    // Objects stored before encryption was enabled, encrypted
+	Errors    int    // Objects that failed to be rotated
+}
+
+// String formats the report for people.
+func (r RotationReport) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("Dry run, nothing was rotated\n")
+	}
+	fmt.Fprintf(&b, "Master key:  %s\n", r.KeyID)
+	fmt.Fprintf(&b, "Objects:     %d\n", r.Objects)
+	fmt.Fprintf(&b, "Current:     %d\n", r.Current)
+	fmt.Fprintf(&b, "Rewrapped:   %d\n", r.Rewrapped)
+	fmt.Fprintf(&b, "Encrypted:   %d\n", r.Encrypted)
+	fmt.Fprintf(&b, "Errors:      %d\n", r.Errors)
+	return b.String()
+}
+
+// RotateKeys wraps the data keys of the objects under the prefixes with the
+// current master key, so previous master keys can be retired. The objects
+// themselves aren't encrypted again, except for objects stored before
+// encryption was enabled, which are encrypted. Objects failing to be rotated
+// are logged and counted, and listing errors stop the rotation.
+func (s *EncryptedStorage) RotateKeys(ctx context.Context, dryRun bool) (RotationReport, error) {
+	report := RotationReport{DryRun: dryRun}
+	keyID, err := s.kms.KeyID(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to get current master key: %w", err)
+	}
+	report.KeyID = keyID
+
+	for _, prefix := range s.prefixes {
+		for object, err := range s.storage.List(ctx, prefix) {
+			if err != nil {
+				return report, fmt.Errorf("failed to list objects: %w", err)
+			}
+			report.Objects++
+
+			if err := s.rotate(ctx, object.Key, keyID, &report); err != nil {
+				slog.Error("Failed to rotate object key", "error", err, "key", object.Key)
+				report.Errors++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// rotate rewraps the data key of an object with the master key with the ID,
+// or encrypts the object if it isn't encrypted yet.
+func (s *EncryptedStorage) rotate(ctx context.Context, key, keyID string, report *RotationReport) error {
+	info, err := s.storage.Stat(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		// Deleted since it was listed
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Objects encrypted before envelopes were stored in them are rewrapped to
+	// store theirs
+	var count *int
+	switch {
+	case info.Metadata[metaKeyID] == keyID && info.Metadata[metaEnvelope] != "":
+		report.Current++
+		return nil
+	case info.Metadata[metaKeyID] == "":
+		count = &report.Encrypted
+	default:
+		count = &report.Rewrapped
+	}
+	if report.DryRun {
+		*count++
+		return nil
+	}
+
+	data, contentType, err := s.storage.Download(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var metadata map[string]string
+	if info.Metadata[metaKeyID] == "" {
+		data, metadata, err = s.encrypt(ctx, data)
+	} else {
+		data, metadata, err = s.rewrap(ctx, data, info.Metadata)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := []UploadOption{WithMetadata(info.Metadata), WithMetadata(metadata)}
+	if info.CacheControl != "" {
+		opts = append(opts, WithCacheControl(info.CacheControl))
+	}
+	if _, err := s.storage.Upload(ctx, key, data, contentType, opts...); err != nil {
+		return err
+	}
+	*count++
+	return nil
+}
+
+// rewrap wraps the data key of an encrypted object with the current master
+// key, and returns the object with its new envelope and the metadata
+// replacing its metadata. Objects without an envelope are read with the
+// envelope in their metadata.
+func (s *EncryptedStorage) rewrap(ctx context.Context, data []byte, metadata map[string]string) (
+	[]byte, map[string]string, error) {
+	e, sealed, ok := parseEnvelope(data)
+	if !ok {
+		var err error
+		if e, err = metadataEnvelope(metadata); err != nil {
+			return nil, nil, err
+		}
+	}
+	dataKey, err := s.dataKey(ctx, e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyID, wrapped, err := s.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	e = envelope{keyID: keyID, wrapped: wrapped}
+	return append(e.marshal(), sealed...), encryptionMetadata(e), nil
+}
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/kms"
+)
+
+// newTestEncryptedStorage creates an EncryptedStorage of local storage
+// encrypting raw photos with a master key
+func newTestEncryptedStorage(t *testing.T) (*EncryptedStorage, *LocalStorage) {
+	local := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	local.SetPrivate(true)
+	keys, err := kms.NewStatic(bytes.Repeat([]byte{1}, kms.KeySize))
+	require.NoError(t, err)
+
+	return NewEncryptedStorage(local, keys, []string{"raw/"}, "http://localhost:8080/encrypted", nil), local
+}
+
+func TestEncryptedStorage_Upload(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	ctx := context.Background()
+	data := []byte("raw photo")
+
+	url, err := s.Upload(ctx, "raw/u/photo.jpg", data, "image/jpeg", WithCacheControl("private"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/raw/u/photo.jpg", url)
+
+	// The stored object is encrypted, with its data key in its envelope and
+	// its metadata
+	stored, _, err := local.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "raw photo")
+	e, sealed, ok := parseEnvelope(stored)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(e.keyID, "static-"))
+	assert.Len(t, sealed, len(data)+gcmOverhead)
+
+	info, err := local.Stat(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, algorithmGCM, info.Metadata[metaAlgorithm])
+	assert.Equal(t, e.keyID, info.Metadata[metaKeyID])
+	assert.NotEmpty(t, info.Metadata[metaDataKey])
+	assert.Equal(t, "private", info.CacheControl)
+
+	downloaded, contentType, err := s.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	info, err = s.Stat(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size)
+	assert.Empty(t, info.SHA256)
+
+	// Copies keep the envelope decrypting them
+	require.NoError(t, s.Copy(ctx, "raw/u/photo.jpg", "raw/u/copy.jpg"))
+	downloaded, _, err = s.Download(ctx, "raw/u/copy.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+
+	// Objects are decrypted with their envelope, whatever their metadata is
+	_, err = local.Upload(ctx, "raw/u/photo.jpg", stored, "image/jpeg")
+	require.NoError(t, err)
+	downloaded, _, err = s.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+}
+
+func TestEncryptedStorage_WithoutEnvelope(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	ctx := context.Background()
+
+	// Objects encrypted before envelopes were stored in them only have their
+	// data key in their metadata
+	_, err := s.Upload(ctx, "raw/u/photo.jpg", []byte("raw photo"), "image/jpeg")
+	require.NoError(t, err)
+	stored, _, err := local.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	info, err := local.Stat(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	_, sealed, ok := parseEnvelope(stored)
+	require.True(t, ok)
+	metadata := maps.Clone(info.Metadata)
+	delete(metadata, metaEnvelope)
+	_, err = local.Upload(ctx, "raw/u/photo.jpg", sealed, "image/jpeg", WithMetadata(metadata))
+	require.NoError(t, err)
+
+	downloaded, _, err := s.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "raw photo", string(downloaded))
+	info, err = s.Stat(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("raw photo")), info.Size)
+
+	// and get one when their keys are rotated, even to the same master key
+	report, err := s.RotateKeys(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Rewrapped)
+	rotated, _, err := local.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	_, rotatedSealed, ok := parseEnvelope(rotated)
+	require.True(t, ok)
+	assert.Equal(t, sealed, rotatedSealed)
+	downloaded, _, err = s.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "raw photo", string(downloaded))
+}
+
+func TestEncryptedStorage_CopyAcrossPrefixes(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	ctx := context.Background()
+
+	_, err := s.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg",
+		WithCacheControl("private"), WithMetadata(map[string]string{"photo-id": "p"}))
+	require.NoError(t, err)
+
+	// Objects copied into the prefixes are encrypted
+	require.NoError(t, s.Copy(ctx, "photos/p/original.jpg", "raw/u/original.jpg"))
+	stored, _, err := local.Download(ctx, "raw/u/original.jpg")
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "original")
+	info, err := local.Stat(ctx, "raw/u/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "p", info.Metadata["photo-id"])
+	assert.NotEmpty(t, info.Metadata[metaKeyID])
+	assert.Equal(t, "private", info.CacheControl)
+
+	// and decrypted when they're moved out of them
+	require.NoError(t, s.Move(ctx, "raw/u/original.jpg", "photos/q/original.jpg"))
+	stored, contentType, err := local.Download(ctx, "photos/q/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(stored))
+	assert.Equal(t, "image/jpeg", contentType)
+	info, err = local.Stat(ctx, "photos/q/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "p", info.Metadata["photo-id"])
+	assert.Empty(t, info.Metadata[metaKeyID])
+	exists, err := local.Exists(ctx, "raw/u/original.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.ErrorIs(t, s.Move(ctx, "raw/u/missing.jpg", "photos/q/missing.jpg"), ErrNotFound)
+}
+
+func TestEncryptedStorage_Unencrypted(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	ctx := context.Background()
+
+	// Objects outside the prefixes aren't encrypted
+	_, err := s.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg")
+	require.NoError(t, err)
+	stored, _, err := local.Download(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("original"), stored)
+
+	// Objects stored before encryption was enabled are read as they are
+	_, err = local.Upload(ctx, "raw/u/old.jpg", []byte("old"), "image/jpeg")
+	require.NoError(t, err)
+	downloaded, _, err := s.Download(ctx, "raw/u/old.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), downloaded)
+
+	_, _, err = s.Download(ctx, "raw/u/missing.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestEncryptedStorage_UnknownKey(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	ctx := context.Background()
+
+	_, err := s.Upload(ctx, "raw/u/photo.jpg", []byte("raw photo"), "image/jpeg")
+	require.NoError(t, err)
+
+	other, err := kms.NewStatic(bytes.Repeat([]byte{2}, kms.KeySize))
+	require.NoError(t, err)
+	_, _, err = NewEncryptedStorage(local, other, []string{"raw/"}, "", nil).Download(ctx, "raw/u/photo.jpg")
+	assert.ErrorIs(t, err, kms.ErrUnknownKey)
+}
+
+func TestEncryptedStorage_DirectUpload(t *testing.T) {
+	s, _ := newTestEncryptedStorage(t)
+	ctx := context.Background()
+	conditions := UploadConditions{ContentType: "image/jpeg", ContentLength: 3, SHA256: strings.Repeat("a", 64)}
+
+	_, err := s.GenerateUploadURL(ctx, "raw/u/photo.jpg", conditions, time.Minute)
+	assert.ErrorIs(t, err, ErrEncrypted)
+	_, err = s.GenerateUploadForm(ctx, "raw/u/photo.jpg", conditions, time.Minute)
+	assert.ErrorIs(t, err, ErrEncrypted)
+
+	_, err = s.GenerateUploadURL(ctx, "photos/p/original.jpg", conditions, time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestEncryptedStorage_ServeHTTP(t *testing.T) {
+	s, _ := newTestEncryptedStorage(t)
+	ctx := context.Background()
+
+	_, err := s.Upload(ctx, "raw/u/photo.jpg", []byte("raw photo"), "image/jpeg")
+	require.NoError(t, err)
+
+	signed, err := s.GenerateURL(ctx, "raw/u/photo.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "http://localhost:8080/encrypted/raw/u/photo.jpg?"))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		u, err := url.Parse(target)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, strings.TrimPrefix(u.Path, "/encrypted")+"?"+u.RawQuery, nil)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(signed)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "raw photo", w.Body.String())
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+
+	// Signatures are bound to their key and expiration
+	w = serve(strings.Replace(signed, "photo.jpg", "other.jpg", 1))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	expired, err := s.GenerateURL(ctx, "raw/u/photo.jpg", -time.Minute)
+	require.NoError(t, err)
+	w = serve(expired)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Unencrypted objects are presigned by storage
+	unencrypted, err := s.GenerateURL(ctx, "photos/p/original.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(unencrypted, "http://localhost:8080/files/photos/p/original.jpg?"))
+}
+
+func TestEncryptedStorage_RotateKeys(t *testing.T) {
+	local := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{1}, kms.KeySize)
+	newKey := bytes.Repeat([]byte{2}, kms.KeySize)
+
+	oldKeys, err := kms.NewStatic(oldKey)
+	require.NoError(t, err)
+	old := NewEncryptedStorage(local, oldKeys, []string{"raw/"}, "", nil)
+	_, err = old.Upload(ctx, "raw/u/a.jpg", []byte("a"), "image/jpeg",
+		WithMetadata(map[string]string{"photo-id": "1"}))
+	require.NoError(t, err)
+	_, err = local.Upload(ctx, "raw/u/b.jpg", []byte("b"), "image/jpeg")
+	require.NoError(t, err)
+	stored, _, err := local.Download(ctx, "raw/u/a.jpg")
+	require.NoError(t, err)
+
+	newKeys, err := kms.NewStatic(newKey, oldKey)
+	require.NoError(t, err)
+	s := NewEncryptedStorage(local, newKeys, []string{"raw/"}, "", nil)
+	keyID, err := newKeys.KeyID(ctx)
+	require.NoError(t, err)
+
+	report, err := s.RotateKeys(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, RotationReport{DryRun: true, KeyID: keyID, Objects: 2, Rewrapped: 1, Encrypted: 1}, report)
+
+	report, err = s.RotateKeys(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, RotationReport{KeyID: keyID, Objects: 2, Rewrapped: 1, Encrypted: 1}, report)
+
+	// The data key is rewrapped without encrypting the object again
+	info, err := local.Stat(ctx, "raw/u/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, keyID, info.Metadata[metaKeyID])
+	assert.Equal(t, "1", info.Metadata["photo-id"])
+	rotated, _, err := local.Download(ctx, "raw/u/a.jpg")
+	require.NoError(t, err)
+	e, rotatedSealed, ok := parseEnvelope(rotated)
+	require.True(t, ok)
+	assert.Equal(t, keyID, e.keyID)
+	_, sealed, ok := parseEnvelope(stored)
+	require.True(t, ok)
+	assert.Equal(t, sealed, rotatedSealed)
+
+	// Objects are readable without the previous key
+	current, err := kms.NewStatic(newKey)
+	require.NoError(t, err)
+	s = NewEncryptedStorage(local, current, []string{"raw/"}, "", nil)
+	for key, want := range map[string]string{"raw/u/a.jpg": "a", "raw/u/b.jpg": "b"} {
+		data, _, err := s.Download(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+
+	report, err = s.RotateKeys(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, RotationReport{KeyID: keyID, Objects: 2, Current: 2}, report)
+}
+
+func TestAs(t *testing.T) {
+	s, local := newTestEncryptedStorage(t)
+	resilient := NewResilientStorage(s, ResilienceOptions{})
+
+	found, ok := As[*LocalStorage](resilient)
+	assert.True(t, ok)
+	assert.Same(t, local, found)
+
+	encrypted, ok := As[*EncryptedStorage](resilient)
+	assert.True(t, ok)
+	assert.Same(t, s, encrypted)
+
+	_, ok = As[*S3Storage](resilient)
+	assert.False(t, ok)
+}
@@ -0,0 +1,454 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LocalStorage implements Storage interface using the local filesystem. It is
+// intended for local development where objects are served by the API itself.
+type LocalStorage struct {
+	root    string
+	baseURL string // Base URL the root directory is served from
+	secret  []byte // Key signing presigned uploads and downloads
+	private bool   // Objects are only served with presigned URLs
+}
+
+// NewLocalStorage creates a new LocalStorage instance rooted at the given
+// directory.
+func NewLocalStorage(root, baseURL string) *LocalStorage {
+	// Presigned uploads only need to outlive their expiration, so a key per
+	// process is enough
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+	}
+}
+
+// path resolves a key to a filesystem path, rejecting keys that would escape
+// the storage root.
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("key cannot be empty")
+	}
+
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// localMetadata holds the attributes of an object the filesystem doesn't
+// keep. It's stored in a hidden file beside the object.
+type localMetadata struct {
+	CacheControl string            `json:"cache_control,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// metadataPath returns the path of the metadata of the object at p
+func metadataPath(p string) string {
+	return filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".meta")
+}
+
+// readMetadata reads the metadata of the object at p, which is empty if the
+// object has none.
+func readMetadata(p string) (localMetadata, error) {
+	var meta localMetadata
+	data, err := os.ReadFile(metadataPath(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, nil
+	} else if err != nil {
+		return meta, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// writeMetadata writes the metadata of the object at p, removing it if it's
+// empty.
+func writeMetadata(p string, meta localMetadata) error {
+	if meta.CacheControl == "" && len(meta.Metadata) == 0 {
+		if err := os.Remove(metadataPath(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete metadata: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return writeFile(metadataPath(p), bytes.NewReader(data), nil)
+}
+
+// Upload writes data to the filesystem and returns the public URL. The
+// content type is derived from the key.
+func (s *LocalStorage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...UploadOption) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("data cannot be empty")
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFile(p, bytes.NewReader(data), nil); err != nil {
+		return "", err
+	}
+
+	o := NewUploadOptions(opts...)
+	if err := writeMetadata(p, localMetadata{CacheControl: o.CacheControl, Metadata: o.Metadata}); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s", s.baseURL, key), nil
+}
+
+// writeFile writes the contents of r to the filesystem. It writes to a
+// temporary file first so readers never observe partial objects, and discards
+// it if verify, when given, fails after the contents have been written.
+func writeFile(p string, r io.Reader, verify func() error) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if verify != nil {
+		if err := verify(); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// Download reads data from the filesystem and its MIME type
+func (s *LocalStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrNotFound
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(p))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return data, mimeType, nil
+}
+
+// Delete removes an object from the filesystem
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return writeMetadata(p, localMetadata{})
+}
+
+// Exists checks if an object exists on the filesystem
+func (s *LocalStorage) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check file existence: %w", err)
+	}
+
+	return true, nil
+}
+
+// SetPrivate sets whether objects are only served with presigned URLs.
+func (s *LocalStorage) SetPrivate(private bool) {
+	s.private = private
+}
+
+// BaseURL returns the URL objects are served under
+func (s *LocalStorage) BaseURL() string {
+	return s.baseURL
+}
+
+// GenerateURL returns the URL of the object. Objects of public storage are
+// served without access control, so the expiration is only validated. Those
+// of private storage are served with a signed URL until it expires.
+func (s *LocalStorage) GenerateURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	if expiration <= 0 {
+		return "", fmt.Errorf("expiration must be positive")
+	}
+
+	if !s.private {
+		return fmt.Sprintf("%s/%s", s.baseURL, key), nil
+	}
+	return fmt.Sprintf("%s/%s?%s", s.baseURL, key, s.signDownload(key, time.Now().Add(expiration)).Encode()), nil
+}
+
+// Stat returns information about an object on the filesystem. The checksums
+// are computed from the file, since the filesystem doesn't store them.
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	} else if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), f); err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	meta, err := readMetadata(p)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		URL:          fmt.Sprintf("%s/%s", s.baseURL, key),
+		Size:         stat.Size(),
+		ContentType:  mime.TypeByExtension(filepath.Ext(p)),
+		CacheControl: meta.CacheControl,
+		Metadata:     meta.Metadata,
+		ETag:         hex.EncodeToString(md5Hash.Sum(nil)),
+		SHA256:       hex.EncodeToString(sha256Hash.Sum(nil)),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+// Copy copies an object on the filesystem with its metadata
+func (s *LocalStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	src, err := s.path(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := s.path(dstKey)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	meta, err := readMetadata(src)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(dst, f, nil); err != nil {
+		return err
+	}
+	return writeMetadata(dst, meta)
+}
+
+// Move renames an object on the filesystem with its metadata
+func (s *LocalStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	src, err := s.path(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := s.path(dstKey)
+	if err != nil {
+		return err
+	}
+
+	meta, err := readMetadata(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(src, dst); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	if err := writeMetadata(dst, meta); err != nil {
+		return err
+	}
+	return writeMetadata(src, localMetadata{})
+}
+
+// List iterates over the objects on the filesystem whose keys start with the
+// prefix, ordered by key, reading a directory at a time. Hidden files, which
+// hold metadata and uploads in progress, aren't objects and are skipped.
+func (s *LocalStorage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		// Walk the deepest directory containing every key with the prefix
+		dir := prefix
+		if !strings.HasSuffix(dir, "/") {
+			dir = path.Dir(dir)
+		}
+		dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+		if dir != "" {
+			dir += "/"
+		}
+
+		s.walk(ctx, dir, prefix, yield)
+	}
+}
+
+// walk yields the objects under the directory of the key prefix dir whose keys
+// start with the prefix, and reports whether iteration should continue.
+func (s *LocalStorage) walk(ctx context.Context, dir, prefix string, yield func(ObjectInfo, error) bool) bool {
+	if err := ctx.Err(); err != nil {
+		yield(ObjectInfo{}, err)
+		return false
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.root, filepath.FromSlash(dir)))
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	} else if err != nil {
+		yield(ObjectInfo{}, fmt.Errorf("failed to list files: %w", err))
+		return false
+	}
+
+	// Directories are ordered by the keys within them, which follow their name
+	// and a slash
+	name := func(entry fs.DirEntry) string {
+		if entry.IsDir() {
+			return entry.Name() + "/"
+		}
+		return entry.Name()
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(name(a), name(b))
+	})
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		key := dir + name(entry)
+		if !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key) {
+			continue
+		}
+
+		if entry.IsDir() {
+			if !s.walk(ctx, key, prefix, yield) {
+				return false
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Deleted since the directory was read
+		} else if err != nil {
+			yield(ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err))
+			return false
+		}
+		object := ObjectInfo{
+			Key:          key,
+			URL:          fmt.Sprintf("%s/%s", s.baseURL, key),
+			Size:         info.Size(),
+			ContentType:  mime.TypeByExtension(path.Ext(key)),
+			LastModified: info.ModTime(),
+		}
+		if !yield(object, nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DeleteMany removes objects from the filesystem one at a time
+func (s *LocalStorage) DeleteMany(ctx context.Context, keys []string) error {
+	errs := make(map[string]error)
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			errs[key] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return &DeleteError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage_ImplementsStorageInterface(t *testing.T) {
+	var _ Storage = (*LocalStorage)(nil)
+}
+
+func TestLocalStorage_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	storage := NewLocalStorage(root, "http://localhost:8080/files/")
+
+	url, err := storage.Upload(ctx, "raw/user/photo.jpg", []byte("test image data"), "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/raw/user/photo.jpg", url)
+	assert.FileExists(t, filepath.Join(root, "raw", "user", "photo.jpg"))
+
+	exists, err := storage.Exists(ctx, "raw/user/photo.jpg")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	data, mimeType, err := storage.Download(ctx, "raw/user/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test image data"), data)
+	assert.Equal(t, "image/jpeg", mimeType)
+
+	presigned, err := storage.GenerateURL(ctx, "raw/user/photo.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, url, presigned)
+
+	require.NoError(t, storage.Delete(ctx, "raw/user/photo.jpg"))
+	exists, err = storage.Exists(ctx, "raw/user/photo.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, _, err = storage.Download(ctx, "raw/user/photo.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing object is not an error
+	assert.NoError(t, storage.Delete(ctx, "raw/user/photo.jpg"))
+}
+
+func TestLocalStorage_InvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	storage := NewLocalStorage(filepath.Join(root, "storage"), "http://localhost:8080/files")
+
+	for _, key := range []string{"", "../escape.jpg", "raw/../../escape.jpg", "/absolute.jpg", "raw//photo.jpg"} {
+		t.Run(key, func(t *testing.T) {
+			_, err := storage.Upload(ctx, key, []byte("data"), "image/jpeg")
+			assert.Error(t, err)
+		})
+	}
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no files should be written outside the storage root")
+}
+
+func TestLocalStorage_EmptyData(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir(), "")
+
+	_, err := storage.Upload(context.Background(), "empty.jpg", nil, "image/jpeg")
+	assert.Error(t, err)
+}
+
+func TestLocalStorage_List(t *testing.T) {
+	ctx := context.Background()
+	storage := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+
+	for _, key := range []string{"photos/b/small.jpg", "photos/a/large.webp", "photos/a.jpg", "photos/a/small.jpg",
+		"raw/u/p.jpg"} {
+		_, err := storage.Upload(ctx, key, []byte(key), "image/jpeg")
+		require.NoError(t, err)
+	}
+
+	list := func(prefix string) []ObjectInfo {
+		var objects []ObjectInfo
+		for object, err := range storage.List(ctx, prefix) {
+			require.NoError(t, err)
+			objects = append(objects, object)
+		}
+		return objects
+	}
+
+	// Keys are ordered as strings, so photos/a.jpg comes before photos/a/
+	objects := list("photos/")
+	keys := make([]string, len(objects))
+	for i, object := range objects {
+		keys[i] = object.Key
+	}
+	assert.Equal(t, []string{"photos/a.jpg", "photos/a/large.webp", "photos/a/small.jpg", "photos/b/small.jpg"}, keys)
+	assert.Equal(t, int64(len("photos/a.jpg")), objects[0].Size)
+	assert.Equal(t, "http://localhost:8080/files/photos/a.jpg", objects[0].URL)
+
+	// Prefixes needn't end at a directory
+	objects = list("photos/a/s")
+	require.Len(t, objects, 1)
+	assert.Equal(t, "photos/a/small.jpg", objects[0].Key)
+
+	assert.Len(t, list(""), 5)
+	assert.Empty(t, list("missing/"))
+
+	// Iteration can stop early
+	for object := range storage.List(ctx, "photos/") {
+		assert.Equal(t, "photos/a.jpg", object.Key)
+		break
+	}
+}
+
+func TestLocalStorage_DeleteMany(t *testing.T) {
+	ctx := context.Background()
+	storage := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+
+	for _, key := range []string{"photos/a/small.jpg", "photos/a/large.jpg"} {
+		_, err := storage.Upload(ctx, key, []byte(key), "image/jpeg")
+		require.NoError(t, err)
+	}
+
+	// Missing objects aren't errors, invalid keys are reported
+	err := storage.DeleteMany(ctx, []string{"photos/a/small.jpg", "photos/a/large.jpg", "photos/missing.jpg",
+		"../escape"})
+	var deleteErr *DeleteError
+	require.ErrorAs(t, err, &deleteErr)
+	assert.Len(t, deleteErr.Errors, 1)
+	assert.Contains(t, deleteErr.Errors, "../escape")
+
+	exists, err := storage.Exists(ctx, "photos/a/small.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, storage.DeleteMany(ctx, nil))
+}
+
+func TestLocalStorage_Metadata(t *testing.T) {
+	ctx := context.Background()
+	storage := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+
+	_, err := storage.Upload(ctx, "photos/p/thumb.jpg", []byte("thumb"), "image/jpeg",
+		WithCacheControl("public, max-age=60"), WithMetadata(map[string]string{"Photo-ID": "p"}))
+	require.NoError(t, err)
+
+	info, err := storage.Stat(ctx, "photos/p/thumb.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "public, max-age=60", info.CacheControl)
+	assert.Equal(t, map[string]string{"photo-id": "p"}, info.Metadata)
+
+	// Metadata isn't listed as objects
+	var keys []string
+	for object, err := range storage.List(ctx, "") {
+		require.NoError(t, err)
+		keys = append(keys, object.Key)
+	}
+	assert.Equal(t, []string{"photos/p/thumb.jpg"}, keys)
+
+	// Copies keep the metadata, moves take it along
+	require.NoError(t, storage.Copy(ctx, "photos/p/thumb.jpg", "photos/q/thumb.jpg"))
+	require.NoError(t, storage.Move(ctx, "photos/p/thumb.jpg", "archive/p/thumb.jpg"))
+	for _, key := range []string{"photos/q/thumb.jpg", "archive/p/thumb.jpg"} {
+		info, err := storage.Stat(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), info.Size)
+		assert.Equal(t, "public, max-age=60", info.CacheControl)
+		assert.Equal(t, map[string]string{"photo-id": "p"}, info.Metadata)
+	}
+	_, err = storage.Stat(ctx, "photos/p/thumb.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, storage.Copy(ctx, "photos/p/thumb.jpg", "photos/r/thumb.jpg"), ErrNotFound)
+	assert.ErrorIs(t, storage.Move(ctx, "photos/p/thumb.jpg", "photos/r/thumb.jpg"), ErrNotFound)
+	assert.Error(t, storage.Copy(ctx, "photos/q/thumb.jpg", "../escape"))
+
+	// Uploading again replaces the metadata, deleting removes it
+	_, err = storage.Upload(ctx, "photos/q/thumb.jpg", []byte("thumb"), "image/jpeg")
+	require.NoError(t, err)
+	info, err = storage.Stat(ctx, "photos/q/thumb.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, info.CacheControl)
+	assert.Empty(t, info.Metadata)
+
+	require.NoError(t, storage.Delete(ctx, "archive/p/thumb.jpg"))
+	entries, err := os.ReadDir(filepath.Join(storage.root, "archive", "p"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
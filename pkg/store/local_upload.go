@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Presigned uploads to local storage are signed with an HMAC of the upload
+// conditions. The conditions are sent as query parameters of a PUT, or as form
+// fields preceding the file of a POST.
+const (
+	paramContentLength = "content-length"
+	paramSHA256        = "sha256"
+	paramExpires       = "expires"
+	paramSignature     = "signature"
+	fieldContentType   = "Content-Type"
+	fieldFile          = "file"
+)
+
+// errUploadRejected is returned when an upload doesn't match its conditions
+var errUploadRejected = errors.New("upload does not match the presigned conditions")
+
+// GenerateUploadURL creates a signed PUT URL served by ServeHTTP
+func (s *LocalStorage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := conditions.validate(key, expiration); err != nil {
+		return PresignedUpload{}, err
+	}
+	if _, err := s.path(key); err != nil {
+		return PresignedUpload{}, err
+	}
+
+	expiresAt := time.Now().Add(expiration)
+	query := s.signUpload(http.MethodPut, key, conditions, expiresAt)
+	delete(query, fieldContentType)
+
+	return PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       fmt.Sprintf("%s/%s?%s", s.baseURL, key, query.Encode()),
+		Headers:   map[string]string{fieldContentType: conditions.ContentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GenerateUploadForm creates a signed multipart form upload served by
+// ServeHTTP
+func (s *LocalStorage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := conditions.validate(key, expiration); err != nil {
+		return PresignedUpload{}, err
+	}
+	if _, err := s.path(key); err != nil {
+		return PresignedUpload{}, err
+	}
+
+	expiresAt := time.Now().Add(expiration)
+	fields := map[string]string{}
+	for name, values := range s.signUpload(http.MethodPost, key, conditions, expiresAt) {
+		fields[name] = values[0]
+	}
+
+	return PresignedUpload{
+		Method:    http.MethodPost,
+		URL:       fmt.Sprintf("%s/%s", s.baseURL, key),
+		Fields:    fields,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// signUpload returns the conditions of an upload and their signature
+func (s *LocalStorage) signUpload(method, key string, conditions UploadConditions,
+	expiresAt time.Time) url.Values {
+	values := url.Values{}
+	values.Set(fieldContentType, conditions.ContentType)
+	values.Set(paramContentLength, strconv.FormatInt(conditions.ContentLength, 10))
+	values.Set(paramSHA256, conditions.SHA256)
+	values.Set(paramExpires, strconv.FormatInt(expiresAt.Unix(), 10))
+	values.Set(paramSignature, s.signature(method, key, values))
+	return values
+}
+
+func (s *LocalStorage) signature(method, key string, values url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join([]string{
+		method,
+		key,
+		values.Get(fieldContentType),
+		values.Get(paramContentLength),
+		values.Get(paramSHA256),
+		values.Get(paramExpires),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signDownload returns the expiration of a download and its signature
+func (s *LocalStorage) signDownload(key string, expiresAt time.Time) url.Values {
+	values := url.Values{}
+	values.Set(paramExpires, strconv.FormatInt(expiresAt.Unix(), 10))
+	values.Set(paramSignature, s.signature(http.MethodGet, key, values))
+	return values
+}
+
+// verifyDownload checks the signature and expiration of a download.
+func (s *LocalStorage) verifyDownload(key string, values url.Values) error {
+	download := url.Values{}
+	download.Set(paramExpires, values.Get(paramExpires))
+	expected := s.signature(http.MethodGet, key, download)
+	if !hmac.Equal([]byte(expected), []byte(values.Get(paramSignature))) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	expires, err := strconv.ParseInt(values.Get(paramExpires), 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return fmt.Errorf("download has expired")
+	}
+
+	return nil
+}
+
+// verifyUpload checks the signature and expiration of an upload and returns
+// its conditions.
+func (s *LocalStorage) verifyUpload(method, key string, values url.Values) (UploadConditions, error) {
+	expected := s.signature(method, key, values)
+	if !hmac.Equal([]byte(expected), []byte(values.Get(paramSignature))) {
+		return UploadConditions{}, fmt.Errorf("invalid signature")
+	}
+
+	expires, err := strconv.ParseInt(values.Get(paramExpires), 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return UploadConditions{}, fmt.Errorf("upload has expired")
+	}
+
+	length, err := strconv.ParseInt(values.Get(paramContentLength), 10, 64)
+	if err != nil {
+		return UploadConditions{}, fmt.Errorf("invalid content length")
+	}
+
+	return UploadConditions{
+		ContentType:   values.Get(fieldContentType),
+		ContentLength: length,
+		SHA256:        values.Get(paramSHA256),
+	}, nil
+}
+
+// ServeHTTP serves objects with GET and HEAD and receives presigned uploads
+// with PUT and POST. It is mounted at the base URL of the storage.
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.serve(w, r)
+	case http.MethodPut:
+		s.receivePut(w, r)
+	case http.MethodPost:
+		s.receivePost(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// serve serves an object with its Cache-Control header. Hidden files hold
+// metadata and uploads in progress, so they're never served. Objects of
+// private storage are only served with a presigned URL.
+func (s *LocalStorage) serve(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if s.private {
+		if err := s.verifyDownload(key, r.URL.Query()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if p, err := s.path(key); err == nil {
+		if meta, err := readMetadata(p); err == nil && meta.CacheControl != "" {
+			w.Header().Set("Cache-Control", meta.CacheControl)
+		}
+	}
+
+	http.FileServer(http.Dir(s.root)).ServeHTTP(w, r)
+}
+
+func (s *LocalStorage) receivePut(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	values := r.URL.Query()
+	values.Set(fieldContentType, r.Header.Get(fieldContentType))
+
+	conditions, err := s.verifyUpload(http.MethodPut, key, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	s.receive(w, key, conditions, r.Body)
+}
+
+func (s *LocalStorage) receivePost(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Like S3, the fields must precede the file, so the file can be streamed
+	values := url.Values{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			http.Error(w, "missing file field", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() == fieldFile {
+			conditions, err := s.verifyUpload(http.MethodPost, key, values)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			s.receive(w, key, conditions, part)
+			return
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, 1024))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values.Set(part.FormName(), string(value))
+	}
+}
+
+// receive writes an uploaded object, discarding it unless its size and
+// checksum match the conditions.
+func (s *LocalStorage) receive(w http.ResponseWriter, key string, conditions UploadConditions, body io.Reader) {
+	p, err := s.path(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The content type of local objects is derived from the key
+	if contentType := mime.TypeByExtension(filepath.Ext(p)); contentType != conditions.ContentType {
+		http.Error(w, errUploadRejected.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h := sha256.New()
+	counter := &countingWriter{}
+	body = io.TeeReader(io.LimitReader(body, conditions.ContentLength+1), io.MultiWriter(h, counter))
+
+	err = writeFile(p, body, func() error {
+		if counter.n != conditions.ContentLength || hex.EncodeToString(h.Sum(nil)) != conditions.SHA256 {
+			return errUploadRejected
+		}
+		return nil
+	})
+	if errors.Is(err, errUploadRejected) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Like S3, replacing an object replaces its metadata
+	if err := writeMetadata(p, localMetadata{}); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLocalServer serves a LocalStorage like the API does under /files/.
+func newTestLocalServer(t *testing.T) *LocalStorage {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	storage := NewLocalStorage(t.TempDir(), server.URL+"/files")
+	mux.Handle("/files/", http.StripPrefix("/files/", storage))
+	return storage
+}
+
+func testConditions(data []byte) UploadConditions {
+	sum := sha256.Sum256(data)
+	return UploadConditions{
+		ContentType:   "image/jpeg",
+		ContentLength: int64(len(data)),
+		SHA256:        hex.EncodeToString(sum[:]),
+	}
+}
+
+func put(t *testing.T, upload PresignedUpload, data []byte) int {
+	req, err := http.NewRequest(upload.Method, upload.URL, bytes.NewReader(data))
+	require.NoError(t, err)
+	for name, value := range upload.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func post(t *testing.T, upload PresignedUpload, data []byte) int {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range upload.Fields {
+		require.NoError(t, form.WriteField(name, value))
+	}
+	file, err := form.CreateFormFile("file", "photo.jpg")
+	require.NoError(t, err)
+	_, _ = file.Write(data)
+	require.NoError(t, form.Close())
+
+	resp, err := http.Post(upload.URL, form.FormDataContentType(), &body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLocalStorage_PresignedUploads(t *testing.T) {
+	data := []byte("test image data")
+
+	tests := []struct {
+		name     string
+		generate func(*LocalStorage, context.Context, string, UploadConditions, time.Duration) (PresignedUpload, error)
+		send     func(*testing.T, PresignedUpload, []byte) int
+	}{
+		{"PUT", (*LocalStorage).GenerateUploadURL, put},
+		{"POST", (*LocalStorage).GenerateUploadForm, post},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := newTestLocalServer(t)
+
+			upload, err := tt.generate(storage, ctx, "raw/user/photo.jpg", testConditions(data), time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, upload.Method)
+
+			// Content that doesn't match the conditions is rejected
+			assert.Equal(t, http.StatusBadRequest, tt.send(t, upload, []byte("test image dat4")))
+			assert.Equal(t, http.StatusBadRequest, tt.send(t, upload, append(data, '!')))
+			_, err = storage.Stat(ctx, "raw/user/photo.jpg")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.Equal(t, http.StatusNoContent, tt.send(t, upload, data))
+
+			info, err := storage.Stat(ctx, "raw/user/photo.jpg")
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), info.Size)
+			assert.Equal(t, "image/jpeg", info.ContentType)
+			assert.Equal(t, testConditions(data).SHA256, info.SHA256)
+			assert.Equal(t, "bf6d3bdce17efe14125f44654d4941cb", info.ETag)
+
+			resp, err := http.Get(info.URL)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestLocalStorage_PresignedUploadRejected(t *testing.T) {
+	ctx := context.Background()
+	data := []byte("test image data")
+	storage := newTestLocalServer(t)
+
+	t.Run("expired", func(t *testing.T) {
+		upload, err := storage.GenerateUploadURL(ctx, "raw/user/photo.jpg", testConditions(data), time.Nanosecond)
+		require.NoError(t, err)
+		time.Sleep(time.Second)
+
+		assert.Equal(t, http.StatusForbidden, put(t, upload, data))
+	})
+
+	t.Run("tampered content type", func(t *testing.T) {
+		upload, err := storage.GenerateUploadURL(ctx, "raw/user/photo.jpg", testConditions(data), time.Minute)
+		require.NoError(t, err)
+		upload.Headers["Content-Type"] = "image/png"
+
+		assert.Equal(t, http.StatusForbidden, put(t, upload, data))
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		upload, err := storage.GenerateUploadURL(ctx, "raw/user/photo.jpg", testConditions(data), time.Minute)
+		require.NoError(t, err)
+		upload.URL = strings.Replace(upload.URL, "/raw/user/photo.jpg?", "/raw/user/other.jpg?", 1)
+
+		assert.Equal(t, http.StatusForbidden, put(t, upload, data))
+	})
+
+	t.Run("content type not matching the key", func(t *testing.T) {
+		conditions := testConditions(data)
+		conditions.ContentType = "image/png"
+		upload, err := storage.GenerateUploadURL(ctx, "raw/user/photo.jpg", conditions, time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, put(t, upload, data))
+	})
+}
+
+func TestLocalStorage_Serve(t *testing.T) {
+	storage := newTestLocalServer(t)
+
+	url, err := storage.Upload(context.Background(), "photos/p/thumb.jpg", []byte("thumb"), "image/jpeg",
+		WithCacheControl("public, max-age=60"))
+	require.NoError(t, err)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public, max-age=60", resp.Header.Get("Cache-Control"))
+
+	// Metadata isn't served
+	resp, err = http.Get(strings.TrimSuffix(url, "thumb.jpg") + ".thumb.jpg.meta")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestLocalStorage_ServePrivate(t *testing.T) {
+	storage := newTestLocalServer(t)
+	storage.SetPrivate(true)
+	ctx := context.Background()
+
+	uploaded, err := storage.Upload(ctx, "raw/u/photo.jpg", []byte("photo"), "image/jpeg")
+	require.NoError(t, err)
+	presigned, err := storage.GenerateURL(ctx, "raw/u/photo.jpg", time.Minute)
+	require.NoError(t, err)
+	// Expirations are signed in whole seconds, so this one has already passed
+	expired, err := storage.GenerateURL(ctx, "raw/u/photo.jpg", time.Nanosecond)
+	require.NoError(t, err)
+	other, err := storage.GenerateURL(ctx, "raw/u/other.jpg", time.Minute)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"presigned", presigned, http.StatusOK},
+		{"unsigned", uploaded, http.StatusForbidden},
+		{"expired", expired, http.StatusForbidden},
+		{"signed for another key", strings.Replace(other, "other.jpg", "photo.jpg", 1), http.StatusForbidden},
+	} {
+		resp, err := http.Get(tt.url)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, tt.status, resp.StatusCode, tt.name)
+	}
+}
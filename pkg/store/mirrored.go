@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// repairBatchSize is how many queued repairs are read at a time
+const repairBatchSize = 100
+
+// Repair is the key of an object queued for repair, and when it was last
+// queued
+type Repair struct {
+	Key      string    `db:"key"`
+	QueuedAt time.Time `db:"queued_at"`
+}
+
+// RepairQueue holds the keys of the objects the secondary storage of a
+// MirroredStorage may differ on from the primary storage
+type RepairQueue interface {
+	// QueueStorageRepair queues the key, once however many times it's queued,
+	// updating when it was queued
+	QueueStorageRepair(ctx context.Context, key string) error
+
+	// GetStorageRepairs returns up to limit repairs queued before the time,
+	// ordered by key after the given one
+	GetStorageRepairs(ctx context.Context, before time.Time, after string, limit int) ([]Repair, error)
+
+	// DeleteStorageRepair removes the repair, unless the key was queued again
+	// since
+	DeleteStorageRepair(ctx context.Context, repair Repair) error
+}
+
+// MemoryRepairQueue is a RepairQueue held in memory, losing its repairs when
+// the process exits
+type MemoryRepairQueue struct {
+	mu      sync.Mutex
+	repairs map[string]time.Time
+}
+
+// NewMemoryRepairQueue creates an empty MemoryRepairQueue.
+func NewMemoryRepairQueue() *MemoryRepairQueue {
+	return &MemoryRepairQueue{repairs: map[string]time.Time{}}
+}
+
+// QueueStorageRepair queues the key
+func (q *MemoryRepairQueue) QueueStorageRepair(ctx context.Context, key string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.repairs[key] = time.Now()
+	return nil
+}
+
+// GetStorageRepairs returns up to limit repairs queued before the time,
+// ordered by key after the given one
+func (q *MemoryRepairQueue) GetStorageRepairs(ctx context.Context, before time.Time, after string,
+	limit int) ([]Repair, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	repairs := []Repair{}
+	for _, key := range slices.Sorted(maps.Keys(q.repairs)) {
+		if len(repairs) == limit {
+			break
+		}
+		if queuedAt := q.repairs[key]; key > after && queuedAt.Before(before) {
+			repairs = append(repairs, Repair{Key: key, QueuedAt: queuedAt})
+		}
+	}
+	return repairs, nil
+}
+
+// DeleteStorageRepair removes the repair, unless the key was queued again
+// since
+func (q *MemoryRepairQueue) DeleteStorageRepair(ctx context.Context, repair Repair) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if queuedAt, ok := q.repairs[repair.Key]; ok && queuedAt.Equal(repair.QueuedAt) {
+		delete(q.repairs, repair.Key)
+	}
+	return nil
+}
+
+// MirroredStorage writes objects to a primary and a secondary storage, and
+// reads them from the primary storage, falling back to the secondary storage
+// when the primary storage fails. Objects the primary storage doesn't have
+// aren't read from the secondary storage, which may still have objects whose
+// deletion is queued for repair. Writes only fail when the primary storage
+// fails. The keys the secondary storage fails to be written are queued, and
+// Repair later copies their objects from the primary storage or deletes them.
+//
+// Objects are listed, presigned and given URLs by the primary storage. Keys of
+// presigned uploads, which only reach the primary storage, are queued as
+// they're presigned, so their objects are copied once repaired.
+type MirroredStorage struct {
+	primary   Storage
+	secondary Storage
+	queue     RepairQueue
+
+	// repairDelay is how long repairs stay queued before being repaired, so
+	// presigned uploads can complete first
+	repairDelay time.Duration
+}
+
+// RepairReport summarizes a repair of the secondary storage.
+type RepairReport struct {
+	Copied  int // Objects copied from the primary storage
+	Deleted int // Objects deleted since the primary storage doesn't have them
+	Errors  int // Objects that failed to be repaired, left queued
+}
+
+// LogValue logs the report as a group.
+func (r RepairReport) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("copied", r.Copied),
+		slog.Int("deleted", r.Deleted),
+		slog.Int("errors", r.Errors),
+	)
+}
+
+// NewMirroredStorage creates a MirroredStorage of the primary and secondary
+// storage, queueing the keys to repair in the queue. Repairs are repaired once
+// queued for the delay.
+func NewMirroredStorage(primary, secondary Storage, queue RepairQueue, repairDelay time.Duration) *MirroredStorage {
+	return &MirroredStorage{primary: primary, secondary: secondary, queue: queue, repairDelay: repairDelay}
+}
+
+// Unwrap returns the primary storage
+func (s *MirroredStorage) Unwrap() Storage {
+	return s.primary
+}
+
+// repairLater logs the failure to write the keys to the secondary storage and
+// queues them for repair. They're queued even if the operation was canceled,
+// as the primary storage may have been written.
+func (s *MirroredStorage) repairLater(ctx context.Context, err error, keys ...string) {
+	slog.Error("Failed to write secondary storage", "error", err, "keys", keys)
+
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := s.queue.QueueStorageRepair(ctx, key); err != nil {
+			slog.Error("Failed to queue storage repair", "error", err, "key", key)
+		}
+	}
+}
+
+// fallback logs that the secondary storage is read since the primary storage
+// failed.
+func fallback(key string, err error) {
+	slog.Warn("Reading object from secondary storage", "error", err, "key", key)
+}
+
+// Upload uploads data to both storages and returns the URL of the object in
+// the primary storage
+func (s *MirroredStorage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...UploadOption) (string, error) {
+	url, err := s.primary.Upload(ctx, key, data, contentType, opts...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.secondary.Upload(ctx, key, data, contentType, opts...); err != nil {
+		s.repairLater(ctx, err, key)
+	}
+	return url, nil
+}
+
+// Download retrieves data from the primary storage, or the secondary storage
+// if the primary storage fails. The error of the primary storage is returned
+// if both fail.
+func (s *MirroredStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	data, contentType, err := s.primary.Download(ctx, key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return data, contentType, err
+	}
+
+	data, contentType, secondaryErr := s.secondary.Download(ctx, key)
+	if secondaryErr != nil {
+		return nil, "", err
+	}
+	fallback(key, err)
+	return data, contentType, nil
+}
+
+// Delete removes an object from both storages
+func (s *MirroredStorage) Delete(ctx context.Context, key string) error {
+	if err := s.primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := s.secondary.Delete(ctx, key); err != nil {
+		s.repairLater(ctx, err, key)
+	}
+	return nil
+}
+
+// DeleteMany removes objects from both storages, returning the error of the
+// primary storage. Objects the primary storage failed to delete are kept in
+// the secondary storage too.
+func (s *MirroredStorage) DeleteMany(ctx context.Context, keys []string) error {
+	primaryErr := s.primary.DeleteMany(ctx, keys)
+	var deleteErr *DeleteError
+	if primaryErr != nil && !errors.As(primaryErr, &deleteErr) {
+		return primaryErr
+	}
+
+	deleted := keys
+	if deleteErr != nil {
+		deleted = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+			_, failed := deleteErr.Errors[key]
+			return failed
+		})
+	}
+	if len(deleted) == 0 {
+		return primaryErr
+	}
+
+	err := s.secondary.DeleteMany(ctx, deleted)
+	var failed *DeleteError
+	switch {
+	case errors.As(err, &failed):
+		s.repairLater(ctx, err, slices.Sorted(maps.Keys(failed.Errors))...)
+	case err != nil:
+		s.repairLater(ctx, err, deleted...)
+	}
+	return primaryErr
+}
+
+// Exists checks if an object exists in the primary storage, or the secondary
+// storage if the primary storage fails
+func (s *MirroredStorage) Exists(ctx context.Context, key string) (bool, error) {
+	exists, err := s.primary.Exists(ctx, key)
+	if err == nil {
+		return exists, nil
+	}
+
+	exists, secondaryErr := s.secondary.Exists(ctx, key)
+	if secondaryErr != nil {
+		return false, err
+	}
+	fallback(key, err)
+	return exists, nil
+}
+
+// GenerateURL creates a presigned URL of the object in the primary storage
+func (s *MirroredStorage) GenerateURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	return s.primary.GenerateURL(ctx, key, expiration)
+}
+
+// BaseURL returns the URL objects are uploaded under in the primary storage
+func (s *MirroredStorage) BaseURL() string {
+	return s.primary.BaseURL()
+}
+
+// Stat returns information about an object in the primary storage, or the
+// secondary storage if the primary storage fails, with its URL in the primary
+// storage
+func (s *MirroredStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.primary.Stat(ctx, key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return info, err
+	}
+
+	info, secondaryErr := s.secondary.Stat(ctx, key)
+	if secondaryErr != nil {
+		return ObjectInfo{}, err
+	}
+	fallback(key, err)
+	info.URL = fmt.Sprintf("%s/%s", s.primary.BaseURL(), key)
+	return info, nil
+}
+
+// Copy copies an object within both storages
+func (s *MirroredStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.primary.Copy(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+	if err := s.secondary.Copy(ctx, srcKey, dstKey); err != nil {
+		s.repairLater(ctx, err, dstKey)
+	}
+	return nil
+}
+
+// Move moves an object within both storages
+func (s *MirroredStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.primary.Move(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+	if err := s.secondary.Move(ctx, srcKey, dstKey); err != nil {
+		s.repairLater(ctx, err, srcKey, dstKey)
+	}
+	return nil
+}
+
+// List iterates over the objects of the primary storage
+func (s *MirroredStorage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return s.primary.List(ctx, prefix)
+}
+
+// GenerateUploadURL queues the key for repair and creates a presigned PUT
+// request uploading to the primary storage
+func (s *MirroredStorage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := s.queue.QueueStorageRepair(ctx, key); err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to queue storage repair: %w", err)
+	}
+	return s.primary.GenerateUploadURL(ctx, key, conditions, expiration)
+}
+
+// GenerateUploadForm queues the key for repair and creates a presigned POST
+// request uploading to the primary storage
+func (s *MirroredStorage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := s.queue.QueueStorageRepair(ctx, key); err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to queue storage repair: %w", err)
+	}
+	return s.primary.GenerateUploadForm(ctx, key, conditions, expiration)
+}
+
+// Repair makes the secondary storage match the primary storage on the queued
+// keys, copying the objects of the primary storage with their metadata and
+// deleting the objects it doesn't have. Failures to repair an object are
+// logged and counted, leaving it queued for a later repair, an error is only
+// returned if the queue can't be read.
+func (s *MirroredStorage) Repair(ctx context.Context) (RepairReport, error) {
+	var report RepairReport
+	before := time.Now().Add(-s.repairDelay)
+
+	for after := ""; ; {
+		repairs, err := s.queue.GetStorageRepairs(ctx, before, after, repairBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to get storage repairs: %w", err)
+		}
+
+		for _, repair := range repairs {
+			copied, err := s.repair(ctx, repair.Key)
+			if err == nil {
+				err = s.queue.DeleteStorageRepair(ctx, repair)
+			}
+			switch {
+			case err != nil:
+				slog.Error("Failed to repair object", "error", err, "key", repair.Key)
+				report.Errors++
+			case copied:
+				report.Copied++
+			default:
+				report.Deleted++
+			}
+		}
+
+		if len(repairs) < repairBatchSize {
+			return report, nil
+		}
+		after = repairs[len(repairs)-1].Key
+	}
+}
+
+// repair copies the object from the primary storage to the secondary storage,
+// or deletes it from the secondary storage if the primary storage doesn't have
+// it, reporting whether it was copied.
+func (s *MirroredStorage) repair(ctx context.Context, key string) (bool, error) {
+	info, err := s.primary.Stat(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, s.secondary.Delete(ctx, key)
+	} else if err != nil {
+		return false, err
+	}
+
+	data, contentType, err := s.primary.Download(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	_, err = s.secondary.Upload(ctx, key, data, contentType,
+		WithCacheControl(info.CacheControl), WithMetadata(info.Metadata))
+	return err == nil, err
+}
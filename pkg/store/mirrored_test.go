@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMirroredStorage creates a MirroredStorage of local storages,
+// repairing without delay
+func newTestMirroredStorage(t *testing.T) (*MirroredStorage, *LocalStorage, *LocalStorage, *MemoryRepairQueue) {
+	primary := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	secondary := NewLocalStorage(t.TempDir(), "http://localhost:8080/mirror")
+	queue := NewMemoryRepairQueue()
+	return NewMirroredStorage(primary, secondary, queue, 0), primary, secondary, queue
+}
+
+// queued returns the keys queued for repair.
+func queued(t *testing.T, queue *MemoryRepairQueue) []string {
+	repairs, err := queue.GetStorageRepairs(context.Background(), time.Now().Add(time.Second), "", 100)
+	require.NoError(t, err)
+
+	keys := []string{}
+	for _, repair := range repairs {
+		keys = append(keys, repair.Key)
+	}
+	return keys
+}
+
+func TestMirroredStorage_Upload(t *testing.T) {
+	s, primary, secondary, queue := newTestMirroredStorage(t)
+	ctx := context.Background()
+
+	url, err := s.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg",
+		WithCacheControl("public"), WithMetadata(map[string]string{"photo-id": "p"}))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/photos/p/original.jpg", url)
+
+	for _, storage := range []Storage{primary, secondary} {
+		info, err := storage.Stat(ctx, "photos/p/original.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, "public", info.CacheControl)
+		assert.Equal(t, "p", info.Metadata["photo-id"])
+	}
+
+	require.NoError(t, s.Copy(ctx, "photos/p/original.jpg", "photos/p/copy.jpg"))
+	require.NoError(t, s.Move(ctx, "photos/p/copy.jpg", "photos/p/moved.jpg"))
+	require.NoError(t, s.DeleteMany(ctx, []string{"photos/p/original.jpg"}))
+	for _, storage := range []Storage{primary, secondary} {
+		exists, err := storage.Exists(ctx, "photos/p/moved.jpg")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = storage.Exists(ctx, "photos/p/original.jpg")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+
+	require.NoError(t, s.Delete(ctx, "photos/p/moved.jpg"))
+	exists, err := secondary.Exists(ctx, "photos/p/moved.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, queued(t, queue))
+}
+
+func TestMirroredStorage_Fallback(t *testing.T) {
+	primary := NewMockStorage(t)
+	secondary := NewLocalStorage(t.TempDir(), "http://localhost:8080/mirror")
+	s := NewMirroredStorage(primary, secondary, NewMemoryRepairQueue(), 0)
+	ctx := context.Background()
+	unavailable := errors.New("unavailable")
+
+	// Objects are read from the secondary storage when the primary storage
+	// fails, with their URL in the primary storage
+	_, err := secondary.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg")
+	require.NoError(t, err)
+	primary.EXPECT().Download(mock.Anything, mock.Anything).Return(nil, "", unavailable)
+	primary.EXPECT().Stat(mock.Anything, mock.Anything).Return(ObjectInfo{}, unavailable)
+	primary.EXPECT().Exists(mock.Anything, mock.Anything).Return(false, unavailable)
+	primary.EXPECT().BaseURL().Return("http://localhost:8080/files")
+
+	data, contentType, err := s.Download(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("original"), data)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	info, err := s.Stat(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/photos/p/original.jpg", info.URL)
+
+	exists, err := s.Exists(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = s.Exists(ctx, "photos/p/missing.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Errors of the primary storage are returned when both fail
+	_, _, err = s.Download(ctx, "photos/p/missing.jpg")
+	assert.EqualError(t, err, "unavailable")
+	_, err = s.Stat(ctx, "photos/p/missing.jpg")
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestMirroredStorage_NotFound(t *testing.T) {
+	s, primary, secondary, _ := newTestMirroredStorage(t)
+	ctx := context.Background()
+
+	// An object whose deletion from the secondary storage failed isn't read
+	// from it until it's repaired
+	_, err := s.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg")
+	require.NoError(t, err)
+	require.NoError(t, primary.Delete(ctx, "photos/p/original.jpg"))
+	exists, err := secondary.Exists(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	_, _, err = s.Download(ctx, "photos/p/original.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Stat(ctx, "photos/p/original.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+	exists, err = s.Exists(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMirroredStorage_Repair(t *testing.T) {
+	primary := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	secondary := NewLocalStorage(t.TempDir(), "http://localhost:8080/mirror")
+	failing := NewMockStorage(t)
+	queue := NewMemoryRepairQueue()
+	s := NewMirroredStorage(primary, failing, queue, 0)
+	ctx := context.Background()
+	unavailable := errors.New("unavailable")
+
+	// Writes succeed without the secondary storage, which is queued for repair
+	failing.EXPECT().Upload(mock.Anything, "photos/p/original.jpg", mock.Anything, "image/jpeg", mock.Anything).
+		Return("", unavailable)
+	failing.EXPECT().DeleteMany(mock.Anything, []string{"photos/p/a.jpg", "photos/p/b.jpg"}).
+		Return(&DeleteError{Errors: map[string]error{"photos/p/b.jpg": unavailable}})
+
+	_, err := s.Upload(ctx, "photos/p/original.jpg", []byte("original"), "image/jpeg",
+		WithMetadata(map[string]string{"photo-id": "p"}))
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteMany(ctx, []string{"photos/p/a.jpg", "photos/p/b.jpg"}))
+	assert.Equal(t, []string{"photos/p/b.jpg", "photos/p/original.jpg"}, queued(t, queue))
+
+	// Repairs wait for their delay
+	_, err = secondary.Upload(ctx, "photos/p/b.jpg", []byte("b"), "image/jpeg")
+	require.NoError(t, err)
+	s = NewMirroredStorage(primary, secondary, queue, time.Hour)
+	report, err := s.Repair(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RepairReport{}, report)
+
+	s = NewMirroredStorage(primary, secondary, queue, 0)
+	report, err = s.Repair(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RepairReport{Copied: 1, Deleted: 1}, report)
+	assert.Empty(t, queued(t, queue))
+
+	info, err := secondary.Stat(ctx, "photos/p/original.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "p", info.Metadata["photo-id"])
+	exists, err := secondary.Exists(ctx, "photos/p/b.jpg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMirroredStorage_DirectUpload(t *testing.T) {
+	s, primary, secondary, queue := newTestMirroredStorage(t)
+	ctx := context.Background()
+
+	// Presigned uploads only reach the primary storage, and are repaired
+	_, err := s.GenerateUploadURL(ctx, "raw/u/photo.jpg", testConditions([]byte("raw")), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"raw/u/photo.jpg"}, queued(t, queue))
+
+	_, err = primary.Upload(ctx, "raw/u/photo.jpg", []byte("raw"), "image/jpeg")
+	require.NoError(t, err)
+	report, err := s.Repair(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RepairReport{Copied: 1}, report)
+
+	data, _, err := secondary.Download(ctx, "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("raw"), data)
+}
+
+func TestMemoryRepairQueue(t *testing.T) {
+	queue := NewMemoryRepairQueue()
+	ctx := context.Background()
+
+	require.NoError(t, queue.QueueStorageRepair(ctx, "b"))
+	require.NoError(t, queue.QueueStorageRepair(ctx, "a"))
+	require.NoError(t, queue.QueueStorageRepair(ctx, "c"))
+	assert.Equal(t, []string{"a", "b", "c"}, queued(t, queue))
+
+	repairs, err := queue.GetStorageRepairs(ctx, time.Now().Add(time.Second), "a", 1)
+	require.NoError(t, err)
+	require.Len(t, repairs, 1)
+	assert.Equal(t, "b", repairs[0].Key)
+
+	// Keys queued again since they were read stay queued
+	time.Sleep(time.Millisecond)
+	require.NoError(t, queue.QueueStorageRepair(ctx, "b"))
+	require.NoError(t, queue.DeleteStorageRepair(ctx, repairs[0]))
+	assert.Equal(t, []string{"a", "b", "c"}, queued(t, queue))
+
+	repairs, err = queue.GetStorageRepairs(ctx, time.Now().Add(time.Second), "", 10)
+	require.NoError(t, err)
+	for _, repair := range repairs {
+		require.NoError(t, queue.DeleteStorageRepair(ctx, repair))
+	}
+	assert.Empty(t, queued(t, queue))
+}
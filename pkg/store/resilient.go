@@ -0,0 +1,393 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// ErrCircuitOpen is returned without calling storage while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("storage is unavailable, circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Operations are let through
+	BreakerOpen     BreakerState = "open"      // Operations fail fast until the cooldown passes
+	BreakerHalfOpen BreakerState = "half-open" // One operation probes whether storage is back
+)
+
+// maxRetryDelay caps the backoff between attempts
+const maxRetryDelay = 5 * time.Second
+
+// retryables tell whether an error of an attempt is transient: connection
+// errors, timeouts, throttling and 5xx responses
+var retryables = retry.IsErrorRetryables(append([]retry.IsErrorRetryable{
+	retry.RetryableErrorCode{Codes: map[string]struct{}{"InternalError": {}}},
+}, retry.DefaultRetryables...))
+
+// ResilienceOptions configure a ResilientStorage
+type ResilienceOptions struct {
+	Timeout          time.Duration // Timeout of an attempt, none if 0
+	Retries          int           // Retries after the first attempt
+	RetryDelay       time.Duration // Backoff before the first retry, doubled by each retry
+	BreakerThreshold int           // Consecutive failed operations opening the breaker
+	BreakerCooldown  time.Duration // How long the breaker stays open before probing
+}
+
+// ResilientStorage decorates storage with a timeout of each attempt of an
+// operation, retries of transient errors with jittered exponential backoff,
+// and a circuit breaker. Errors telling storage is unreachable or overloaded
+// are retried, while errors it answered with, such as ErrNotFound or a denied
+// access, aren't. Operations still failing with transient errors after their
+// retries count towards opening the breaker, which then fails operations with
+// ErrCircuitOpen until its cooldown passes and an operation succeeds.
+//
+// Presigning is done without calling storage, so it is neither retried nor
+// stopped by the breaker.
+type ResilientStorage struct {
+	storage Storage
+	opts    ResilienceOptions
+	breaker *breaker
+}
+
+// Check that ResilientStorage implements Storage
+var _ Storage = (*ResilientStorage)(nil)
+
+// NewResilientStorage creates a ResilientStorage decorating the storage
+func NewResilientStorage(storage Storage, opts ResilienceOptions) *ResilientStorage {
+	return &ResilientStorage{
+		storage: storage,
+		opts:    opts,
+		breaker: &breaker{
+			threshold: opts.BreakerThreshold,
+			cooldown:  opts.BreakerCooldown,
+			state:     BreakerClosed,
+			now:       time.Now,
+		},
+	}
+}
+
+// Unwrap returns the decorated storage
+func (s *ResilientStorage) Unwrap() Storage {
+	return s.storage
+}
+
+// State returns the state of the circuit breaker
+func (s *ResilientStorage) State() BreakerState {
+	return s.breaker.State()
+}
+
+// Upload uploads data to storage and returns the public URL
+func (s *ResilientStorage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...UploadOption) (string, error) {
+	var url string
+	err := s.do(ctx, func(ctx context.Context) (err error) {
+		url, err = s.storage.Upload(ctx, key, data, contentType, opts...)
+		return err
+	})
+	return url, err
+}
+
+// Download retrieves data from storage and returns data with MIME type, or
+// ErrNotFound
+func (s *ResilientStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	var data []byte
+	var mimeType string
+	err := s.do(ctx, func(ctx context.Context) (err error) {
+		data, mimeType, err = s.storage.Download(ctx, key)
+		return err
+	})
+	return data, mimeType, err
+}
+
+// Delete removes an object from storage
+func (s *ResilientStorage) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, func(ctx context.Context) error {
+		return s.storage.Delete(ctx, key)
+	})
+}
+
+// DeleteMany removes objects from storage, retrying the objects that failed
+// to be deleted with transient errors
+func (s *ResilientStorage) DeleteMany(ctx context.Context, keys []string) error {
+	failed := make(map[string]error)
+	err := s.do(ctx, func(ctx context.Context) error {
+		err := s.storage.DeleteMany(ctx, keys)
+		var deleteErr *DeleteError
+		if !errors.As(err, &deleteErr) {
+			return err
+		}
+
+		// Objects failing with errors that aren't transient aren't retried
+		transient := make(map[string]error)
+		keys = nil
+		for key, err := range deleteErr.Errors {
+			if retryable(err) {
+				transient[key] = err
+				keys = append(keys, key)
+			} else {
+				failed[key] = err
+			}
+		}
+		if len(transient) == 0 {
+			return nil
+		}
+		return &DeleteError{Errors: transient}
+	})
+
+	var deleteErr *DeleteError
+	if errors.As(err, &deleteErr) {
+		for key, err := range deleteErr.Errors {
+			failed[key] = err
+		}
+	} else if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &DeleteError{Errors: failed}
+	}
+	return nil
+}
+
+// Exists checks if an object exists in storage
+func (s *ResilientStorage) Exists(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := s.do(ctx, func(ctx context.Context) (err error) {
+		exists, err = s.storage.Exists(ctx, key)
+		return err
+	})
+	return exists, err
+}
+
+// GenerateURL creates a presigned URL for temporary access
+func (s *ResilientStorage) GenerateURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	return s.storage.GenerateURL(ctx, key, expiration)
+}
+
+// BaseURL returns the URL objects are uploaded under
+func (s *ResilientStorage) BaseURL() string {
+	return s.storage.BaseURL()
+}
+
+// Stat returns information about an object, or ErrNotFound
+func (s *ResilientStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := s.do(ctx, func(ctx context.Context) (err error) {
+		info, err = s.storage.Stat(ctx, key)
+		return err
+	})
+	return info, err
+}
+
+// Copy copies an object within storage with its metadata, or returns
+// ErrNotFound
+func (s *ResilientStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return s.do(ctx, func(ctx context.Context) error {
+		return s.storage.Copy(ctx, srcKey, dstKey)
+	})
+}
+
+// Move copies an object within storage with its metadata and deletes the
+// original, or returns ErrNotFound
+func (s *ResilientStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	return s.do(ctx, func(ctx context.Context) error {
+		return s.storage.Move(ctx, srcKey, dstKey)
+	})
+}
+
+// List iterates over the objects whose keys start with the prefix. Listing
+// isn't timed out, since it goes at the pace of the caller. After a transient
+// error, listing starts over and skips the objects iterated already, which
+// come first since objects are ordered by key.
+func (s *ResilientStorage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		var last string
+		var listed, stopped bool
+		err := s.run(ctx, func(ctx context.Context) error {
+			for info, err := range s.storage.List(ctx, prefix) {
+				if err != nil {
+					return err
+				}
+				if listed && info.Key <= last {
+					continue
+				}
+				if !yield(info, nil) {
+					stopped = true
+					return nil
+				}
+				last, listed = info.Key, true
+			}
+			return nil
+		})
+		if err != nil && !stopped {
+			yield(ObjectInfo{}, err)
+		}
+	}
+}
+
+// GenerateUploadURL creates a presigned PUT request uploading an object
+func (s *ResilientStorage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	return s.storage.GenerateUploadURL(ctx, key, conditions, expiration)
+}
+
+// GenerateUploadForm creates a presigned POST request uploading an object
+func (s *ResilientStorage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	return s.storage.GenerateUploadForm(ctx, key, conditions, expiration)
+}
+
+// do runs an operation like run, timing out each attempt
+func (s *ResilientStorage) do(ctx context.Context, op func(ctx context.Context) error) error {
+	return s.run(ctx, func(ctx context.Context) error {
+		if s.opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+			defer cancel()
+		}
+		return op(ctx)
+	})
+}
+
+// run runs an operation if the breaker lets it through, retrying it after
+// transient errors, and records its outcome in the breaker.
+func (s *ResilientStorage) run(ctx context.Context, op func(ctx context.Context) error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = op(ctx); !s.retry(ctx, err, attempt) {
+			break
+		}
+	}
+
+	s.breaker.record(ctx, err)
+	return err
+}
+
+// retry tells whether to retry an operation whose attempt failed with the
+// error, after waiting for the backoff. Operations aren't retried once the
+// breaker opened, or after their context is done.
+func (s *ResilientStorage) retry(ctx context.Context, err error, attempt int) bool {
+	if err == nil || attempt >= s.opts.Retries || ctx.Err() != nil || !retryable(err) ||
+		s.breaker.State() == BreakerOpen {
+		return false
+	}
+
+	timer := time.NewTimer(s.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// backoff returns a random delay up to the retry delay doubled by each
+// attempt, so clients retrying together spread their retries
+func (s *ResilientStorage) backoff(attempt int) time.Duration {
+	delay := min(s.opts.RetryDelay<<attempt, maxRetryDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay) + 1
+}
+
+// retryable tells whether an error is transient. An attempt timing out is,
+// unlike the context of the operation being canceled. A DeleteError is if
+// all its objects failed with transient errors.
+func retryable(err error) bool {
+	var deleteErr *DeleteError
+	switch {
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, ErrCircuitOpen), errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &deleteErr):
+		for _, err := range deleteErr.Errors {
+			if !retryable(err) {
+				return false
+			}
+		}
+		return len(deleteErr.Errors) > 0
+	}
+	return retryables.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// breaker is a circuit breaker opening after consecutive failed operations.
+// Once its cooldown passes, it lets an operation through to probe storage,
+// closing if it succeeds and opening again if it fails.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // Consecutive failed operations
+	openedAt time.Time // When the breaker last opened
+	probing  bool      // Whether the probe of a half-open breaker is running
+}
+
+// State returns the state of the breaker
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if an operation can't run, which is while the
+// breaker is open, and while it is half-open and probing already.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record records the outcome of an operation. Operations failing with
+// transient errors fail, while those storage answered succeed. Operations
+// whose context is done tell nothing about storage.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case err != nil && ctx.Err() != nil:
+	case !retryable(err):
+		b.state = BreakerClosed
+		b.failures = 0
+	default:
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	}
+}
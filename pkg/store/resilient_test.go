@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Errors S3 answers with when throttling and when denying access
+var (
+	errSlowDown     = &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."}
+	errAccessDenied = &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+)
+
+// newTestResilientStorage creates a ResilientStorage of a mock retrying twice
+// without waiting, whose breaker opens after 2 failed operations for a
+// minute, and whose clock is set by the returned function.
+func newTestResilientStorage(t *testing.T) (*ResilientStorage, *MockStorage, func(time.Time)) {
+	storage := NewMockStorage(t)
+	s := NewResilientStorage(storage, ResilienceOptions{
+		Timeout:          time.Second,
+		Retries:          2,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+
+	now := time.Now()
+	s.breaker.now = func() time.Time { return now }
+	return s, storage, func(t time.Time) { now = t }
+}
+
+func TestResilientStorage_Retry(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	ctx := context.Background()
+
+	storage.EXPECT().Upload(mock.Anything, "photo.jpg", []byte("data"), "image/jpeg").
+		Return("", errSlowDown).Twice()
+	storage.EXPECT().Upload(mock.Anything, "photo.jpg", []byte("data"), "image/jpeg").
+		Return("https://bucket/photo.jpg", nil).Once()
+
+	url, err := s.Upload(ctx, "photo.jpg", []byte("data"), "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://bucket/photo.jpg", url)
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+func TestResilientStorage_RetryExhausted(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").Return(errSlowDown).Times(3)
+
+	err := s.Delete(context.Background(), "photo.jpg")
+	assert.ErrorIs(t, err, errSlowDown)
+}
+
+func TestResilientStorage_NotRetryable(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	ctx := context.Background()
+
+	storage.EXPECT().Download(mock.Anything, "missing.jpg").Return(nil, "", ErrNotFound).Once()
+	storage.EXPECT().Copy(mock.Anything, "a.jpg", "b.jpg").Return(errAccessDenied).Once()
+	storage.EXPECT().Exists(mock.Anything, "").Return(false, errors.New("key cannot be empty")).Once()
+
+	_, _, err := s.Download(ctx, "missing.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Copy(ctx, "a.jpg", "b.jpg"), errAccessDenied)
+	_, err = s.Exists(ctx, "")
+	assert.EqualError(t, err, "key cannot be empty")
+
+	// Storage answered, so the breaker stays closed
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+func TestResilientStorage_Timeout(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	s.opts.Timeout = 10 * time.Millisecond
+
+	// The first attempt hangs until it times out
+	storage.EXPECT().Stat(mock.Anything, "photo.jpg").RunAndReturn(
+		func(ctx context.Context, key string) (ObjectInfo, error) {
+			<-ctx.Done()
+			return ObjectInfo{}, ctx.Err()
+		}).Once()
+	storage.EXPECT().Stat(mock.Anything, "photo.jpg").Return(ObjectInfo{Key: "photo.jpg"}, nil).Once()
+
+	info, err := s.Stat(context.Background(), "photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "photo.jpg", info.Key)
+}
+
+func TestResilientStorage_Canceled(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").RunAndReturn(func(context.Context, string) error {
+		cancel()
+		return errSlowDown
+	}).Once()
+
+	err := s.Delete(ctx, "photo.jpg")
+	assert.ErrorIs(t, err, errSlowDown)
+
+	// Giving up tells nothing about storage
+	assert.Equal(t, 0, s.breaker.failures)
+}
+
+func TestResilientStorage_DeleteMany(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+
+	// Only objects failing with transient errors are retried
+	storage.EXPECT().DeleteMany(mock.Anything, []string{"a.jpg", "b.jpg", "c.jpg"}).Return(&DeleteError{
+		Errors: map[string]error{"a.jpg": errSlowDown, "b.jpg": errAccessDenied},
+	}).Once()
+	storage.EXPECT().DeleteMany(mock.Anything, []string{"a.jpg"}).Return(nil).Once()
+
+	err := s.DeleteMany(context.Background(), []string{"a.jpg", "b.jpg", "c.jpg"})
+	var deleteErr *DeleteError
+	require.ErrorAs(t, err, &deleteErr)
+	assert.Equal(t, map[string]error{"b.jpg": errAccessDenied}, deleteErr.Errors)
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+func TestResilientStorage_List(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+
+	// Listing fails after the first object, and starts over after it
+	storage.EXPECT().List(mock.Anything, "photos/").Return(func(yield func(ObjectInfo, error) bool) {
+		if yield(ObjectInfo{Key: "photos/a.jpg"}, nil) {
+			yield(ObjectInfo{}, errSlowDown)
+		}
+	}).Once()
+	storage.EXPECT().List(mock.Anything, "photos/").Return(objects("photos/a.jpg", "photos/b.jpg")).Once()
+
+	var keys []string
+	for info, err := range s.List(context.Background(), "photos/") {
+		require.NoError(t, err)
+		keys = append(keys, info.Key)
+	}
+	assert.Equal(t, []string{"photos/a.jpg", "photos/b.jpg"}, keys)
+}
+
+// objects iterates over objects with the keys
+func objects(keys ...string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		for _, key := range keys {
+			if !yield(ObjectInfo{Key: key}, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestResilientStorage_Breaker(t *testing.T) {
+	s, storage, setNow := newTestResilientStorage(t)
+	ctx := context.Background()
+	start := time.Now()
+
+	// Operations failing after their retries open the breaker
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").Return(errSlowDown).Times(3)
+	require.ErrorIs(t, s.Delete(ctx, "photo.jpg"), errSlowDown)
+	assert.Equal(t, BreakerClosed, s.State())
+
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").Return(errSlowDown).Times(3)
+	require.ErrorIs(t, s.Delete(ctx, "photo.jpg"), errSlowDown)
+	assert.Equal(t, BreakerOpen, s.State())
+
+	// The open breaker fails fast without calling storage
+	assert.ErrorIs(t, s.Delete(ctx, "photo.jpg"), ErrCircuitOpen)
+	for _, err := range s.List(ctx, "photos/") {
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	}
+
+	// Once the cooldown passes, a failed probe opens it again
+	setNow(start.Add(time.Minute))
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").Return(errSlowDown).Times(3)
+	require.ErrorIs(t, s.Delete(ctx, "photo.jpg"), errSlowDown)
+	assert.Equal(t, BreakerOpen, s.State())
+	assert.ErrorIs(t, s.Delete(ctx, "photo.jpg"), ErrCircuitOpen)
+
+	// A successful probe closes it, while other operations fail fast
+	setNow(start.Add(2 * time.Minute))
+	storage.EXPECT().Delete(mock.Anything, "photo.jpg").RunAndReturn(func(context.Context, string) error {
+		assert.Equal(t, BreakerHalfOpen, s.State())
+		assert.ErrorIs(t, s.Delete(ctx, "other.jpg"), ErrCircuitOpen)
+		return nil
+	}).Once()
+	require.NoError(t, s.Delete(ctx, "photo.jpg"))
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+func TestResilientStorage_BreakerResetBySuccess(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	ctx := context.Background()
+
+	// Failures must be consecutive to open the breaker
+	storage.EXPECT().Delete(mock.Anything, "a.jpg").Return(errSlowDown).Times(6)
+	storage.EXPECT().Delete(mock.Anything, "b.jpg").Return(nil).Once()
+
+	require.Error(t, s.Delete(ctx, "a.jpg"))
+	require.NoError(t, s.Delete(ctx, "b.jpg"))
+	require.Error(t, s.Delete(ctx, "a.jpg"))
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+func TestResilientStorage_Presign(t *testing.T) {
+	s, storage, _ := newTestResilientStorage(t)
+	s.breaker.state = BreakerOpen
+
+	// Presigning doesn't call storage, so the breaker doesn't stop it
+	storage.EXPECT().GenerateURL(mock.Anything, "photo.jpg", time.Minute).
+		Return("https://bucket/photo.jpg?signature", nil).Once()
+	storage.EXPECT().BaseURL().Return("https://bucket").Once()
+
+	url, err := s.GenerateURL(context.Background(), "photo.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "https://bucket/photo.jpg?signature", url)
+	assert.Equal(t, "https://bucket", s.BaseURL())
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"throttled", errSlowDown, true},
+		{"internal error", &smithy.GenericAPIError{Code: "InternalError"}, true},
+		{"access denied", errAccessDenied, false},
+		{"not found", ErrNotFound, false},
+		{"circuit open", ErrCircuitOpen, false},
+		{"timeout", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+		{"delete error", &DeleteError{Errors: map[string]error{"a": errSlowDown}}, true},
+		{"delete error not all transient", &DeleteError{Errors: map[string]error{
+			"a": errSlowDown, "b": errAccessDenied}}, false},
+		{"delete object error", &objectError{code: "SlowDown", message: "Please reduce your request rate."}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryable(tt.err))
+		})
+	}
+}
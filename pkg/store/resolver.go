@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Class is a class of objects whose URLs are delivered with the same
+// expiration
+type Class string
+
+const (
+	ClassRaw       Class = "raw"       // Raw photos as uploaded, with their EXIF data
+	ClassOriginal  Class = "original"  // Originals of processed photos
+	ClassThumbnail Class = "thumbnail" // Thumbnails and the other variants of photos
+)
+
+// Signer signs the URLs objects are fetched from through a CDN
+type Signer interface {
+	// SignURL returns the URL of an object on the CDN, valid until the
+	// expiration passes
+	SignURL(key string, expiration time.Duration) (string, error)
+}
+
+// ErrUnknownBackend is returned when resolving the URL of an object in a
+// storage backend the resolver doesn't have
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// URLResolver resolves the keys of objects in storage backends into the URLs
+// clients fetch them from, so rows store keys that stay valid when the base
+// URL of storage changes. Objects of public storage are fetched from their URL
+// in storage. Objects of private storage are fetched from presigned URLs, and
+// objects behind a CDN from URLs signed for the CDN, which expire after the
+// expiration of the class of the object.
+type URLResolver struct {
+	backends    map[string]Storage
+	private     bool
+	signer      Signer
+	expirations map[Class]time.Duration
+}
+
+// NewURLResolver creates a URLResolver of objects in public storage backends,
+// keyed by their IDs.
+func NewURLResolver(backends map[string]Storage) *URLResolver {
+	return &URLResolver{backends: backends}
+}
+
+// NewPresignedURLResolver creates a URLResolver of objects in private storage
+// backends, presigning URLs with the expiration of their class.
+func NewPresignedURLResolver(backends map[string]Storage, expirations map[Class]time.Duration) *URLResolver {
+	return &URLResolver{backends: backends, private: true, expirations: expirations}
+}
+
+// NewCDNURLResolver creates a URLResolver of objects through a CDN, signing
+// URLs with the expiration of their class.
+func NewCDNURLResolver(backends map[string]Storage, signer Signer,
+	expirations map[Class]time.Duration) *URLResolver {
+	return &URLResolver{backends: backends, private: true, signer: signer, expirations: expirations}
+}
+
+// Backend returns the storage backend with the ID.
+func (r *URLResolver) Backend(id string) (Storage, error) {
+	storage, ok := r.backends[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, id)
+	}
+	return storage, nil
+}
+
+// URL returns the URL clients fetch an object of the class from, given the ID
+// of its storage backend and its key. Empty keys resolve to empty URLs.
+func (r *URLResolver) URL(ctx context.Context, class Class, backend, key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	storage, err := r.Backend(backend)
+	if err != nil {
+		return "", err
+	}
+	if !r.private {
+		return storage.BaseURL() + "/" + key, nil
+	}
+
+	expiration, ok := r.expirations[class]
+	if !ok {
+		return "", fmt.Errorf("no URL expiration for %s objects", class)
+	}
+	// The CDN would serve encrypted objects as they're stored, so they're
+	// fetched decrypted from storage
+	encrypted, ok := As[*EncryptedStorage](storage)
+	if r.signer != nil && !(ok && encrypted.Encrypts(key)) {
+		return r.signer.SignURL(key, expiration)
+	}
+	return storage.GenerateURL(ctx, key, expiration)
+}
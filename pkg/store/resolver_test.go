@@ -0,0 +1,69 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"jelly/pkg/kms"
+)
+
+// stubSigner signs URLs of a CDN with their expiration
+type stubSigner struct{}
+
+func (stubSigner) SignURL(key string, expiration time.Duration) (string, error) {
+	return "https://cdn.example.com/" + key + "?expires=" + expiration.String(), nil
+}
+
+func TestURLResolver_URL(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir(), "http://localhost:8080/files")
+	ctx := context.Background()
+
+	// Objects of public storage are fetched from their URL in storage
+	resolver := NewURLResolver(map[string]Storage{"default": storage})
+	url, err := resolver.URL(ctx, ClassRaw, "default", "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/raw/u/photo.jpg", url)
+
+	// Empty keys have no URL, and unknown backends none either
+	url, err = resolver.URL(ctx, ClassThumbnail, "default", "")
+	require.NoError(t, err)
+	assert.Empty(t, url)
+	_, err = resolver.URL(ctx, ClassRaw, "old", "raw/u/photo.jpg")
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+
+	// Objects of private storage are fetched from presigned URLs
+	storage.SetPrivate(true)
+	resolver = NewPresignedURLResolver(map[string]Storage{"default": storage},
+		map[Class]time.Duration{ClassRaw: time.Minute})
+	url, err = resolver.URL(ctx, ClassRaw, "default", "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(url, "http://localhost:8080/files/raw/u/photo.jpg?"))
+	assert.Contains(t, url, "signature=")
+
+	// Classes without an expiration aren't resolved
+	_, err = resolver.URL(ctx, ClassThumbnail, "default", "photos/p/thumb.jpg")
+	assert.Error(t, err)
+
+	// Objects behind a CDN are fetched from URLs signed for it
+	resolver = NewCDNURLResolver(map[string]Storage{"default": storage}, stubSigner{},
+		map[Class]time.Duration{ClassThumbnail: time.Hour})
+	url, err = resolver.URL(ctx, ClassThumbnail, "default", "photos/p/thumb.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/photos/p/thumb.jpg?expires=1h0m0s", url)
+
+	// Except encrypted objects, which are fetched decrypted from storage
+	keys, err := kms.NewStatic(bytes.Repeat([]byte{1}, kms.KeySize))
+	require.NoError(t, err)
+	encrypted := NewEncryptedStorage(storage, keys, []string{"raw/"}, "http://localhost:8080/encrypted", nil)
+	resolver = NewCDNURLResolver(map[string]Storage{"default": encrypted}, stubSigner{},
+		map[Class]time.Duration{ClassRaw: time.Minute})
+	url, err = resolver.URL(ctx, ClassRaw, "default", "raw/u/photo.jpg")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(url, "http://localhost:8080/encrypted/raw/u/photo.jpg?"))
+}
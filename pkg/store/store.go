@@ -5,33 +5,203 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 // Storage defines the interface for object storage operations
 type Storage interface {
 	// Upload uploads data to storage and returns the public URL
-	Upload(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	Upload(ctx context.Context, key string, data []byte, contentType string, opts ...UploadOption) (string, error)
 
-	// Download retrieves data from storage and returns data with MIME type
+	// Download retrieves data from storage and returns data with MIME type, or
+	// ErrNotFound
 	Download(ctx context.Context, key string) ([]byte, string, error)
 
 	// Delete removes an object from storage
 	Delete(ctx context.Context, key string) error
 
+	// DeleteMany removes objects from storage, returning a *DeleteError with
+	// the keys that failed to be deleted
+	DeleteMany(ctx context.Context, keys []string) error
+
 	// Exists checks if an object exists in storage
 	Exists(ctx context.Context, key string) (bool, error)
 
 	// GenerateURL creates a presigned URL for temporary access
 	GenerateURL(ctx context.Context, key string, expiration time.Duration) (string, error)
+
+	// BaseURL returns the URL objects are uploaded under, the URL of an object
+	// being its key appended to it
+	BaseURL() string
+
+	// Stat returns information about an object, or ErrNotFound
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Copy copies an object within storage with its metadata, or returns
+	// ErrNotFound
+	Copy(ctx context.Context, srcKey, dstKey string) error
+
+	// Move copies an object within storage with its metadata and deletes the
+	// original, or returns ErrNotFound
+	Move(ctx context.Context, srcKey, dstKey string) error
+
+	// List iterates over the objects whose keys start with the prefix, ordered
+	// by key, fetching them a page at a time. Iteration stops after an error.
+	List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error]
+
+	// GenerateUploadURL creates a presigned PUT request that uploads an object
+	// matching the conditions directly to storage
+	GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+		expiration time.Duration) (PresignedUpload, error)
+
+	// GenerateUploadForm creates a presigned POST request that uploads an
+	// object matching the conditions directly to storage from a multipart form
+	GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+		expiration time.Duration) (PresignedUpload, error)
+}
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// As finds the first storage of type T among the storage and the storage it
+// decorates, which decorators return from an Unwrap method, like errors.As.
+func As[T Storage](storage Storage) (T, bool) {
+	for storage != nil {
+		if t, ok := storage.(T); ok {
+			return t, true
+		}
+		decorator, ok := storage.(interface{ Unwrap() Storage })
+		if !ok {
+			break
+		}
+		storage = decorator.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// DeleteError reports the objects DeleteMany failed to delete
+type DeleteError struct {
+	Errors map[string]error // Errors by key
+}
+
+func (e *DeleteError) Error() string {
+	keys := slices.Sorted(maps.Keys(e.Errors))
+	if len(keys) == 1 {
+		return fmt.Sprintf("failed to delete %s: %v", keys[0], e.Errors[keys[0]])
+	}
+	return fmt.Sprintf("failed to delete %d objects, first %s: %v", len(keys), keys[0], e.Errors[keys[0]])
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	URL          string // Public URL of the object
+	Size         int64
+	ContentType  string
+	CacheControl string
+	Metadata     map[string]string // User metadata, with lower case keys
+	ETag         string
+	SHA256       string // Hex encoded checksum, empty if the backend has none
+	LastModified time.Time
+}
+
+// UploadOptions are the optional attributes of an uploaded object
+type UploadOptions struct {
+	CacheControl string            // Cache-Control header the object is served with
+	Metadata     map[string]string // User metadata, with lower case keys
+}
+
+// UploadOption sets an optional attribute of an uploaded object
+type UploadOption func(*UploadOptions)
+
+// WithCacheControl serves the object with the Cache-Control header.
+func WithCacheControl(value string) UploadOption {
+	return func(o *UploadOptions) {
+		o.CacheControl = value
+	}
+}
+
+// WithMetadata stores user metadata, such as the ID of the photo, with the
+// object. Keys are lower cased, like S3 does.
+func WithMetadata(metadata map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			o.Metadata[strings.ToLower(k)] = v
+		}
+	}
+}
+
+// NewUploadOptions applies the options of an upload.
+func NewUploadOptions(opts ...UploadOption) UploadOptions {
+	var o UploadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UploadConditions restricts the object a presigned upload may write
+type UploadConditions struct {
+	ContentType   string
+	ContentLength int64  // Exact size of the object in bytes
+	SHA256        string // Hex encoded checksum of the object
+}
+
+// PresignedUpload is a request that uploads an object directly to storage
+type PresignedUpload struct {
+	Method    string
+	URL       string
+	Headers   map[string]string // Headers that must be sent with a PUT request
+	Fields    map[string]string // Form fields that must precede the file in a POST request
+	ExpiresAt time.Time
+}
+
+// validate checks the key, conditions and expiration of a presigned upload
+func (c UploadConditions) validate(key string, expiration time.Duration) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+
+	if expiration <= 0 {
+		return fmt.Errorf("expiration must be positive")
+	}
+
+	if c.ContentType == "" {
+		return fmt.Errorf("content type cannot be empty")
+	}
+
+	if c.ContentLength <= 0 {
+		return fmt.Errorf("content length must be positive")
+	}
+
+	if sum, err := hex.DecodeString(c.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 checksum: %s", c.SHA256)
+	}
+
+	return nil
 }
 
 // S3Storage implements Storage interface using Amazon S3
@@ -40,6 +210,40 @@ type S3Storage struct {
 	bucket  string
 	region  string
 	baseURL string // Base URL for public access (e.g., CloudFront domain)
+	private bool   // Objects are uploaded without ACLs, readable with presigned URLs only
+}
+
+// NewDefaultCredentials returns the credentials of the default AWS credential
+// chain: environment variables, shared config and credentials files, web
+// identity tokens, then container and instance roles.
+func NewDefaultCredentials(ctx context.Context) (aws.CredentialsProvider, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	return cfg.Credentials, nil
+}
+
+// NewS3Client creates an S3 client for the given region using credentials
+// from the default AWS credential chain. An optional endpoint can be given to
+// target S3 compatible services (e.g., MinIO).
+func NewS3Client(ctx context.Context, region, endpoint string) (*s3.Client, error) {
+	// Requests aren't retried by the client, since ResilientStorage retries
+	// whole operations
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(region),
+		awsconfig.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	return s3.NewFromConfig(cfg, func(options *s3.Options) {
+		if endpoint != "" {
+			options.BaseEndpoint = aws.String(endpoint)
+			options.UsePathStyle = true
+		}
+	}), nil
 }
 
 // NewS3Storage creates a new S3Storage instance
@@ -63,8 +267,31 @@ func NewS3StorageWithCustomURL(client *s3.Client, bucket, region, baseURL string
 	}
 }
 
-// Upload uploads data to S3 and returns the public URL
-func (s *S3Storage) Upload(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+// SetPrivate sets whether objects are uploaded without the public-read ACL, so
+// they're only readable with presigned URLs. Buckets blocking public ACLs
+// must be private.
+func (s *S3Storage) SetPrivate(private bool) {
+	s.private = private
+}
+
+// acl returns the canned ACL objects are uploaded with, none if the storage
+// is private.
+func (s *S3Storage) acl() types.ObjectCannedACL {
+	if s.private {
+		return ""
+	}
+	return types.ObjectCannedACLPublicRead
+}
+
+// BaseURL returns the URL objects are uploaded under
+func (s *S3Storage) BaseURL() string {
+	return s.baseURL
+}
+
+// Upload uploads data to S3 and returns the URL of the object, which is only
+// readable if the storage isn't private
+func (s *S3Storage) Upload(ctx context.Context, key string, data []byte, contentType string,
+	opts ...UploadOption) (string, error) {
 	if len(data) == 0 {
 		return "", fmt.Errorf("data cannot be empty")
 	}
@@ -83,7 +310,13 @@ func (s *S3Storage) Upload(ctx context.Context, key string, data []byte, content
 		Key:         aws.String(key),
 		Body:        bytes.NewReader(data),
 		ContentType: aws.String(contentType),
-		ACL:         types.ObjectCannedACLPublicRead, // Make publicly readable
+		ACL:         s.acl(),
+	}
+	if o := NewUploadOptions(opts...); o.CacheControl != "" || len(o.Metadata) > 0 {
+		input.Metadata = o.Metadata
+		if o.CacheControl != "" {
+			input.CacheControl = aws.String(o.CacheControl)
+		}
 	}
 
 	_, err := s.client.PutObject(ctx, input)
@@ -91,7 +324,7 @@ func (s *S3Storage) Upload(ctx context.Context, key string, data []byte, content
 		return "", fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
-	// Return public URL
+	// Return the URL of the object
 	url := fmt.Sprintf("%s/%s", s.baseURL, key)
 	return url, nil
 }
@@ -109,6 +342,10 @@ func (s *S3Storage) Download(ctx context.Context, key string) (data []byte, mime
 
 	result, err := s.client.GetObject(ctx, input)
 	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", ErrNotFound
+		}
 		return nil, "", fmt.Errorf("failed to download from S3: %w", err)
 	}
 	defer result.Body.Close()
@@ -140,6 +377,106 @@ func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Copy copies an object within the bucket with CopyObject, without
+// downloading it. Objects larger than 5 GB can't be copied in one request.
+func (s *S3Storage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if srcKey == "" || dstKey == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+
+	// The content type, Cache-Control and metadata are copied with the object
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(s.bucket + "/" + escapeKey(srcKey)),
+		ACL:        s.acl(),
+	}
+
+	_, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		// CopyObject errors aren't modeled, so a missing source is only told
+		// apart by its code
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	return nil
+}
+
+// escapeKey URL encodes the segments of a key.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Move copies an object within the bucket and deletes the original. S3 has no
+// rename, so the object briefly exists under both keys.
+func (s *S3Storage) Move(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.Copy(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+	return s.Delete(ctx, srcKey)
+}
+
+// maxDeleteObjects is how many objects a DeleteObjects request can delete
+const maxDeleteObjects = 1000
+
+// DeleteMany removes objects from S3 with as few DeleteObjects requests as
+// possible. Keys of a failed request are reported with its error.
+func (s *S3Storage) DeleteMany(ctx context.Context, keys []string) error {
+	errs := make(map[string]error)
+	for batch := range slices.Chunk(keys, maxDeleteObjects) {
+		objects := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+
+		input := &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		}
+
+		result, err := s.client.DeleteObjects(ctx, input)
+		if err != nil {
+			for _, key := range batch {
+				errs[key] = fmt.Errorf("failed to delete from S3: %w", err)
+			}
+			continue
+		}
+		for _, e := range result.Errors {
+			errs[aws.ToString(e.Key)] = fmt.Errorf("failed to delete from S3: %w",
+				&objectError{code: aws.ToString(e.Code), message: aws.ToString(e.Message)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &DeleteError{Errors: errs}
+	}
+	return nil
+}
+
+// objectError is the error S3 reports for an object of a batch request. Its
+// code tells whether deleting the object can be retried.
+type objectError struct {
+	code    string
+	message string
+}
+
+func (e *objectError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// ErrorCode returns the S3 error code
+func (e *objectError) ErrorCode() string {
+	return e.code
+}
+
 // Exists checks if an object exists in S3
 func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
 	if key == "" {
@@ -192,3 +529,176 @@ func (s *S3Storage) GenerateURL(ctx context.Context, key string, expiration time
 
 	return result.URL, nil
 }
+
+// Stat returns the metadata of an object in S3 without downloading it
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if key == "" {
+		return ObjectInfo{}, fmt.Errorf("key cannot be empty")
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+
+	result, err := s.client.HeadObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		var notFound *types.NotFound
+		if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+			return ObjectInfo{}, ErrNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to get object metadata: %w", err)
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		URL:          fmt.Sprintf("%s/%s", s.baseURL, key),
+		Size:         aws.ToInt64(result.ContentLength),
+		ContentType:  aws.ToString(result.ContentType),
+		CacheControl: aws.ToString(result.CacheControl),
+		Metadata:     result.Metadata,
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		LastModified: aws.ToTime(result.LastModified),
+	}
+
+	// Multipart uploads have a checksum of checksums, which isn't comparable
+	if result.ChecksumSHA256 != nil && !strings.Contains(*result.ChecksumSHA256, "-") {
+		sum, err := base64.StdEncoding.DecodeString(*result.ChecksumSHA256)
+		if err == nil {
+			info.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+
+	return info, nil
+}
+
+// List iterates over the objects in S3 whose keys start with the prefix,
+// ordered by key, fetching a page of up to 1000 at a time. Listings don't
+// include checksums or content types.
+func (s *S3Storage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(s.bucket),
+			Prefix: aws.String(prefix),
+		}
+
+		paginator := s3.NewListObjectsV2Paginator(s.client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(ObjectInfo{}, fmt.Errorf("failed to list objects: %w", err))
+				return
+			}
+			for _, object := range page.Contents {
+				key := aws.ToString(object.Key)
+				info := ObjectInfo{
+					Key:          key,
+					URL:          fmt.Sprintf("%s/%s", s.baseURL, key),
+					Size:         aws.ToInt64(object.Size),
+					ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+					LastModified: aws.ToTime(object.LastModified),
+				}
+				if !yield(info, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// GenerateUploadURL creates a presigned PUT URL. The content type, length and
+// checksum are signed, so S3 rejects uploads that don't match them.
+func (s *S3Storage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := conditions.validate(key, expiration); err != nil {
+		return PresignedUpload{}, err
+	}
+
+	checksum, _ := hex.DecodeString(conditions.SHA256)
+	input := &s3.PutObjectInput{
+		Bucket:         aws.String(s.bucket),
+		Key:            aws.String(key),
+		ContentType:    aws.String(conditions.ContentType),
+		ContentLength:  aws.Int64(conditions.ContentLength),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(checksum)),
+		ACL:            s.acl(),
+	}
+
+	presignClient := s3.NewPresignClient(s.client)
+	expiresAt := time.Now().Add(expiration)
+	result, err := presignClient.PresignPutObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = expiration
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+
+	// The client must send the signed headers as they were signed. Host and
+	// Content-Length are set by the client's HTTP library.
+	headers := map[string]string{}
+	for name := range result.SignedHeader {
+		if name == "Host" || name == "Content-Length" {
+			continue
+		}
+		headers[name] = result.SignedHeader.Get(name)
+	}
+
+	return PresignedUpload{
+		Method:    result.Method,
+		URL:       result.URL,
+		Headers:   headers,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GenerateUploadForm creates a presigned POST policy. The policy conditions
+// restrict the content type, length and checksum of the uploaded object.
+func (s *S3Storage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions,
+	expiration time.Duration) (PresignedUpload, error) {
+	if err := conditions.validate(key, expiration); err != nil {
+		return PresignedUpload{}, err
+	}
+
+	sum, _ := hex.DecodeString(conditions.SHA256)
+	checksum := base64.StdEncoding.EncodeToString(sum)
+	acl := string(s.acl())
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	presignClient := s3.NewPresignClient(s.client)
+	expiresAt := time.Now().Add(expiration)
+	result, err := presignClient.PresignPostObject(ctx, input, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expiration
+		opts.Conditions = []interface{}{
+			map[string]string{"Content-Type": conditions.ContentType},
+			map[string]string{"x-amz-checksum-sha256": checksum},
+			[]interface{}{"content-length-range", conditions.ContentLength, conditions.ContentLength},
+		}
+		if acl != "" {
+			opts.Conditions = append(opts.Conditions, map[string]string{"acl": acl})
+		}
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to generate presigned upload form: %w", err)
+	}
+
+	// Fields covered by the policy conditions must be sent as well
+	fields := result.Values
+	if acl != "" {
+		fields["acl"] = acl
+	}
+	fields["Content-Type"] = conditions.ContentType
+	fields["x-amz-checksum-sha256"] = checksum
+
+	return PresignedUpload{
+		Method:    http.MethodPost,
+		URL:       result.URL,
+		Fields:    fields,
+		ExpiresAt: expiresAt,
+	}, nil
+}
@@ -6,6 +6,7 @@ package store
 
 import (
 	"context"
+	"iter"
 	"time"
 
 	mock "github.com/stretchr/testify/mock"
@@ -38,6 +39,113 @@ func (_m *MockStorage) EXPECT() *MockStorage_Expecter {
 	return &MockStorage_Expecter{mock: &_m.Mock}
 }
 
+// BaseURL provides a mock function for the type MockStorage
+func (_mock *MockStorage) BaseURL() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for BaseURL")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockStorage_BaseURL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BaseURL'
+type MockStorage_BaseURL_Call struct {
+	*mock.Call
+}
+
+// BaseURL is a helper method to define mock.On call
+func (_e *MockStorage_Expecter) BaseURL() *MockStorage_BaseURL_Call {
+	return &MockStorage_BaseURL_Call{Call: _e.mock.On("BaseURL")}
+}
+
+func (_c *MockStorage_BaseURL_Call) Run(run func()) *MockStorage_BaseURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockStorage_BaseURL_Call) Return(s string) *MockStorage_BaseURL_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockStorage_BaseURL_Call) RunAndReturn(run func() string) *MockStorage_BaseURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Copy provides a mock function for the type MockStorage
+func (_mock *MockStorage) Copy(ctx context.Context, srcKey string, dstKey string) error {
+	ret := _mock.Called(ctx, srcKey, dstKey)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Copy")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, srcKey, dstKey)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_Copy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Copy'
+type MockStorage_Copy_Call struct {
+	*mock.Call
+}
+
+// Copy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - srcKey string
+//   - dstKey string
+func (_e *MockStorage_Expecter) Copy(ctx interface{}, srcKey interface{}, dstKey interface{}) *MockStorage_Copy_Call {
+	return &MockStorage_Copy_Call{Call: _e.mock.On("Copy", ctx, srcKey, dstKey)}
+}
+
+func (_c *MockStorage_Copy_Call) Run(run func(ctx context.Context, srcKey string, dstKey string)) *MockStorage_Copy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_Copy_Call) Return(err error) *MockStorage_Copy_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStorage_Copy_Call) RunAndReturn(run func(ctx context.Context, srcKey string, dstKey string) error) *MockStorage_Copy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Delete provides a mock function for the type MockStorage
 func (_mock *MockStorage) Delete(ctx context.Context, key string) error {
 	ret := _mock.Called(ctx, key)
@@ -95,6 +203,63 @@ func (_c *MockStorage_Delete_Call) RunAndReturn(run func(ctx context.Context, ke
 	return _c
 }
 
+// DeleteMany provides a mock function for the type MockStorage
+func (_mock *MockStorage) DeleteMany(ctx context.Context, keys []string) error {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMany")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) error); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_DeleteMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteMany'
+type MockStorage_DeleteMany_Call struct {
+	*mock.Call
+}
+
+// DeleteMany is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keys []string
+func (_e *MockStorage_Expecter) DeleteMany(ctx interface{}, keys interface{}) *MockStorage_DeleteMany_Call {
+	return &MockStorage_DeleteMany_Call{Call: _e.mock.On("DeleteMany", ctx, keys)}
+}
+
+func (_c *MockStorage_DeleteMany_Call) Run(run func(ctx context.Context, keys []string)) *MockStorage_DeleteMany_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_DeleteMany_Call) Return(err error) *MockStorage_DeleteMany_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStorage_DeleteMany_Call) RunAndReturn(run func(ctx context.Context, keys []string) error) *MockStorage_DeleteMany_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Download provides a mock function for the type MockStorage
 func (_mock *MockStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
 	ret := _mock.Called(ctx, key)
@@ -307,9 +472,359 @@ func (_c *MockStorage_GenerateURL_Call) RunAndReturn(run func(ctx context.Contex
 	return _c
 }
 
+// GenerateUploadForm provides a mock function for the type MockStorage
+func (_mock *MockStorage) GenerateUploadForm(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration) (PresignedUpload, error) {
+	ret := _mock.Called(ctx, key, conditions, expiration)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GenerateUploadForm")
+	}
+
+	var r0 PresignedUpload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, UploadConditions, time.Duration) (PresignedUpload, error)); ok {
+		return returnFunc(ctx, key, conditions, expiration)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, UploadConditions, time.Duration) PresignedUpload); ok {
+		r0 = returnFunc(ctx, key, conditions, expiration)
+	} else {
+		r0 = ret.Get(0).(PresignedUpload)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, UploadConditions, time.Duration) error); ok {
+		r1 = returnFunc(ctx, key, conditions, expiration)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_GenerateUploadForm_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GenerateUploadForm'
+type MockStorage_GenerateUploadForm_Call struct {
+	*mock.Call
+}
+
+// GenerateUploadForm is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - conditions UploadConditions
+//   - expiration time.Duration
+func (_e *MockStorage_Expecter) GenerateUploadForm(ctx interface{}, key interface{}, conditions interface{}, expiration interface{}) *MockStorage_GenerateUploadForm_Call {
+	return &MockStorage_GenerateUploadForm_Call{Call: _e.mock.On("GenerateUploadForm", ctx, key, conditions, expiration)}
+}
+
+func (_c *MockStorage_GenerateUploadForm_Call) Run(run func(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration)) *MockStorage_GenerateUploadForm_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 UploadConditions
+		if args[2] != nil {
+			arg2 = args[2].(UploadConditions)
+		}
+		var arg3 time.Duration
+		if args[3] != nil {
+			arg3 = args[3].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_GenerateUploadForm_Call) Return(presignedUpload PresignedUpload, err error) *MockStorage_GenerateUploadForm_Call {
+	_c.Call.Return(presignedUpload, err)
+	return _c
+}
+
+func (_c *MockStorage_GenerateUploadForm_Call) RunAndReturn(run func(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration) (PresignedUpload, error)) *MockStorage_GenerateUploadForm_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GenerateUploadURL provides a mock function for the type MockStorage
+func (_mock *MockStorage) GenerateUploadURL(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration) (PresignedUpload, error) {
+	ret := _mock.Called(ctx, key, conditions, expiration)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GenerateUploadURL")
+	}
+
+	var r0 PresignedUpload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, UploadConditions, time.Duration) (PresignedUpload, error)); ok {
+		return returnFunc(ctx, key, conditions, expiration)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, UploadConditions, time.Duration) PresignedUpload); ok {
+		r0 = returnFunc(ctx, key, conditions, expiration)
+	} else {
+		r0 = ret.Get(0).(PresignedUpload)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, UploadConditions, time.Duration) error); ok {
+		r1 = returnFunc(ctx, key, conditions, expiration)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_GenerateUploadURL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GenerateUploadURL'
+type MockStorage_GenerateUploadURL_Call struct {
+	*mock.Call
+}
+
+// GenerateUploadURL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - conditions UploadConditions
+//   - expiration time.Duration
+func (_e *MockStorage_Expecter) GenerateUploadURL(ctx interface{}, key interface{}, conditions interface{}, expiration interface{}) *MockStorage_GenerateUploadURL_Call {
+	return &MockStorage_GenerateUploadURL_Call{Call: _e.mock.On("GenerateUploadURL", ctx, key, conditions, expiration)}
+}
+
+func (_c *MockStorage_GenerateUploadURL_Call) Run(run func(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration)) *MockStorage_GenerateUploadURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 UploadConditions
+		if args[2] != nil {
+			arg2 = args[2].(UploadConditions)
+		}
+		var arg3 time.Duration
+		if args[3] != nil {
+			arg3 = args[3].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_GenerateUploadURL_Call) Return(presignedUpload PresignedUpload, err error) *MockStorage_GenerateUploadURL_Call {
+	_c.Call.Return(presignedUpload, err)
+	return _c
+}
+
+func (_c *MockStorage_GenerateUploadURL_Call) RunAndReturn(run func(ctx context.Context, key string, conditions UploadConditions, expiration time.Duration) (PresignedUpload, error)) *MockStorage_GenerateUploadURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// List provides a mock function for the type MockStorage
+func (_mock *MockStorage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	ret := _mock.Called(ctx, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 iter.Seq2[ObjectInfo, error]
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) iter.Seq2[ObjectInfo, error]); ok {
+		r0 = returnFunc(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(iter.Seq2[ObjectInfo, error])
+		}
+	}
+	return r0
+}
+
+// MockStorage_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockStorage_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - prefix string
+func (_e *MockStorage_Expecter) List(ctx interface{}, prefix interface{}) *MockStorage_List_Call {
+	return &MockStorage_List_Call{Call: _e.mock.On("List", ctx, prefix)}
+}
+
+func (_c *MockStorage_List_Call) Run(run func(ctx context.Context, prefix string)) *MockStorage_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_List_Call) Return(seq2 iter.Seq2[ObjectInfo, error]) *MockStorage_List_Call {
+	_c.Call.Return(seq2)
+	return _c
+}
+
+func (_c *MockStorage_List_Call) RunAndReturn(run func(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error]) *MockStorage_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Move provides a mock function for the type MockStorage
+func (_mock *MockStorage) Move(ctx context.Context, srcKey string, dstKey string) error {
+	ret := _mock.Called(ctx, srcKey, dstKey)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Move")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, srcKey, dstKey)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_Move_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Move'
+type MockStorage_Move_Call struct {
+	*mock.Call
+}
+
+// Move is a helper method to define mock.On call
+//   - ctx context.Context
+//   - srcKey string
+//   - dstKey string
+func (_e *MockStorage_Expecter) Move(ctx interface{}, srcKey interface{}, dstKey interface{}) *MockStorage_Move_Call {
+	return &MockStorage_Move_Call{Call: _e.mock.On("Move", ctx, srcKey, dstKey)}
+}
+
+func (_c *MockStorage_Move_Call) Run(run func(ctx context.Context, srcKey string, dstKey string)) *MockStorage_Move_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_Move_Call) Return(err error) *MockStorage_Move_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStorage_Move_Call) RunAndReturn(run func(ctx context.Context, srcKey string, dstKey string) error) *MockStorage_Move_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stat provides a mock function for the type MockStorage
+func (_mock *MockStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stat")
+	}
+
+	var r0 ObjectInfo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (ObjectInfo, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) ObjectInfo); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		r0 = ret.Get(0).(ObjectInfo)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_Stat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stat'
+type MockStorage_Stat_Call struct {
+	*mock.Call
+}
+
+// Stat is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+func (_e *MockStorage_Expecter) Stat(ctx interface{}, key interface{}) *MockStorage_Stat_Call {
+	return &MockStorage_Stat_Call{Call: _e.mock.On("Stat", ctx, key)}
+}
+
+func (_c *MockStorage_Stat_Call) Run(run func(ctx context.Context, key string)) *MockStorage_Stat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_Stat_Call) Return(objectInfo ObjectInfo, err error) *MockStorage_Stat_Call {
+	_c.Call.Return(objectInfo, err)
+	return _c
+}
+
+func (_c *MockStorage_Stat_Call) RunAndReturn(run func(ctx context.Context, key string) (ObjectInfo, error)) *MockStorage_Stat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Upload provides a mock function for the type MockStorage
-func (_mock *MockStorage) Upload(ctx context.Context, key string, data []byte, contentType string) (string, error) {
-	ret := _mock.Called(ctx, key, data, contentType)
+func (_mock *MockStorage) Upload(ctx context.Context, key string, data []byte, contentType string, opts ...UploadOption) (string, error) {
+	var tmpRet mock.Arguments
+	if len(opts) > 0 {
+		tmpRet = _mock.Called(ctx, key, data, contentType, opts)
+	} else {
+		tmpRet = _mock.Called(ctx, key, data, contentType)
+	}
+	ret := tmpRet
 
 	if len(ret) == 0 {
 		panic("no return value specified for Upload")
@@ -317,16 +832,16 @@ func (_mock *MockStorage) Upload(ctx context.Context, key string, data []byte, c
 
 	var r0 string
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, string) (string, error)); ok {
-		return returnFunc(ctx, key, data, contentType)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, string, ...UploadOption) (string, error)); ok {
+		return returnFunc(ctx, key, data, contentType, opts...)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, string) string); ok {
-		r0 = returnFunc(ctx, key, data, contentType)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, string, ...UploadOption) string); ok {
+		r0 = returnFunc(ctx, key, data, contentType, opts...)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, string) error); ok {
-		r1 = returnFunc(ctx, key, data, contentType)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, string, ...UploadOption) error); ok {
+		r1 = returnFunc(ctx, key, data, contentType, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -343,11 +858,13 @@ type MockStorage_Upload_Call struct {
 //   - key string
 //   - data []byte
 //   - contentType string
-func (_e *MockStorage_Expecter) Upload(ctx interface{}, key interface{}, data interface{}, contentType interface{}) *MockStorage_Upload_Call {
-	return &MockStorage_Upload_Call{Call: _e.mock.On("Upload", ctx, key, data, contentType)}
+//   - opts ...UploadOption
+func (_e *MockStorage_Expecter) Upload(ctx interface{}, key interface{}, data interface{}, contentType interface{}, opts ...any) *MockStorage_Upload_Call {
+	return &MockStorage_Upload_Call{Call: _e.mock.On("Upload",
+		append([]any{ctx, key, data, contentType}, opts...)...)}
 }
 
-func (_c *MockStorage_Upload_Call) Run(run func(ctx context.Context, key string, data []byte, contentType string)) *MockStorage_Upload_Call {
+func (_c *MockStorage_Upload_Call) Run(run func(ctx context.Context, key string, data []byte, contentType string, opts ...UploadOption)) *MockStorage_Upload_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -365,11 +882,18 @@ func (_c *MockStorage_Upload_Call) Run(run func(ctx context.Context, key string,
 		if args[3] != nil {
 			arg3 = args[3].(string)
 		}
+		var arg4 []UploadOption
+		var variadicArgs []UploadOption
+		if len(args) > 4 {
+			variadicArgs = args[4].([]UploadOption)
+		}
+		arg4 = variadicArgs
 		run(
 			arg0,
 			arg1,
 			arg2,
 			arg3,
+			arg4...,
 		)
 	})
 	return _c
@@ -380,7 +904,7 @@ func (_c *MockStorage_Upload_Call) Return(s string, err error) *MockStorage_Uplo
 	return _c
 }
 
-func (_c *MockStorage_Upload_Call) RunAndReturn(run func(ctx context.Context, key string, data []byte, contentType string) (string, error)) *MockStorage_Upload_Call {
+func (_c *MockStorage_Upload_Call) RunAndReturn(run func(ctx context.Context, key string, data []byte, contentType string, opts ...UploadOption) (string, error)) *MockStorage_Upload_Call {
 	_c.Call.Return(run)
 	return _c
 }